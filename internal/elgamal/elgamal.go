@@ -1,7 +1,6 @@
 package elgamal
 
 import (
-	"crypto/rand"
 	"io"
 
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
@@ -20,10 +19,11 @@ type Ciphertext struct {
 	M curve.Point
 }
 
-// Encrypt returns the encryption of `message` as (L=nonce⋅G, M=message⋅G + nonce⋅public), as well as the `nonce`.
-func Encrypt(public PublicKey, message curve.Scalar) (*Ciphertext, Nonce) {
+// Encrypt returns the encryption of `message` as (L=nonce⋅G, M=message⋅G + nonce⋅public), as well as the `nonce`,
+// which is sampled from source.
+func Encrypt(source io.Reader, public PublicKey, message curve.Scalar) (*Ciphertext, Nonce) {
 	group := public.Curve()
-	nonce := sample.Scalar(rand.Reader, group)
+	nonce := sample.Scalar(source, group)
 	L := nonce.ActOnBase()
 	M := message.ActOnBase().Add(nonce.Act(public))
 	return &Ciphertext{
@@ -0,0 +1,111 @@
+// Package merkle commits to a list of leaves with a single root, such that each leaf can be opened on its own.
+package merkle
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/pkg/hash"
+)
+
+// Tree is a Merkle tree over a non-empty list of leaves.
+//
+// An odd node out at the end of a level is moved up as is,
+// and the root also commits to the number of leaves.
+type Tree struct {
+	domain string
+	// levels[0] holds the leaves, and each following level the parents of the previous one,
+	// up to the single top node.
+	levels [][][]byte
+}
+
+// New builds the tree over leaves.
+//
+// domain separates the nodes of this tree from those of trees built for another purpose.
+func New(domain string, leaves [][]byte) (*Tree, error) {
+	if len(leaves) == 0 {
+		return nil, errors.New("merkle: no leaves")
+	}
+	levels := [][][]byte{leaves}
+	for level := leaves; len(level) > 1; {
+		parents := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				parents = append(parents, level[i])
+				continue
+			}
+			parents = append(parents, node(domain, level[i], level[i+1]))
+		}
+		levels = append(levels, parents)
+		level = parents
+	}
+	return &Tree{domain: domain, levels: levels}, nil
+}
+
+// Len returns the number of leaves.
+func (t *Tree) Len() int {
+	return len(t.levels[0])
+}
+
+// Root returns the root of the tree.
+func (t *Tree) Root() []byte {
+	return root(t.domain, t.Len(), t.levels[len(t.levels)-1][0])
+}
+
+// Siblings returns the nodes needed to recompute the root from the leaf at index, starting from the bottom.
+func (t *Tree) Siblings(index int) ([][]byte, error) {
+	if index < 0 || index >= t.Len() {
+		return nil, fmt.Errorf("merkle: index %d out of range for %d leaves", index, t.Len())
+	}
+	var siblings [][]byte
+	for _, level := range t.levels[:len(t.levels)-1] {
+		if sibling := index ^ 1; sibling < len(level) {
+			siblings = append(siblings, level[sibling])
+		}
+		index /= 2
+	}
+	return siblings, nil
+}
+
+// Root recomputes the root of a tree of size leaves from the leaf at index, and its siblings returned by Tree.Siblings.
+func Root(domain string, leaf []byte, index, size int, siblings [][]byte) ([]byte, error) {
+	if index < 0 || index >= size {
+		return nil, fmt.Errorf("merkle: index %d out of range for %d leaves", index, size)
+	}
+	current := leaf
+	for i, n := index, size; n > 1; i, n = i/2, (n+1)/2 {
+		if i^1 >= n {
+			continue
+		}
+		if len(siblings) == 0 {
+			return nil, errors.New("merkle: missing sibling")
+		}
+		if i%2 == 0 {
+			current = node(domain, current, siblings[0])
+		} else {
+			current = node(domain, siblings[0], current)
+		}
+		siblings = siblings[1:]
+	}
+	if len(siblings) != 0 {
+		return nil, errors.New("merkle: too many siblings")
+	}
+	return root(domain, size, current), nil
+}
+
+func node(domain string, left, right []byte) []byte {
+	return hash.New(&hash.BytesWithDomain{
+		TheDomain: domain + " Node",
+		Bytes:     append(append([]byte(nil), left...), right...),
+	}).Sum()
+}
+
+func root(domain string, size int, top []byte) []byte {
+	sizeBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(sizeBytes, uint64(size))
+	return hash.New(&hash.BytesWithDomain{
+		TheDomain: domain + " Root",
+		Bytes:     append(sizeBytes, top...),
+	}).Sum()
+}
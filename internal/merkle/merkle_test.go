@@ -0,0 +1,53 @@
+package merkle
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTree(t *testing.T) {
+	if _, err := New("test", nil); err == nil {
+		t.Error("empty tree accepted")
+	}
+	for size := 1; size <= 9; size++ {
+		leaves := make([][]byte, size)
+		for i := range leaves {
+			leaves[i] = []byte{byte(i)}
+		}
+		tree, err := New("test", leaves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range leaves {
+			siblings, err := tree.Siblings(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			root, err := Root("test", leaves[i], i, size, siblings)
+			if err != nil || !bytes.Equal(root, tree.Root()) {
+				t.Errorf("leaf %d of %d: root doesn't match: %v", i, size, err)
+			}
+
+			if root, _ = Root("other", leaves[i], i, size, siblings); bytes.Equal(root, tree.Root()) {
+				t.Errorf("leaf %d of %d: root matches with another domain", i, size)
+			}
+			if root, _ = Root("test", []byte{byte(i + 1)}, i, size, siblings); bytes.Equal(root, tree.Root()) {
+				t.Errorf("leaf %d of %d: root matches with another leaf", i, size)
+			}
+			if root, _ = Root("test", leaves[i], i, size+1, siblings); bytes.Equal(root, tree.Root()) {
+				t.Errorf("leaf %d of %d: root matches with another size", i, size)
+			}
+			if len(siblings) > 0 {
+				if _, err = Root("test", leaves[i], i, size, siblings[1:]); err == nil {
+					t.Errorf("leaf %d of %d: missing sibling accepted", i, size)
+				}
+			}
+			if _, err = Root("test", leaves[i], i, size, append(siblings, leaves[i])); err == nil {
+				t.Errorf("leaf %d of %d: extra sibling accepted", i, size)
+			}
+		}
+		if _, err = tree.Siblings(size); err == nil {
+			t.Errorf("index %d out of range accepted", size)
+		}
+	}
+}
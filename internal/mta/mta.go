@@ -1,7 +1,7 @@
 package mta
 
 import (
-	"crypto/rand"
+	"io"
 
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/pkg/hash"
@@ -23,11 +23,12 @@ import (
 // - D = (aⱼ ⊙ Bᵢ) ⊕ encᵢ(- β, s)
 // - F = encⱼ(-β, r)
 // - Proof = zkaffg proof of correct encryption.
-func ProveAffG(group curve.Curve, h *hash.Hash,
+// The randomness of β, the ciphertexts and the proof is read from source.
+func ProveAffG(source io.Reader, group curve.Curve, h *hash.Hash,
 	senderSecretShare *safenum.Int, senderSecretSharePoint curve.Point, receiverEncryptedShare *paillier.Ciphertext,
 	sender *paillier.SecretKey, receiver *paillier.PublicKey, verifier *pedersen.Parameters) (Beta *safenum.Int, D, F *paillier.Ciphertext, Proof *zkaffg.Proof) {
-	D, F, S, R, BetaNeg := newMta(senderSecretShare, receiverEncryptedShare, sender, receiver)
-	Proof = zkaffg.NewProof(group, h, zkaffg.Public{
+	D, F, S, R, BetaNeg := newMta(source, senderSecretShare, receiverEncryptedShare, sender, receiver)
+	Proof = zkaffg.NewProofFromSource(source, group, h, zkaffg.Public{
 		Kv:       receiverEncryptedShare,
 		Dv:       D,
 		Fp:       F,
@@ -56,12 +57,13 @@ func ProveAffG(group curve.Curve, h *hash.Hash,
 // - D = (aⱼ ⊙ Bᵢ) ⊕ encᵢ(-β, s)
 // - F = encⱼ(-β, r)
 // - Proof = zkaffp proof of correct encryption.
-func ProveAffP(group curve.Curve, h *hash.Hash,
+// The randomness of β, the ciphertexts and the proof is read from source.
+func ProveAffP(source io.Reader, group curve.Curve, h *hash.Hash,
 	senderSecretShare *safenum.Int, senderEncryptedShare *paillier.Ciphertext, senderEncryptedShareNonce *safenum.Nat,
 	receiverEncryptedShare *paillier.Ciphertext,
 	sender *paillier.SecretKey, receiver *paillier.PublicKey, verifier *pedersen.Parameters) (Beta *safenum.Int, D, F *paillier.Ciphertext, Proof *zkaffp.Proof) {
-	D, F, S, R, BetaNeg := newMta(senderSecretShare, receiverEncryptedShare, sender, receiver)
-	Proof = zkaffp.NewProof(group, h, zkaffp.Public{
+	D, F, S, R, BetaNeg := newMta(source, senderSecretShare, receiverEncryptedShare, sender, receiver)
+	Proof = zkaffp.NewProofFromSource(source, group, h, zkaffp.Public{
 		Kv:       receiverEncryptedShare,
 		Dv:       D,
 		Fp:       F,
@@ -81,13 +83,13 @@ func ProveAffP(group curve.Curve, h *hash.Hash,
 	return
 }
 
-func newMta(senderSecretShare *safenum.Int, receiverEncryptedShare *paillier.Ciphertext,
+func newMta(source io.Reader, senderSecretShare *safenum.Int, receiverEncryptedShare *paillier.Ciphertext,
 	sender *paillier.SecretKey, receiver *paillier.PublicKey) (D, F *paillier.Ciphertext, S, R *safenum.Nat, BetaNeg *safenum.Int) {
-	BetaNeg = sample.IntervalLPrime(rand.Reader)
+	BetaNeg = sample.IntervalLPrime(source)
 
-	F, R = sender.Enc(BetaNeg) // F = encᵢ(-β, r)
+	F, R = sender.EncFromSource(source, BetaNeg) // F = encᵢ(-β, r)
 
-	D, S = receiver.Enc(BetaNeg)
+	D, S = receiver.EncFromSource(source, BetaNeg)
 	tmp := receiverEncryptedShare.Clone().Mul(receiver, senderSecretShare) // tmp = aᵢ ⊙ Bⱼ
 	D.Add(receiver, tmp)                                                   // D = encⱼ(-β;s) ⊕ (aᵢ ⊙ Bⱼ) = encⱼ(aᵢ•bⱼ-β)
 
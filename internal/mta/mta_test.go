@@ -1,6 +1,7 @@
 package mta
 
 import (
+	"crypto/rand"
 	mrand "math/rand"
 	"testing"
 
@@ -55,8 +56,8 @@ func Test_newMtA(t *testing.T) {
 
 	{
 		Ai, Aj := aiScalar.ActOnBase(), ajScalar.ActOnBase()
-		betaI, Di, Fi, proofI := ProveAffG(group, hash.New(), ai, Ai, Bj, ski, paillierJ, zk.Pedersen)
-		betaJ, Dj, Fj, proofJ := ProveAffG(group, hash.New(), aj, Aj, Bi, skj, paillierI, zk.Pedersen)
+		betaI, Di, Fi, proofI := ProveAffG(rand.Reader, group, hash.New(), ai, Ai, Bj, ski, paillierJ, zk.Pedersen)
+		betaJ, Dj, Fj, proofJ := ProveAffG(rand.Reader, group, hash.New(), aj, Aj, Bi, skj, paillierI, zk.Pedersen)
 
 		assert.True(t, proofI.Verify(hash.New(), zkaffg.Public{
 			Kv:       Bj,
@@ -82,8 +83,8 @@ func Test_newMtA(t *testing.T) {
 	{
 		Ai, nonceI := ski.Enc(ai)
 		Aj, nonceJ := skj.Enc(aj)
-		betaI, Di, Fi, proofI := ProveAffP(group, hash.New(), ai, Ai, nonceI, Bj, ski, paillierJ, zk.Pedersen)
-		betaJ, Dj, Fj, proofJ := ProveAffP(group, hash.New(), aj, Aj, nonceJ, Bi, skj, paillierI, zk.Pedersen)
+		betaI, Di, Fi, proofI := ProveAffP(rand.Reader, group, hash.New(), ai, Ai, nonceI, Bj, ski, paillierJ, zk.Pedersen)
+		betaJ, Dj, Fj, proofJ := ProveAffP(rand.Reader, group, hash.New(), aj, Aj, nonceJ, Bi, skj, paillierI, zk.Pedersen)
 
 		assert.True(t, proofI.Verify(group, hash.New(), zkaffp.Public{
 			Kv:       Bj,
@@ -1,6 +1,8 @@
 package ot
 
 import (
+	"io"
+
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/math/sample"
@@ -91,6 +93,7 @@ func (r *AdditiveOTSender) Round1(msg *AdditiveOTReceiveRound1Message) (*Additiv
 // AdditiveOTReceiver holds the Receiver's state for the Additive OT Protocol.
 type AdditiveOTReceiver struct {
 	// After setup
+	rand    io.Reader
 	ctxHash *hash.Hash
 	group   curve.Curve
 	setup   *CorreOTReceiveSetup
@@ -109,8 +112,8 @@ type AdditiveOTReceiver struct {
 // and for the Receiver to receive choice_j * alpha_j - pad_j for each of the pads, and their choices.
 //
 // A single setup can be used for multiple protocol executions, but should be initialized with a nonce.
-func NewAdditiveOTReceiver(ctxHash *hash.Hash, setup *CorreOTReceiveSetup, group curve.Curve, choices []byte) *AdditiveOTReceiver {
-	return &AdditiveOTReceiver{ctxHash: ctxHash, setup: setup, group: group, choices: choices}
+func NewAdditiveOTReceiver(rand io.Reader, ctxHash *hash.Hash, setup *CorreOTReceiveSetup, group curve.Curve, choices []byte) *AdditiveOTReceiver {
+	return &AdditiveOTReceiver{rand: rand, ctxHash: ctxHash, setup: setup, group: group, choices: choices}
 }
 
 // AdditiveOTReceiveRound1Message is the first message sent by the Receiver in an Additive OT.
@@ -120,7 +123,7 @@ type AdditiveOTReceiveRound1Message struct {
 
 // Round1 executes the Receiver's first round of an Additive OT.
 func (r *AdditiveOTReceiver) Round1() *AdditiveOTReceiveRound1Message {
-	msg, result := ExtendedOTReceive(r.ctxHash, r.setup, r.choices)
+	msg, result := ExtendedOTReceive(r.rand, r.ctxHash, r.setup, r.choices)
 	r.result = result
 	return &AdditiveOTReceiveRound1Message{Msg: msg}
 }
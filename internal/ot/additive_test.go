@@ -13,7 +13,7 @@ import (
 
 func runAdditiveOT(hash *hash.Hash, choices []byte, alpha [2]curve.Scalar, sendSetup *CorreOTSendSetup, receiveSetup *CorreOTReceiveSetup) (AdditiveOTSendResult, AdditiveOTReceiveResult, error) {
 	sender := NewAdditiveOTSender(hash.Clone(), sendSetup, 8*len(choices), alpha)
-	receiver := NewAdditiveOTReceiver(hash.Clone(), receiveSetup, alpha[0].Curve(), choices)
+	receiver := NewAdditiveOTReceiver(rand.Reader, hash.Clone(), receiveSetup, alpha[0].Curve(), choices)
 	msgR1 := receiver.Round1()
 	msgS1, sendResult, err := sender.Round1(msgR1)
 	if err != nil {
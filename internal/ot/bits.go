@@ -1,5 +1,7 @@
 package ot
 
+import "github.com/cronokirby/safenum"
+
 // bitAt returns the ith bit in a vector of bits.
 //
 // The indexing goes from bytes 0..len() - 1, and from the LSB to the MSB inside
@@ -7,3 +9,30 @@ package ot
 func bitAt(i int, data []byte) byte {
 	return (data[i>>3] >> (i & 0b111)) & 1
 }
+
+// constantTimeSelectBytes sets dst to a if choose is 0, and to b if choose is 1, without branching on choose.
+//
+// Only the first min(len(dst), len(a), len(b)) bytes of dst are written.
+// dst may alias a or b.
+func constantTimeSelectBytes(dst, a, b []byte, choose safenum.Choice) {
+	mask := -byte(choose)
+	for i := 0; i < len(dst) && i < len(a) && i < len(b); i++ {
+		dst[i] = a[i] ^ (mask & (a[i] ^ b[i]))
+	}
+}
+
+// ChoiceFromBool converts a bool into a safenum.Choice, with true becoming 1.
+//
+// This isn't constant time, so callers who need to hide their choice should
+// build the safenum.Choice directly.
+func ChoiceFromBool(b bool) safenum.Choice {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// BoolFromChoice converts a safenum.Choice back into a bool, with 1 becoming true.
+func BoolFromChoice(choice safenum.Choice) bool {
+	return choice == 1
+}
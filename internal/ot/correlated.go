@@ -1,13 +1,16 @@
 package ot
 
 import (
-	"crypto/rand"
 	"errors"
+	"fmt"
+	"io"
 
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/params"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
 	"github.com/koteld/multi-party-sig/pkg/pool"
 	"github.com/zeebo/blake3"
 )
@@ -26,8 +29,9 @@ type CorreOTSendSetup struct {
 // This struct is needed, because there are multiple rounds in the setup.
 type CorreOTSetupSender struct {
 	// After setup
-	pl   *pool.Pool
-	hash *hash.Hash
+	pl    *pool.Pool
+	hash  *hash.Hash
+	group curve.Curve
 	// After Round 1
 	// The setup which can be used for the different Random OTs.
 	setup *RandomOTReceiveSetup
@@ -40,8 +44,8 @@ type CorreOTSetupSender struct {
 // NewCorreOTSetupSender initializes the state for setting up the Sender part of a Correlated OT.
 //
 // This follows the Initialize part of Figure 3, in https://eprint.iacr.org/2015/546.
-func NewCorreOTSetupSender(pl *pool.Pool, hash *hash.Hash) *CorreOTSetupSender {
-	return &CorreOTSetupSender{pl: pl, hash: hash}
+func NewCorreOTSetupSender(pl *pool.Pool, hash *hash.Hash, group curve.Curve) *CorreOTSetupSender {
+	return &CorreOTSetupSender{pl: pl, hash: hash, group: group}
 }
 
 // CorreOTSetupSendRound1Message is the first message sent by the Sender in the Correlated OT setup.
@@ -51,14 +55,17 @@ type CorreOTSetupSendRound1Message struct {
 }
 
 // Round1 executes the Sender's first round of the Correlated OT setup.
-func (r *CorreOTSetupSender) Round1(msg *CorreOTSetupReceiveRound1Message) (*CorreOTSetupSendRound1Message, error) {
+//
+// The correlation and the Random OT choices are sampled from rand.
+func (r *CorreOTSetupSender) Round1(rand io.Reader, msg *CorreOTSetupReceiveRound1Message) (*CorreOTSetupSendRound1Message, error) {
+	defer trace.Region("ot: correlated setup send")()
 	var err error
-	r.setup, err = RandomOTSetupReceive(r.hash, &msg.Msg)
+	r.setup, err = RandomOTSetupReceive(r.hash, r.group, &msg.Msg)
 	if err != nil {
 		return nil, err
 	}
 
-	_, _ = rand.Read(r._Delta[:])
+	_, _ = io.ReadFull(rand, r._Delta[:])
 
 	randomOTNonces := r.hash.Fork(&hash.BytesWithDomain{
 		TheDomain: "CorreOT Random OT Nonces",
@@ -68,13 +75,14 @@ func (r *CorreOTSetupSender) Round1(msg *CorreOTSetupReceiveRound1Message) (*Cor
 		choice := safenum.Choice(bitAt(i, r._Delta[:]))
 		nonce := make([]byte, 32)
 		_, _ = randomOTNonces.Read(nonce)
-		r.randomOTReceivers[i] = NewRandomOTReceiver(nonce, r.setup, choice)
+		r.randomOTReceivers[i] = NewRandomOTReceiver(r.hash, nonce, r.setup, choice)
 	}
 
 	outMsg := new(CorreOTSetupSendRound1Message)
+	sources := sample.Split(rand, params.OTParam)
 	errors := r.pl.Parallelize(params.OTParam, func(i int) interface{} {
 		var err error
-		outMsg.Msgs[i], err = r.randomOTReceivers[i].Round1()
+		outMsg.Msgs[i], err = r.randomOTReceivers[i].Round1(sources[i])
 		return err
 	})
 	for _, err := range errors {
@@ -93,6 +101,7 @@ type CorreOTSetupSendRound2Message struct {
 
 // Round2 executes the Sender's second round of the Correlated OT setup.
 func (r *CorreOTSetupSender) Round2(msg *CorreOTSetupReceiveRound2Message) *CorreOTSetupSendRound2Message {
+	defer trace.Region("ot: correlated setup send")()
 	outMsg := new(CorreOTSetupSendRound2Message)
 	for i := 0; i < params.OTParam; i++ {
 		outMsg.Msgs[i] = r.randomOTReceivers[i].Round2(&msg.Msgs[i])
@@ -102,6 +111,7 @@ func (r *CorreOTSetupSender) Round2(msg *CorreOTSetupReceiveRound2Message) *Corr
 
 // Round2 executes the Sender's final round of the Correlated OT setup.
 func (r *CorreOTSetupSender) Round3(msg *CorreOTSetupReceiveRound3Message) (*CorreOTSendSetup, error) {
+	defer trace.Region("ot: correlated setup send")()
 	setup := new(CorreOTSendSetup)
 	setup._Delta = r._Delta
 	var err error
@@ -155,9 +165,10 @@ func EmptyCorreOTSetupReceiveRound1Message(group curve.Curve) *CorreOTSetupRecei
 	return &CorreOTSetupReceiveRound1Message{Msg: *EmptyRandomOTSetupSendMessage(group)}
 }
 
-// Round1 runs the first round of a Receiver's correlated OT Setup.
-func (r *CorreOTSetupReceiver) Round1() *CorreOTSetupReceiveRound1Message {
-	msg, setup := RandomOTSetupSend(r.hash, r.group)
+// Round1 runs the first round of a Receiver's correlated OT Setup, with the setup's secret sampled from rand.
+func (r *CorreOTSetupReceiver) Round1(rand io.Reader) *CorreOTSetupReceiveRound1Message {
+	defer trace.Region("ot: correlated setup receive")()
+	msg, setup := RandomOTSetupSend(rand, r.hash, r.group)
 	r.setup = setup
 
 	randomOTNonces := r.hash.Fork(&hash.BytesWithDomain{
@@ -167,7 +178,7 @@ func (r *CorreOTSetupReceiver) Round1() *CorreOTSetupReceiveRound1Message {
 	for i := 0; i < params.OTParam; i++ {
 		nonce := make([]byte, 32)
 		_, _ = randomOTNonces.Read(nonce)
-		r.randomOTSenders[i] = NewRandomOTSender(nonce, r.setup)
+		r.randomOTSenders[i] = NewRandomOTSender(r.hash, nonce, r.setup)
 	}
 
 	return &CorreOTSetupReceiveRound1Message{*msg}
@@ -178,8 +189,22 @@ type CorreOTSetupReceiveRound2Message struct {
 	Msgs [params.OTParam]RandomOTSendRound1Message
 }
 
+// Validate checks each of the challenges of the message, see RandomOTSendRound1Message.Validate.
+func (m *CorreOTSetupReceiveRound2Message) Validate() error {
+	for i := range m.Msgs {
+		if err := m.Msgs[i].Validate(); err != nil {
+			return fmt.Errorf("random OT %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
 // Round1 runs the second round of a Receiver's correlated OT Setup.
 func (r *CorreOTSetupReceiver) Round2(msg *CorreOTSetupSendRound1Message) (*CorreOTSetupReceiveRound2Message, error) {
+	defer trace.Region("ot: correlated setup receive")()
+	if err := checkGroup(r.group); err != nil {
+		return nil, fmt.Errorf("CorreOTSetupReceiver: %w", err)
+	}
 	outMsg := new(CorreOTSetupReceiveRound2Message)
 
 	errors := r.pl.Parallelize(params.OTParam, func(i int) interface{} {
@@ -202,6 +227,7 @@ type CorreOTSetupReceiveRound3Message struct {
 
 // Round1 runs the third round of a Receiver's correlated OT Setup.
 func (r *CorreOTSetupReceiver) Round3(msg *CorreOTSetupSendRound2Message) (*CorreOTSetupReceiveRound3Message, *CorreOTReceiveSetup, error) {
+	defer trace.Region("ot: correlated setup receive")()
 	outMsg := new(CorreOTSetupReceiveRound3Message)
 	setup := new(CorreOTReceiveSetup)
 	for i := 0; i < params.OTParam; i++ {
@@ -249,6 +275,7 @@ type CorreOTSendResult struct {
 // A single setup can be used for multiple runs of the protocol, but it's important
 // that ctxHash be initialized with some kind of nonce in that case.
 func CorreOTSend(ctxHash *hash.Hash, setup *CorreOTSendSetup, batchSize int, msg *CorreOTReceiveMessage) (*CorreOTSendResult, error) {
+	defer trace.Region("ot: correlated send")()
 	batchSizeBytes := batchSize >> 3
 
 	// Doing a keyed hash for our PRG is faster than cloning a forked hash many times
@@ -299,6 +326,7 @@ type CorreOTReceiveResult struct {
 // A single setup can be used for multiple runs of the protocol, but it's important
 // that ctxHash be initialized with some kind of nonce in that case.
 func CorreOTReceive(ctxHash *hash.Hash, setup *CorreOTReceiveSetup, choices []byte) (*CorreOTReceiveMessage, *CorreOTReceiveResult) {
+	defer trace.Region("ot: correlated receive")()
 	batchSizeBytes := len(choices)
 
 	// Doing a keyed hash for our PRG is faster than cloning a forked hash many times
@@ -1,11 +1,12 @@
 package ot
 
 import (
-	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"io"
 
 	"github.com/koteld/multi-party-sig/internal/params"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/zeebo/blake3"
 )
@@ -83,11 +84,16 @@ type ExtendedOTSendResult struct {
 //
 // The goal of this protocol is to conduct a large number of random oblivious transfers.
 //
-// This follows Figure 7 of https://eprint.iacr.org/2015/546.
+// This follows Figure 7 of https://eprint.iacr.org/2015/546, which is the IKNP extension with a check
+// against a malicious Receiver.
+// Only the Correlated OT setup runs base Random OTs, params.OTParam of them, and each call then costs
+// a few hashes and XORs per transfer, so tens of thousands of transfers can be split into batches
+// sharing the same setup.
 //
 // A single setup can be used for many invocations of this protocol, so long as the
 // hash is initialized with some kind of nonce.
 func ExtendedOTSend(ctxHash *hash.Hash, setup *CorreOTSendSetup, batchSize int, msg *ExtendedOTReceiveMessage) (*ExtendedOTSendResult, error) {
+	defer trace.Region("ot: extended send")()
 	inflatedBatchSize := batchSize + params.OTParam + params.StatParam
 
 	correResult, err := CorreOTSend(ctxHash, setup, inflatedBatchSize, msg.CorreMsg)
@@ -162,11 +168,12 @@ type ExtendedOTReceiveMessage struct {
 //
 // A single setup can be used for many invocations of this protocol, so long as the
 // hash is initialized with some kind of nonce.
-func ExtendedOTReceive(ctxHash *hash.Hash, setup *CorreOTReceiveSetup, choices []byte) (*ExtendedOTReceiveMessage, *ExtendedOTReceiveResult) {
+func ExtendedOTReceive(rand io.Reader, ctxHash *hash.Hash, setup *CorreOTReceiveSetup, choices []byte) (*ExtendedOTReceiveMessage, *ExtendedOTReceiveResult) {
+	defer trace.Region("ot: extended receive")()
 	inflatedBatchSize := 8*len(choices) + params.OTParam + params.StatParam
 	extraChoices := make([]byte, inflatedBatchSize/8)
 	copy(extraChoices, choices)
-	_, _ = rand.Read(extraChoices[len(choices):])
+	_, _ = io.ReadFull(rand, extraChoices[len(choices):])
 
 	correMsg, correResult := CorreOTReceive(ctxHash, setup, extraChoices)
 
@@ -11,7 +11,7 @@ import (
 )
 
 func runExtendedOT(hash *hash.Hash, choices []byte, sendSetup *CorreOTSendSetup, receiveSetup *CorreOTReceiveSetup) (*ExtendedOTSendResult, *ExtendedOTReceiveResult, error) {
-	msg, receiveResult := ExtendedOTReceive(hash.Clone(), receiveSetup, choices)
+	msg, receiveResult := ExtendedOTReceive(rand.Reader, hash.Clone(), receiveSetup, choices)
 	sendResult, err := ExtendedOTSend(hash.Clone(), sendSetup, 8*len(choices), msg)
 	if err != nil {
 		return nil, nil, err
@@ -64,3 +64,53 @@ func BenchmarkExtendedOT(b *testing.B) {
 		runExtendedOT(hash.New(), choices, sendSetup, receiveSetup)
 	}
 }
+
+func TestExtendedOTLargeBatch(t *testing.T) {
+	sendSetup, receiveSetup, err := runCorreOTSetup(nil, hash.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	choices := make([]byte, 10000/8)
+	_, _ = rand.Read(choices)
+	sendResult, receiveResult, err := runExtendedOT(hash.New(), choices, sendSetup, receiveSetup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sendResult._V0) != 10000 || len(receiveResult._VChoices) != 10000 {
+		t.Fatalf("expected 10000 transfers, got %d and %d", len(sendResult._V0), len(receiveResult._VChoices))
+	}
+	for i := range receiveResult._VChoices {
+		chosen, other := sendResult._V0[i], sendResult._V1[i]
+		if bitAt(i, choices) == 1 {
+			chosen, other = other, chosen
+		}
+		if receiveResult._VChoices[i] != chosen {
+			t.Fatalf("transfer %d: the receiver didn't get its choice", i)
+		}
+		if receiveResult._VChoices[i] == other {
+			t.Fatalf("transfer %d: the receiver got both messages", i)
+		}
+	}
+
+	// a batch with another nonce reuses the setup, but gives unrelated results
+	H := hash.New()
+	_ = H.WriteAny([]byte("nonce"))
+	nextResult, _, err := runExtendedOT(H, choices, sendSetup, receiveSetup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextResult._V0[0] == sendResult._V0[0] {
+		t.Error("batches with different nonces shouldn't give the same results")
+	}
+}
+
+func BenchmarkExtendedOT10k(b *testing.B) {
+	sendSetup, receiveSetup, _ := runCorreOTSetup(nil, hash.New())
+	choices := make([]byte, 10000/8)
+	_, _ = rand.Read(choices)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runExtendedOT(hash.New(), choices, sendSetup, receiveSetup)
+	}
+}
@@ -0,0 +1,124 @@
+package ot
+
+import (
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
+)
+
+// idealAdditiveOT is the ideal functionality of an Additive OT, for testing the protocols built on top of it.
+//
+// It produces the same results as running an AdditiveOTSender against an AdditiveOTReceiver,
+// but the pads are sampled directly, and both of the receiver's possible outputs can be inspected.
+type idealAdditiveOT struct {
+	alpha   [2]curve.Scalar
+	choices []byte
+	pads    AdditiveOTSendResult
+}
+
+// newIdealAdditiveOT samples the pads for an Additive OT with one transfer per bit of choices.
+func newIdealAdditiveOT(rand io.Reader, alpha [2]curve.Scalar, choices []byte) *idealAdditiveOT {
+	group := alpha[0].Curve()
+	pads := make(AdditiveOTSendResult, 8*len(choices))
+	for i := range pads {
+		pads[i][0] = sample.Scalar(rand, group)
+		pads[i][1] = sample.Scalar(rand, group)
+	}
+	return &idealAdditiveOT{alpha: alpha, choices: choices, pads: pads}
+}
+
+// outputs returns both outputs the receiver could have received for transfer i:
+// -padᵢ for a choice of 0, and α - padᵢ for a choice of 1.
+func (o *idealAdditiveOT) outputs(i int) [2][2]curve.Scalar {
+	var outputs [2][2]curve.Scalar
+	for j := 0; j < 2; j++ {
+		group := o.alpha[j].Curve()
+		outputs[0][j] = group.NewScalar().Set(o.pads[i][j]).Negate()
+		outputs[1][j] = group.NewScalar().Set(o.alpha[j]).Sub(o.pads[i][j])
+	}
+	return outputs
+}
+
+// idealSender and idealReceiver replace the Additive OT of a MultiplySender and MultiplyReceiver.
+type (
+	idealSender   struct{ *idealAdditiveOT }
+	idealReceiver struct{ *idealAdditiveOT }
+)
+
+func (o idealSender) Round1(*AdditiveOTReceiveRound1Message) (*AdditiveOTSendRound1Message, AdditiveOTSendResult, error) {
+	return &AdditiveOTSendRound1Message{}, o.pads, nil
+}
+
+func (o idealReceiver) Round1() *AdditiveOTReceiveRound1Message {
+	return &AdditiveOTReceiveRound1Message{}
+}
+
+func (o idealReceiver) Round2(*AdditiveOTSendRound1Message) (AdditiveOTReceiveResult, error) {
+	result := make(AdditiveOTReceiveResult, len(o.pads))
+	for i := range result {
+		result[i] = o.outputs(i)[bitAt(i, o.choices)]
+	}
+	return result, nil
+}
+
+// idealMultiply runs the multiplication protocol for alpha and beta, replacing the Additive OT
+// with its ideal functionality, and returns the shares of both parties.
+func idealMultiply(t *testing.T, ctxHash *hash.Hash, alpha, beta curve.Scalar) (curve.Scalar, curve.Scalar) {
+	sender := NewMultiplySender(rand.Reader, ctxHash, nil, alpha)
+	receiver, err := NewMultiplyReceiver(rand.Reader, ctxHash, nil, beta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ideal := newIdealAdditiveOT(rand.Reader, sender.doubleAlpha, receiver.choices)
+	if len(ideal.pads) != len(sender.gadget) {
+		t.Fatalf("expected %d transfers, got %d", len(sender.gadget), len(ideal.pads))
+	}
+	// both outputs differ by exactly α, so only the choice decides what the receiver learns
+	for i := range ideal.pads {
+		outputs := ideal.outputs(i)
+		for j := 0; j < 2; j++ {
+			if !alpha.Curve().NewScalar().Set(outputs[1][j]).Sub(outputs[0][j]).Equal(ideal.alpha[j]) {
+				t.Fatalf("transfer %d: outputs don't differ by alpha", i)
+			}
+		}
+	}
+	sender.sender = idealSender{ideal}
+	receiver.receiver = idealReceiver{ideal}
+
+	senderMsg, shareA, err := sender.Round1(receiver.Round1())
+	if err != nil {
+		t.Fatal(err)
+	}
+	shareB, err := receiver.Round2(senderMsg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return shareA, shareB
+}
+
+func TestIdealMultiply(t *testing.T) {
+	group := curve.Secp256k1{}
+	ctxHash := hash.New()
+	alpha := sample.Scalar(rand.Reader, group)
+
+	// every single byte value, along with the extremes of the scalar range
+	betas := make([]curve.Scalar, 0, 256+2)
+	for b := 0; b < 256; b++ {
+		betas = append(betas, group.NewScalar().SetNat(new(safenum.Nat).SetUint64(uint64(b))))
+	}
+	minusOne := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1)).Negate()
+	betas = append(betas, minusOne, sample.Scalar(rand.Reader, group))
+
+	for _, beta := range betas {
+		shareA, shareB := idealMultiply(t, ctxHash, alpha, beta)
+		expected := group.NewScalar().Set(alpha).Mul(beta)
+		if !shareA.Add(shareB).Equal(expected) {
+			t.Fatalf("shares don't add up to alpha * beta")
+		}
+	}
+}
@@ -1,8 +1,9 @@
 package ot
 
 import (
-	"crypto/rand"
 	"errors"
+	"fmt"
+	"io"
 
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/params"
@@ -21,13 +22,13 @@ func scalarBytes(group curve.Curve) int {
 // The noise should be public, but the encoding will be unpredictable, but still decodable.
 //
 // The noise vector should have a length that's a multiple of 8
-func encode(beta curve.Scalar, noise []curve.Scalar) ([]byte, error) {
+func encode(rand io.Reader, beta curve.Scalar, noise []curve.Scalar) ([]byte, error) {
 	// This follows Algorithm 4 in Doerner's paper:
 	//   https://eprint.iacr.org/2018/499
 	group := beta.Curve()
 
 	gamma := make([]byte, len(noise)/8)
-	_, _ = rand.Read(gamma)
+	_, _ = io.ReadFull(rand, gamma)
 
 	acc := group.NewScalar().Set(beta)
 	mulNat := new(safenum.Nat)
@@ -70,6 +71,17 @@ func makeGadget(ctxHash *hash.Hash, group curve.Curve) []curve.Scalar {
 	return out
 }
 
+// additiveSender is the Sender side of the Additive OT underlying the multiplication protocol.
+type additiveSender interface {
+	Round1(msg *AdditiveOTReceiveRound1Message) (*AdditiveOTSendRound1Message, AdditiveOTSendResult, error)
+}
+
+// additiveReceiver is the Receiver side of the Additive OT underlying the multiplication protocol.
+type additiveReceiver interface {
+	Round1() *AdditiveOTReceiveRound1Message
+	Round2(msg *AdditiveOTSendRound1Message) (AdditiveOTReceiveResult, error)
+}
+
 // MultiplySender contains the state for the Sender of the multiplication protocol.
 type MultiplySender struct {
 	// After setup
@@ -78,7 +90,7 @@ type MultiplySender struct {
 	setup       *CorreOTSendSetup
 	doubleAlpha [2]curve.Scalar
 	gadget      []curve.Scalar
-	sender      *AdditiveOTSender
+	sender      additiveSender
 }
 
 // NewMultiplySender initializes the Sender for the multiplication protocol.
@@ -86,13 +98,15 @@ type MultiplySender struct {
 // The Sender has a scalar alpha, the Receiver beta, and the goal is to create an additive
 // sharing of alpha * beta.
 //
+// The randomness of the Sender is read from rand.
+//
 // This follows Protocol 5 of https://eprint.iacr.org/2018/4990.
-func NewMultiplySender(ctxHash *hash.Hash, setup *CorreOTSendSetup, alpha curve.Scalar) *MultiplySender {
+func NewMultiplySender(rand io.Reader, ctxHash *hash.Hash, setup *CorreOTSendSetup, alpha curve.Scalar) *MultiplySender {
 	group := alpha.Curve()
 	gadget := makeGadget(ctxHash, group)
 	var doubleAlpha [2]curve.Scalar
 	doubleAlpha[0] = alpha
-	doubleAlpha[1] = sample.Scalar(rand.Reader, group)
+	doubleAlpha[1] = sample.Scalar(rand, group)
 	return &MultiplySender{
 		ctxHash:     ctxHash,
 		group:       group,
@@ -165,7 +179,7 @@ type MultiplyReceiver struct {
 	beta     curve.Scalar
 	gadget   []curve.Scalar
 	choices  []byte
-	receiver *AdditiveOTReceiver
+	receiver additiveReceiver
 }
 
 // NewMultiplyReceiver initializes the Receiver for the multiplication protocol.
@@ -173,11 +187,16 @@ type MultiplyReceiver struct {
 // The Sender has a scalar alpha, the Receiver beta, and the goal is to create an additive
 // sharing of alpha * beta.
 //
+// The randomness of the Receiver is read from rand.
+//
 // This follows Protocol 5 of https://eprint.iacr.org/2018/4990.
-func NewMultiplyReceiver(ctxHash *hash.Hash, setup *CorreOTReceiveSetup, beta curve.Scalar) (*MultiplyReceiver, error) {
+func NewMultiplyReceiver(rand io.Reader, ctxHash *hash.Hash, setup *CorreOTReceiveSetup, beta curve.Scalar) (*MultiplyReceiver, error) {
 	group := beta.Curve()
+	if err := checkGroup(group); err != nil {
+		return nil, fmt.Errorf("NewMultiplyReceiver: %w", err)
+	}
 	gadget := makeGadget(ctxHash, group)
-	choices, err := encode(beta, gadget[scalarBytes(group):])
+	choices, err := encode(rand, beta, gadget[scalarBytes(group):])
 	if err != nil {
 		return nil, err
 	}
@@ -188,7 +207,7 @@ func NewMultiplyReceiver(ctxHash *hash.Hash, setup *CorreOTReceiveSetup, beta cu
 		beta:     beta,
 		gadget:   gadget,
 		choices:  choices,
-		receiver: NewAdditiveOTReceiver(ctxHash, setup, group, choices),
+		receiver: NewAdditiveOTReceiver(rand, ctxHash, setup, group, choices),
 	}, nil
 }
 
@@ -1,12 +1,15 @@
 package ot
 
 import (
-	"crypto/rand"
 	"crypto/subtle"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/cronokirby/safenum"
+	"github.com/fxamacker/cbor/v2"
 	"github.com/koteld/multi-party-sig/internal/params"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/math/sample"
@@ -42,12 +45,18 @@ type RandomOTSendSetup struct {
 //
 // The hash should be used to tie the execution of the protocol to the ambient context,
 // if that's desired.
+// It is used for the proof of knowledge of the secret key, so including the Sender's identity
+// prevents this message from being reused by another party.
 //
 // This setup can be done once and then used for multiple executions.
-func RandomOTSetupSend(hash *hash.Hash, group curve.Curve) (*RandomOTSetupSendMessage, *RandomOTSendSetup) {
-	b := sample.Scalar(rand.Reader, group)
+//
+// All the randomness is drawn from rand, which should usually be crypto/rand.Reader.
+func RandomOTSetupSend(rand io.Reader, hash *hash.Hash, group curve.Curve) (*RandomOTSetupSendMessage, *RandomOTSendSetup) {
+	defer trace.Region("ot: random setup send")()
+	b := sample.Scalar(rand, group)
 	B := b.ActOnBase()
-	BProof := zksch.NewProof(hash, B, b, nil)
+	nonce := zksch.NewRandomness(rand, group, nil)
+	BProof := &zksch.Proof{C: *nonce.Commitment(), Z: *nonce.Prove(hash, B, b, nil)}
 	return &RandomOTSetupSendMessage{B: B, BProof: BProof}, &RandomOTSendSetup{_B: B, b: b, _bB: b.Act(B)}
 }
 
@@ -60,10 +69,24 @@ type RandomOTReceiveSetup struct {
 // RandomOTSetupReceive runs the Receiver's part of the setup protocol for Random OT.
 //
 // The hash should be used to tie the execution of the protocol to the ambient context,
-// if that's desired.
+// if that's desired, and must be the same as the Sender's.
+// The Sender's public key must belong to group, which the Receiver expects the OTs to use.
 //
 // This setup can be done once and then used for multiple executions.
-func RandomOTSetupReceive(hash *hash.Hash, msg *RandomOTSetupSendMessage) (*RandomOTReceiveSetup, error) {
+func RandomOTSetupReceive(hash *hash.Hash, group curve.Curve, msg *RandomOTSetupSendMessage) (*RandomOTReceiveSetup, error) {
+	defer trace.Region("ot: random setup receive")()
+	if msg == nil || msg.B == nil {
+		return nil, fmt.Errorf("RandomOTSetupReceive: missing public key")
+	}
+	if err := group.ValidatePoint(msg.B); err != nil {
+		return nil, fmt.Errorf("RandomOTSetupReceive: %w", err)
+	}
+	if msg.B.IsIdentity() {
+		return nil, fmt.Errorf("RandomOTSetupReceive: public key is identity")
+	}
+	if err := checkGroup(group); err != nil {
+		return nil, fmt.Errorf("RandomOTSetupReceive: %w", err)
+	}
 	if !msg.BProof.Verify(hash, msg.B, nil) {
 		return nil, fmt.Errorf("RandomOTSetupReceive: Schnorr proof failed to verify")
 	}
@@ -71,6 +94,40 @@ func RandomOTSetupReceive(hash *hash.Hash, msg *RandomOTSetupSendMessage) (*Rand
 	return &RandomOTReceiveSetup{_B: msg.B}, nil
 }
 
+// checkGroup rejects a group which needs wider OTs than the ones implemented.
+//
+// The width of the OTs needed by group is given by params.OTBytesFor,
+// but the messages and results of the OTs are still arrays of params.OTBytes,
+// which is the width for secp256k1.
+func checkGroup(group curve.Curve) error {
+	if n := params.OTBytesFor(group); n > params.OTBytes {
+		return fmt.Errorf("curve %s needs %d byte OTs, but only %d are supported", group.Name(), n, params.OTBytes)
+	}
+	return nil
+}
+
+// RandomOTSetupReceiveExpecting is like RandomOTSetupReceive, but also checks that
+// the sender's public key matches expectedB, agreed upon beforehand.
+//
+// This rejects a setup message which has been swapped for another valid one.
+func RandomOTSetupReceiveExpecting(hash *hash.Hash, msg *RandomOTSetupSendMessage, expectedB curve.Point) (*RandomOTReceiveSetup, error) {
+	if msg == nil || msg.B == nil || expectedB == nil {
+		return nil, fmt.Errorf("RandomOTSetupReceive: missing public key")
+	}
+	BBytes, err := msg.B.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	expectedBBytes, err := expectedB.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(BBytes, expectedBBytes) != 1 {
+		return nil, fmt.Errorf("RandomOTSetupReceive: unexpected public key")
+	}
+	return RandomOTSetupReceive(hash, expectedB.Curve(), msg)
+}
+
 // RandomOTReceiver contains the state needed for a single execution of a Random OT.
 //
 // This should be created from a saved setup, for each execution.
@@ -97,15 +154,12 @@ type RandomOTReceiever struct {
 // NewRandomOTReceiver sets up the receiver's state for a single Random OT.
 //
 // The nonce should be 32 bytes, and must be different if a single setup is used for multiple OTs.
+// The pads are also bound to ctxHash, which must not be nil, and should commit to the ambient session,
+// so that reusing a nonce across sessions doesn't produce the same pads.
 //
 // choice indicates which of the two random messages should be received.
-func NewRandomOTReceiver(nonce []byte, result *RandomOTReceiveSetup, choice safenum.Choice) (out RandomOTReceiever) {
-	// This will only error if the nonce has the wrong length, which is a programmer error
-	var err error
-	out.hash, err = blake3.NewKeyed(nonce)
-	if err != nil {
-		panic(err)
-	}
+func NewRandomOTReceiver(ctxHash *hash.Hash, nonce []byte, result *RandomOTReceiveSetup, choice safenum.Choice) (out RandomOTReceiever) {
+	out.hash = randomOTHasher(ctxHash, nonce)
 	out.group = result._B.Curve()
 	out.choice = choice
 	out._B = result._B
@@ -113,6 +167,36 @@ func NewRandomOTReceiver(nonce []byte, result *RandomOTReceiveSetup, choice safe
 	return
 }
 
+// NewRandomOTReceiverBool is like NewRandomOTReceiver, but takes the choice as a bool.
+//
+// When choose is true, the receiver learns Rand1, otherwise Rand0.
+func NewRandomOTReceiverBool(ctxHash *hash.Hash, nonce []byte, result *RandomOTReceiveSetup, choose bool) RandomOTReceiever {
+	return NewRandomOTReceiver(ctxHash, nonce, result, ChoiceFromBool(choose))
+}
+
+// randomOTHasher returns the keyed hash used for a single Random OT,
+// with the key derived from both the nonce and the context.
+func randomOTHasher(ctxHash *hash.Hash, nonce []byte) *blake3.Hasher {
+	// A nonce with the wrong length is a programmer error
+	if len(nonce) != 32 {
+		panic(fmt.Sprintf("random OT: nonce must be 32 bytes, got %d", len(nonce)))
+	}
+	// So is a missing context, without which the pads would repeat across sessions
+	if ctxHash == nil {
+		panic("random OT: missing context hash")
+	}
+	key := make([]byte, 32)
+	_, _ = ctxHash.Fork(&hash.BytesWithDomain{
+		TheDomain: "Random OT Key",
+		Bytes:     nonce,
+	}).Digest().Read(key)
+	h, err := blake3.NewKeyed(key)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
 // RandomOTReceiveRound1Message is the first message sent by the receiver in a Random OT.
 type RandomOTReceiveRound1Message struct {
 	ABytes []byte
@@ -121,11 +205,13 @@ type RandomOTReceiveRound1Message struct {
 // Round1 executes the receiver's side of round 1 of a Random OT.
 //
 // This is the starting point for a Random OT.
-func (r *RandomOTReceiever) Round1() (outMsg RandomOTReceiveRound1Message, err error) {
+//
+// The randomness is drawn from rand, which should usually be crypto/rand.Reader.
+func (r *RandomOTReceiever) Round1(rand io.Reader) (outMsg RandomOTReceiveRound1Message, err error) {
 	// We sample a <- Z_q, and then compute
 	//   A = a * G + w * B
 	//   randChoice = H(a * B)
-	a := sample.Scalar(rand.Reader, r.group)
+	a := sample.Scalar(rand, r.group)
 	A := a.ActOnBase()
 	outMsg.ABytes, err = A.MarshalBinary()
 	if err != nil {
@@ -136,10 +222,7 @@ func (r *RandomOTReceiever) Round1() (outMsg RandomOTReceiveRound1Message, err e
 	if err != nil {
 		return outMsg, err
 	}
-	mask := -byte(r.choice)
-	for i := 0; i < len(outMsg.ABytes) && i < len(_APlusBBytes); i++ {
-		outMsg.ABytes[i] ^= (mask & (outMsg.ABytes[i] ^ _APlusBBytes[i]))
-	}
+	constantTimeSelectBytes(outMsg.ABytes, outMsg.ABytes, _APlusBBytes, r.choice)
 
 	abBytes, err := a.Act(r._B).MarshalBinary()
 	if err != nil {
@@ -158,6 +241,8 @@ type RandomOTReceiveRound2Message struct {
 }
 
 // Round2 executes the receiver's side of round 2 of a Random OT.
+//
+// msg can be checked with Validate first, to fail before Round3.
 func (r *RandomOTReceiever) Round2(msg *RandomOTSendRound1Message) (outMsg RandomOTReceiveRound2Message) {
 	// response = H(H(randW)) ^ (w * challenge).
 	r.receivedChallenge = msg.Challenge
@@ -171,10 +256,11 @@ func (r *RandomOTReceiever) Round2(msg *RandomOTSendRound1Message) (outMsg Rando
 
 	copy(r.hh_randChoice[:], outMsg.Response[:])
 
-	mask := -byte(r.choice)
+	var responseXorChallenge [params.OTBytes]byte
 	for i := 0; i < len(msg.Challenge); i++ {
-		outMsg.Response[i] ^= mask & msg.Challenge[i]
+		responseXorChallenge[i] = outMsg.Response[i] ^ msg.Challenge[i]
 	}
+	constantTimeSelectBytes(outMsg.Response[:], outMsg.Response[:], responseXorChallenge[:], r.choice)
 
 	return
 }
@@ -201,11 +287,8 @@ func (r *RandomOTReceiever) Round3(msg *RandomOTSendRound2Message) ([params.OTBy
 	}
 
 	// Assign the decommitment hash to the one matching our own choice
-	h_decommitChoice := h_decommit0
-	mask := -byte(r.choice)
-	for i := 0; i < params.OTBytes; i++ {
-		h_decommitChoice[i] ^= mask & (h_decommitChoice[i] ^ h_decommit1[i])
-	}
+	var h_decommitChoice [params.OTBytes]byte
+	constantTimeSelectBytes(h_decommitChoice[:], h_decommit0[:], h_decommit1[:], r.choice)
 	if subtle.ConstantTimeCompare(h_decommitChoice[:], r.hh_randChoice[:]) != 1 {
 		return r.randChoice, fmt.Errorf("RandomOTReceive Round 3: incorrect decommitment")
 	}
@@ -236,13 +319,9 @@ type RandomOTSender struct {
 // NewRandomOTSender sets up the receiver's state for a single Random OT.
 //
 // The nonce should be 32 bytes, and must be different if a single setup is used for multiple OTs.
-func NewRandomOTSender(nonce []byte, result *RandomOTSendSetup) (out RandomOTSender) {
-	// This will only error if the nonce has the wrong length, which is a programmer error
-	var err error
-	out.hash, err = blake3.NewKeyed(nonce)
-	if err != nil {
-		panic(err)
-	}
+// ctxHash must match the one given to NewRandomOTReceiver.
+func NewRandomOTSender(ctxHash *hash.Hash, nonce []byte, result *RandomOTSendSetup) (out RandomOTSender) {
+	out.hash = randomOTHasher(ctxHash, nonce)
 	out.group = result.b.Curve()
 	out.b = result.b
 	out._B = result._B
@@ -256,6 +335,36 @@ type RandomOTSendRound1Message struct {
 	Challenge [params.OTBytes]byte
 }
 
+// UnmarshalCBOR implements cbor.Unmarshaler, and rejects a challenge which doesn't have exactly params.OTBytes bytes,
+// instead of padding or truncating it.
+func (m *RandomOTSendRound1Message) UnmarshalCBOR(data []byte) error {
+	var raw struct {
+		Challenge []byte
+	}
+	if err := cbor.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if l := len(raw.Challenge); l != params.OTBytes {
+		return fmt.Errorf("RandomOTSendRound1Message: incorrect challenge length (got %d, expected %d)", l, params.OTBytes)
+	}
+	copy(m.Challenge[:], raw.Challenge)
+	return nil
+}
+
+// Validate checks that the challenge isn't 0.
+//
+// An honest challenge is the XOR of two hashes, so it is never 0 in practice.
+// A cheating sender is caught by RandomOTReceiever.Round3 anyway, but this allows
+// rejecting the message before running Round2.
+func (m *RandomOTSendRound1Message) Validate() error {
+	for _, b := range m.Challenge {
+		if b != 0 {
+			return nil
+		}
+	}
+	return errors.New("RandomOTSendRound1Message: challenge is 0")
+}
+
 // Round1 executes the sender's side of round 1 for a Random OT.
 func (r *RandomOTSender) Round1(msg *RandomOTReceiveRound1Message) (outMsg RandomOTSendRound1Message, err error) {
 	// We can compute the two random pads:
@@ -265,6 +374,9 @@ func (r *RandomOTSender) Round1(msg *RandomOTReceiveRound1Message) (outMsg Rando
 	if err = _A.UnmarshalBinary(msg.ABytes); err != nil {
 		return
 	}
+	if err = r.group.ValidatePoint(_A); err != nil {
+		return
+	}
 	bA := r.b.Act(_A)
 
 	r.hash.Reset()
@@ -337,3 +449,28 @@ func (r *RandomOTSender) Round2(msg *RandomOTReceiveRound2Message) (outMsg Rando
 
 	return
 }
+
+// expandPad stretches a single random pad into outLen bytes, using blake3's XOF.
+func expandPad(pad [params.OTBytes]byte, outLen int) []byte {
+	h := blake3.NewDeriveKey("multi-party-sig random OT expansion")
+	_, _ = h.Write(pad[:])
+	out := make([]byte, outLen)
+	_, _ = h.Digest().Read(out)
+	return out
+}
+
+// ExpandResult stretches both of the sender's random pads into outLen bytes each.
+//
+// This avoids having to run multiple OTs when more randomness is needed.
+// The receiver should use ExpandChoice on its own pad, which will match the
+// expansion of the pad corresponding to its choice.
+func ExpandResult(res RandomOTSendResult, outLen int) (out0, out1 []byte) {
+	return expandPad(res.Rand0, outLen), expandPad(res.Rand1, outLen)
+}
+
+// ExpandChoice stretches the receiver's random pad into outLen bytes.
+//
+// This is the receiver's counterpart to ExpandResult.
+func ExpandChoice(randChoice [params.OTBytes]byte, outLen int) []byte {
+	return expandPad(randChoice, outLen)
+}
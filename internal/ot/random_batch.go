@@ -0,0 +1,129 @@
+package ot
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/internal/merkle"
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/pool"
+)
+
+// RandomOTSenderBatch holds the sender's state for a batch of Random OTs sharing a single setup.
+//
+// The instances are processed in parallel, but all the messages and results
+// are always ordered by the index of the instance, regardless of the order in
+// which they completed.
+type RandomOTSenderBatch struct {
+	pl      *pool.Pool
+	senders []RandomOTSender
+	// decommitments and tree are set by Round2Committed, and used to open single instances.
+	decommitments []RandomOTSendRound2Message
+	tree          *merkle.Tree
+	// instanceHook is called with the index of each instance before it is processed.
+	//
+	// This is only set by tests, to make instances complete out of order.
+	instanceHook func(i int)
+}
+
+// NewRandomOTSenderBatch sets up the sender's state for count Random OTs.
+//
+// The nonce for each instance is derived from hash and its index with RandomOTNonce,
+// so hash should be the same hash the receiver uses with NewRandomOTReceiverBatch.
+// It must be specific to the session: reusing it for another session, or another batch with the same setup,
+// repeats the pads of the first batch.
+func NewRandomOTSenderBatch(pl *pool.Pool, hash *hash.Hash, setup *RandomOTSendSetup, count int) *RandomOTSenderBatch {
+	nonces := batchNonces(hash, count)
+	senders := make([]RandomOTSender, count)
+	for i := range senders {
+		senders[i] = NewRandomOTSender(hash, nonces[i], setup)
+	}
+	return &RandomOTSenderBatch{pl: pl, senders: senders}
+}
+
+// Round1 executes the sender's side of round 1 for every instance in the batch.
+//
+// msgs[i] should be the message of the ith receiver, and the ith output message is meant for it.
+func (b *RandomOTSenderBatch) Round1(msgs []RandomOTReceiveRound1Message) ([]RandomOTSendRound1Message, error) {
+	if len(msgs) != len(b.senders) {
+		return nil, fmt.Errorf("RandomOTSenderBatch Round1: expected %d messages, got %d", len(b.senders), len(msgs))
+	}
+	outMsgs := make([]RandomOTSendRound1Message, len(b.senders))
+	errs := b.pl.Parallelize(len(b.senders), func(i int) interface{} {
+		if b.instanceHook != nil {
+			b.instanceHook(i)
+		}
+		var err error
+		outMsgs[i], err = b.senders[i].Round1(&msgs[i])
+		return err
+	})
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("RandomOTSenderBatch Round1: instance %d: %w", i, err.(error))
+		}
+	}
+	return outMsgs, nil
+}
+
+// Round2 executes the sender's side of round 2 for every instance in the batch.
+//
+// The ith result always belongs to the ith instance.
+func (b *RandomOTSenderBatch) Round2(msgs []RandomOTReceiveRound2Message) ([]RandomOTSendRound2Message, []RandomOTSendResult, error) {
+	if len(msgs) != len(b.senders) {
+		return nil, nil, fmt.Errorf("RandomOTSenderBatch Round2: expected %d messages, got %d", len(b.senders), len(msgs))
+	}
+	outMsgs := make([]RandomOTSendRound2Message, len(b.senders))
+	results := make([]RandomOTSendResult, len(b.senders))
+	errs := b.pl.Parallelize(len(b.senders), func(i int) interface{} {
+		if b.instanceHook != nil {
+			b.instanceHook(i)
+		}
+		var err error
+		outMsgs[i], results[i], err = b.senders[i].Round2(&msgs[i])
+		return err
+	})
+	for i, err := range errs {
+		if err != nil {
+			return nil, nil, fmt.Errorf("RandomOTSenderBatch Round2: instance %d: %w", i, err.(error))
+		}
+	}
+	return outMsgs, results, nil
+}
+
+// NewRandomOTReceiverBatch sets up one receiver for each of the choices, using the same nonces as NewRandomOTSenderBatch.
+//
+// As there, hash must never be reused for another batch.
+func NewRandomOTReceiverBatch(hash *hash.Hash, setup *RandomOTReceiveSetup, choices []bool) []RandomOTReceiever {
+	nonces := batchNonces(hash, len(choices))
+	receivers := make([]RandomOTReceiever, len(choices))
+	for i, choice := range choices {
+		receivers[i] = NewRandomOTReceiverBool(hash, nonces[i], setup, choice)
+	}
+	return receivers
+}
+
+// RandomOTNonce derives the nonce of the Random OT with the given counter in a session.
+//
+// The nonces of a session are all distinct, so callers only need to give each of its OTs a different counter.
+// They are only as unique as the session hash: it must commit to the session's ID and transcript,
+// and the same session hash must never be used for two different sessions,
+// since their OTs would then produce the same pads.
+func RandomOTNonce(session *hash.Hash, counter uint64) []byte {
+	counterBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(counterBytes, counter)
+	nonce := make([]byte, 32)
+	_, _ = session.Fork(&hash.BytesWithDomain{
+		TheDomain: "Random OT Nonce",
+		Bytes:     counterBytes,
+	}).Digest().Read(nonce)
+	return nonce
+}
+
+// batchNonces derives a distinct 32 byte nonce for each instance in a batch, using its index as a counter.
+func batchNonces(h *hash.Hash, count int) [][]byte {
+	nonces := make([][]byte, count)
+	for i := range nonces {
+		nonces[i] = RandomOTNonce(h, uint64(i))
+	}
+	return nonces
+}
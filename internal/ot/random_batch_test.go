@@ -0,0 +1,154 @@
+package ot
+
+import (
+	"bytes"
+	"crypto/rand"
+	mrand "math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/koteld/multi-party-sig/internal/params"
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/pool"
+)
+
+func TestRandomOTBatchOrdering(t *testing.T) {
+	const count = 8
+
+	// Later instances wait less, so that they complete first.
+	var mu sync.Mutex
+	var order []int
+	hook := func(i int) {
+		time.Sleep(time.Duration(count-i) * 5 * time.Millisecond)
+		mu.Lock()
+		order = append(order, i)
+		mu.Unlock()
+	}
+
+	pl := pool.NewPool(count)
+	defer pl.TearDown()
+
+	h := hash.New()
+	msgS0, setupS := RandomOTSetupSend(rand.Reader, h.Clone(), testGroup)
+	setupR, err := RandomOTSetupReceive(h.Clone(), testGroup, msgS0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	choices := make([]bool, count)
+	for i := range choices {
+		choices[i] = i%3 == 0
+	}
+	receivers := NewRandomOTReceiverBatch(h, setupR, choices)
+	sender := NewRandomOTSenderBatch(pl, h, setupS, count)
+	sender.instanceHook = hook
+
+	msgsR1 := make([]RandomOTReceiveRound1Message, count)
+	for i := range receivers {
+		if msgsR1[i], err = receivers[i].Round1(rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgsS1, err := sender.Round1(msgsR1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgsR2 := make([]RandomOTReceiveRound2Message, count)
+	for i := range receivers {
+		msgsR2[i] = receivers[i].Round2(&msgsS1[i])
+	}
+	msgsS2, results, err := sender.Round2(msgsR2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inOrder := true
+	for i := range order {
+		if order[i] != i%count {
+			inOrder = false
+		}
+	}
+	if inOrder {
+		t.Fatal("instances completed in order, the test isn't exercising anything")
+	}
+
+	for i := range receivers {
+		randChoice, err := receivers[i].Round3(&msgsS2[i])
+		if err != nil {
+			t.Fatalf("instance %d: %v", i, err)
+		}
+		expected := results[i].Rand0
+		if choices[i] {
+			expected = results[i].Rand1
+		}
+		if !bytes.Equal(expected[:], randChoice[:]) {
+			t.Errorf("result %d doesn't belong to instance %d", i, i)
+		}
+	}
+
+	if _, err := sender.Round1(msgsR1[1:]); err == nil {
+		t.Error("a batch with missing messages was accepted")
+	}
+}
+
+func TestRandomOTBatchNonces(t *testing.T) {
+	const count = 4
+
+	h := hash.New()
+	msgS0, setupS := RandomOTSetupSend(rand.Reader, h.Clone(), testGroup)
+	setupR, err := RandomOTSetupReceive(h.Clone(), testGroup, msgS0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run samples the receiver's randomness from the same seed for every instance,
+	// so that the pads only depend on the nonces.
+	run := func(session *hash.Hash) []RandomOTSendResult {
+		receivers := NewRandomOTReceiverBatch(session, setupR, make([]bool, count))
+		sender := NewRandomOTSenderBatch(nil, session, setupS, count)
+		msgsR1 := make([]RandomOTReceiveRound1Message, count)
+		for i := range receivers {
+			if msgsR1[i], err = receivers[i].Round1(mrand.New(mrand.NewSource(1))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		msgsS1, err := sender.Round1(msgsR1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgsR2 := make([]RandomOTReceiveRound2Message, count)
+		for i := range receivers {
+			msgsR2[i] = receivers[i].Round2(&msgsS1[i])
+		}
+		_, results, err := sender.Round2(msgsR2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	session := func(id string) *hash.Hash {
+		return hash.New(&hash.BytesWithDomain{TheDomain: "Session ID", Bytes: []byte(id)})
+	}
+	results := run(session("first"))
+	seen := make(map[[params.OTBytes]byte]bool)
+	for i, result := range results {
+		if seen[result.Rand0] {
+			t.Errorf("instance %d has the same pad as a previous instance", i)
+		}
+		seen[result.Rand0] = true
+	}
+	for i, result := range run(session("second")) {
+		if seen[result.Rand0] {
+			t.Errorf("instance %d of another session has the same pad as the first session", i)
+		}
+	}
+	// which is why a session hash must not be reused
+	if again := run(session("first")); again[0].Rand0 != results[0].Rand0 {
+		t.Error("the same session should derive the same nonces")
+	}
+
+	if bytes.Equal(RandomOTNonce(session("first"), 0), RandomOTNonce(session("first"), 1)) {
+		t.Error("different counters should give different nonces")
+	}
+}
@@ -0,0 +1,119 @@
+package ot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/internal/merkle"
+	"github.com/koteld/multi-party-sig/internal/params"
+	"github.com/koteld/multi-party-sig/pkg/hash"
+)
+
+// RandomOTSendBatchCommitment replaces the RandomOTSendRound2Message of every instance of a batch.
+//
+// Instead of sending all the decommitments, the sender commits to them with a Merkle tree,
+// and only opens the instances a verifier asks for, see RandomOTSenderBatch.Open.
+type RandomOTSendBatchCommitment struct {
+	// Root commits to the decommitments of each instance, and to their number.
+	Root []byte
+}
+
+// RandomOTDecommitmentProof opens the decommitments of a single instance of a committed batch.
+type RandomOTDecommitmentProof struct {
+	// Index is the position of the instance in the batch.
+	Index int
+	// Size is the number of instances in the batch.
+	Size int
+	// Decommitment is what the sender would have sent for this instance in round 2.
+	Decommitment RandomOTSendRound2Message
+	// Siblings are the nodes needed to recompute the root from the instance's leaf, starting from the bottom.
+	Siblings [][]byte
+}
+
+// Round2Committed is like Round2, but commits to the decommitments of all the instances,
+// instead of returning them.
+func (b *RandomOTSenderBatch) Round2Committed(msgs []RandomOTReceiveRound2Message) (*RandomOTSendBatchCommitment, []RandomOTSendResult, error) {
+	decommitments, results, err := b.Round2(msgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(decommitments) == 0 {
+		return nil, nil, errors.New("RandomOTSenderBatch Round2: empty batch")
+	}
+
+	leaves := make([][]byte, len(decommitments))
+	for i := range decommitments {
+		leaves[i] = decommitmentLeaf(&decommitments[i])
+	}
+	tree, err := merkle.New(decommitmentDomain, leaves)
+	if err != nil {
+		return nil, nil, fmt.Errorf("RandomOTSenderBatch Round2: %w", err)
+	}
+	b.decommitments = decommitments
+	b.tree = tree
+	return &RandomOTSendBatchCommitment{Root: tree.Root()}, results, nil
+}
+
+// Open returns the decommitments of the instance at index, along with a proof that they match the commitment.
+func (b *RandomOTSenderBatch) Open(index int) (*RandomOTDecommitmentProof, error) {
+	if b.tree == nil {
+		return nil, errors.New("RandomOTSenderBatch Open: the batch hasn't been committed")
+	}
+	siblings, err := b.tree.Siblings(index)
+	if err != nil {
+		return nil, fmt.Errorf("RandomOTSenderBatch Open: %w", err)
+	}
+	return &RandomOTDecommitmentProof{
+		Index:        index,
+		Size:         len(b.decommitments),
+		Decommitment: b.decommitments[index],
+		Siblings:     siblings,
+	}, nil
+}
+
+// VerifyRandomOTDecommitment checks that proof opens an instance of the batch with the given commitment.
+//
+// This only shows that the sender committed to these decommitments,
+// Round3Committed also checks them against the receiver's challenge.
+func VerifyRandomOTDecommitment(commitment *RandomOTSendBatchCommitment, proof *RandomOTDecommitmentProof) error {
+	if commitment == nil || proof == nil || proof.Index < 0 || proof.Index >= proof.Size {
+		return errors.New("random OT decommitment: invalid proof")
+	}
+	root, err := merkle.Root(decommitmentDomain, decommitmentLeaf(&proof.Decommitment), proof.Index, proof.Size, proof.Siblings)
+	if err != nil {
+		return fmt.Errorf("random OT decommitment: %w", err)
+	}
+	if !bytes.Equal(root, commitment.Root) {
+		return errors.New("random OT decommitment: doesn't match the commitment")
+	}
+	return nil
+}
+
+// Round3Committed is like Round3, for an instance of a committed batch which was opened by the sender.
+//
+// proof must be for the same index as this receiver.
+func (r *RandomOTReceiever) Round3Committed(commitment *RandomOTSendBatchCommitment, proof *RandomOTDecommitmentProof) ([params.OTBytes]byte, error) {
+	if err := VerifyRandomOTDecommitment(commitment, proof); err != nil {
+		return r.randChoice, fmt.Errorf("RandomOTReceive Round 3: %w", err)
+	}
+	return r.Round3(&proof.Decommitment)
+}
+
+// UnverifiedResult returns the random message received in Round1, without checking the sender's decommitments.
+//
+// This is meant for the instances of a committed batch which aren't audited,
+// and so rely on the sender having been caught cheating in the audited ones with good probability.
+func (r *RandomOTReceiever) UnverifiedResult() [params.OTBytes]byte {
+	return r.randChoice
+}
+
+// decommitmentDomain separates the Merkle tree of a committed batch from other trees.
+const decommitmentDomain = "Random OT Decommitment"
+
+func decommitmentLeaf(msg *RandomOTSendRound2Message) []byte {
+	return hash.New(&hash.BytesWithDomain{
+		TheDomain: "Random OT Decommitment Leaf",
+		Bytes:     append(append([]byte(nil), msg.Decommit0[:]...), msg.Decommit1[:]...),
+	}).Sum()
+}
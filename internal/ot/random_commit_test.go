@@ -0,0 +1,103 @@
+package ot
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/pkg/hash"
+)
+
+func TestRandomOTBatchCommitted(t *testing.T) {
+	const count = 11
+
+	h := hash.New()
+	msgS0, setupS := RandomOTSetupSend(rand.Reader, h.Clone(), testGroup)
+	setupR, err := RandomOTSetupReceive(h.Clone(), testGroup, msgS0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	choices := make([]bool, count)
+	for i := range choices {
+		choices[i] = i%2 == 0
+	}
+	receivers := NewRandomOTReceiverBatch(h, setupR, choices)
+	sender := NewRandomOTSenderBatch(nil, h, setupS, count)
+
+	if _, err = sender.Open(0); err == nil {
+		t.Error("opening before committing should fail")
+	}
+
+	msgsR1 := make([]RandomOTReceiveRound1Message, count)
+	for i := range receivers {
+		if msgsR1[i], err = receivers[i].Round1(rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgsS1, err := sender.Round1(msgsR1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgsR2 := make([]RandomOTReceiveRound2Message, count)
+	for i := range receivers {
+		msgsR2[i] = receivers[i].Round2(&msgsS1[i])
+	}
+	commitment, results, err := sender.Round2Committed(msgsR2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	audited := map[int]bool{0: true, 5: true, count - 1: true}
+	for i := range receivers {
+		expected := results[i].Rand0
+		if choices[i] {
+			expected = results[i].Rand1
+		}
+		randChoice := receivers[i].UnverifiedResult()
+		if audited[i] {
+			proof, err := sender.Open(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if randChoice, err = receivers[i].Round3Committed(commitment, proof); err != nil {
+				t.Fatalf("instance %d: %v", i, err)
+			}
+		}
+		if randChoice != expected {
+			t.Errorf("instance %d: received the wrong message", i)
+		}
+	}
+
+	if _, err = sender.Open(count); err == nil {
+		t.Error("opening an index out of range should fail")
+	}
+
+	proof, err := sender.Open(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := *proof
+	tampered.Decommitment.Decommit0[0] ^= 1
+	if err = VerifyRandomOTDecommitment(commitment, &tampered); err == nil {
+		t.Error("a tampered leaf should be caught")
+	}
+	tampered = *proof
+	tampered.Index = 4
+	if err = VerifyRandomOTDecommitment(commitment, &tampered); err == nil {
+		t.Error("a leaf moved to another index should be caught")
+	}
+	tampered = *proof
+	tampered.Siblings = tampered.Siblings[1:]
+	if err = VerifyRandomOTDecommitment(commitment, &tampered); err == nil {
+		t.Error("a missing sibling should be caught")
+	}
+	tampered = *proof
+	tampered.Size = count + 1
+	if err = VerifyRandomOTDecommitment(commitment, &tampered); err == nil {
+		t.Error("a proof for another batch size should be caught")
+	}
+
+	// a valid opening of another instance doesn't match this receiver's challenge
+	if _, err = receivers[4].Round3Committed(commitment, proof); err == nil {
+		t.Error("the decommitments of another instance should be rejected")
+	}
+}
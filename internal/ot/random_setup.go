@@ -0,0 +1,139 @@
+package ot
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+)
+
+// randomOTSetupSendMessage has the same fields as RandomOTSetupSendMessage, without its marshalling methods.
+type randomOTSetupSendMessage RandomOTSetupSendMessage
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (m *RandomOTSetupSendMessage) MarshalBinary() ([]byte, error) {
+	return cbor.Marshal((*randomOTSetupSendMessage)(m))
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+//
+// m must have been created with EmptyRandomOTSetupSendMessage.
+// The message still needs to be checked by RandomOTSetupReceive.
+func (m *RandomOTSetupSendMessage) UnmarshalBinary(data []byte) error {
+	if m.B == nil || m.BProof == nil {
+		return errors.New("RandomOTSetupSendMessage.UnmarshalBinary called without setting a group")
+	}
+	return cbor.Unmarshal(data, (*randomOTSetupSendMessage)(m))
+}
+
+// EmptyRandomOTSendSetup creates a RandomOTSendSetup with a fixed group, ready to be unmarshalled.
+func EmptyRandomOTSendSetup(group curve.Curve) *RandomOTSendSetup {
+	return &RandomOTSendSetup{b: group.NewScalar()}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The result contains the secret key b of the sender, and must be stored as securely as the setup itself.
+// It should never be sent to the receiver, which only needs the RandomOTSetupSendMessage.
+func (r *RandomOTSendSetup) MarshalBinary() ([]byte, error) {
+	return r.b.MarshalBinary()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+//
+// r must have been created with EmptyRandomOTSendSetup.
+func (r *RandomOTSendSetup) UnmarshalBinary(data []byte) error {
+	if r.b == nil {
+		return errors.New("RandomOTSendSetup.UnmarshalBinary called without setting a group")
+	}
+	b := r.b.Curve().NewScalar()
+	if err := b.UnmarshalBinary(data); err != nil {
+		return fmt.Errorf("RandomOTSendSetup: %w", err)
+	}
+	if b.IsZero() {
+		return errors.New("RandomOTSendSetup: secret key is zero")
+	}
+	B := b.ActOnBase()
+	r.b, r._B, r._bB = b, B, b.Act(B)
+	return nil
+}
+
+// EmptyRandomOTReceiveSetup creates a RandomOTReceiveSetup with a fixed group, ready to be unmarshalled.
+func EmptyRandomOTReceiveSetup(group curve.Curve) *RandomOTReceiveSetup {
+	return &RandomOTReceiveSetup{_B: group.NewPoint()}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The receiver's setup only contains the public key of the sender.
+func (r *RandomOTReceiveSetup) MarshalBinary() ([]byte, error) {
+	return r._B.MarshalBinary()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+//
+// r must have been created with EmptyRandomOTReceiveSetup.
+func (r *RandomOTReceiveSetup) UnmarshalBinary(data []byte) error {
+	if r._B == nil {
+		return errors.New("RandomOTReceiveSetup.UnmarshalBinary called without setting a group")
+	}
+	group := r._B.Curve()
+	B := group.NewPoint()
+	if err := B.UnmarshalBinary(data); err != nil {
+		return fmt.Errorf("RandomOTReceiveSetup: %w", err)
+	}
+	if err := group.ValidatePoint(B); err != nil {
+		return fmt.Errorf("RandomOTReceiveSetup: %w", err)
+	}
+	if B.IsIdentity() {
+		return errors.New("RandomOTReceiveSetup: public key is identity")
+	}
+	r._B = B
+	return nil
+}
+
+// SelfCheck runs a Random OT locally, playing both roles, for each choice bit,
+// and checks that the receiver gets the right pad.
+//
+// recvSetup should be the setup the receiver obtained from this sender.
+// An error means that one of the setups is corrupted, and a new setup needs to be run.
+func (setup *RandomOTSendSetup) SelfCheck(recvSetup *RandomOTReceiveSetup) error {
+	ctxHash := hash.New(&hash.BytesWithDomain{TheDomain: "Random OT SelfCheck"})
+	for _, choice := range []bool{false, true} {
+		// each OT with the same setup needs its own nonce
+		nonce := make([]byte, 32)
+		if _, err := rand.Read(nonce); err != nil {
+			return fmt.Errorf("ot: self check: %w", err)
+		}
+		receiver := NewRandomOTReceiverBool(ctxHash, nonce, recvSetup, choice)
+		sender := NewRandomOTSender(ctxHash, nonce, setup)
+		msgR1, err := receiver.Round1(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("ot: self check: %w", err)
+		}
+		msgS1, err := sender.Round1(&msgR1)
+		if err != nil {
+			return fmt.Errorf("ot: self check: %w", err)
+		}
+		msgR2 := receiver.Round2(&msgS1)
+		msgS2, resultS, err := sender.Round2(&msgR2)
+		if err != nil {
+			return fmt.Errorf("ot: self check: %w", err)
+		}
+		resultR, err := receiver.Round3(&msgS2)
+		if err != nil {
+			return fmt.Errorf("ot: self check: %w", err)
+		}
+		expected := resultS.Rand0
+		if choice {
+			expected = resultS.Rand1
+		}
+		if resultR != expected || resultS.Rand0 == resultS.Rand1 {
+			return fmt.Errorf("ot: self check: receiver got the wrong pad for choice %t", choice)
+		}
+	}
+	return nil
+}
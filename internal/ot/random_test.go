@@ -2,12 +2,18 @@ package ot
 
 import (
 	"bytes"
+	"crypto/rand"
+	"io"
+	mrand "math/rand"
 	"testing"
 	"testing/quick"
 
 	"github.com/cronokirby/safenum"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/koteld/multi-party-sig/internal/params"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
 )
 
 var testGroup = curve.Secp256k1{}
@@ -15,19 +21,15 @@ var testGroup = curve.Secp256k1{}
 func runRandomOT(choice bool, hash *hash.Hash) (*RandomOTSendResult, []byte, error) {
 	nonce := make([]byte, 32)
 	_, _ = hash.Digest().Read(nonce)
-	safeChoice := safenum.Choice(0)
-	if choice {
-		safeChoice = 1
-	}
-	msgS0, setupS := RandomOTSetupSend(hash.Clone(), testGroup)
-	setupR, err := RandomOTSetupReceive(hash.Clone(), msgS0)
+	msgS0, setupS := RandomOTSetupSend(rand.Reader, hash.Clone(), testGroup)
+	setupR, err := RandomOTSetupReceive(hash.Clone(), testGroup, msgS0)
 	if err != nil {
 		return nil, nil, err
 	}
-	receiver := NewRandomOTReceiver(nonce, setupR, safeChoice)
-	sender := NewRandomOTSender(nonce, setupS)
+	receiver := NewRandomOTReceiverBool(hash, nonce, setupR, choice)
+	sender := NewRandomOTSender(hash, nonce, setupS)
 
-	msgR1, err := receiver.Round1()
+	msgR1, err := receiver.Round1(rand.Reader)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -72,8 +74,445 @@ func TestRandomOT(t *testing.T) {
 	}
 }
 
+func TestChoiceFromBool(t *testing.T) {
+	if ChoiceFromBool(true) != 1 || ChoiceFromBool(false) != 0 {
+		t.Error("ChoiceFromBool gave the wrong choice")
+	}
+	if !BoolFromChoice(ChoiceFromBool(true)) || BoolFromChoice(ChoiceFromBool(false)) {
+		t.Error("BoolFromChoice isn't the inverse of ChoiceFromBool")
+	}
+	// Choosing true should select Rand1
+	result, randChoice, err := runRandomOT(true, hash.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result.Rand1[:], randChoice) {
+		t.Error("choosing true didn't select Rand1")
+	}
+}
+
+func TestConstantTimeSelectBytes(t *testing.T) {
+	a := []byte{0x00, 0x0F, 0xF0, 0xFF}
+	b := []byte{0xFF, 0xAA, 0x55, 0x00}
+
+	dst := make([]byte, 4)
+	constantTimeSelectBytes(dst, a, b, 0)
+	if !bytes.Equal(dst, a) {
+		t.Errorf("choose = 0: got %x, expected %x", dst, a)
+	}
+	constantTimeSelectBytes(dst, a, b, 1)
+	if !bytes.Equal(dst, b) {
+		t.Errorf("choose = 1: got %x, expected %x", dst, b)
+	}
+
+	// only the common prefix is written
+	for _, choose := range []safenum.Choice{0, 1} {
+		expected := [][]byte{a, b}[choose]
+		dst = []byte{0x11, 0x11, 0x11, 0x11, 0x11}
+		constantTimeSelectBytes(dst, a[:3], b, choose)
+		if !bytes.Equal(dst, append(append([]byte{}, expected[:3]...), 0x11, 0x11)) {
+			t.Errorf("short a, choose = %d: got %x", choose, dst)
+		}
+		dst = []byte{0x11, 0x11, 0x11, 0x11, 0x11}
+		constantTimeSelectBytes(dst, a, b[:2], choose)
+		if !bytes.Equal(dst, append(append([]byte{}, expected[:2]...), 0x11, 0x11, 0x11)) {
+			t.Errorf("short b, choose = %d: got %x", choose, dst)
+		}
+		dst = make([]byte, 2)
+		constantTimeSelectBytes(dst, a, b, choose)
+		if !bytes.Equal(dst, expected[:2]) {
+			t.Errorf("short dst, choose = %d: got %x", choose, dst)
+		}
+	}
+
+	// dst may alias a
+	dst = append([]byte{}, a...)
+	constantTimeSelectBytes(dst, dst, b, 1)
+	if !bytes.Equal(dst, b) {
+		t.Errorf("aliased: got %x, expected %x", dst, b)
+	}
+}
+
+func TestRandomOTSetupReceiveExpecting(t *testing.T) {
+	hash := hash.New()
+	msg, _ := RandomOTSetupSend(rand.Reader, hash.Clone(), testGroup)
+	if _, err := RandomOTSetupReceiveExpecting(hash.Clone(), msg, msg.B); err != nil {
+		t.Error(err)
+	}
+	otherMsg, _ := RandomOTSetupSend(rand.Reader, hash.Clone(), testGroup)
+	if _, err := RandomOTSetupReceiveExpecting(hash.Clone(), otherMsg, msg.B); err == nil {
+		t.Error("a setup with a mismatched B was accepted")
+	}
+	if _, err := RandomOTSetupReceiveExpecting(hash.Clone(), nil, msg.B); err == nil {
+		t.Error("a missing setup was accepted")
+	}
+	if _, err := RandomOTSetupReceiveExpecting(hash.Clone(), &RandomOTSetupSendMessage{BProof: msg.BProof}, msg.B); err == nil {
+		t.Error("a setup without B was accepted")
+	}
+	if _, err := RandomOTSetupReceiveExpecting(hash.Clone(), msg, nil); err == nil {
+		t.Error("a setup without an expected B was accepted")
+	}
+}
+
+func TestRandomOTSetupProverBinding(t *testing.T) {
+	hashFor := func(id party.ID) *hash.Hash {
+		h := hash.New()
+		_ = h.WriteAny(id)
+		return h
+	}
+	msg, _ := RandomOTSetupSend(rand.Reader, hashFor("a"), testGroup)
+	if _, err := RandomOTSetupReceive(hashFor("a"), testGroup, msg); err != nil {
+		t.Error(err)
+	}
+	if _, err := RandomOTSetupReceive(hashFor("b"), testGroup, msg); err == nil {
+		t.Error("a setup made by a was accepted as coming from b")
+	}
+}
+
+func testExpandResult(choice bool, init []byte, outLen uint16) bool {
+	hash := hash.New()
+	_ = hash.WriteAny(init)
+	result, randChoice, err := runRandomOT(choice, hash)
+	if err != nil {
+		return false
+	}
+	var pad [params.OTBytes]byte
+	copy(pad[:], randChoice)
+	out0, out1 := ExpandResult(*result, int(outLen))
+	outChoice := ExpandChoice(pad, int(outLen))
+	if len(out0) != int(outLen) || len(out1) != int(outLen) {
+		return false
+	}
+	if outLen > 0 && bytes.Equal(out0, out1) {
+		return false
+	}
+	if choice {
+		return bytes.Equal(out1, outChoice)
+	} else {
+		return bytes.Equal(out0, outChoice)
+	}
+}
+
+func TestExpandResult(t *testing.T) {
+	err := quick.Check(testExpandResult, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// runRandomOTTranscript runs a Random OT with all randomness drawn from source, returning the messages exchanged.
+func runRandomOTTranscript(source io.Reader) ([]byte, error) {
+	hash := hash.New()
+	nonce := make([]byte, 32)
+	msgS0, setupS := RandomOTSetupSend(source, hash.Clone(), testGroup)
+	setupR, err := RandomOTSetupReceive(hash.Clone(), testGroup, msgS0)
+	if err != nil {
+		return nil, err
+	}
+	receiver := NewRandomOTReceiver(hash, nonce, setupR, 1)
+	sender := NewRandomOTSender(hash, nonce, setupS)
+
+	msgR1, err := receiver.Round1(source)
+	if err != nil {
+		return nil, err
+	}
+	msgS1, err := sender.Round1(&msgR1)
+	if err != nil {
+		return nil, err
+	}
+	msgR2 := receiver.Round2(&msgS1)
+	msgS2, _, err := sender.Round2(&msgR2)
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal([]interface{}{msgS0, msgR1, msgS1, msgR2, msgS2})
+}
+
+func TestRandomOTReproducible(t *testing.T) {
+	transcript1, err := runRandomOTTranscript(mrand.New(mrand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcript2, err := runRandomOTTranscript(mrand.New(mrand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(transcript1, transcript2) {
+		t.Error("transcripts with the same source differ")
+	}
+	transcript3, err := runRandomOTTranscript(mrand.New(mrand.NewSource(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(transcript1, transcript3) {
+		t.Error("transcripts with different sources are the same")
+	}
+}
+
 func BenchmarkRandomOT(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		runRandomOT(true, hash.New())
 	}
 }
+
+// runRandomOTWithContext runs a Random OT over an existing setup, with a fixed nonce and receiver randomness.
+func runRandomOTWithContext(ctxHash *hash.Hash, setupS *RandomOTSendSetup, setupR *RandomOTReceiveSetup) (RandomOTSendResult, error) {
+	nonce := make([]byte, 32)
+	receiver := NewRandomOTReceiver(ctxHash.Clone(), nonce, setupR, 0)
+	sender := NewRandomOTSender(ctxHash.Clone(), nonce, setupS)
+	msgR1, err := receiver.Round1(mrand.New(mrand.NewSource(1)))
+	if err != nil {
+		return RandomOTSendResult{}, err
+	}
+	msgS1, err := sender.Round1(&msgR1)
+	if err != nil {
+		return RandomOTSendResult{}, err
+	}
+	msgR2 := receiver.Round2(&msgS1)
+	msgS2, result, err := sender.Round2(&msgR2)
+	if err != nil {
+		return RandomOTSendResult{}, err
+	}
+	if _, err = receiver.Round3(&msgS2); err != nil {
+		return RandomOTSendResult{}, err
+	}
+	return result, nil
+}
+
+func TestRandomOTSessionBinding(t *testing.T) {
+	msgS0, setupS := RandomOTSetupSend(rand.Reader, hash.New(), testGroup)
+	setupR, err := RandomOTSetupReceive(hash.New(), testGroup, msgS0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session1 := hash.New(&hash.BytesWithDomain{TheDomain: "Session", Bytes: []byte{1}})
+	session2 := hash.New(&hash.BytesWithDomain{TheDomain: "Session", Bytes: []byte{2}})
+
+	result1, err := runRandomOTWithContext(session1, setupS, setupR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := runRandomOTWithContext(session1, setupS, setupR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != result1 {
+		t.Error("the same session and nonce should give the same pads")
+	}
+
+	result2, err := runRandomOTWithContext(session2, setupS, setupR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(result1.Rand0[:], result2.Rand0[:]) || bytes.Equal(result1.Rand1[:], result2.Rand1[:]) {
+		t.Error("the same nonce in different sessions should give different pads")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("a Random OT without a session was set up")
+		}
+	}()
+	NewRandomOTSender(nil, make([]byte, 32), setupS)
+}
+
+func TestRandomOTSetupMarshal(t *testing.T) {
+	ctxHash := hash.New()
+	msg, setupS := RandomOTSetupSend(rand.Reader, ctxHash.Clone(), testGroup)
+
+	msgBytes, err := msg.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg2 := EmptyRandomOTSetupSendMessage(testGroup)
+	if err = msg2.UnmarshalBinary(msgBytes); err != nil {
+		t.Fatal(err)
+	}
+	setupR, err := RandomOTSetupReceive(ctxHash.Clone(), testGroup, msg2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setupSBytes, err := setupS.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupS2 := EmptyRandomOTSendSetup(testGroup)
+	if err = setupS2.UnmarshalBinary(setupSBytes); err != nil {
+		t.Fatal(err)
+	}
+	setupRBytes, err := setupR.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupR2 := EmptyRandomOTReceiveSetup(testGroup)
+	if err = setupR2.UnmarshalBinary(setupRBytes); err != nil {
+		t.Fatal(err)
+	}
+
+	// run the same OT with both setups, and the same randomness
+	run := func(setupS *RandomOTSendSetup, setupR *RandomOTReceiveSetup) (RandomOTSendResult, [params.OTBytes]byte) {
+		nonce := make([]byte, 32)
+		receiver := NewRandomOTReceiverBool(ctxHash, nonce, setupR, true)
+		sender := NewRandomOTSender(ctxHash, nonce, setupS)
+		msgR1, err := receiver.Round1(mrand.New(mrand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgS1, err := sender.Round1(&msgR1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgR2 := receiver.Round2(&msgS1)
+		msgS2, resultS, err := sender.Round2(&msgR2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resultR, err := receiver.Round3(&msgS2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resultS, resultR
+	}
+	resultS, resultR := run(setupS, setupR)
+	resultS2, resultR2 := run(setupS2, setupR2)
+	if resultS != resultS2 || resultR != resultR2 {
+		t.Error("unmarshalled setups should produce the same OT results")
+	}
+	if resultR != resultS.Rand1 {
+		t.Error("receiver should get the pad of its choice")
+	}
+
+	if err = EmptyRandomOTSendSetup(testGroup).UnmarshalBinary(make([]byte, 32)); err == nil {
+		t.Error("a zero secret key should be rejected")
+	}
+	if err = EmptyRandomOTReceiveSetup(testGroup).UnmarshalBinary(msgBytes); err == nil {
+		t.Error("unmarshalling a setup from the wrong data should fail")
+	}
+}
+
+func TestRandomOTSelfCheck(t *testing.T) {
+	ctxHash := hash.New()
+	msg, setupS := RandomOTSetupSend(rand.Reader, ctxHash.Clone(), testGroup)
+	setupR, err := RandomOTSetupReceive(ctxHash.Clone(), testGroup, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setupS.SelfCheck(setupR); err != nil {
+		t.Errorf("a valid setup should pass: %v", err)
+	}
+
+	corrupted := *setupS
+	corrupted.b = testGroup.NewScalar().Set(setupS.b).Add(testGroup.NewScalar().SetNat(new(safenum.Nat).SetUint64(1)))
+	if err = corrupted.SelfCheck(setupR); err == nil {
+		t.Error("a setup with a corrupted b should fail")
+	}
+	if err = setupS.SelfCheck(setupR); err != nil {
+		t.Errorf("the original setup should be unaffected: %v", err)
+	}
+}
+
+func TestRandomOTChallengeValidate(t *testing.T) {
+	h := hash.New()
+	nonce := make([]byte, 32)
+	msgS0, setupS := RandomOTSetupSend(rand.Reader, h.Clone(), testGroup)
+	setupR, err := RandomOTSetupReceive(h.Clone(), testGroup, msgS0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := NewRandomOTReceiverBool(h, nonce, setupR, true)
+	sender := NewRandomOTSender(h, nonce, setupS)
+	msgR1, err := receiver.Round1(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgS1, err := sender.Round1(&msgR1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = msgS1.Validate(); err != nil {
+		t.Error("honest challenge rejected:", err)
+	}
+
+	data, err := cbor.Marshal(&msgS1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded RandomOTSendRound1Message
+	if err = cbor.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != msgS1 {
+		t.Error("challenge changed by marshalling")
+	}
+
+	// a challenge of the wrong length would otherwise be padded or truncated
+	for _, l := range []int{0, params.OTBytes - 1, params.OTBytes + 1} {
+		data, err = cbor.Marshal(struct{ Challenge []byte }{bytes.Repeat([]byte{1}, l)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = cbor.Unmarshal(data, &decoded); err == nil {
+			t.Errorf("challenge of %d bytes accepted", l)
+		}
+	}
+
+	var zero RandomOTSendRound1Message
+	if err = zero.Validate(); err == nil {
+		t.Error("zero challenge accepted")
+	}
+	var setupMsg CorreOTSetupReceiveRound2Message
+	for i := range setupMsg.Msgs {
+		setupMsg.Msgs[i] = msgS1
+	}
+	if err = setupMsg.Validate(); err != nil {
+		t.Error("honest challenges rejected:", err)
+	}
+	setupMsg.Msgs[params.OTParam-1] = zero
+	if err = setupMsg.Validate(); err == nil {
+		t.Error("zero challenge accepted in correlated OT setup")
+	}
+}
+
+// p384 only reports the size of the P-384 group order, which is all params.OTBytesFor looks at.
+type p384 struct {
+	curve.Secp256k1
+}
+
+func (p384) Name() string    { return "P-384" }
+func (p384) ScalarBits() int { return 384 }
+
+func TestCheckGroup(t *testing.T) {
+	if err := checkGroup(testGroup); err != nil {
+		t.Error("secp256k1 rejected:", err)
+	}
+	if err := checkGroup(p384{}); err == nil {
+		t.Error("P-384 accepted with 128 bit OTs")
+	}
+}
+
+// otherGroup accepts any point, like a curve which the sender could pick instead of the one the receiver expects.
+type otherGroup struct {
+	curve.Secp256k1
+}
+
+func (otherGroup) ValidatePoint(curve.Point) error { return nil }
+
+type otherPoint struct {
+	*curve.Secp256k1Point
+}
+
+func (otherPoint) Curve() curve.Curve { return otherGroup{} }
+
+func TestRandomOTSetupReceiveGroup(t *testing.T) {
+	hash := hash.New()
+	msg, _ := RandomOTSetupSend(rand.Reader, hash.Clone(), testGroup)
+	if _, err := RandomOTSetupReceive(hash.Clone(), testGroup, msg); err != nil {
+		t.Error(err)
+	}
+	msg.B = otherPoint{msg.B.(*curve.Secp256k1Point)}
+	if _, err := RandomOTSetupReceive(hash.Clone(), testGroup, msg); err == nil {
+		t.Error("a public key from another group was accepted")
+	}
+}
@@ -0,0 +1,18 @@
+package params
+
+import "github.com/koteld/multi-party-sig/pkg/math/curve"
+
+// OTParamFor returns the security parameter of the Oblivious Transfers used with group, in bits.
+//
+// The best known attacks against the discrete logarithm in a group of order ≈ 2ⁿ take ≈ 2ⁿᐟ² steps,
+// so the OT security matches half the bit size of the group order, rounded up to a multiple of 64 bits.
+// This is OTParam for secp256k1.
+func OTParamFor(group curve.Curve) int {
+	bits := (group.ScalarBits() + 1) / 2
+	return (bits + 63) / 64 * 64
+}
+
+// OTBytesFor returns OTParamFor(group) in bytes.
+func OTBytesFor(group curve.Curve) int {
+	return OTParamFor(group) / 8
+}
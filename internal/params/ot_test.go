@@ -0,0 +1,31 @@
+package params_test
+
+import (
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/params"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+)
+
+// p384 only reports the size of the P-384 group order, which is all OTBytesFor looks at.
+type p384 struct {
+	curve.Secp256k1
+}
+
+func (p384) Name() string    { return "P-384" }
+func (p384) ScalarBits() int { return 384 }
+
+func TestOTBytesFor(t *testing.T) {
+	if got := params.OTBytesFor(curve.Secp256k1{}); got != params.OTBytes {
+		t.Errorf("OTBytesFor(secp256k1) = %d, expected %d", got, params.OTBytes)
+	}
+	if got := params.OTParamFor(curve.Secp256k1{}); got != params.OTParam {
+		t.Errorf("OTParamFor(secp256k1) = %d, expected %d", got, params.OTParam)
+	}
+	if got := params.OTBytesFor(p384{}); got <= params.OTBytes {
+		t.Errorf("OTBytesFor(P-384) = %d, expected more than %d", got, params.OTBytes)
+	}
+	if got := params.OTBytesFor(p384{}); got != 24 {
+		t.Errorf("OTBytesFor(P-384) = %d, expected 24", got)
+	}
+}
@@ -1,8 +1,10 @@
 package round
 
 import (
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"sync"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/pool"
+	"github.com/zeebo/blake3"
 )
 
 // Helper implements Session without Round, and can therefore be embedded in the first round of a protocol
@@ -31,6 +34,9 @@ type Helper struct {
 
 	hash *hash.Hash
 
+	// source is where the rounds sample their secret randomness, or nil for crypto/rand.
+	source io.Reader
+
 	mtx sync.Mutex
 }
 
@@ -129,6 +135,45 @@ func (h *Helper) HashForID(id party.ID) *hash.Hash {
 	return cloned
 }
 
+// Rand returns the source of the secret randomness sampled by the rounds of this session.
+//
+// It is crypto/rand, unless another source was set with SetRand.
+func (h *Helper) Rand() io.Reader {
+	if h.source == nil {
+		return rand.Reader
+	}
+	return h.source
+}
+
+// SetRand replaces the source of randomness of this session.
+//
+// It must be called before the first round is finalized, and source must be a cryptographically secure generator.
+// A deterministic source lets the session be executed again with the same secrets, for example to recover after a crash.
+func (h *Helper) SetRand(source io.Reader) {
+	h.source = source
+}
+
+// MinSeedSize is the minimum size of the seed given to SetSeed.
+const MinSeedSize = 32
+
+// SetSeed replaces the source of randomness of this session with one derived from seed, like SetRand.
+//
+// The source also depends on the SSID, so that the same seed used for another session still gives different secrets.
+func (h *Helper) SetSeed(seed []byte) error {
+	if len(seed) < MinSeedSize {
+		return fmt.Errorf("randomness seed must be at least %d bytes", MinSeedSize)
+	}
+	key := make([]byte, 32)
+	blake3.DeriveKey("multi-party-sig session randomness", seed, key)
+	hasher, err := blake3.NewKeyed(key)
+	if err != nil {
+		return err
+	}
+	_, _ = hasher.Write(h.ssid)
+	h.source = hasher.Digest()
+	return nil
+}
+
 // UpdateHashState writes additional data to the hash state.
 func (h *Helper) UpdateHashState(value hash.WriterToWithDomain) {
 	h.mtx.Lock()
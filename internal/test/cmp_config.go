@@ -1,7 +1,11 @@
 package test
 
 import (
+	"fmt"
 	"io"
+	"sync"
+
+	"github.com/cronokirby/safenum"
 
 	"github.com/koteld/multi-party-sig/internal/types"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
@@ -15,24 +19,75 @@ import (
 )
 
 // GenerateConfig creates some random configuration for N parties with set threshold T over the group.
+//
+// The configs are generated by a single dealer, and are marked with config.OriginDealer.
 func GenerateConfig(group curve.Curve, N, T int, source io.Reader, pl *pool.Pool) (map[party.ID]*config.Config, party.IDSlice) {
+	configs, partyIDs, err := generateConfig(group, N, T, source, func(int) (*paillier.SecretKey, error) {
+		return paillier.NewSecretKey(pl)
+	})
+	if err != nil {
+		panic(err)
+	}
+	return configs, partyIDs
+}
+
+// GenerateTestConfigs is like GenerateConfig, but reuses the Paillier keys sampled by previous calls
+// in the same process, which are the slow part of generating a config.
+//
+// The i-th party of every config gets the same Paillier key, so configs from different calls
+// shouldn't be used together, nor by tests which need fresh Paillier keys.
+// Each config gets its own copy of the key, so erasing it, as config.Config.Renounce does, doesn't affect other calls.
+func GenerateTestConfigs(group curve.Curve, t, n int, rand io.Reader) (map[party.ID]*config.Config, error) {
+	if !config.ValidThreshold(t, n) {
+		return nil, fmt.Errorf("test: threshold %d is invalid for %d parties", t, n)
+	}
+	configs, _, err := generateConfig(group, n, t, rand, cachedPaillierKey)
+	return configs, err
+}
+
+var paillierPrimes struct {
+	sync.Mutex
+	primes [][2][]byte
+}
+
+// cachedPaillierKey returns a copy of the i-th cached Paillier key, sampling the missing ones.
+func cachedPaillierKey(i int) (*paillier.SecretKey, error) {
+	paillierPrimes.Lock()
+	defer paillierPrimes.Unlock()
+	for len(paillierPrimes.primes) <= i {
+		sk, err := paillier.NewSecretKey(nil)
+		if err != nil {
+			return nil, err
+		}
+		paillierPrimes.primes = append(paillierPrimes.primes, [2][]byte{sk.P().Bytes(), sk.Q().Bytes()})
+	}
+	primes := paillierPrimes.primes[i]
+	p, q := new(safenum.Nat).SetBytes(primes[0]), new(safenum.Nat).SetBytes(primes[1])
+	return paillier.NewSecretKeyFromPrimes(p, q), nil
+}
+
+func generateConfig(group curve.Curve, N, T int, source io.Reader, newPaillier func(i int) (*paillier.SecretKey, error)) (map[party.ID]*config.Config, party.IDSlice, error) {
 	partyIDs := PartyIDs(N)
 	configs := make(map[party.ID]*config.Config, N)
 	public := make(map[party.ID]*config.Public, N)
 
-	f := polynomial.NewPolynomial(group, T, sample.Scalar(source, group))
+	f := polynomial.NewPolynomialFromSource(source, group, T, sample.Scalar(source, group))
+	commitments := polynomial.NewPolynomialExponent(f).Coefficients()
 
 	rid, err := types.NewRID(source)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 	chainKey, err := types.NewRID(source)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 
-	for _, pid := range partyIDs {
-		paillierSecret := paillier.NewSecretKey(pl)
+	for i, pid := range partyIDs {
+		paillierSecret, err := newPaillier(i)
+		if err != nil {
+			return nil, nil, err
+		}
 		s, t, _ := sample.Pedersen(source, paillierSecret.Phi(), paillierSecret.N())
 		pedersenPublic := pedersen.New(paillierSecret.Modulus(), s, t)
 		elGamalSecret := sample.Scalar(source, group)
@@ -48,6 +103,9 @@ func GenerateConfig(group curve.Curve, N, T int, source io.Reader, pl *pool.Pool
 			RID:       rid.Copy(),
 			ChainKey:  chainKey.Copy(),
 			Public:    public,
+			Origin:    config.OriginDealer,
+
+			VSSCommitments: append([]curve.Point(nil), commitments...),
 		}
 		X := ecdsaSecret.ActOnBase()
 		public[pid] = &config.Public{
@@ -57,5 +115,5 @@ func GenerateConfig(group curve.Curve, N, T int, source io.Reader, pl *pool.Pool
 			Pedersen: pedersenPublic,
 		}
 	}
-	return configs, partyIDs
+	return configs, partyIDs, nil
 }
@@ -0,0 +1,54 @@
+package test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+)
+
+// RunProtocol runs a MultiHandler for each party over a new Network, until all of them have finished,
+// and returns the result of each party.
+//
+// The protocol of party id is start(id), and its handler is created with the options returned by each of opts.
+// tb fails immediately if a handler can't be created or doesn't produce a result.
+func RunProtocol(tb testing.TB, ids party.IDSlice, sessionID []byte, start func(id party.ID) protocol.StartFunc,
+	opts ...func(id party.ID) protocol.HandlerOption) map[party.ID]interface{} {
+	tb.Helper()
+	network := NewNetwork(ids)
+	results := make(map[party.ID]interface{}, len(ids))
+	var (
+		mtx sync.Mutex
+		wg  sync.WaitGroup
+	)
+	wg.Add(len(ids))
+	for _, id := range ids {
+		go func(id party.ID) {
+			defer wg.Done()
+			options := make([]protocol.HandlerOption, 0, len(opts))
+			for _, opt := range opts {
+				options = append(options, opt(id))
+			}
+			h, err := protocol.NewMultiHandler(start(id), sessionID, options...)
+			if err != nil {
+				tb.Error(err)
+				return
+			}
+			HandlerLoop(id, h, network)
+			result, err := h.Result()
+			if err != nil {
+				tb.Error(err)
+				return
+			}
+			mtx.Lock()
+			results[id] = result
+			mtx.Unlock()
+		}(id)
+	}
+	wg.Wait()
+	if tb.Failed() {
+		tb.FailNow()
+	}
+	return results
+}
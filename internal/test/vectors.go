@@ -0,0 +1,90 @@
+package test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/protocols/cmp/config"
+)
+
+// Vector pins the parts of a configuration generated by GenerateConfig which
+// are fully determined by the source of randomness.
+//
+// GenerateConfig samples Paillier keys from crypto/rand, so they, and anything derived
+// from them, are not included.
+type Vector struct {
+	Name      string            `json:"name"`
+	Seed      int64             `json:"seed"`
+	N         int               `json:"n"`
+	T         int               `json:"t"`
+	PublicKey string            `json:"public_key"`
+	RID       string            `json:"rid"`
+	ChainKey  string            `json:"chain_key"`
+	Shares    map[string]string `json:"shares"`
+	// Signature is the encoding of R followed by S, of a signature produced with the configs
+	// by sessions whose randomness is seeded as well.
+	Signature string `json:"signature,omitempty"`
+}
+
+// NewVector extracts a Vector from the configs produced by GenerateConfig.
+func NewVector(name string, seed int64, T int, configs map[party.ID]*config.Config) (*Vector, error) {
+	v := &Vector{
+		Name:   name,
+		Seed:   seed,
+		N:      len(configs),
+		T:      T,
+		Shares: make(map[string]string, len(configs)),
+	}
+	for id, c := range configs {
+		if v.PublicKey == "" {
+			public, err := c.PublicPoint().MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			v.PublicKey = hex.EncodeToString(public)
+			v.RID = hex.EncodeToString(c.RID)
+			v.ChainKey = hex.EncodeToString(c.ChainKey)
+		}
+		share, err := c.Public[id].ECDSA.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		v.Shares[string(id)] = hex.EncodeToString(share)
+	}
+	return v, nil
+}
+
+// Diff returns a human readable line for each field where v and expected differ.
+//
+// An empty result means that both vectors are the same.
+func (v *Vector) Diff(expected *Vector) []string {
+	var diff []string
+	field := func(name string, got, want interface{}) {
+		if got != want {
+			diff = append(diff, fmt.Sprintf("%s %s:\n\tgot:  %v\n\twant: %v", v.Name, name, got, want))
+		}
+	}
+	field("n", v.N, expected.N)
+	field("t", v.T, expected.T)
+	field("public_key", v.PublicKey, expected.PublicKey)
+	field("rid", v.RID, expected.RID)
+	field("chain_key", v.ChainKey, expected.ChainKey)
+	field("signature", v.Signature, expected.Signature)
+
+	ids := make([]string, 0, len(v.Shares)+len(expected.Shares))
+	for id := range v.Shares {
+		ids = append(ids, id)
+	}
+	for id := range expected.Shares {
+		if _, ok := v.Shares[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		field("shares["+id+"]", v.Shares[id], expected.Shares[id])
+	}
+	return diff
+}
@@ -0,0 +1,14 @@
+// Package trace marks the phases of the protocols as regions and tasks of the Go execution tracer.
+//
+// It is only enabled when building with the mpstrace tag, for example
+//
+//	go test -tags mpstrace -run TestX -trace trace.out ./protocols/frost
+//	go tool trace trace.out
+//
+// Without the tag, every function is an empty stub which the compiler inlines away.
+//
+// Each MultiHandler runs as a task named after its protocol, with a region for the verification
+// of each message, broadcast or not, and for the finalization of each round.
+// Inside these, the OT setup and extension, the zero-knowledge proofs and the Lagrange interpolation
+// have regions of their own, named after their package.
+package trace
@@ -0,0 +1,26 @@
+//go:build !mpstrace
+// +build !mpstrace
+
+package trace
+
+import "context"
+
+// Enabled is true when the package is built with the mpstrace tag.
+const Enabled = false
+
+func end() {}
+
+// NewTask does nothing without the mpstrace tag.
+func NewTask(string) (context.Context, func()) {
+	return nil, end
+}
+
+// Region does nothing without the mpstrace tag.
+func Region(string) func() {
+	return end
+}
+
+// RoundRegion does nothing without the mpstrace tag.
+func RoundRegion(context.Context, string, uint16, string) func() {
+	return end
+}
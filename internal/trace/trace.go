@@ -0,0 +1,37 @@
+//go:build mpstrace
+// +build mpstrace
+
+package trace
+
+import (
+	"context"
+	"fmt"
+	rtrace "runtime/trace"
+)
+
+// Enabled is true when the package is built with the mpstrace tag.
+const Enabled = true
+
+// NewTask starts a task named name, and returns its context along with the function ending it.
+func NewTask(name string) (context.Context, func()) {
+	ctx, task := rtrace.NewTask(context.Background(), name)
+	return ctx, task.End
+}
+
+// Region starts a region named name, and returns the function ending it.
+//
+// It is meant to be deferred at the top of a function:
+//
+//	defer trace.Region("ot: extended send")()
+func Region(name string) func() {
+	return rtrace.StartRegion(context.Background(), name).End
+}
+
+// RoundRegion starts a region for step of round number of protocol, in the task of ctx,
+// and returns the function ending it.
+func RoundRegion(ctx context.Context, protocol string, number uint16, step string) func() {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return rtrace.StartRegion(ctx, fmt.Sprintf("%s: round %d: %s", protocol, number, step)).End
+}
@@ -0,0 +1,83 @@
+//go:build mpstrace
+// +build mpstrace
+
+package trace_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	rtrace "runtime/trace"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/ot"
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+	"github.com/koteld/multi-party-sig/protocols/frost"
+)
+
+func TestSignRegions(t *testing.T) {
+	group := curve.Secp256k1{}
+	ids := test.PartyIDs(3)
+	message := []byte("hello")
+
+	configs := test.RunProtocol(t, ids, nil, func(id party.ID) protocol.StartFunc {
+		return frost.Keygen(group, id, ids, 1)
+	})
+
+	var buf bytes.Buffer
+	if err := rtrace.Start(&buf); err != nil {
+		t.Fatal(err)
+	}
+	signers := ids[:2]
+	results := test.RunProtocol(t, signers, nil, func(id party.ID) protocol.StartFunc {
+		return frost.Sign(configs[id].(*frost.Config), signers, message)
+	})
+	rtrace.Stop()
+
+	signature := results[signers[0]].(frost.Signature)
+	if !signature.Verify(configs[signers[0]].(*frost.Config).PublicKey, message) {
+		t.Fatal("invalid signature")
+	}
+	for _, name := range []string{
+		"frost/sign-threshold",
+		"frost/sign-threshold: round 1: finalize",
+		"frost/sign-threshold: round 2: verify broadcast",
+		"frost/sign-threshold: round 2: finalize",
+		"frost/sign-threshold: round 3: verify broadcast",
+		"polynomial: lagrange",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(name)) {
+			t.Errorf("the trace has no region %q", name)
+		}
+	}
+}
+
+func TestCorreOTSetupRegions(t *testing.T) {
+	group := curve.Secp256k1{}
+	h := hash.New()
+
+	var buf bytes.Buffer
+	if err := rtrace.Start(&buf); err != nil {
+		t.Fatal(err)
+	}
+	sender := ot.NewCorreOTSetupSender(nil, h.Clone(), group)
+	receiver := ot.NewCorreOTSetupReceiver(nil, h.Clone(), group)
+	_, err := sender.Round1(rand.Reader, receiver.Round1(rand.Reader))
+	rtrace.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the sender of the correlated OT is the receiver of the random OTs
+	for _, name := range []string{
+		"ot: correlated setup send",
+		"ot: random setup receive",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(name)) {
+			t.Errorf("the trace has no region %q", name)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package ecdsa
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/internal/merkle"
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+)
+
+// SignatureBatch is a Merkle tree over a list of (message, signature) pairs, all under the same public key.
+//
+// The Root can be posted in place of the whole batch, and an InclusionProof then shows that
+// a given pair was part of it. Every signature is verified when the batch is created,
+// so the tree only ever contains valid signatures.
+type SignatureBatch struct {
+	tree *merkle.Tree
+}
+
+// InclusionProof shows that a (message, signature) pair is part of the SignatureBatch with a given Root.
+type InclusionProof struct {
+	// Index is the position of the pair in the batch.
+	Index int
+	// Size is the number of pairs in the batch.
+	Size int
+	// Siblings are the nodes needed to recompute the root from the leaf, starting from the bottom.
+	Siblings [][]byte
+}
+
+// NewSignatureBatch verifies each signatures[i] of the message hashes[i] under X,
+// and builds the Merkle tree of the batch.
+func NewSignatureBatch(X curve.Point, hashes [][]byte, signatures []*Signature) (*SignatureBatch, error) {
+	if len(hashes) == 0 {
+		return nil, errors.New("ecdsa: empty signature batch")
+	}
+	if len(hashes) != len(signatures) {
+		return nil, fmt.Errorf("ecdsa: got %d signatures for %d messages", len(signatures), len(hashes))
+	}
+	leaves := make([][]byte, len(hashes))
+	for i := range hashes {
+		if signatures[i] == nil {
+			return nil, fmt.Errorf("ecdsa: signature %d is nil", i)
+		}
+		if err := signatures[i].VerifyErr(X, hashes[i]); err != nil {
+			return nil, fmt.Errorf("ecdsa: signature %d: %w", i, err)
+		}
+		leaves[i] = batchLeaf(hashes[i], signatures[i])
+	}
+
+	tree, err := merkle.New(batchDomain, leaves)
+	if err != nil {
+		return nil, fmt.Errorf("ecdsa: %w", err)
+	}
+	return &SignatureBatch{tree: tree}, nil
+}
+
+// Len returns the number of signatures in the batch.
+func (b *SignatureBatch) Len() int {
+	return b.tree.Len()
+}
+
+// Root returns the root of the Merkle tree, which commits to every pair of the batch, and to their number.
+func (b *SignatureBatch) Root() []byte {
+	return b.tree.Root()
+}
+
+// Prove returns an InclusionProof for the pair at index.
+func (b *SignatureBatch) Prove(index int) (*InclusionProof, error) {
+	siblings, err := b.tree.Siblings(index)
+	if err != nil {
+		return nil, fmt.Errorf("ecdsa: %w", err)
+	}
+	return &InclusionProof{Index: index, Size: b.Len(), Siblings: siblings}, nil
+}
+
+// VerifyProof checks that sig is a valid signature of the message hash under X,
+// and that the pair is included in the batch with the given root.
+func VerifyProof(root []byte, X curve.Point, hash []byte, sig *Signature, proof *InclusionProof) bool {
+	if sig == nil || proof == nil || proof.Index < 0 || proof.Index >= proof.Size {
+		return false
+	}
+	if !sig.Verify(X, hash) {
+		return false
+	}
+
+	computed, err := merkle.Root(batchDomain, batchLeaf(hash, sig), proof.Index, proof.Size, proof.Siblings)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(computed, root)
+}
+
+// batchDomain separates the Merkle tree of a SignatureBatch from other trees.
+const batchDomain = "SignatureBatch"
+
+func batchLeaf(messageHash []byte, sig *Signature) []byte {
+	h := hash.New(&hash.BytesWithDomain{
+		TheDomain: "SignatureBatch Leaf",
+		Bytes:     messageHash,
+	})
+	_ = h.WriteAny(sig.R, sig.S)
+	return h.Sum()
+}
@@ -0,0 +1,104 @@
+package ecdsa
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
+)
+
+func TestSignatureBatch(t *testing.T) {
+	group := curve.Secp256k1{}
+	x := sample.Scalar(rand.Reader, group)
+	X := x.ActOnBase()
+
+	for _, n := range []int{1, 2, 5, 8} {
+		hashes := make([][]byte, n)
+		signatures := make([]*Signature, n)
+		for i := range hashes {
+			hashes[i] = []byte(fmt.Sprintf("message %d", i))
+			signatures[i] = NewSignature(x, hashes[i], nil)
+		}
+		batch, err := NewSignatureBatch(X, hashes, signatures)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := batch.Root()
+
+		for i := range hashes {
+			proof, err := batch.Prove(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !VerifyProof(root, X, hashes[i], signatures[i], proof) {
+				t.Errorf("n = %d: proof %d should verify", n, i)
+			}
+
+			other := (i + 1) % n
+			if other != i && VerifyProof(root, X, hashes[other], signatures[other], proof) {
+				t.Errorf("n = %d: proof %d should not verify for pair %d", n, i, other)
+			}
+			moved := *proof
+			moved.Index = other
+			if other != i && VerifyProof(root, X, hashes[i], signatures[i], &moved) {
+				t.Errorf("n = %d: proof %d should not verify at index %d", n, i, other)
+			}
+			resized := *proof
+			resized.Size++
+			if VerifyProof(root, X, hashes[i], signatures[i], &resized) {
+				t.Errorf("n = %d: proof %d should not verify for another size", n, i)
+			}
+		}
+
+		if _, err = batch.Prove(n); err == nil {
+			t.Errorf("n = %d: proving an index out of range should fail", n)
+		}
+	}
+}
+
+func TestSignatureBatchTampered(t *testing.T) {
+	group := curve.Secp256k1{}
+	x := sample.Scalar(rand.Reader, group)
+	X := x.ActOnBase()
+
+	hashes := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	signatures := make([]*Signature, len(hashes))
+	for i := range hashes {
+		signatures[i] = NewSignature(x, hashes[i], nil)
+	}
+	batch, err := NewSignatureBatch(X, hashes, signatures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := batch.Root()
+	proof, err := batch.Prove(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a valid signature of the same message which isn't in the batch
+	resigned := NewSignature(x, hashes[1], nil)
+	if VerifyProof(root, X, hashes[1], resigned, proof) {
+		t.Error("a pair which isn't in the batch should not verify")
+	}
+
+	tampered := *proof
+	tampered.Siblings = append([][]byte(nil), proof.Siblings...)
+	tampered.Siblings[0] = append([]byte(nil), proof.Siblings[0]...)
+	tampered.Siblings[0][0] ^= 1
+	if VerifyProof(root, X, hashes[1], signatures[1], &tampered) {
+		t.Error("a proof with a tampered sibling should not verify")
+	}
+
+	// invalid signatures are rejected when building the batch
+	invalid := append([]*Signature(nil), signatures...)
+	invalid[2] = signatures[1]
+	if _, err = NewSignatureBatch(X, hashes, invalid); err == nil {
+		t.Error("a batch with an invalid signature should be rejected")
+	}
+	if _, err = NewSignatureBatch(X, hashes, signatures[:2]); err == nil {
+		t.Error("a batch with missing signatures should be rejected")
+	}
+}
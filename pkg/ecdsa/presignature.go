@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/koteld/multi-party-sig/internal/types"
+	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/party"
 )
@@ -22,6 +23,8 @@ type PreSignature struct {
 	KShare curve.Scalar
 	// ChiShare = χᵢ
 	ChiShare curve.Scalar
+	// Proof optionally shows that R was derived from the nonce shares, see VerifyPresignature.
+	Proof *PreSignatureProof
 }
 
 // Group returns the elliptic curve group associated with this PreSignature.
@@ -37,6 +40,7 @@ func EmptyPreSignature(group curve.Curve) *PreSignature {
 		S:        party.EmptyPointMap(group),
 		KShare:   group.NewScalar(),
 		ChiShare: group.NewScalar(),
+		Proof:    emptyPreSignatureProof(group),
 	}
 }
 
@@ -68,23 +72,29 @@ func (sig *PreSignature) Signature(shares map[party.ID]SignatureShare) *Signatur
 // VerifySignatureShares should be called if the signature returned by PreSignature.Signature is not valid.
 // It returns the list of parties whose shares are invalid.
 func (sig *PreSignature) VerifySignatureShares(shares map[party.ID]SignatureShare, hash []byte) (culprits []party.ID) {
-	r := sig.R.XScalar()
-	m := curve.FromHash(sig.Group(), hash)
 	for j, share := range shares {
-		Rj, Sj := sig.RBar.Points[j], sig.S.Points[j]
-		if Rj == nil || Sj == nil {
-			culprits = append(culprits, j)
-			continue
-		}
-		lhs := share.Act(sig.R)
-		rhs := m.Act(Rj).Add(r.Act(Sj))
-		if !lhs.Equal(rhs) {
+		if !VerifyShare(sig.R, sig.RBar.Points[j], sig.S.Points[j], hash, share) {
 			culprits = append(culprits, j)
 		}
 	}
 	return
 }
 
+// VerifyShare checks that the share σⱼ of party j is consistent with its public values in a presignature,
+// where R̄ⱼ = (k⁻¹kⱼ)⋅G and Sⱼ = χⱼ⋅R.
+//
+// Since σⱼ = kⱼm+rχⱼ, a valid share satisfies σⱼ⋅R = m⋅R̄ⱼ + r⋅Sⱼ.
+func VerifyShare(R, RBar, S curve.Point, hash []byte, share SignatureShare) bool {
+	if R == nil || RBar == nil || S == nil || share == nil {
+		return false
+	}
+	r := R.XScalar()
+	m := curve.FromHash(R.Curve(), hash)
+	lhs := share.Act(R)
+	rhs := m.Act(RBar).Add(r.Act(S))
+	return lhs.Equal(rhs)
+}
+
 func (sig *PreSignature) Validate() error {
 	if len(sig.RBar.Points) != len(sig.S.Points) {
 		return errors.New("presignature: different number of R,S shares")
@@ -110,6 +120,42 @@ func (sig *PreSignature) Validate() error {
 	return nil
 }
 
+// AggregateNonce returns ∑ⱼ cⱼ⋅Cⱼ for the commitments Cⱼ and coefficients cⱼ, where a missing cⱼ is taken to be 1.
+//
+// With the shares Γⱼ broadcast during presign and cⱼ = δ⁻¹ for every j, this is R = δ⁻¹⋅Γ,
+// which lets an auditor check the R of a PreSignature from the public values of the protocol.
+// With the shares R̄ⱼ of a PreSignature, and no coefficients, the result should be G.
+// It returns nil if there are no commitments.
+func AggregateNonce(commitments map[party.ID]curve.Point, coeffs map[party.ID]curve.Scalar) curve.Point {
+	var R curve.Point
+	for j, C := range commitments {
+		if R == nil {
+			R = C.Curve().NewPoint()
+		}
+		if c, ok := coeffs[j]; ok {
+			C = c.Act(C)
+		}
+		R = R.Add(C)
+	}
+	return R
+}
+
+// DerivedID returns a stable identifier for this PreSignature, for tracking its single use.
+//
+// It is a hash of the public values the signers agreed on: the jointly generated ID, the signers, and R.
+// Every party therefore computes the same value, and since ID is fresh for every run of presign,
+// so is the result.
+func (sig *PreSignature) DerivedID() types.RID {
+	h := hash.New(&hash.BytesWithDomain{
+		TheDomain: "PreSignature ID",
+		Bytes:     sig.ID,
+	})
+	_ = h.WriteAny(sig.SignerIDs(), sig.R)
+	id := types.EmptyRID()
+	_, _ = h.Digest().Read(id)
+	return id
+}
+
 func (sig *PreSignature) SignerIDs() party.IDSlice {
 	ids := make([]party.ID, 0, len(sig.RBar.Points))
 	for id := range sig.RBar.Points {
@@ -0,0 +1,101 @@
+package ecdsa
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	zksch "github.com/koteld/multi-party-sig/pkg/zk/sch"
+)
+
+// PreSignatureProof shows that one party's share of a PreSignature is consistent with R.
+//
+// Since R̄ⱼ = (k⁻¹kⱼ)⋅G and R = k⁻¹⋅G, every share satisfies R̄ⱼ = kⱼ⋅R.
+// The proof is a Schnorr proof of knowledge of kᵢ for R̄ᵢ, using R as the generator.
+type PreSignatureProof struct {
+	// ID is the party whose nonce share kᵢ the proof is for.
+	ID party.ID
+	// Proof shows knowledge of kᵢ such that R̄ᵢ = kᵢ⋅R.
+	Proof *zksch.Proof
+}
+
+// Prove attaches a PreSignatureProof for the share of party id, which must be the owner of this PreSignature.
+func (sig *PreSignature) Prove(id party.ID) error {
+	RBar, ok := sig.RBar.Points[id]
+	if !ok {
+		return fmt.Errorf("presignature: %s is not a signer", id)
+	}
+	if sig.KShare == nil || !sig.KShare.Act(sig.R).Equal(RBar) {
+		return errors.New("presignature: KShare doesn't match RBar")
+	}
+	sig.Proof = &PreSignatureProof{
+		ID:    id,
+		Proof: zksch.NewProof(sig.proofHash(id), RBar, sig.KShare, sig.R),
+	}
+	return nil
+}
+
+// VerifyPresignature checks that the R of a PreSignature was derived from the nonce shares R̄ⱼ,
+// using only its public values:
+//
+//   - ∑ⱼ R̄ⱼ = G, which holds when R = k⁻¹⋅G and R̄ⱼ = (k⁻¹kⱼ)⋅G for k = ∑ⱼ kⱼ,
+//   - the attached proof shows that its party knows kᵢ such that R̄ᵢ = kᵢ⋅R.
+//
+// A presignature whose R was replaced after the fact fails the second check.
+func VerifyPresignature(presig *PreSignature) error {
+	if presig == nil || presig.R == nil || presig.RBar == nil || presig.S == nil {
+		return errors.New("presignature: incomplete")
+	}
+	if presig.R.IsIdentity() {
+		return errors.New("presignature: R is identity")
+	}
+	if len(presig.RBar.Points) != len(presig.S.Points) {
+		return errors.New("presignature: different number of R,S shares")
+	}
+
+	group := presig.Group()
+	sum := group.NewPoint()
+	for id, RBar := range presig.RBar.Points {
+		if _, ok := presig.S.Points[id]; !ok {
+			return fmt.Errorf("presignature: missing S share for %s", id)
+		}
+		sum = sum.Add(RBar)
+	}
+	if !sum.Equal(group.NewBasePoint()) {
+		return errors.New("presignature: RBar shares don't sum to G")
+	}
+
+	if presig.Proof == nil || presig.Proof.Proof == nil {
+		return errors.New("presignature: missing proof")
+	}
+	id := presig.Proof.ID
+	RBar, ok := presig.RBar.Points[id]
+	if !ok {
+		return fmt.Errorf("presignature: proof is for %s, who is not a signer", id)
+	}
+	if !presig.Proof.Proof.Verify(presig.proofHash(id), RBar, presig.R) {
+		return errors.New("presignature: proof failed to verify")
+	}
+	return nil
+}
+
+// proofHash returns the hash state used for the proof of party id,
+// which commits to all the public values of the PreSignature.
+func (sig *PreSignature) proofHash(id party.ID) *hash.Hash {
+	h := hash.New(&hash.BytesWithDomain{
+		TheDomain: "PreSignature Proof",
+		Bytes:     []byte(id),
+	})
+	_ = h.WriteAny(sig.ID, sig.R)
+	for _, j := range sig.SignerIDs() {
+		_ = h.WriteAny(j, sig.RBar.Points[j], sig.S.Points[j])
+	}
+	return h
+}
+
+// emptyPreSignatureProof returns a PreSignatureProof with a given group, ready for unmarshalling.
+func emptyPreSignatureProof(group curve.Curve) *PreSignatureProof {
+	return &PreSignatureProof{Proof: zksch.EmptyProof(group)}
+}
@@ -1,11 +1,15 @@
 package ecdsa
 
 import (
+	"bytes"
 	"encoding/binary"
 	mrand "math/rand"
 	"testing"
 
+	"github.com/cronokirby/safenum"
+	"github.com/fxamacker/cbor/v2"
 	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/internal/types"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/math/sample"
 	"github.com/koteld/multi-party-sig/pkg/party"
@@ -108,3 +112,143 @@ func TestPreSignature_Fail(t *testing.T) {
 		}
 	}
 }
+
+func TestPreSignature_DerivedID(t *testing.T) {
+	N := 5
+	group := curve.Secp256k1{}
+	_, _, preSignatures := NewPreSignatures(group, N)
+	rid, err := types.NewRID(mrand.New(mrand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, preSignature := range preSignatures {
+		preSignature.ID = rid
+	}
+
+	// every party derives the same ID from its own share
+	ids := test.PartyIDs(N)
+	derivedID := preSignatures[ids[0]].DerivedID()
+	for _, id := range ids {
+		if !bytes.Equal(derivedID, preSignatures[id].DerivedID()) {
+			t.Errorf("%s derived another ID", id)
+		}
+	}
+
+	// another run gets another presignature ID, and so another derived ID, even with the same nonce
+	other := *preSignatures[ids[0]]
+	if other.ID, err = types.NewRID(mrand.New(mrand.NewSource(2))); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(derivedID, other.DerivedID()) {
+		t.Error("two runs have the same ID")
+	}
+	// as does another nonce
+	other = *preSignatures[ids[0]]
+	other.R = sample.Scalar(mrand.New(mrand.NewSource(3)), group).ActOnBase()
+	if bytes.Equal(derivedID, other.DerivedID()) {
+		t.Error("two nonces have the same ID")
+	}
+}
+
+func TestVerifyShare(t *testing.T) {
+	N := 5
+	group := curve.Secp256k1{}
+	message := []byte("HELLO WORLD")
+	_, _, preSignatures := NewPreSignatures(group, N)
+	ids := test.PartyIDs(N)
+	bad := ids[2]
+
+	for i, id := range ids {
+		preSignature := preSignatures[id]
+		share := preSignature.SignatureShare(message)
+		if id == bad {
+			share.Add(group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1)))
+		}
+		valid := VerifyShare(preSignature.R, preSignature.RBar.Points[id], preSignature.S.Points[id], message, share)
+		if valid != (id != bad) {
+			t.Errorf("share of %s: expected valid = %t", id, id != bad)
+		}
+		// the share must be checked against the public values of the same party
+		other := ids[(i+1)%N]
+		if VerifyShare(preSignature.R, preSignature.RBar.Points[other], preSignature.S.Points[other], message, share) {
+			t.Errorf("share of %s should not verify for %s", id, other)
+		}
+	}
+}
+
+func TestVerifyPresignature(t *testing.T) {
+	N := 5
+	group := curve.Secp256k1{}
+	_, _, preSignatures := NewPreSignatures(group, N)
+	ids := test.PartyIDs(N)
+
+	for _, id := range ids {
+		preSignature := preSignatures[id]
+		preSignature.ID = types.EmptyRID()
+		if err := VerifyPresignature(preSignature); err == nil {
+			t.Error("a presignature without a proof should not verify")
+		}
+		if err := preSignature.Prove(id); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyPresignature(preSignature); err != nil {
+			t.Error("honest presignature should verify:", err)
+		}
+
+		data, err := cbor.Marshal(preSignature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unmarshalled := EmptyPreSignature(group)
+		if err = cbor.Unmarshal(data, unmarshalled); err != nil {
+			t.Fatal(err)
+		}
+		if err = VerifyPresignature(unmarshalled); err != nil {
+			t.Error("unmarshalled presignature should verify:", err)
+		}
+	}
+
+	// the shares of another party can't be proven
+	preSignature := preSignatures[ids[0]]
+	if err := preSignature.Prove(ids[1]); err == nil {
+		t.Error("proving for another party should fail")
+	}
+
+	// replacing a nonce share invalidates the proof
+	tampered := *preSignature
+	RBar := make(map[party.ID]curve.Point, N)
+	for id, point := range preSignature.RBar.Points {
+		RBar[id] = point
+	}
+	RBar[ids[0]], RBar[ids[1]] = RBar[ids[1]], RBar[ids[0]]
+	tampered.RBar = party.NewPointMap(RBar)
+	if err := VerifyPresignature(&tampered); err == nil {
+		t.Error("presignature with tampered RBar should not verify")
+	}
+}
+
+func TestVerifyPresignatureTamperedR(t *testing.T) {
+	N := 3
+	group := curve.Secp256k1{}
+	_, _, preSignatures := NewPreSignatures(group, N)
+	id := test.PartyIDs(N)[0]
+	preSignature := preSignatures[id]
+	preSignature.ID = types.EmptyRID()
+	if err := preSignature.Prove(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyPresignature(preSignature); err != nil {
+		t.Fatal("honest presignature should verify:", err)
+	}
+
+	tampered := *preSignature
+	tampered.R = sample.Scalar(mrand.New(mrand.NewSource(1)), group).ActOnBase()
+	if err := VerifyPresignature(&tampered); err == nil {
+		t.Error("presignature with a tampered R should not verify")
+	}
+	// R is negated, so that it still has the same x coordinate
+	tampered.R = preSignature.R.Negate()
+	if err := VerifyPresignature(&tampered); err == nil {
+		t.Error("presignature with a negated R should not verify")
+	}
+}
@@ -1,6 +1,9 @@
 package ecdsa
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 )
 
@@ -13,26 +16,236 @@ type Signature struct {
 	S curve.Scalar
 }
 
+// ErrZeroSignatureValue is returned when r or s is zero.
+//
+// This only happens with negligible probability, and the signature is invalid,
+// but signing again with a fresh nonce, or presignature, will succeed.
+// Callers can check for it using errors.Is, in order to retry.
+var ErrZeroSignatureValue = errors.New("ecdsa: r or s is zero, signing should be retried")
+
+// ErrUnsupportedCurve is returned when signing on a curve other than secp256k1.
+//
+// A message is converted to a scalar with curve.FromHash, and signatures are encoded,
+// in ways which have only been checked against other implementations for secp256k1.
+// On another curve, such as P-256, the signatures might not verify with crypto/ecdsa, so they aren't produced at all.
+var ErrUnsupportedCurve = errors.New("ecdsa: signing is only supported on secp256k1")
+
+// CheckCurve returns ErrUnsupportedCurve, unless group is secp256k1.
+func CheckCurve(group curve.Curve) error {
+	if _, ok := group.(curve.Secp256k1); !ok {
+		return fmt.Errorf("%w, not %s", ErrUnsupportedCurve, group.Name())
+	}
+	return nil
+}
+
 // EmptySignature returns a new signature with a given curve, ready to be unmarshalled.
 func EmptySignature(group curve.Curve) Signature {
 	return Signature{R: group.NewPoint(), S: group.NewScalar()}
 }
 
+// ErrCurveMismatch is returned when a signature and public key belong to different curves.
+var ErrCurveMismatch = errors.New("ecdsa: signature and public key are on different curves")
+
 // Verify is a custom signature format using curve data.
 func (sig Signature) Verify(X curve.Point, hash []byte) bool {
+	return sig.VerifyErr(X, hash) == nil
+}
+
+// VerifyErr is like Verify, but returns an error explaining why the signature is invalid.
+//
+// In particular, ErrCurveMismatch is returned if R, S, and X don't all share the same curve,
+// instead of mixing elements from different groups.
+func (sig Signature) VerifyErr(X curve.Point, hash []byte) error {
+	if X == nil || sig.R == nil || sig.S == nil {
+		return errors.New("ecdsa: nil signature or public key")
+	}
 	group := X.Curve()
+	if sig.R.Curve().Name() != group.Name() || sig.S.Curve().Name() != group.Name() {
+		return ErrCurveMismatch
+	}
 
+	// R' = s⁻¹⋅(m⋅G + r⋅X) = (m⋅s⁻¹)⋅G + (r⋅s⁻¹)⋅X
 	m := curve.FromHash(group, hash)
 	sInv := group.NewScalar().Set(sig.S).Invert()
-	mG := m.ActOnBase()
+	u1 := m.Mul(sInv)
+	u2 := sig.R.XScalar().Mul(sInv)
+	R2 := curve.ScalarBaseMultAdd(group, u1, u2, X)
+	if !R2.Equal(sig.R) {
+		return errors.New("ecdsa: invalid signature")
+	}
+	return nil
+}
+
+// WhichKey returns the index of the first key in candidates under which sig is valid for hash,
+// or false if there is none.
+//
+// Since sig holds the whole point R, the only key it can be valid under is X = r⁻¹⋅(s⋅R - m⋅G),
+// so X is computed once and compared with each candidate, instead of verifying sig for every one of them.
+func WhichKey(candidates []curve.Point, hash []byte, sig Signature) (int, bool) {
+	if sig.R == nil || sig.S == nil || sig.R.IsIdentity() {
+		return 0, false
+	}
+	group := sig.R.Curve()
+	if sig.S.Curve().Name() != group.Name() {
+		return 0, false
+	}
 	r := sig.R.XScalar()
-	rX := r.Act(X)
-	R2 := mG.Add(rX)
-	R2 = sInv.Act(R2)
-	return R2.Equal(sig.R)
+	if r.IsZero() || sig.S.IsZero() {
+		return 0, false
+	}
+
+	// X = (-m⋅r⁻¹)⋅G + (s⋅r⁻¹)⋅R
+	m := curve.FromHash(group, hash)
+	rInv := r.Invert()
+	u1 := m.Mul(rInv).Negate()
+	u2 := group.NewScalar().Set(sig.S).Mul(rInv)
+	X := curve.ScalarBaseMultAdd(group, u1, u2, sig.R)
+	if X.IsIdentity() {
+		return 0, false
+	}
+	for i, candidate := range candidates {
+		if candidate == nil || candidate.Curve().Name() != group.Name() {
+			continue
+		}
+		if X.Equal(candidate) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// DetectReuse scans sigs for two different signatures sharing the same nonce, and returns its R if there are.
+//
+// Signing two messages with the same presignature gives two signatures with the same r,
+// from which anyone can compute the nonce, and then the secret key.
+// Signatures are compared by r, the x coordinate of R reduced modulo the order, since R and -R leak the key as well.
+// The same signature appearing twice, possibly with s negated as by ToCompactEth, isn't reported.
+func DetectReuse(sigs []Signature) (reused bool, culpritR curve.Point) {
+	type seen struct {
+		R curve.Point
+		S curve.Scalar
+	}
+	byR := make(map[string][]seen, len(sigs))
+	for _, sig := range sigs {
+		if sig.R == nil || sig.S == nil {
+			continue
+		}
+		r, err := sig.RValue().MarshalBinary()
+		if err != nil {
+			continue
+		}
+		key := sig.R.Curve().Name() + string(r)
+		for _, other := range byR[key] {
+			negated := sig.S.Curve().NewScalar().Set(sig.S).Negate()
+			if !other.S.Equal(sig.S) && !other.S.Equal(negated) {
+				return true, other.R
+			}
+		}
+		byR[key] = append(byR[key], seen{R: sig.R, S: sig.S})
+	}
+	return false, nil
+}
+
+// CheckNonZero returns ErrZeroSignatureValue if r, the x coordinate of R reduced modulo the order, or s is zero.
+func (sig Signature) CheckNonZero() error {
+	if sig.R == nil || sig.S == nil {
+		return errors.New("ecdsa: signature has nil fields")
+	}
+	if sig.R.XScalar().IsZero() || sig.S.IsZero() {
+		return ErrZeroSignatureValue
+	}
+	return nil
+}
+
+// RValue returns r, the x coordinate of R reduced modulo the order of the group.
+//
+// This is the r of the standard (r, s) encoding, and doesn't depend on the parity of R.
+// For the negligible fraction of nonces where the x coordinate of R is at least the order,
+// r differs from the x coordinate written by ToCompactEth.
+func (sig Signature) RValue() curve.Scalar {
+	return sig.R.XScalar()
+}
+
+// RBytes returns the x coordinate of R, as written in the first half of ToCompactEth.
+//
+// For secp256k1, this doesn't allocate, unlike R.XBytes.
+func (sig Signature) RBytes() [32]byte {
+	var out [32]byte
+	if R, ok := sig.R.(*curve.Secp256k1Point); ok {
+		R.PutXBytes(&out)
+	} else {
+		copy(out[:], sig.R.XBytes())
+	}
+	return out
+}
+
+// VerifyFromRX checks a signature given only r, the x coordinate of R reduced modulo the order, and s.
+//
+// This is the classic ECDSA verification, recomputing R = s⁻¹(m⋅G + r⋅X),
+// and comparing its x coordinate with r. Since rx is a Scalar, it's already reduced,
+// so an r ⩾ n can't be represented. Zero values of r or s are rejected.
+func VerifyFromRX(X curve.Point, hash []byte, rx curve.Scalar, s curve.Scalar) bool {
+	if X == nil || rx == nil || s == nil {
+		return false
+	}
+	if X.IsIdentity() || rx.IsZero() || s.IsZero() {
+		return false
+	}
+	group := X.Curve()
+	if rx.Curve().Name() != group.Name() || s.Curve().Name() != group.Name() {
+		return false
+	}
+
+	m := curve.FromHash(group, hash)
+	sInv := group.NewScalar().Set(s).Invert()
+	u1 := m.Mul(sInv)
+	u2 := group.NewScalar().Set(rx).Mul(sInv)
+	R := curve.ScalarBaseMultAdd(group, u1, u2, X)
+	if R.IsIdentity() {
+		return false
+	}
+	return R.XScalar().Equal(rx)
+}
+
+// VerifyFromRParity checks a signature stored as r, the x coordinate of R, the parity of the y coordinate of R, and s.
+//
+// R is rebuilt from r and yOdd, checked to be on the curve, and the signature (R, s) is then verified as by Verify.
+// Unlike VerifyFromRX, this checks the parity of R, and so rejects (r, s) with the opposite bit.
+// As with VerifyFromRX, an x coordinate ⩾ n can't be represented by rx. Zero values of r or s are rejected.
+func VerifyFromRParity(X curve.Point, hash []byte, rx curve.Scalar, yOdd bool, s curve.Scalar) bool {
+	if X == nil || rx == nil || s == nil {
+		return false
+	}
+	if X.IsIdentity() || rx.IsZero() || s.IsZero() {
+		return false
+	}
+	group := X.Curve()
+	if rx.Curve().Name() != group.Name() || s.Curve().Name() != group.Name() {
+		return false
+	}
+
+	// R is the point with x coordinate r, in the compressed encoding where the prefix holds the parity of y
+	xBytes, err := rx.MarshalBinary()
+	if err != nil {
+		return false
+	}
+	prefix := byte(2)
+	if yOdd {
+		prefix = 3
+	}
+	R := group.NewPoint()
+	if err = R.UnmarshalBinary(append([]byte{prefix}, xBytes...)); err != nil {
+		return false
+	}
+	if group.ValidatePoint(R) != nil || R.IsIdentity() {
+		return false
+	}
+	return Signature{R: R, S: s}.Verify(X, hash)
 }
 
 // ToCompactEth serializes signature to the compact format [R || S || V] format where V is 0 or 1.
+//
+// S is normalized to the lower half of the order, as Ethereum requires, without modifying the signature.
 func (sig Signature) ToCompactEth() []byte {
 	b := make([]byte, compactSigSize)
 
@@ -40,12 +253,14 @@ func (sig Signature) ToCompactEth() []byte {
 	S := sig.S
 	recoveryID := byte(R.IsOddYBit())
 
-	if R.XScalar().IsOverHalfOrder() {
+	if S.IsOverHalfOrder() {
+		// (r, -s) is a signature with -R as the nonce point, whose y coordinate has the opposite parity
 		recoveryID ^= 0x01
-		S.Negate()
+		S = S.Neg()
 	}
 
-	bytesR := R.XBytes()
+	// both encodings are fixed width, so values with leading zero bytes stay aligned
+	bytesR := sig.RBytes()
 	bytesS := S.Bytes()
 
 	copy(b[0:32], bytesR[:])
@@ -55,3 +270,39 @@ func (sig Signature) ToCompactEth() []byte {
 
 	return b
 }
+
+// CheckRecoveryID checks that the compact signature [R || S || V], in the format of ToCompactEth,
+// recovers expectedKey for hash when using its recovery id V.
+//
+// A valid signature with a flipped V recovers another key, so this rejects a wrong or forged recovery byte,
+// as well as a signature which isn't valid for expectedKey.
+// V must be 0 or 1, and the x coordinate of R must be less than the order of the group.
+func CheckRecoveryID(group curve.Curve, hash, compact []byte, expectedKey curve.Point) bool {
+	if len(compact) != compactSigSize || compact[64] > 1 {
+		return false
+	}
+	if expectedKey == nil || expectedKey.Curve().Name() != group.Name() || expectedKey.IsIdentity() {
+		return false
+	}
+	r, s := group.NewScalar(), group.NewScalar()
+	if r.UnmarshalBinary(compact[0:32]) != nil || s.UnmarshalBinary(compact[32:64]) != nil {
+		return false
+	}
+	if r.IsZero() || s.IsZero() {
+		return false
+	}
+
+	// R is the point with x coordinate r, and the parity of its y coordinate given by V
+	R := group.NewPoint()
+	if err := R.UnmarshalBinary(append([]byte{2 + compact[64]}, compact[0:32]...)); err != nil {
+		return false
+	}
+
+	// X = r⁻¹⋅(s⋅R - m⋅G) = (-m⋅r⁻¹)⋅G + (s⋅r⁻¹)⋅R
+	m := curve.FromHash(group, hash)
+	rInv := group.NewScalar().Set(r).Invert()
+	u1 := m.Mul(rInv).Negate()
+	u2 := s.Mul(rInv)
+	X := curve.ScalarBaseMultAdd(group, u1, u2, R)
+	return X.Equal(expectedKey)
+}
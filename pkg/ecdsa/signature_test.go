@@ -1,9 +1,15 @@
 package ecdsa
 
 import (
+	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"testing"
 
+	"github.com/cronokirby/safenum"
+	decred "github.com/decred/dcrd/dcrec/secp256k1/v3/ecdsa"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/math/sample"
 )
@@ -36,3 +42,467 @@ func TestSignature_Verify(t *testing.T) {
 		t.Error("verify failed")
 	}
 }
+
+func TestSignature_ToCompactEth(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	m := []byte("hello")
+	x := sample.Scalar(rand.Reader, group)
+	X := x.ActOnBase()
+	// Look for a signature with a high S, which needs to be normalized
+	var sig *Signature
+	for sig == nil || !sig.S.IsOverHalfOrder() {
+		sig = NewSignature(x, m, nil)
+	}
+	S := group.NewScalar().Set(sig.S)
+
+	compact := sig.ToCompactEth()
+	if !sig.S.Equal(S) {
+		t.Error("ToCompactEth mutated the signature")
+	}
+	if !sig.Verify(X, m) {
+		t.Error("verify failed after ToCompactEth")
+	}
+	lowS := group.NewScalar()
+	if err := lowS.UnmarshalBinary(compact[32:64]); err != nil {
+		t.Fatal(err)
+	}
+	if lowS.IsOverHalfOrder() || !lowS.Equal(S.Neg()) {
+		t.Error("ToCompactEth didn't normalize S")
+	}
+	if compact[64] != byte(sig.R.IsOddYBit())^1 {
+		t.Error("ToCompactEth didn't flip the recovery id")
+	}
+}
+
+func TestSignature_ToCompactEthTwice(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	m := []byte("hello")
+	x := sample.Scalar(rand.Reader, group)
+	X := x.ActOnBase()
+	for i := 0; i < 10; i++ {
+		sig := NewSignature(x, m, nil)
+		first := sig.ToCompactEth()
+		second := sig.ToCompactEth()
+		if !bytes.Equal(first, second) {
+			t.Error("ToCompactEth isn't stable across calls")
+		}
+		if !sig.Verify(X, m) {
+			t.Error("verify failed after ToCompactEth")
+		}
+	}
+}
+
+func TestSignature_ToCompactEthLeadingZero(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	x := sample.Scalar(rand.Reader, group)
+	X := x.ActOnBase()
+	publicKey, err := X.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Look for a signature whose normalized S has a zero high byte,
+	// which would get misaligned by a variable length encoding.
+	var (
+		m       [32]byte
+		sig     *Signature
+		compact []byte
+	)
+	for i := 0; compact == nil || compact[32] != 0; i++ {
+		m = sha256.Sum256([]byte{byte(i), byte(i >> 8), byte(i >> 16)})
+		sig = NewSignature(x, m[:], nil)
+		compact = sig.ToCompactEth()
+	}
+
+	S := group.NewScalar()
+	if err := S.UnmarshalBinary(compact[32:64]); err != nil {
+		t.Fatal(err)
+	}
+	if !S.Equal(sig.S) && !S.Equal(sig.S.Neg()) {
+		t.Error("ToCompactEth misencoded S")
+	}
+	if !VerifyFromRX(X, m[:], sig.R.XScalar(), S) {
+		t.Error("verify failed on the compact encoding")
+	}
+
+	// [27 + 4 + V || R || S] is the compact format used for recovery, with a compressed key.
+	recoverable := make([]byte, 0, compactSigSize)
+	recoverable = append(recoverable, 27+4+compact[64])
+	recoverable = append(recoverable, compact[:64]...)
+	recovered, _, err := decred.RecoverCompact(recoverable, m[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(recovered.SerializeCompressed(), publicKey) {
+		t.Error("recovered the wrong public key")
+	}
+}
+
+// pointWithZeroR returns the point whose x coordinate is the order of secp256k1, so that r = 0.
+func pointWithZeroR(t *testing.T) curve.Point {
+	data, _ := hex.DecodeString("02fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+	R := curve.Secp256k1{}.NewPoint()
+	if err := R.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	return R
+}
+
+func TestSignature_CheckNonZero(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	x := sample.Scalar(rand.Reader, group)
+	sig := NewSignature(x, []byte("hello"), nil)
+	if err := sig.CheckNonZero(); err != nil {
+		t.Error(err)
+	}
+
+	R := pointWithZeroR(t)
+	if !R.XScalar().IsZero() {
+		t.Fatal("r should be zero")
+	}
+	zeroR := Signature{R: R, S: sig.S}
+	if err := zeroR.CheckNonZero(); !errors.Is(err, ErrZeroSignatureValue) {
+		t.Errorf("expected ErrZeroSignatureValue for r = 0, got %v", err)
+	}
+	zeroS := Signature{R: sig.R, S: group.NewScalar()}
+	if err := zeroS.CheckNonZero(); !errors.Is(err, ErrZeroSignatureValue) {
+		t.Errorf("expected ErrZeroSignatureValue for s = 0, got %v", err)
+	}
+}
+
+func TestVerifyFromRX(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	m := []byte("hello")
+	x := sample.Scalar(rand.Reader, group)
+	X := x.ActOnBase()
+	for i := 0; i < 10; i++ {
+		sig := NewSignature(x, m, nil)
+		rx := sig.R.XScalar()
+		if !sig.Verify(X, m) || !VerifyFromRX(X, m, rx, sig.S) {
+			t.Error("verify failed")
+		}
+		// (r, -s) is also valid, but not for the point based Verify, since R is fixed
+		if !VerifyFromRX(X, m, rx, sig.S.Neg()) {
+			t.Error("verify with negated s failed")
+		}
+		if VerifyFromRX(X, []byte("world"), rx, sig.S) {
+			t.Error("verify succeeded on a different message")
+		}
+		if VerifyFromRX(X.Negate(), m, rx, sig.S) {
+			t.Error("verify succeeded with a different public key")
+		}
+	}
+	sig := NewSignature(x, m, nil)
+	if VerifyFromRX(X, m, group.NewScalar(), sig.S) || VerifyFromRX(X, m, sig.R.XScalar(), group.NewScalar()) {
+		t.Error("verify succeeded with a zero value")
+	}
+}
+
+func TestVerifyFromRParity(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	m := []byte("hello")
+	x := sample.Scalar(rand.Reader, group)
+	X := x.ActOnBase()
+	for i := 0; i < 10; i++ {
+		sig := NewSignature(x, m, nil)
+		rx := sig.R.XScalar()
+		yOdd := sig.R.IsOddYBit() == 1
+		if !sig.Verify(X, m) || !VerifyFromRParity(X, m, rx, yOdd, sig.S) {
+			t.Error("verify failed")
+		}
+		// the opposite parity gives -R, which only the point based Verify with -s accepts
+		if VerifyFromRParity(X, m, rx, !yOdd, sig.S) {
+			t.Error("verify succeeded with the wrong parity")
+		}
+		negated := Signature{R: sig.R.Negate(), S: sig.S.Neg()}
+		if !negated.Verify(X, m) || !VerifyFromRParity(X, m, rx, !yOdd, negated.S) {
+			t.Error("verify with negated R and s failed")
+		}
+		if VerifyFromRParity(X, []byte("world"), rx, yOdd, sig.S) {
+			t.Error("verify succeeded on a different message")
+		}
+		if VerifyFromRParity(X.Negate(), m, rx, yOdd, sig.S) {
+			t.Error("verify succeeded with a different public key")
+		}
+	}
+
+	sig := NewSignature(x, m, nil)
+	yOdd := sig.R.IsOddYBit() == 1
+	if VerifyFromRParity(X, m, group.NewScalar(), yOdd, sig.S) || VerifyFromRParity(X, m, sig.R.XScalar(), yOdd, group.NewScalar()) {
+		t.Error("verify succeeded with a zero value")
+	}
+
+	// an x coordinate with no point on the curve is rejected
+	for i := uint64(1); ; i++ {
+		rx := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(i))
+		xBytes, _ := rx.MarshalBinary()
+		if _, err := group.LiftX(xBytes); err == nil {
+			continue
+		}
+		if VerifyFromRParity(X, m, rx, false, sig.S) || VerifyFromRParity(X, m, rx, true, sig.S) {
+			t.Error("verify succeeded with an x coordinate not on the curve")
+		}
+		break
+	}
+}
+
+// otherCurve stands in for a different group, such as P-256.
+type otherCurve struct {
+	curve.Secp256k1
+}
+
+func (otherCurve) Name() string {
+	return "P-256"
+}
+
+type otherPoint struct {
+	curve.Point
+}
+
+func (otherPoint) Curve() curve.Curve {
+	return otherCurve{}
+}
+
+type otherScalar struct {
+	curve.Scalar
+}
+
+func (otherScalar) Curve() curve.Curve {
+	return otherCurve{}
+}
+
+func TestSignature_VerifyCurveMismatch(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	m := []byte("hello")
+	x := sample.Scalar(rand.Reader, group)
+	X := x.ActOnBase()
+	sig := NewSignature(x, m, nil)
+
+	cases := map[string]struct {
+		sig Signature
+		X   curve.Point
+	}{
+		"X": {*sig, otherPoint{X}},
+		"R": {Signature{R: otherPoint{sig.R}, S: sig.S}, X},
+		"S": {Signature{R: sig.R, S: otherScalar{sig.S}}, X},
+	}
+	for name, c := range cases {
+		if err := c.sig.VerifyErr(c.X, m); !errors.Is(err, ErrCurveMismatch) {
+			t.Errorf("%s on another curve: expected ErrCurveMismatch, got %v", name, err)
+		}
+		if c.sig.Verify(c.X, m) {
+			t.Errorf("%s on another curve: signature verified", name)
+		}
+	}
+
+	if VerifyFromRX(otherPoint{X}, m, sig.R.XScalar(), sig.S) {
+		t.Error("X on another curve: VerifyFromRX succeeded")
+	}
+	if VerifyFromRX(X, m, sig.R.XScalar(), otherScalar{sig.S}) {
+		t.Error("S on another curve: VerifyFromRX succeeded")
+	}
+
+	if err := sig.VerifyErr(X, m); err != nil {
+		t.Errorf("VerifyErr failed on a valid signature: %v", err)
+	}
+}
+
+func TestSignature_RValue(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	for i := 0; i < 10; i++ {
+		x := sample.Scalar(rand.Reader, group)
+		sig := NewSignature(x, []byte("hello"), nil)
+		r, err := sig.RValue().MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(r, sig.ToCompactEth()[:32]) {
+			t.Error("RValue should match the r of ToCompactEth")
+		}
+	}
+
+	// find a point whose x coordinate is n + i, for a small i
+	n, _ := hex.DecodeString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+	var R curve.Point
+	var xBytes []byte
+	var i byte
+	for ; R == nil; i++ {
+		xBytes = append([]byte(nil), n...)
+		xBytes[31] += i
+		p := group.NewPoint()
+		if p.UnmarshalBinary(append([]byte{0x02}, xBytes...)) == nil {
+			R = p
+		}
+	}
+	sig := Signature{R: R, S: sample.Scalar(rand.Reader, group)}
+	r, err := sig.RValue().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := make([]byte, 32)
+	expected[31] = i - 1
+	if !bytes.Equal(r, expected) {
+		t.Errorf("RValue should be reduced modulo n, got %x, expected %x", r, expected)
+	}
+	if !bytes.Equal(sig.ToCompactEth()[:32], xBytes) {
+		t.Error("ToCompactEth should hold the unreduced x coordinate")
+	}
+}
+
+func TestCheckRecoveryID(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	m := []byte("hello")
+	x := sample.Scalar(rand.Reader, group)
+	X := x.ActOnBase()
+	for i := 0; i < 8; i++ {
+		compact := NewSignature(x, m, nil).ToCompactEth()
+		if !CheckRecoveryID(group, m, compact, X) {
+			t.Fatal("the recovery id of a valid signature was rejected")
+		}
+
+		flipped := append([]byte{}, compact...)
+		flipped[64] ^= 1
+		if CheckRecoveryID(group, m, flipped, X) {
+			t.Error("a flipped recovery id was accepted")
+		}
+		flipped[64] = 27
+		if CheckRecoveryID(group, m, flipped, X) {
+			t.Error("a recovery id out of range was accepted")
+		}
+		if CheckRecoveryID(group, []byte("goodbye"), compact, X) {
+			t.Error("a signature of another message was accepted")
+		}
+		if CheckRecoveryID(group, m, compact, sample.Scalar(rand.Reader, group).ActOnBase()) {
+			t.Error("another key was accepted")
+		}
+	}
+}
+
+func TestSignature_RBytes(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	x := sample.Scalar(rand.Reader, group)
+	for i := 0; i < 16; i++ {
+		sig := NewSignature(x, []byte{byte(i)}, nil)
+		RBytes := sig.RBytes()
+		if !bytes.Equal(RBytes[:], sig.R.XBytes()) {
+			t.Error("RBytes doesn't match the x coordinate of R")
+		}
+		if !bytes.Equal(RBytes[:], sig.ToCompactEth()[:32]) {
+			t.Error("RBytes doesn't match the R of ToCompactEth")
+		}
+	}
+}
+
+func BenchmarkSignature_RBytes(b *testing.B) {
+	sig := NewSignature(sample.Scalar(rand.Reader, curve.Secp256k1{}), []byte("hello"), nil)
+	b.Run("RBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = sig.RBytes()
+		}
+	})
+	b.Run("XBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var out [32]byte
+			copy(out[:], sig.R.XBytes())
+		}
+	})
+}
+
+func TestWhichKey(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	m := []byte("hello")
+	x := sample.Scalar(rand.Reader, group)
+	X := x.ActOnBase()
+	sig := NewSignature(x, m, nil)
+
+	candidates := make([]curve.Point, 4)
+	for i := range candidates {
+		candidates[i] = sample.Scalar(rand.Reader, group).ActOnBase()
+	}
+	if _, ok := WhichKey(candidates, m, *sig); ok {
+		t.Error("found a key which didn't sign")
+	}
+	if _, ok := WhichKey(nil, m, *sig); ok {
+		t.Error("found a key among no candidates")
+	}
+
+	for position := range candidates {
+		withKey := append([]curve.Point{}, candidates...)
+		withKey[position] = X
+		i, ok := WhichKey(withKey, m, *sig)
+		if !ok || i != position {
+			t.Errorf("expected the key at %d, got %d, %v", position, i, ok)
+		}
+		if _, ok = WhichKey(withKey, []byte("goodbye"), *sig); ok {
+			t.Error("found a key for another message")
+		}
+	}
+
+	// the first match is returned
+	i, ok := WhichKey([]curve.Point{candidates[0], X, X}, m, *sig)
+	if !ok || i != 1 {
+		t.Errorf("expected the first match at 1, got %d, %v", i, ok)
+	}
+}
+
+func TestDetectReuse(t *testing.T) {
+	group := curve.Secp256k1{}
+	x := sample.Scalar(rand.Reader, group)
+
+	var sigs []Signature
+	for _, m := range []string{"a", "b", "c"} {
+		sigs = append(sigs, *NewSignature(x, []byte(m), nil))
+	}
+	if reused, _ := DetectReuse(sigs); reused {
+		t.Error("detected reuse among fresh nonces")
+	}
+	if reused, _ := DetectReuse(append(sigs, sigs[1])); reused {
+		t.Error("the same signature twice isn't a reuse")
+	}
+	negated := Signature{R: sigs[1].R, S: group.NewScalar().Set(sigs[1].S).Negate()}
+	if reused, _ := DetectReuse(append(sigs, negated)); reused {
+		t.Error("the same signature with s negated isn't a reuse")
+	}
+
+	// two messages signed with the same nonce
+	k := sample.Scalar(rand.Reader, group)
+	a, b := NewSignature(x, []byte("a"), k), NewSignature(x, []byte("b"), k)
+	reused, R := DetectReuse(append(sigs, *a, *b))
+	if !reused || !R.Equal(a.R) {
+		t.Error("didn't detect the reused nonce")
+	}
+
+	// -R gives the same r, and leaks the key as well
+	flipped := NewSignature(x, []byte("b"), group.NewScalar().Set(k).Negate())
+	if reused, _ = DetectReuse([]Signature{*a, *flipped}); !reused {
+		t.Error("didn't detect the reused nonce with R negated")
+	}
+}
+
+// p256 stands in for a curve without signing support.
+type p256 struct {
+	curve.Secp256k1
+}
+
+func (p256) Name() string { return "P-256" }
+
+func TestCheckCurve(t *testing.T) {
+	if err := CheckCurve(curve.Secp256k1{}); err != nil {
+		t.Error("secp256k1 rejected:", err)
+	}
+	if err := CheckCurve(p256{}); !errors.Is(err, ErrUnsupportedCurve) {
+		t.Errorf("expected ErrUnsupportedCurve for P-256, got %v", err)
+	}
+}
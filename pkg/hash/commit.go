@@ -70,10 +70,15 @@ func (d Decommitment) Validate() error {
 // Commit creates a commitment to data, and returns a commitment hash, and a decommitment string such that
 // commitment = h(data, decommitment).
 func (hash *Hash) Commit(data ...interface{}) (Commitment, Decommitment, error) {
+	return hash.CommitFromSource(rand.Reader, data...)
+}
+
+// CommitFromSource is like Commit, but reads the decommitment from source.
+func (hash *Hash) CommitFromSource(source io.Reader, data ...interface{}) (Commitment, Decommitment, error) {
 	var err error
 	decommitment := Decommitment(make([]byte, params.SecBytes))
 
-	if _, err = rand.Read(decommitment); err != nil {
+	if _, err = io.ReadFull(source, decommitment); err != nil {
 		return nil, nil, fmt.Errorf("hash.Commit: failed to generate decommitment: %w", err)
 	}
 
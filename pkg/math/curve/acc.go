@@ -0,0 +1,69 @@
+package curve
+
+import "github.com/cronokirby/safenum"
+
+// ScalarAcc is a Scalar being computed by a long chain of additions and multiplications,
+// such as the Lagrange coefficients.
+//
+// Its methods update the accumulator in place, and share a single temporary Scalar,
+// so that a chain doesn't allocate anything after NewScalarAcc.
+// The result is obtained with Scalar, which returns a copy that later operations won't modify.
+type ScalarAcc struct {
+	value, tmp, one Scalar
+}
+
+// NewScalarAcc returns an accumulator for Scalars of group, starting at 0.
+func NewScalarAcc(group Curve) *ScalarAcc {
+	return &ScalarAcc{
+		value: group.NewScalar(),
+		tmp:   group.NewScalar(),
+		one:   group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1)),
+	}
+}
+
+// Set sets the accumulator to s.
+func (a *ScalarAcc) Set(s Scalar) *ScalarAcc {
+	a.value.Set(s)
+	return a
+}
+
+// SetOne sets the accumulator to 1, to start a product.
+func (a *ScalarAcc) SetOne() *ScalarAcc {
+	a.value.Set(a.one)
+	return a
+}
+
+// AddAssign adds s to the accumulator.
+func (a *ScalarAcc) AddAssign(s Scalar) *ScalarAcc {
+	a.value.Add(s)
+	return a
+}
+
+// SubAssign subtracts s from the accumulator.
+func (a *ScalarAcc) SubAssign(s Scalar) *ScalarAcc {
+	a.value.Add(a.tmp.Set(s).Negate())
+	return a
+}
+
+// MulAssign multiplies the accumulator by s.
+func (a *ScalarAcc) MulAssign(s Scalar) *ScalarAcc {
+	a.value.Mul(s)
+	return a
+}
+
+// MulDiffAssign multiplies the accumulator by x - y.
+func (a *ScalarAcc) MulDiffAssign(x, y Scalar) *ScalarAcc {
+	a.value.Mul(a.tmp.Set(y).Negate().Add(x))
+	return a
+}
+
+// InvertAssign replaces the accumulator by its inverse.
+func (a *ScalarAcc) InvertAssign() *ScalarAcc {
+	a.value.Invert()
+	return a
+}
+
+// Scalar returns the value of the accumulator, as a new Scalar.
+func (a *ScalarAcc) Scalar() Scalar {
+	return a.value.Curve().NewScalar().Set(a.value)
+}
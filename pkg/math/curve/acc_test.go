@@ -0,0 +1,38 @@
+package curve_test
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScalarAcc(t *testing.T) {
+	group := curve.Secp256k1{}
+	a := sample.Scalar(rand.Reader, group)
+	b := sample.Scalar(rand.Reader, group)
+	c := sample.Scalar(rand.Reader, group)
+	aCopy := group.NewScalar().Set(a)
+	bCopy := group.NewScalar().Set(b)
+
+	// ((a + b)⋅c - b)⋅(a - c) / a
+	acc := curve.NewScalarAcc(group)
+	result := acc.Set(a).AddAssign(b).MulAssign(c).SubAssign(b).MulDiffAssign(a, c).Scalar()
+	expected := group.NewScalar().Set(a).Add(b).Mul(c).Sub(b).Mul(group.NewScalar().Set(a).Sub(c))
+	assert.True(t, result.Equal(expected))
+
+	inverse := acc.Set(result).MulAssign(group.NewScalar().Set(a).Invert()).InvertAssign().Scalar()
+	expectedInverse := group.NewScalar().Set(expected).Mul(group.NewScalar().Set(a).Invert()).Invert()
+	assert.True(t, inverse.Equal(expectedInverse))
+	assert.True(t, result.Equal(expected), "the returned Scalar shouldn't change with the accumulator")
+
+	one := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1))
+	assert.True(t, acc.SetOne().Scalar().Equal(one))
+	assert.True(t, curve.NewScalarAcc(group).Scalar().IsZero())
+
+	assert.True(t, a.Equal(aCopy), "the inputs shouldn't be modified")
+	assert.True(t, b.Equal(bCopy), "the inputs shouldn't be modified")
+}
@@ -2,6 +2,8 @@ package curve
 
 import (
 	"encoding"
+	"hash"
+	"io"
 
 	"github.com/cronokirby/safenum"
 )
@@ -33,6 +35,14 @@ type Curve interface {
 	SafeScalarBytes() int
 	// Order returns a Modulus holding order of this group.
 	Order() *safenum.Modulus
+	// ValidatePoint checks that a Point belongs to this curve, and lies in its prime order subgroup.
+	//
+	// For curves of prime order, every point on the curve is in the group, and
+	// being on the curve is enough. Curves with a cofactor also need to check
+	// that the point isn't in a small subgroup, for example with InPrimeOrderSubgroup.
+	//
+	// Point.UnmarshalBinary is expected to call this before accepting a point.
+	ValidatePoint(Point) error
 }
 
 // Scalar represents a number modulo the order of some Elliptic Curve group.
@@ -62,6 +72,10 @@ type Scalar interface {
 	Sub(Scalar) Scalar
 	// Negate mutates this Scalar, replacing it with its negation.
 	Negate() Scalar
+	// Neg returns a new Scalar holding the negation of this Scalar.
+	//
+	// Unlike Negate, this doesn't mutate the Scalar, so it can be used on shared values.
+	Neg() Scalar
 	// Mul mutates this Scalar, replacing it with another.
 	Mul(Scalar) Scalar
 	// Invert mutates this Scalar, replacing it with its multiplicative inverse.
@@ -95,6 +109,11 @@ type Scalar interface {
 	Bytes() [32]byte
 
 	IsOverHalfOrder() bool
+
+	// Hash returns a digest of this Scalar, suitable for use as a map key.
+	//
+	// Equal Scalars should always have the same Hash.
+	Hash() [32]byte
 }
 
 // Point represents an element of our Elliptic Curve group.
@@ -149,6 +168,21 @@ type Point interface {
 	XBytes() []byte
 
 	IsOddYBit() uint32
+
+	// Hash returns a digest of the compressed encoding of this Point, suitable for use as a map key.
+	//
+	// Equal Points should always have the same Hash, regardless of their internal representation.
+	Hash() [32]byte
+}
+
+// InPrimeOrderSubgroup checks that p lies in the subgroup of prime order q, by checking that q⋅p is the identity.
+//
+// Since a scalar can't hold q itself, this computes (q-1)⋅p + p instead, which requires
+// Scalar.Act to perform a full scalar multiplication, even for points outside the subgroup.
+func InPrimeOrderSubgroup(p Point) bool {
+	group := p.Curve()
+	minusOne := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1)).Negate()
+	return minusOne.Act(p).Add(p).IsIdentity()
 }
 
 // MakeInt converts a scalar into an Int.
@@ -184,3 +218,15 @@ func FromHash(group Curve, h []byte) Scalar {
 	}
 	return group.NewScalar().SetNat(s)
 }
+
+// HashReaderToScalar streams the contents of r through h, and converts the digest to a Scalar, as in FromHash.
+//
+// This gives the same result as hashing the whole content in memory, and calling FromHash,
+// but lets large inputs be signed without reading them all at once.
+// h should be freshly created, since any data already written to it is part of the digest.
+func HashReaderToScalar(group Curve, r io.Reader, h hash.Hash) (Scalar, error) {
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return FromHash(group, h.Sum(nil)), nil
+}
@@ -0,0 +1,171 @@
+package curve_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashReaderToScalar(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	// several MB, much more than io.Copy reads at once
+	content := bytes.Repeat([]byte("streamed content\n"), 1<<18)
+
+	digest256 := sha256.Sum256(content)
+	s, err := curve.HashReaderToScalar(group, bytes.NewReader(content), sha256.New())
+	require.NoError(t, err)
+	assert.True(t, s.Equal(curve.FromHash(group, digest256[:])), "streamed SHA-256 doesn't match")
+
+	// a digest longer than the order gets truncated
+	digest512 := sha512.Sum512(content)
+	s, err = curve.HashReaderToScalar(group, bytes.NewReader(content), sha512.New())
+	require.NoError(t, err)
+	assert.True(t, s.Equal(curve.FromHash(group, digest512[:])), "streamed SHA-512 doesn't match")
+
+	readErr := errors.New("read failed")
+	_, err = curve.HashReaderToScalar(group, io.MultiReader(bytes.NewReader(content), &failingReader{readErr}), sha256.New())
+	assert.ErrorIs(t, err, readErr)
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// toyCurve is the additive group of integers modulo toyCofactor⋅toyOrder.
+//
+// It stands in for a curve with a cofactor: the multiples of toyCofactor form the subgroup
+// of prime order toyOrder, and the multiples of toyOrder form a small subgroup outside it.
+type toyCurve struct{}
+
+const (
+	toyOrder    = 11
+	toyCofactor = 4
+)
+
+func (toyCurve) NewPoint() curve.Point     { return new(toyPoint) }
+func (toyCurve) NewBasePoint() curve.Point { p := toyPoint(toyCofactor); return &p }
+func (toyCurve) NewScalar() curve.Scalar   { return new(toyScalar) }
+func (toyCurve) Name() string              { return "toy" }
+func (toyCurve) ScalarBits() int           { return 4 }
+func (toyCurve) SafeScalarBytes() int      { return 33 }
+func (toyCurve) Order() *safenum.Modulus   { return safenum.ModulusFromUint64(toyOrder) }
+
+func (toyCurve) ValidatePoint(p curve.Point) error {
+	if _, ok := p.(*toyPoint); !ok {
+		return errors.New("toy: not a toy point")
+	}
+	if !curve.InPrimeOrderSubgroup(p) {
+		return errors.New("toy: point not in the prime order subgroup")
+	}
+	return nil
+}
+
+type toyScalar uint64
+
+func (s *toyScalar) MarshalBinary() ([]byte, error) { return []byte{byte(*s)}, nil }
+func (s *toyScalar) UnmarshalBinary(data []byte) error {
+	*s = toyScalar(data[0] % toyOrder)
+	return nil
+}
+func (*toyScalar) Curve() curve.Curve { return toyCurve{} }
+func (s *toyScalar) Add(t curve.Scalar) curve.Scalar {
+	*s = (*s + *t.(*toyScalar)) % toyOrder
+	return s
+}
+func (s *toyScalar) Sub(t curve.Scalar) curve.Scalar { return s.Add(t.Neg()) }
+func (s *toyScalar) Negate() curve.Scalar {
+	*s = (toyOrder - *s) % toyOrder
+	return s
+}
+func (s *toyScalar) Neg() curve.Scalar { t := *s; return t.Negate() }
+func (s *toyScalar) Mul(t curve.Scalar) curve.Scalar {
+	*s = (*s * *t.(*toyScalar)) % toyOrder
+	return s
+}
+func (s *toyScalar) Invert() curve.Scalar {
+	t := *s
+	for i := 0; i < toyOrder-3; i++ {
+		s.Mul(&t)
+	}
+	return s
+}
+func (s *toyScalar) Equal(t curve.Scalar) bool { return *s == *t.(*toyScalar) }
+func (s *toyScalar) IsZero() bool              { return *s == 0 }
+func (s *toyScalar) Set(t curve.Scalar) curve.Scalar {
+	*s = *t.(*toyScalar)
+	return s
+}
+func (s *toyScalar) SetNat(n *safenum.Nat) curve.Scalar {
+	*s = toyScalar(new(big.Int).Mod(n.Big(), big.NewInt(toyOrder)).Uint64())
+	return s
+}
+func (s *toyScalar) Act(p curve.Point) curve.Point {
+	q := toyPoint(uint64(*s) * uint64(*p.(*toyPoint)) % (toyCofactor * toyOrder))
+	return &q
+}
+func (s *toyScalar) ActOnBase() curve.Point { return s.Act(toyCurve{}.NewBasePoint()) }
+func (s *toyScalar) Bytes() (b [32]byte)    { b[31] = byte(*s); return }
+func (s *toyScalar) IsOverHalfOrder() bool  { return *s > toyOrder/2 }
+func (s *toyScalar) Hash() (h [32]byte)     { return s.Bytes() }
+
+type toyPoint uint64
+
+func (p *toyPoint) MarshalBinary() ([]byte, error) { return []byte{byte(*p)}, nil }
+func (p *toyPoint) UnmarshalBinary(data []byte) error {
+	*p = toyPoint(data[0] % (toyCofactor * toyOrder))
+	return toyCurve{}.ValidatePoint(p)
+}
+func (p *toyPoint) MarshalBinaryEth() ([]byte, error)    { return p.MarshalBinary() }
+func (p *toyPoint) UnmarshalBinaryEth(data []byte) error { return p.UnmarshalBinary(data) }
+func (*toyPoint) Curve() curve.Curve                     { return toyCurve{} }
+func (p *toyPoint) Add(q curve.Point) curve.Point {
+	r := (*p + *q.(*toyPoint)) % (toyCofactor * toyOrder)
+	return &r
+}
+func (p *toyPoint) Sub(q curve.Point) curve.Point { return p.Add(q.Negate()) }
+func (p *toyPoint) Negate() curve.Point {
+	r := (toyCofactor*toyOrder - *p) % (toyCofactor * toyOrder)
+	return &r
+}
+func (p *toyPoint) Equal(q curve.Point) bool { return *p == *q.(*toyPoint) }
+func (p *toyPoint) IsIdentity() bool         { return *p == 0 }
+func (*toyPoint) XScalar() curve.Scalar      { return nil }
+func (p *toyPoint) XBytes() []byte           { return []byte{byte(*p)} }
+func (p *toyPoint) IsOddYBit() uint32        { return 0 }
+func (p *toyPoint) Hash() (h [32]byte)       { h[31] = byte(*p); return }
+
+func TestInPrimeOrderSubgroup(t *testing.T) {
+	group := toyCurve{}
+
+	for i := uint64(0); i < toyOrder; i++ {
+		p := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(i)).ActOnBase()
+		assert.True(t, curve.InPrimeOrderSubgroup(p), "%d⋅G", i)
+		assert.NoError(t, group.ValidatePoint(p), "%d⋅G", i)
+	}
+
+	// multiples of the order have order dividing the cofactor, and so do their sums with the subgroup
+	for i := uint64(1); i < toyCofactor; i++ {
+		small := toyPoint(i * toyOrder)
+		assert.False(t, curve.InPrimeOrderSubgroup(&small), "small order point %d", small)
+		assert.Error(t, group.ValidatePoint(&small), "small order point %d", small)
+		assert.Error(t, group.NewPoint().UnmarshalBinary([]byte{byte(small)}), "small order point %d", small)
+
+		mixed := small.Add(group.NewBasePoint())
+		assert.False(t, curve.InPrimeOrderSubgroup(mixed), "point %d+G", small)
+		assert.Error(t, group.ValidatePoint(mixed), "point %d+G", small)
+	}
+}
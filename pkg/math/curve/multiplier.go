@@ -0,0 +1,298 @@
+package curve
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/cronokirby/safenum"
+	"github.com/decred/dcrd/dcrec/secp256k1/v3"
+)
+
+// ScalarMultiplier multiplies points by a fixed scalar.
+type ScalarMultiplier interface {
+	// Mul returns s⋅p, for the scalar s this multiplier was created with.
+	Mul(p Point) Point
+}
+
+// PrecomputeScalar prepares s for repeated multiplications by different points.
+//
+// The result of Mul is the same as s.Act(p), but the work which only depends on s
+// is done once here, rather than in every multiplication.
+// Like Act, multiplications run in variable time.
+//
+// Curves without a specialized implementation fall back to s.Act.
+func PrecomputeScalar(s Scalar) ScalarMultiplier {
+	if s, ok := s.(*Secp256k1Scalar); ok {
+		return newSecp256k1Multiplier(s)
+	}
+	return actMultiplier{s.Curve().NewScalar().Set(s)}
+}
+
+// ScalarBaseMultAdd returns a⋅G + b⋅P, where G is the base point of group.
+//
+// For secp256k1, both multiplications share their doublings, as in Shamir's trick,
+// which is the shape of ECDSA verification. Like Act, this runs in variable time.
+func ScalarBaseMultAdd(group Curve, a, b Scalar, P Point) Point {
+	if _, ok := group.(Secp256k1); ok {
+		return secp256k1ScalarBaseMultAdd(secp256k1CastScalar(a), secp256k1CastScalar(b), secp256k1CastPoint(P))
+	}
+	return a.ActOnBase().Add(b.Act(P))
+}
+
+type actMultiplier struct {
+	s Scalar
+}
+
+func (m actMultiplier) Mul(p Point) Point {
+	return m.s.Act(p)
+}
+
+// wnafWidth is the window size used for the digits of a precomputed scalar.
+//
+// Each multiplication builds a table of 2^(wnafWidth-2) multiples of the point,
+// and the digits are non-zero once every wnafWidth+1 positions on average.
+const wnafWidth = 5
+
+var (
+	secp256k1N, _     = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	endomorphismA1, _ = new(big.Int).SetString("3086d221a7d46bcde86c90e49284eb15", 16)
+	endomorphismB1, _ = new(big.Int).SetString("-e4437ed6010e88286f547fa90abfe4c3", 16)
+	endomorphismA2, _ = new(big.Int).SetString("114ca50f7a8e2f3f657c1108d9d44cfd8", 16)
+	endomorphismB2, _ = new(big.Int).SetString("3086d221a7d46bcde86c90e49284eb15", 16)
+	// endomorphismBeta is a cube root of unity mod p, such that (x, y) ↦ (βx, y) is multiplication by λ,
+	// where λ is the cube root of unity mod n matching the lattice basis above.
+	endomorphismBeta secp256k1.FieldVal
+)
+
+func init() {
+	beta, _ := hex.DecodeString("7ae96a2b657c07106e64479eac3434e99cf0497512f58995c1396c28719501ee")
+	endomorphismBeta.SetByteSlice(beta)
+}
+
+// secp256k1Multiplier holds a scalar k split as k = k₁ + k₂⋅λ (mod n), with both halves in wNAF form.
+//
+// Then k⋅P = k₁⋅P + k₂⋅ϕ(P), where ϕ(x, y) = (βx, y), which halves the number of doublings.
+type secp256k1Multiplier struct {
+	k1, k2 []int8
+}
+
+func newSecp256k1Multiplier(s *Secp256k1Scalar) *secp256k1Multiplier {
+	k1, k2 := splitScalar(s)
+	return &secp256k1Multiplier{k1: k1, k2: k2}
+}
+
+// splitScalar returns k₁ and k₂ in wNAF form, such that k = k₁ + k₂⋅λ (mod n).
+func splitScalar(s *Secp256k1Scalar) (k1NAF, k2NAF []int8) {
+	kBytes := s.value.Bytes()
+	k := new(big.Int).SetBytes(kBytes[:])
+
+	// This is algorithm 3.74 from "Guide to Elliptic Curve Cryptography",
+	// without rounding c₁ and c₂, which only makes k₁ and k₂ a little larger.
+	c1 := new(big.Int).Mul(endomorphismB2, k)
+	c1.Div(c1, secp256k1N)
+	c2 := new(big.Int).Mul(endomorphismB1, k)
+	c2.Div(c2, secp256k1N)
+	// k₁ = k - c₁⋅a₁ + c₂⋅a₂, the sign of c₂ being flipped
+	k1 := new(big.Int).Sub(k, new(big.Int).Mul(c1, endomorphismA1))
+	k1.Add(k1, new(big.Int).Mul(c2, endomorphismA2))
+	// k₂ = c₂⋅b₂ - c₁⋅b₁
+	k2 := new(big.Int).Mul(c2, endomorphismB2)
+	k2.Sub(k2, new(big.Int).Mul(c1, endomorphismB1))
+
+	return wnaf(k1), wnaf(k2)
+}
+
+// wnaf returns the width-wnafWidth non-adjacent form of k, least significant digit first.
+//
+// Every digit is either 0 or odd, with |d| < 2^(wnafWidth-1).
+func wnaf(k *big.Int) []int8 {
+	negative := k.Sign() < 0
+	k = new(big.Int).Abs(k)
+	const window = 1 << wnafWidth
+	digits := make([]int8, 0, k.BitLen()+1)
+	for k.Sign() > 0 {
+		var d int64
+		if k.Bit(0) == 1 {
+			d = int64(k.Bits()[0] & (window - 1))
+			if d >= window/2 {
+				d -= window
+			}
+			k.Sub(k, big.NewInt(d))
+		}
+		if negative {
+			d = -d
+		}
+		digits = append(digits, int8(d))
+		k.Rsh(k, 1)
+	}
+	return digits
+}
+
+func (m *secp256k1Multiplier) Mul(p Point) Point {
+	point := secp256k1CastPoint(p)
+	out := new(Secp256k1Point)
+	if point.IsIdentity() {
+		return out
+	}
+	table, tablePhi := newWNAFTables(&point.value)
+	interleaveWNAF(&out.value, []wnafTerm{
+		{m.k1, table[:]},
+		{m.k2, tablePhi[:]},
+	})
+	return out
+}
+
+var (
+	secp256k1BaseTablesOnce                   sync.Once
+	secp256k1BaseTable, secp256k1BaseTablePhi *wnafTable
+)
+
+// secp256k1BaseTables returns the tables of the base point, computing them on first use.
+func secp256k1BaseTables() (table, tablePhi *wnafTable) {
+	secp256k1BaseTablesOnce.Do(func() {
+		G := Secp256k1{}.NewBasePoint().(*Secp256k1Point)
+		secp256k1BaseTable, secp256k1BaseTablePhi = newWNAFTables(&G.value)
+	})
+	return secp256k1BaseTable, secp256k1BaseTablePhi
+}
+
+// VerifyBaseTable checks the precomputed multiples of the base point of group against direct computation,
+// since a corrupted entry would silently make every result using it wrong.
+//
+// Each odd multiple (2i+1)⋅G is computed by repeated additions of G, and compared with the table
+// used by ScalarBaseMultAdd, and with ActOnBase, which has a table of its own.
+// The tables are small enough that every entry is checked.
+// Curves without precomputed tables always pass.
+func VerifyBaseTable(group Curve) error {
+	if _, ok := group.(Secp256k1); !ok {
+		return nil
+	}
+	table, tablePhi := secp256k1BaseTables()
+
+	G := group.NewBasePoint()
+	twoG := G.Add(G)
+	multiple := G
+	for i := range table {
+		if i > 0 {
+			multiple = multiple.Add(twoG)
+		}
+		k := 2*i + 1
+		if !group.NewScalar().SetNat(new(safenum.Nat).SetUint64(uint64(k))).ActOnBase().Equal(multiple) {
+			return fmt.Errorf("curve: %d⋅G computed with ActOnBase is wrong", k)
+		}
+
+		expected := secp256k1CastPoint(multiple).value
+		expected.ToAffine()
+		if entry := (&Secp256k1Point{value: table[i]}); !entry.Equal(&Secp256k1Point{value: expected}) {
+			return fmt.Errorf("curve: precomputed %d⋅G is wrong", k)
+		}
+		expected.X.Mul(&endomorphismBeta).Normalize()
+		if entry := (&Secp256k1Point{value: tablePhi[i]}); !entry.Equal(&Secp256k1Point{value: expected}) {
+			return fmt.Errorf("curve: precomputed ϕ(%d⋅G) is wrong", k)
+		}
+	}
+	return nil
+}
+
+func secp256k1ScalarBaseMultAdd(a, b *Secp256k1Scalar, P *Secp256k1Point) *Secp256k1Point {
+	baseTable, baseTablePhi := secp256k1BaseTables()
+
+	a1, a2 := splitScalar(a)
+	terms := []wnafTerm{
+		{a1, baseTable[:]},
+		{a2, baseTablePhi[:]},
+	}
+	if !P.IsIdentity() {
+		b1, b2 := splitScalar(b)
+		table, tablePhi := newWNAFTables(&P.value)
+		terms = append(terms, wnafTerm{b1, table[:]}, wnafTerm{b2, tablePhi[:]})
+	}
+	out := new(Secp256k1Point)
+	interleaveWNAF(&out.value, terms)
+	return out
+}
+
+// wnafTable holds the odd multiples (2i+1)⋅P of a point P, in affine coordinates.
+type wnafTable [1 << (wnafWidth - 2)]secp256k1.JacobianPoint
+
+// newWNAFTables returns the table of p, and the table of ϕ(p).
+//
+// p must not be the identity.
+func newWNAFTables(p *secp256k1.JacobianPoint) (table, tablePhi *wnafTable) {
+	table, tablePhi = new(wnafTable), new(wnafTable)
+	table[0].Set(p)
+	var double secp256k1.JacobianPoint
+	secp256k1.DoubleNonConst(p, &double)
+	for i := 1; i < len(table); i++ {
+		secp256k1.AddNonConst(&table[i-1], &double, &table[i])
+	}
+	toAffineBatch(table[:])
+	for i := range table {
+		tablePhi[i].Set(&table[i])
+		tablePhi[i].X.Mul(&endomorphismBeta).Normalize()
+	}
+	return table, tablePhi
+}
+
+// wnafTerm is a scalar in wNAF form, along with the table of the point it multiplies.
+type wnafTerm struct {
+	digits []int8
+	table  []secp256k1.JacobianPoint
+}
+
+// interleaveWNAF sets acc = ∑ₜ kₜ⋅Pₜ, sharing the doublings between all the terms.
+//
+// acc must be the identity.
+func interleaveWNAF(acc *secp256k1.JacobianPoint, terms []wnafTerm) {
+	n := 0
+	for _, t := range terms {
+		if len(t.digits) > n {
+			n = len(t.digits)
+		}
+	}
+	var neg secp256k1.JacobianPoint
+	for i := n - 1; i >= 0; i-- {
+		secp256k1.DoubleNonConst(acc, acc)
+		for _, t := range terms {
+			if i >= len(t.digits) {
+				continue
+			}
+			switch d := t.digits[i]; {
+			case d > 0:
+				secp256k1.AddNonConst(acc, &t.table[d/2], acc)
+			case d < 0:
+				neg.Set(&t.table[-d/2])
+				neg.Y.Negate(1).Normalize()
+				secp256k1.AddNonConst(acc, &neg, acc)
+			}
+		}
+	}
+}
+
+// toAffineBatch converts points to affine coordinates, using a single field inversion.
+//
+// This makes the additions with these points cheaper, since their z coordinate is 1.
+// None of the points may be the identity.
+func toAffineBatch(points []secp256k1.JacobianPoint) {
+	// prefix[i] = z₀⋯zᵢ₋₁
+	prefix := make([]secp256k1.FieldVal, len(points)+1)
+	prefix[0].SetInt(1)
+	for i := range points {
+		prefix[i+1].Mul2(&prefix[i], &points[i].Z)
+	}
+	var inv secp256k1.FieldVal
+	inv.Set(&prefix[len(points)]).Inverse()
+	var zInv, zInv2 secp256k1.FieldVal
+	for i := len(points) - 1; i >= 0; i-- {
+		// inv = (z₀⋯zᵢ)⁻¹
+		zInv.Mul2(&inv, &prefix[i])
+		inv.Mul(&points[i].Z)
+
+		zInv2.SquareVal(&zInv)
+		points[i].X.Mul(&zInv2).Normalize()
+		points[i].Y.Mul(zInv2.Mul(&zInv)).Normalize()
+		points[i].Z.SetInt(1)
+	}
+}
@@ -0,0 +1,109 @@
+package curve_test
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrecomputeScalar(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	one := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1))
+	scalars := []curve.Scalar{
+		group.NewScalar(),
+		one,
+		group.NewScalar().SetNat(new(safenum.Nat).SetUint64(31)),
+		group.NewScalar().Set(one).Negate(),
+	}
+	for i := 0; i < 20; i++ {
+		scalars = append(scalars, sample.Scalar(rand.Reader, group))
+	}
+	points := []curve.Point{
+		group.NewPoint(),
+		group.NewBasePoint(),
+		// left in Jacobian coordinates
+		sample.Scalar(rand.Reader, group).ActOnBase().Add(group.NewBasePoint()),
+	}
+	for i := 0; i < 5; i++ {
+		points = append(points, sample.Scalar(rand.Reader, group).ActOnBase())
+	}
+
+	for _, s := range scalars {
+		m := curve.PrecomputeScalar(s)
+		for _, p := range points {
+			assert.True(t, m.Mul(p).Equal(s.Act(p)), "Mul should match Act")
+		}
+	}
+}
+
+func TestScalarBaseMultAdd(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	one := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1))
+	scalars := []curve.Scalar{
+		group.NewScalar(),
+		one,
+		group.NewScalar().Set(one).Negate(),
+	}
+	for i := 0; i < 10; i++ {
+		scalars = append(scalars, sample.Scalar(rand.Reader, group))
+	}
+	points := []curve.Point{
+		group.NewPoint(),
+		group.NewBasePoint(),
+		sample.Scalar(rand.Reader, group).ActOnBase().Add(group.NewBasePoint()),
+		sample.Scalar(rand.Reader, group).ActOnBase(),
+	}
+
+	for _, a := range scalars {
+		for _, b := range scalars {
+			for _, p := range points {
+				expected := a.ActOnBase().Add(b.Act(p))
+				assert.True(t, curve.ScalarBaseMultAdd(group, a, b, p).Equal(expected), "should match a⋅G + b⋅P")
+			}
+		}
+	}
+}
+
+func BenchmarkScalarBaseMultAdd(b *testing.B) {
+	group := curve.Secp256k1{}
+	s1 := sample.Scalar(rand.Reader, group)
+	s2 := sample.Scalar(rand.Reader, group)
+	p := sample.Scalar(rand.Reader, group).ActOnBase()
+	b.Run("fused", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			curve.ScalarBaseMultAdd(group, s1, s2, p)
+		}
+	})
+	b.Run("separate", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s1.ActOnBase().Add(s2.Act(p))
+		}
+	})
+}
+
+func BenchmarkPrecomputedScalarMul(b *testing.B) {
+	group := curve.Secp256k1{}
+	s := sample.Scalar(rand.Reader, group)
+	p := sample.Scalar(rand.Reader, group).ActOnBase()
+	m := curve.PrecomputeScalar(s)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Mul(p)
+	}
+}
+
+func BenchmarkScalarAct(b *testing.B) {
+	group := curve.Secp256k1{}
+	s := sample.Scalar(rand.Reader, group)
+	p := sample.Scalar(rand.Reader, group).ActOnBase()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Act(p)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/cronokirby/safenum"
 	"github.com/decred/dcrd/dcrec/secp256k1/v3"
+	"github.com/zeebo/blake3"
 )
 
 var secp256k1BaseX, secp256k1BaseY secp256k1.FieldVal
@@ -51,6 +52,80 @@ func (Secp256k1) Order() *safenum.Modulus {
 	return secp256k1Order
 }
 
+// ValidatePoint implements Curve.
+//
+// secp256k1 has prime order, so this only checks that p is on the curve.
+func (Secp256k1) ValidatePoint(p Point) error {
+	point, ok := p.(*Secp256k1Point)
+	if !ok || point == nil {
+		return errors.New("secp256k1: not a secp256k1 point")
+	}
+	if point.IsIdentity() {
+		return nil
+	}
+	v := point.value
+	v.ToAffine()
+	if !secp256k1.NewPublicKey(&v.X, &v.Y).IsOnCurve() {
+		return errors.New("secp256k1: point not on curve")
+	}
+	return nil
+}
+
+// Known parameters of secp256k1, from SEC 2, kept in their encoded form,
+// independently of the values used by the implementation.
+const (
+	secp256k1FieldPrimeHex = "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f"
+	secp256k1OrderHex      = "fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141"
+	secp256k1BaseHex       = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+)
+
+// Validate checks that the parameters of the curve implementation match the published ones:
+// the field prime, the order of the group, and the base point.
+//
+// It also checks that multiplying by the base point, which uses a precomputed table, agrees with it,
+// as well as the other tables of VerifyBaseTable.
+// This can be called at startup, to detect a corrupted or tampered build.
+func (c Secp256k1) Validate() error {
+	if p := secp256k1.S256().Params().P; p.Text(16) != secp256k1FieldPrimeHex {
+		return fmt.Errorf("secp256k1: field prime is %x", p)
+	}
+	if err := validateSecp256k1(c); err != nil {
+		return err
+	}
+	return VerifyBaseTable(c)
+}
+
+// validateSecp256k1 checks the order and base point of group, which should be secp256k1.
+func validateSecp256k1(group Curve) error {
+	order := hex.EncodeToString(group.Order().Bytes())
+	if order != secp256k1OrderHex {
+		return fmt.Errorf("secp256k1: order is %s", order)
+	}
+
+	G := group.NewBasePoint()
+	if err := group.ValidatePoint(G); err != nil {
+		return fmt.Errorf("secp256k1: base point: %w", err)
+	}
+	GBytes, err := G.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("secp256k1: base point: %w", err)
+	}
+	if base := hex.EncodeToString(GBytes); base != secp256k1BaseHex {
+		return fmt.Errorf("secp256k1: base point is %s", base)
+	}
+
+	// 1⋅G = G, and (n-1)⋅G = -G, using the table for G
+	one := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1))
+	if !one.ActOnBase().Equal(G) {
+		return errors.New("secp256k1: 1⋅G is not the base point")
+	}
+	minusOne := group.NewScalar().Set(one).Negate()
+	if !minusOne.ActOnBase().Equal(G.Negate()) || !minusOne.Act(G).Equal(G.Negate()) {
+		return errors.New("secp256k1: (n-1)⋅G is not -G")
+	}
+	return nil
+}
+
 func (Secp256k1) LiftX(data []byte) (*Secp256k1Point, error) {
 	out := new(Secp256k1Point)
 	out.value.Z.SetInt(1)
@@ -135,6 +210,12 @@ func (s *Secp256k1Scalar) Negate() Scalar {
 	return s
 }
 
+func (s *Secp256k1Scalar) Neg() Scalar {
+	out := new(Secp256k1Scalar)
+	out.value.NegateVal(&s.value)
+	return out
+}
+
 func (s *Secp256k1Scalar) Equal(that Scalar) bool {
 	other := secp256k1CastScalar(that)
 
@@ -179,6 +260,11 @@ func (p *Secp256k1Scalar) IsOverHalfOrder() bool {
 	return p.value.IsOverHalfOrder()
 }
 
+func (p *Secp256k1Scalar) Hash() [32]byte {
+	data := p.value.Bytes()
+	return blake3.Sum256(append([]byte("secp256k1 scalar"), data[:]...))
+}
+
 type Secp256k1Point struct {
 	value secp256k1.JacobianPoint
 }
@@ -200,6 +286,17 @@ func (p *Secp256k1Point) XBytes() []byte {
 	return p.value.X.Bytes()[:]
 }
 
+// PutXBytes writes the x coordinate of p to b, like XBytes but without allocating.
+//
+// A point which is already affine, such as the R of a signature after its first serialization,
+// isn't inverted again.
+func (p *Secp256k1Point) PutXBytes(b *[32]byte) {
+	if !p.value.Z.IsOne() {
+		p.value.ToAffine()
+	}
+	p.value.X.PutBytes(b)
+}
+
 func (p *Secp256k1Point) MarshalBinary() ([]byte, error) {
 	out := make([]byte, 33)
 	// This will modify p, but still return an equivalent value
@@ -211,10 +308,17 @@ func (p *Secp256k1Point) MarshalBinary() ([]byte, error) {
 	return out, nil
 }
 
+// UnmarshalBinary decodes a point in the compressed form written by MarshalBinary.
+//
+// The prefix must be 0x02 or 0x03, and the x coordinate less than the field prime.
 func (p *Secp256k1Point) UnmarshalBinary(data []byte) error {
 	if len(data) != 33 {
 		return fmt.Errorf("invalid length for secp256k1Point: %d", len(data))
 	}
+	// only the canonical compressed encoding is accepted, so that a point has a single encoding
+	if data[0] != 2 && data[0] != 3 {
+		return fmt.Errorf("secp256k1Point.UnmarshalBinary: invalid prefix 0x%02x", data[0])
+	}
 	p.value.Z.SetInt(1)
 	if p.value.X.SetByteSlice(data[1:]) {
 		return fmt.Errorf("secp256k1Point.UnmarshalBinary: x coordinate out of range")
@@ -222,7 +326,7 @@ func (p *Secp256k1Point) UnmarshalBinary(data []byte) error {
 	if !secp256k1.DecompressY(&p.value.X, data[0] == 3, &p.value.Y) {
 		return fmt.Errorf("secp256k1Point.UnmarshalBinary: x coordinate not on curve")
 	}
-	return nil
+	return Secp256k1{}.ValidatePoint(p)
 }
 
 // MarshalBinaryEth converts a Secp256k1Point on the curve into the uncompressed form specified in
@@ -310,9 +414,77 @@ func (p *Secp256k1Point) IsOddYBit() uint32 {
 	return p.value.Y.IsOddBit()
 }
 
+func (p *Secp256k1Point) Hash() [32]byte {
+	data := make([]byte, 0, 16+33)
+	data = append(data, "secp256k1 point"...)
+	if p.IsIdentity() {
+		data = append(data, 0)
+		return blake3.Sum256(data)
+	}
+	// Work on a copy, so that hashing doesn't race with other readers of p
+	v := p.value
+	v.ToAffine()
+	x := v.X.Bytes()
+	data = append(data, byte(v.Y.IsOddBit())+2)
+	data = append(data, x[:]...)
+	return blake3.Sum256(data)
+}
+
 func (p *Secp256k1Point) XScalar() Scalar {
 	out := new(Secp256k1Scalar)
 	p.value.ToAffine()
 	out.value.SetBytes(p.value.X.Bytes())
 	return out
 }
+
+// MultiScalarMult computes Σ scalars[i] * points[i].
+//
+// This uses Straus' method with 4 bit windows, sharing the doublings across
+// all of the terms, which is considerably faster than summing individual
+// scalar multiplications once there are more than a couple of terms.
+//
+// This function runs in variable time, and should only be used on public values.
+func (Secp256k1) MultiScalarMult(scalars []Scalar, points []Point) (Point, error) {
+	if len(scalars) != len(points) {
+		return nil, fmt.Errorf("secp256k1.MultiScalarMult: got %d scalars and %d points", len(scalars), len(points))
+	}
+
+	// tables[i][j] holds (j+1) * points[i]
+	tables := make([][15]secp256k1.JacobianPoint, len(points))
+	digits := make([][32]byte, len(scalars))
+	for i := range points {
+		s, ok := scalars[i].(*Secp256k1Scalar)
+		if !ok {
+			return nil, fmt.Errorf("secp256k1.MultiScalarMult: scalar %d is not a Secp256k1Scalar", i)
+		}
+		p, ok := points[i].(*Secp256k1Point)
+		if !ok {
+			return nil, fmt.Errorf("secp256k1.MultiScalarMult: point %d is not a Secp256k1Point", i)
+		}
+		digits[i] = s.value.Bytes()
+		table := &tables[i]
+		table[0].Set(&p.value)
+		for j := 1; j < len(table); j++ {
+			secp256k1.AddNonConst(&table[j-1], &p.value, &table[j])
+		}
+	}
+
+	out := new(Secp256k1Point)
+	acc := &out.value
+	for k := 0; k < 64; k++ {
+		for d := 0; d < 4; d++ {
+			secp256k1.DoubleNonConst(acc, acc)
+		}
+		for i := range digits {
+			b := digits[i][k/2]
+			if k%2 == 0 {
+				b >>= 4
+			}
+			b &= 0xF
+			if b != 0 {
+				secp256k1.AddNonConst(acc, &tables[i][b-1], acc)
+			}
+		}
+	}
+	return out, nil
+}
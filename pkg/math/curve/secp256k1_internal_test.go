@@ -0,0 +1,71 @@
+package curve
+
+import (
+	"testing"
+
+	"github.com/cronokirby/safenum"
+	"github.com/decred/dcrd/dcrec/secp256k1/v3"
+)
+
+// tamperedCurve is secp256k1 with some of its parameters replaced.
+type tamperedCurve struct {
+	Secp256k1
+	base  Point
+	order *safenum.Modulus
+}
+
+func (c tamperedCurve) NewBasePoint() Point {
+	if c.base != nil {
+		return c.base
+	}
+	return c.Secp256k1.NewBasePoint()
+}
+
+func (c tamperedCurve) Order() *safenum.Modulus {
+	if c.order != nil {
+		return c.order
+	}
+	return c.Secp256k1.Order()
+}
+
+func TestSecp256k1Validate(t *testing.T) {
+	group := Secp256k1{}
+	if err := group.Validate(); err != nil {
+		t.Fatal("secp256k1 should be valid:", err)
+	}
+	if err := validateSecp256k1(tamperedCurve{}); err != nil {
+		t.Fatal("an untampered curve should be valid:", err)
+	}
+
+	two := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(2))
+	if err := validateSecp256k1(tamperedCurve{base: two.ActOnBase()}); err == nil {
+		t.Error("a different base point should be detected")
+	}
+
+	order := new(safenum.Nat).SetBytes(group.Order().Bytes())
+	order.Add(order, new(safenum.Nat).SetUint64(2), -1)
+	if err := validateSecp256k1(tamperedCurve{order: safenum.ModulusFromNat(order)}); err == nil {
+		t.Error("a different order should be detected")
+	}
+}
+
+func TestVerifyBaseTable(t *testing.T) {
+	group := Secp256k1{}
+	if err := VerifyBaseTable(group); err != nil {
+		t.Fatal(err)
+	}
+
+	table, tablePhi := secp256k1BaseTables()
+	for _, tbl := range []*wnafTable{table, tablePhi} {
+		saved := tbl[5]
+		tbl[5].X.Add(new(secp256k1.FieldVal).SetInt(1)).Normalize()
+		err, validateErr := VerifyBaseTable(group), group.Validate()
+		tbl[5] = saved
+		if err == nil || validateErr == nil {
+			t.Error("a corrupted entry wasn't detected")
+		}
+	}
+	if err := VerifyBaseTable(group); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,106 @@
+package curve_test
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPointHash(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	x := sample.Scalar(rand.Reader, group)
+	y := sample.Scalar(rand.Reader, group)
+	// X1 is the result of an addition, and is left in Jacobian coordinates
+	X1 := x.ActOnBase().Add(group.NewPoint())
+	X2 := group.NewPoint()
+	data, _ := x.ActOnBase().MarshalBinary()
+	assert.NoError(t, X2.UnmarshalBinary(data))
+	assert.Equal(t, X1.Hash(), X2.Hash())
+	assert.NotEqual(t, X1.Hash(), X1.Negate().Hash())
+	assert.NotEqual(t, X1.Hash(), y.ActOnBase().Hash())
+	assert.NotEqual(t, X1.Hash(), group.NewPoint().Hash())
+	assert.Equal(t, group.NewPoint().Hash(), X1.Sub(X2).Hash())
+
+	seen := make(map[[32]byte]bool)
+	for i := 0; i < 100; i++ {
+		h := sample.Scalar(rand.Reader, group).ActOnBase().Hash()
+		assert.False(t, seen[h])
+		seen[h] = true
+	}
+}
+
+func TestScalarHash(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	x := sample.Scalar(rand.Reader, group)
+	y := group.NewScalar().Set(x)
+	assert.Equal(t, x.Hash(), y.Hash())
+	y.Add(group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1)))
+	assert.NotEqual(t, x.Hash(), y.Hash())
+}
+
+func TestNegate(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	x := sample.Scalar(rand.Reader, group)
+	X := x.ActOnBase()
+	assert.True(t, X.Negate().Add(X).IsIdentity())
+	assert.True(t, X.Add(X.Negate()).IsIdentity())
+
+	xCopy := group.NewScalar().Set(x)
+	minusX := x.Neg()
+	assert.True(t, x.Equal(xCopy), "Neg shouldn't mutate its receiver")
+	assert.True(t, minusX.Add(x).IsZero())
+	assert.True(t, xCopy.Negate().Equal(x.Neg()))
+	assert.True(t, x.Neg().ActOnBase().Equal(X.Negate()))
+}
+
+// foreignPoint is a Point which doesn't belong to secp256k1.
+type foreignPoint struct {
+	curve.Point
+}
+
+func TestValidatePoint(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	x := sample.Scalar(rand.Reader, group)
+	for _, p := range []curve.Point{group.NewBasePoint(), group.NewPoint(), x.ActOnBase(), x.ActOnBase().Add(group.NewBasePoint())} {
+		assert.NoError(t, group.ValidatePoint(p))
+		assert.True(t, curve.InPrimeOrderSubgroup(p))
+	}
+	assert.Error(t, group.ValidatePoint(nil))
+	assert.Error(t, group.ValidatePoint(foreignPoint{x.ActOnBase()}))
+
+	// x = 0 isn't on the curve, since 7 isn't a square mod p
+	data := make([]byte, 33)
+	data[0] = 2
+	assert.Error(t, group.NewPoint().UnmarshalBinary(data))
+}
+
+func TestUnmarshalNonCanonical(t *testing.T) {
+	group := curve.Secp256k1{}
+	data, err := sample.Scalar(rand.Reader, group).ActOnBase().MarshalBinary()
+	require.NoError(t, err)
+	assert.NoError(t, group.NewPoint().UnmarshalBinary(data))
+
+	for _, prefix := range []byte{0x00, 0x01, 0x04, 0x06, 0x07, 0xff} {
+		encoded := append([]byte{prefix}, data[1:]...)
+		assert.Error(t, group.NewPoint().UnmarshalBinary(encoded), "prefix 0x%02x", prefix)
+	}
+
+	// x = p and x = p + 1, where p + 1 would be read as x = 1 if it were reduced
+	prime, _ := hex.DecodeString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F")
+	for _, x := range [][]byte{prime, append(append([]byte{}, prime[:31]...), 0x30)} {
+		for _, prefix := range []byte{0x02, 0x03} {
+			encoded := append([]byte{prefix}, x...)
+			assert.Error(t, group.NewPoint().UnmarshalBinary(encoded), "x = %x", x)
+		}
+	}
+}
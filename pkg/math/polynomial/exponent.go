@@ -164,6 +164,16 @@ func (p *Exponent) Constant() curve.Point {
 	return p.coefficients[0]
 }
 
+// Coefficients returns a copy of the coefficients [A₀, …, Aₜ] of F(X) = A₀ + A₁•X + … + Aₜ•Xᵗ,
+// where A₀ is the identity if IsConstant is set.
+func (p *Exponent) Coefficients() []curve.Point {
+	coefficients := make([]curve.Point, 0, p.Degree()+1)
+	if p.IsConstant {
+		coefficients = append(coefficients, p.group.NewPoint())
+	}
+	return append(coefficients, p.coefficients...)
+}
+
 // WriteTo implements io.WriterTo and should be used within the hash.Hash function.
 func (p *Exponent) WriteTo(w io.Writer) (int64, error) {
 	data, err := p.MarshalBinary()
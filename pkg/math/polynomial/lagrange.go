@@ -1,7 +1,7 @@
 package polynomial
 
 import (
-	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/party"
 )
@@ -13,12 +13,14 @@ func Lagrange(group curve.Curve, interpolationDomain []party.ID) map[party.ID]cu
 
 // LagrangeFor returns the Lagrange coefficients at 0 for all parties in the given subset.
 func LagrangeFor(group curve.Curve, interpolationDomain []party.ID, subset ...party.ID) map[party.ID]curve.Scalar {
+	defer trace.Region("polynomial: lagrange")()
+	acc := curve.NewScalarAcc(group)
 	// numerator = x₀ * … * xₖ
-	scalars, numerator := getScalarsAndNumerator(group, interpolationDomain)
+	scalars, numerator := getScalarsAndNumerator(acc, group, interpolationDomain)
 
 	coefficients := make(map[party.ID]curve.Scalar, len(subset))
 	for _, j := range subset {
-		coefficients[j] = lagrange(group, scalars, numerator, j)
+		coefficients[j] = lagrange(acc, scalars, numerator, j)
 	}
 	return coefficients
 }
@@ -29,46 +31,41 @@ func LagrangeSingle(group curve.Curve, interpolationDomain []party.ID, j party.I
 }
 
 // getScalarsAndNumerator returns the Scalars associated to the list of party.IDs.
-func getScalarsAndNumerator(group curve.Curve, interpolationDomain []party.ID) (map[party.ID]curve.Scalar, curve.Scalar) {
+func getScalarsAndNumerator(acc *curve.ScalarAcc, group curve.Curve, interpolationDomain []party.ID) (map[party.ID]curve.Scalar, curve.Scalar) {
 	// numerator = x₀ * … * xₖ
-	numerator := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1))
+	acc.SetOne()
 	scalars := make(map[party.ID]curve.Scalar, len(interpolationDomain))
 	for _, id := range interpolationDomain {
 		xi := id.Scalar(group)
 		scalars[id] = xi
-		numerator.Mul(xi)
+		acc.MulAssign(xi)
 	}
-	return scalars, numerator
+	return scalars, acc.Scalar()
 }
 
 // lagrange returns the Lagrange coefficient lⱼ(0), for j in the interpolation domain.
-// The numerator is provided beforehand for efficiency reasons.
+// The numerator is provided beforehand for efficiency reasons, and acc is used for the computation.
 //
 // The following formulas are taken from
 // https://en.wikipedia.org/wiki/Lagrange_polynomial
 //			                 x₀ ⋅⋅⋅ xₖ
 // lⱼ(0) =	--------------------------------------------------
 //			xⱼ⋅(x₀ - xⱼ)⋅⋅⋅(xⱼ₋₁ - xⱼ)⋅(xⱼ₊₁ - xⱼ)⋅⋅⋅(xₖ - xⱼ).
-func lagrange(group curve.Curve, interpolationDomain map[party.ID]curve.Scalar, numerator curve.Scalar, j party.ID) curve.Scalar {
+func lagrange(acc *curve.ScalarAcc, interpolationDomain map[party.ID]curve.Scalar, numerator curve.Scalar, j party.ID) curve.Scalar {
 	xJ := interpolationDomain[j]
-	tmp := group.NewScalar()
 
 	// denominator = xⱼ⋅(xⱼ - x₀)⋅⋅⋅(xⱼ₋₁ - xⱼ)⋅(xⱼ₊₁ - xⱼ)⋅⋅⋅(xₖ - xⱼ)
-	denominator := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1))
+	acc.SetOne()
 	for i, xI := range interpolationDomain {
 		if i == j {
 			// lⱼ *= xⱼ
-			denominator.Mul(xJ)
+			acc.MulAssign(xJ)
 			continue
 		}
-		// tmp = xᵢ - xⱼ
-		tmp.Set(xJ).Negate().Add(xI)
 		// lⱼ *= xᵢ - xⱼ
-		denominator.Mul(tmp)
+		acc.MulDiffAssign(xI, xJ)
 	}
 
 	// lⱼ = numerator/denominator
-	lJ := denominator.Invert()
-	lJ.Mul(numerator)
-	return lJ
+	return acc.InvertAssign().MulAssign(numerator).Scalar()
 }
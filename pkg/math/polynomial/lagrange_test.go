@@ -29,3 +29,34 @@ func TestLagrange(t *testing.T) {
 	assert.True(t, sumEven.Equal(one))
 	assert.True(t, sumOdd.Equal(one))
 }
+
+// TestLagrangeReference compares the coefficients with the formula computed with a new Scalar for each step.
+func TestLagrangeReference(t *testing.T) {
+	group := curve.Secp256k1{}
+	signers := test.PartyIDs(20)
+	coefs := polynomial.Lagrange(group, signers)
+	for _, j := range signers {
+		xJ := j.Scalar(group)
+		numerator := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1))
+		denominator := group.NewScalar().Set(xJ)
+		for _, i := range signers {
+			xI := i.Scalar(group)
+			numerator = group.NewScalar().Set(numerator).Mul(xI)
+			if i != j {
+				denominator = group.NewScalar().Set(denominator).Mul(group.NewScalar().Set(xI).Sub(xJ))
+			}
+		}
+		expected := numerator.Mul(denominator.Invert())
+		assert.True(t, coefs[j].Equal(expected), "wrong coefficient for %s", j)
+	}
+}
+
+func BenchmarkLagrange(b *testing.B) {
+	group := curve.Secp256k1{}
+	signers := test.PartyIDs(20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		polynomial.Lagrange(group, signers)
+	}
+}
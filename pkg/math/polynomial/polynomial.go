@@ -2,6 +2,7 @@ package polynomial
 
 import (
 	"crypto/rand"
+	"io"
 
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/math/sample"
@@ -16,6 +17,13 @@ type Polynomial struct {
 // NewPolynomial generates a Polynomial f(X) = secret + a₁⋅X + … + aₜ⋅Xᵗ,
 // with coefficients in ℤₚ, and degree t.
 func NewPolynomial(group curve.Curve, degree int, constant curve.Scalar) *Polynomial {
+	return NewPolynomialFromSource(rand.Reader, group, degree, constant)
+}
+
+// NewPolynomialFromSource is like NewPolynomial, but samples the coefficients from source.
+//
+// This should only be used with a source other than crypto/rand for testing.
+func NewPolynomialFromSource(source io.Reader, group curve.Curve, degree int, constant curve.Scalar) *Polynomial {
 	polynomial := &Polynomial{
 		group:        group,
 		coefficients: make([]curve.Scalar, degree+1),
@@ -28,7 +36,7 @@ func NewPolynomial(group curve.Curve, degree int, constant curve.Scalar) *Polyno
 	polynomial.coefficients[0] = constant
 
 	for i := 1; i <= degree; i++ {
-		polynomial.coefficients[i] = sample.Scalar(rand.Reader, group)
+		polynomial.coefficients[i] = sample.Scalar(source, group)
 	}
 
 	return polynomial
@@ -1,10 +1,12 @@
 package sample
 
 import (
+	"errors"
 	"io"
 	"math"
 	"math/big"
 	"sync"
+	"sync/atomic"
 
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/params"
@@ -156,12 +158,34 @@ func tryBlumPrime(rand io.Reader) *safenum.Nat {
 	return nil
 }
 
+// DefaultMaxPrimeAttempts is the number of candidate windows Paillier will sieve
+// before giving up on finding a pair of safe primes.
+//
+// With a working source of randomness, only a small fraction of this is ever needed.
+const DefaultMaxPrimeAttempts = 10000
+
+// ErrPrimeAttemptsExhausted is returned when no safe primes were found within the maximum number of attempts.
+//
+// This usually indicates that the source of randomness is broken.
+var ErrPrimeAttemptsExhausted = errors.New("sample: exhausted attempts at generating safe primes")
+
 // Paillier generate the necessary integers for a Paillier key pair.
 // p, q are safe primes ((p - 1) / 2 is also prime), and Blum primes (p = 3 mod 4)
 // n = pq.
-func Paillier(rand io.Reader, pl *pool.Pool) (p, q *safenum.Nat) {
+//
+// This gives up after DefaultMaxPrimeAttempts candidates, returning ErrPrimeAttemptsExhausted.
+func Paillier(rand io.Reader, pl *pool.Pool) (p, q *safenum.Nat, err error) {
+	return PaillierWithMaxAttempts(rand, pl, DefaultMaxPrimeAttempts)
+}
+
+// PaillierWithMaxAttempts is like Paillier, but gives up after maxAttempts candidates instead.
+func PaillierWithMaxAttempts(rand io.Reader, pl *pool.Pool, maxAttempts int) (p, q *safenum.Nat, err error) {
 	reader := pool.NewLockedReader(rand)
+	attempts := int64(0)
 	results := pl.Search(2, func() interface{} {
+		if atomic.AddInt64(&attempts, 1) > int64(maxAttempts) {
+			return ErrPrimeAttemptsExhausted
+		}
 		q := tryBlumPrime(reader)
 		// You have to do this, because of how Go handles nil.
 		if q == nil {
@@ -169,6 +193,10 @@ func Paillier(rand io.Reader, pl *pool.Pool) (p, q *safenum.Nat) {
 		}
 		return q
 	})
-	p, q = results[0].(*safenum.Nat), results[1].(*safenum.Nat)
-	return
+	p, okP := results[0].(*safenum.Nat)
+	q, okQ := results[1].(*safenum.Nat)
+	if !okP || !okQ {
+		return nil, nil, ErrPrimeAttemptsExhausted
+	}
+	return p, q, nil
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/params"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/zeebo/blake3"
 )
 
 const maxIterations = 255
@@ -88,27 +89,50 @@ func Pedersen(rand io.Reader, phi *safenum.Nat, n *safenum.Modulus) (s, t, lambd
 }
 
 // Scalar returns a new *curve.Scalar by reading bytes from rand.
+//
+// The result is never zero, since it is used for secrets and nonces:
+// bytes which reduce to zero modulo the order, including the order itself, are rejected and new ones are read.
+// This only happens with negligible probability for a good source of randomness.
 func Scalar(rand io.Reader, group curve.Curve) curve.Scalar {
 	buffer := make([]byte, group.SafeScalarBytes())
-	mustReadBits(rand, buffer)
-	n := new(safenum.Nat).SetBytes(buffer)
-	return group.NewScalar().SetNat(n)
-}
-
-// ScalarUnit returns a new *curve.Scalar by reading bytes from rand.
-func ScalarUnit(rand io.Reader, group curve.Curve) curve.Scalar {
 	for i := 0; i < maxIterations; i++ {
-		s := Scalar(rand, group)
-		if !s.IsZero() {
+		mustReadBits(rand, buffer)
+		n := new(safenum.Nat).SetBytes(buffer)
+		if s := group.NewScalar().SetNat(n); !s.IsZero() {
 			return s
 		}
 	}
 	panic(ErrMaxIterations)
 }
 
+// ScalarUnit returns a new *curve.Scalar by reading bytes from rand.
+//
+// It is the same as Scalar, which never returns zero either.
+func ScalarUnit(rand io.Reader, group curve.Curve) curve.Scalar {
+	return Scalar(rand, group)
+}
+
 // ScalarPointPair returns a new *curve.Scalar/*curve.Point tuple (x,X) by reading bytes from rand.
 // The tuple satisfies X = x⋅G where G is the base point of the curve.
 func ScalarPointPair(rand io.Reader, group curve.Curve) (curve.Scalar, curve.Point) {
 	s := Scalar(rand, group)
 	return s, s.ActOnBase()
 }
+
+// Split derives n independent sources of randomness from rand.
+//
+// Jobs running in parallel can each read from their own source, instead of sharing rand,
+// so that what they sample only depends on rand, and not on the order in which they run.
+func Split(rand io.Reader, n int) []io.Reader {
+	out := make([]io.Reader, n)
+	key := make([]byte, 32)
+	for i := range out {
+		mustReadBits(rand, key)
+		h, err := blake3.NewKeyed(key)
+		if err != nil {
+			panic(err)
+		}
+		out[i] = h.Digest()
+	}
+	return out
+}
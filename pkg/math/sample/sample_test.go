@@ -1,12 +1,15 @@
 package sample
 
 import (
+	"bytes"
 	"crypto/rand"
+	"io"
 	"math/big"
 	"testing"
 
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/params"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/pool"
 )
 
@@ -25,7 +28,10 @@ func TestPaillier(t *testing.T) {
 	pl := pool.NewPool(0)
 	defer pl.TearDown()
 
-	pNat, _ := Paillier(rand.Reader, pl)
+	pNat, _, err := Paillier(rand.Reader, pl)
+	if err != nil {
+		t.Fatal(err)
+	}
 	p := pNat.Big()
 	if !p.ProbablyPrime(blumPrimeProbabilityIterations) {
 		t.Error("BlumPrime generated a non prime number: ", p)
@@ -37,6 +43,39 @@ func TestPaillier(t *testing.T) {
 	}
 }
 
+// topReader is a deterministic source of randomness which only returns 0xFF bytes.
+//
+// The candidate window it gives tryBlumPrime starts at 2^BitsBlumPrime - 1, which is divisible by 3,
+// and every later candidate is too large, so no safe prime is ever found.
+type topReader struct {
+	reads int
+}
+
+func (r *topReader) Read(b []byte) (int, error) {
+	r.reads++
+	for i := range b {
+		b[i] = 0xFF
+	}
+	return len(b), nil
+}
+
+func TestPaillierMaxAttempts(t *testing.T) {
+	maxAttempts := 10
+	reader := &topReader{}
+	if _, _, err := PaillierWithMaxAttempts(reader, nil, maxAttempts); err != ErrPrimeAttemptsExhausted {
+		t.Errorf("expected ErrPrimeAttemptsExhausted, got %v", err)
+	}
+	if reader.reads != maxAttempts {
+		t.Errorf("expected %d candidate windows, got %d", maxAttempts, reader.reads)
+	}
+
+	pl := pool.NewPool(0)
+	defer pl.TearDown()
+	if _, _, err := PaillierWithMaxAttempts(&topReader{}, pl, maxAttempts); err != ErrPrimeAttemptsExhausted {
+		t.Errorf("expected ErrPrimeAttemptsExhausted, got %v", err)
+	}
+}
+
 // This exists to save the results of functions we want to benchmark, to avoid
 // having them optimized away.
 var resultNat *safenum.Nat
@@ -46,7 +85,7 @@ func BenchmarkPaillier(b *testing.B) {
 	defer pl.TearDown()
 
 	for i := 0; i < b.N; i++ {
-		resultNat, _ = Paillier(rand.Reader, pl)
+		resultNat, _, _ = Paillier(rand.Reader, pl)
 	}
 }
 
@@ -60,3 +99,52 @@ func BenchmarkModN(b *testing.B) {
 		resultNat = ModN(rand.Reader, n)
 	}
 }
+
+func TestScalarNonZero(t *testing.T) {
+	group := curve.Secp256k1{}
+	for i := 0; i < 10000; i++ {
+		if Scalar(rand.Reader, group).IsZero() {
+			t.Fatal("Scalar returned zero")
+		}
+	}
+
+	// bytes for 0, and for the order, are rejected before the ones for 1
+	size := group.SafeScalarBytes()
+	order := group.Order().Bytes()
+	orderBytes := make([]byte, size)
+	copy(orderBytes[size-len(order):], order)
+	one := make([]byte, size)
+	one[size-1] = 1
+	crafted := io.MultiReader(bytes.NewReader(make([]byte, size)), bytes.NewReader(orderBytes), bytes.NewReader(one))
+	s := Scalar(crafted, group)
+	if !s.Equal(group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1))) {
+		t.Error("Scalar didn't retry after reading zero")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Scalar should panic if it only reads zeros")
+		}
+	}()
+	Scalar(bytes.NewReader(make([]byte, size*maxIterations)), group)
+}
+
+func TestSplit(t *testing.T) {
+	seed := append(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)...)
+	first := Split(bytes.NewReader(seed), 2)
+	second := Split(bytes.NewReader(seed), 2)
+
+	// reading the sources in a different order doesn't change what they return
+	a1, b1 := make([]byte, 32), make([]byte, 32)
+	a2, b2 := make([]byte, 32), make([]byte, 32)
+	_, _ = io.ReadFull(first[0], a1)
+	_, _ = io.ReadFull(first[1], b1)
+	_, _ = io.ReadFull(second[1], b2)
+	_, _ = io.ReadFull(second[0], a2)
+	if !bytes.Equal(a1, a2) || !bytes.Equal(b1, b2) {
+		t.Error("Split sources depend on the order they are read in")
+	}
+	if bytes.Equal(a1, b1) {
+		t.Error("Split sources are not independent")
+	}
+}
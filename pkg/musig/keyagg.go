@@ -0,0 +1,89 @@
+package musig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/taproot"
+)
+
+// AggregatePublicKeys computes Σᵢ coeffs[i]⋅keys[i].
+//
+// This is only a building block for aggregating independent keys, and doesn't provide
+// any way to sign jointly under the result.
+func AggregatePublicKeys(keys []curve.Point, coeffs []curve.Scalar) (curve.Point, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("musig: no keys to aggregate")
+	}
+	if len(keys) != len(coeffs) {
+		return nil, fmt.Errorf("musig: got %d keys and %d coefficients", len(keys), len(coeffs))
+	}
+	group := keys[0].Curve()
+	aggregate := group.NewPoint()
+	for i, key := range keys {
+		if key == nil || coeffs[i] == nil {
+			return nil, fmt.Errorf("musig: key or coefficient %d is nil", i)
+		}
+		if key.IsIdentity() {
+			return nil, fmt.Errorf("musig: key %d is the identity", i)
+		}
+		aggregate = aggregate.Add(coeffs[i].Act(key))
+	}
+	if aggregate.IsIdentity() {
+		return nil, errors.New("musig: aggregate key is the identity")
+	}
+	return aggregate, nil
+}
+
+// KeyAggCoefficients derives the MuSig2 coefficients of BIP-327 for a list of secp256k1 keys.
+//
+// The coefficient of the first key different from keys[0] is 1, and every other coefficient is
+// aᵢ = hash_KeyAgg coefficient(L || Pᵢ), with L = hash_KeyAgg list(P₁ || … || Pₙ), over the compressed keys.
+// The order of the keys matters, and the same list always gives the same coefficients.
+//
+// See: https://github.com/bitcoin/bips/blob/master/bip-0327.mediawiki#key-aggregation
+func KeyAggCoefficients(keys []curve.Point) ([]curve.Scalar, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("musig: no keys to aggregate")
+	}
+
+	encoded := make([][]byte, len(keys))
+	for i, key := range keys {
+		if _, ok := key.(*curve.Secp256k1Point); !ok {
+			return nil, fmt.Errorf("musig: key %d is not a secp256k1 point", i)
+		}
+		if key.IsIdentity() {
+			return nil, fmt.Errorf("musig: key %d is the identity", i)
+		}
+		data, err := key.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("musig: key %d: %w", i, err)
+		}
+		encoded[i] = data
+	}
+	L := taproot.TaggedHash("KeyAgg list", encoded...)
+
+	// The second distinct key gets the coefficient 1, which saves a scalar multiplication
+	var second []byte
+	for _, data := range encoded[1:] {
+		if !bytes.Equal(data, encoded[0]) {
+			second = data
+			break
+		}
+	}
+
+	group := curve.Secp256k1{}
+	coeffs := make([]curve.Scalar, len(keys))
+	for i, data := range encoded {
+		if second != nil && bytes.Equal(data, second) {
+			coeffs[i] = group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1))
+			continue
+		}
+		digest := taproot.TaggedHash("KeyAgg coefficient", L, data)
+		coeffs[i] = group.NewScalar().SetNat(new(safenum.Nat).SetBytes(digest))
+	}
+	return coeffs, nil
+}
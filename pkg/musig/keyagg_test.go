@@ -0,0 +1,107 @@
+package musig
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregatePublicKeys(t *testing.T) {
+	group := curve.Secp256k1{}
+	x1, X1 := sample.ScalarPointPair(rand.Reader, group)
+	x2, X2 := sample.ScalarPointPair(rand.Reader, group)
+	keys := []curve.Point{X1, X2}
+
+	coeffs, err := KeyAggCoefficients(keys)
+	require.NoError(t, err)
+	aggregate, err := AggregatePublicKeys(keys, coeffs)
+	require.NoError(t, err)
+
+	// The aggregate secret is Σ aᵢxᵢ
+	secret := group.NewScalar().Set(coeffs[0]).Mul(x1)
+	secret.Add(group.NewScalar().Set(coeffs[1]).Mul(x2))
+	assert.True(t, secret.ActOnBase().Equal(aggregate))
+
+	_, err = AggregatePublicKeys(keys, coeffs[:1])
+	assert.Error(t, err)
+	_, err = AggregatePublicKeys(nil, nil)
+	assert.Error(t, err)
+	one := group.NewScalar().Set(coeffs[0])
+	_, err = AggregatePublicKeys([]curve.Point{X1, X1.Negate()}, []curve.Scalar{one, one})
+	assert.Error(t, err, "an identity aggregate should be rejected")
+}
+
+func TestKeyAggCoefficients(t *testing.T) {
+	group := curve.Secp256k1{}
+	_, X1 := sample.ScalarPointPair(rand.Reader, group)
+	_, X2 := sample.ScalarPointPair(rand.Reader, group)
+	_, X3 := sample.ScalarPointPair(rand.Reader, group)
+
+	coeffs1, err := KeyAggCoefficients([]curve.Point{X1, X2, X3})
+	require.NoError(t, err)
+	coeffs2, err := KeyAggCoefficients([]curve.Point{X1, X2, X3})
+	require.NoError(t, err)
+	for i := range coeffs1 {
+		assert.True(t, coeffs1[i].Equal(coeffs2[i]), "coefficients should be deterministic")
+	}
+	assert.False(t, coeffs1[0].Equal(coeffs1[1]))
+
+	// Coefficients depend on the whole list, not just the key itself
+	coeffs3, err := KeyAggCoefficients([]curve.Point{X1, X2})
+	require.NoError(t, err)
+	assert.False(t, coeffs1[0].Equal(coeffs3[0]))
+}
+
+// TestKeyAggVectors checks the key aggregation test vectors of BIP-327.
+//
+// See: https://github.com/bitcoin/bips/blob/master/bip-0327/vectors/key_agg_vectors.json
+func TestKeyAggVectors(t *testing.T) {
+	pubkeys := []string{
+		"02F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+		"03DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+		"023590A94E768F8E1815C2F24B4D80A8E3149316C3518CE7B7AD338368D038CA66",
+		"020000000000000000000000000000000000000000000000000000000000000005",
+		"02FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC30",
+		"04F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+	}
+	keys := make([]curve.Point, len(pubkeys))
+	for i, pubkey := range pubkeys {
+		data, err := hex.DecodeString(pubkey)
+		require.NoError(t, err)
+		key := curve.Secp256k1{}.NewPoint()
+		if err = key.UnmarshalBinary(data); i < 3 {
+			require.NoError(t, err)
+			keys[i] = key
+		} else {
+			assert.Error(t, err, "invalid public key %d accepted", i)
+		}
+	}
+
+	cases := []struct {
+		indices  []int
+		expected string
+	}{
+		{[]int{0, 1, 2}, "90539EEDE565F5D054F32CC0C220126889ED1E5D193BAF15AEF344FE59D4610C"},
+		{[]int{2, 1, 0}, "6204DE8B083426DC6EAF9502D27024D53FC826BF7D2012148A0575435DF54B2B"},
+		{[]int{0, 0, 0}, "B436E3BAD62B8CD409969A224731C193D051162D8C5AE8B109306127DA3AA935"},
+		{[]int{0, 0, 1, 1}, "69BC22BFA5D106306E48A20679DE1D7389386124D07571D0D872686028C26A3E"},
+	}
+	for _, c := range cases {
+		list := make([]curve.Point, 0, len(c.indices))
+		for _, i := range c.indices {
+			list = append(list, keys[i])
+		}
+		coeffs, err := KeyAggCoefficients(list)
+		require.NoError(t, err)
+		aggregate, err := AggregatePublicKeys(list, coeffs)
+		require.NoError(t, err)
+		x := aggregate.(*curve.Secp256k1Point).XBytes()
+		assert.Equal(t, c.expected, strings.ToUpper(hex.EncodeToString(x)), "keys %v", c.indices)
+	}
+}
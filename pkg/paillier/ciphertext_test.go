@@ -32,7 +32,11 @@ func init() {
 func reinit() {
 	pl := pool.NewPool(0)
 	defer pl.TearDown()
-	paillierPublic, paillierSecret = KeyGen(pl)
+	var err error
+	paillierPublic, paillierSecret, err = KeyGen(pl)
+	if err != nil {
+		panic(err)
+	}
 }
 
 func TestCiphertextValidate(t *testing.T) {
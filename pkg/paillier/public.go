@@ -78,7 +78,12 @@ func ValidateN(n *safenum.Modulus) error {
 //
 // ct = (1+N)ᵐρᴺ (mod N²).
 func (pk PublicKey) Enc(m *safenum.Int) (*Ciphertext, *safenum.Nat) {
-	nonce := sample.UnitModN(rand.Reader, pk.n.Modulus)
+	return pk.EncFromSource(rand.Reader, m)
+}
+
+// EncFromSource is like Enc, but samples the nonce from source.
+func (pk PublicKey) EncFromSource(source io.Reader, m *safenum.Int) (*Ciphertext, *safenum.Nat) {
+	nonce := sample.UnitModN(source, pk.n.Modulus)
 	return pk.EncWithNonce(m, nonce), nonce
 }
 
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/params"
@@ -55,17 +56,48 @@ func (sk *SecretKey) Phi() *safenum.Nat {
 	return sk.phi
 }
 
+// Zeroize overwrites the secret values of sk, leaving only its PublicKey usable.
+//
+// The key can no longer decrypt afterwards.
+func (sk *SecretKey) Zeroize() {
+	for _, n := range []*safenum.Nat{sk.p, sk.q, sk.phi, sk.phiInv} {
+		if n != nil {
+			n.SetBytes(make([]byte, (n.AnnouncedLen()+7)/8))
+		}
+	}
+}
+
 // KeyGen generates a new PublicKey and it's associated SecretKey.
-func KeyGen(pl *pool.Pool) (pk *PublicKey, sk *SecretKey) {
-	sk = NewSecretKey(pl)
+func KeyGen(pl *pool.Pool) (pk *PublicKey, sk *SecretKey, err error) {
+	sk, err = NewSecretKey(pl)
+	if err != nil {
+		return nil, nil, err
+	}
 	pk = sk.PublicKey
 	return
 }
 
 // NewSecretKey generates primes p and q suitable for the scheme, and returns the initialized SecretKey.
-func NewSecretKey(pl *pool.Pool) *SecretKey {
-	// TODO maybe we could take the reader as argument?
-	return NewSecretKeyFromPrimes(sample.Paillier(rand.Reader, pl))
+//
+// An error is only returned if no suitable primes could be found, which indicates a broken source of randomness.
+func NewSecretKey(pl *pool.Pool) (*SecretKey, error) {
+	return NewSecretKeyFromSource(rand.Reader, pl)
+}
+
+// NewSecretKeyFromSource is like NewSecretKey, but samples the primes from source.
+//
+// The search for primes is only spread over pl when source is crypto/rand,
+// since which candidates are tried first would otherwise depend on the scheduling of the workers,
+// and the same source wouldn't always give the same key.
+func NewSecretKeyFromSource(source io.Reader, pl *pool.Pool) (*SecretKey, error) {
+	if source != rand.Reader {
+		pl = nil
+	}
+	p, q, err := sample.Paillier(source, pl)
+	if err != nil {
+		return nil, err
+	}
+	return NewSecretKeyFromPrimes(p, q), nil
 }
 
 // NewSecretKeyFromPrimes generates a new SecretKey. Assumes that P and Q are prime.
@@ -149,7 +181,12 @@ func (sk *SecretKey) DecWithRandomness(ct *Ciphertext) (*safenum.Int, *safenum.N
 }
 
 func (sk SecretKey) GeneratePedersen() (*pedersen.Parameters, *safenum.Nat) {
-	s, t, lambda := sample.Pedersen(rand.Reader, sk.phi, sk.n.Modulus)
+	return sk.GeneratePedersenFromSource(rand.Reader)
+}
+
+// GeneratePedersenFromSource is like GeneratePedersen, but samples the parameters from source.
+func (sk SecretKey) GeneratePedersenFromSource(source io.Reader) (*pedersen.Parameters, *safenum.Nat) {
+	s, t, lambda := sample.Pedersen(source, sk.phi, sk.n.Modulus)
 	ped := pedersen.New(sk.n, s, t)
 	return ped, lambda
 }
@@ -69,16 +69,14 @@ func EmptyPointMap(group curve.Curve) *PointMap {
 	return &PointMap{group: group}
 }
 
+// sortedEncMode encodes maps with their keys sorted.
+var sortedEncMode, _ = cbor.EncOptions{Sort: cbor.SortBytewiseLexical}.EncMode()
+
+// MarshalBinary encodes the points as a CBOR map.
+//
+// The entries are sorted, so that the same map always gives the same bytes.
 func (m *PointMap) MarshalBinary() ([]byte, error) {
-	pointBytes := make(map[ID]cbor.RawMessage, len(m.Points))
-	var err error
-	for k, v := range m.Points {
-		pointBytes[k], err = cbor.Marshal(v)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return cbor.Marshal(pointBytes)
+	return sortedEncMode.Marshal(m.Points)
 }
 
 func (m *PointMap) UnmarshalBinary(data []byte) error {
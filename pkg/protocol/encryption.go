@@ -0,0 +1,134 @@
+package protocol
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+// TransportKeySize is the size of the X25519 keys used to encrypt messages between parties.
+const TransportKeySize = curve25519.ScalarSize
+
+// GenerateTransportKey returns a new X25519 key pair, for use with WithEncryption.
+//
+// The public key should be given to the other parties ahead of time, over an authenticated channel.
+func GenerateTransportKey(rand io.Reader) (publicKey, secretKey []byte, err error) {
+	secretKey = make([]byte, TransportKeySize)
+	if _, err = io.ReadFull(rand, secretKey); err != nil {
+		return nil, nil, fmt.Errorf("protocol: failed to generate transport key: %w", err)
+	}
+	publicKey, err = curve25519.X25519(secretKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, fmt.Errorf("protocol: failed to generate transport key: %w", err)
+	}
+	return publicKey, secretKey, nil
+}
+
+// transportKeys holds the static X25519 keys used to encrypt messages addressed to a single party.
+type transportKeys struct {
+	secret []byte
+	public map[party.ID][]byte
+}
+
+// WithEncryption encrypts the content of every message addressed to a single party,
+// so that only its recipient can read it, even when it is relayed by a coordinator.
+//
+// secretKey is our static X25519 key, and publicKeys must contain the keys of the other parties.
+// The messages are encrypted with a key derived from both parties' static keys,
+// and are bound to their session, sender, recipient, and round.
+// Broadcast messages are public, and are left as they are.
+// All the parties of a session need to use this option.
+//
+// No protocol generates these keys: each party creates its own with GenerateTransportKey,
+// and the public keys are exchanged, and authenticated, by the caller before the first session.
+func WithEncryption(secretKey []byte, publicKeys map[party.ID][]byte) HandlerOption {
+	return func(h *MultiHandler) {
+		h.encryption = &transportKeys{secret: secretKey, public: publicKeys}
+	}
+}
+
+// check makes sure that we can exchange encrypted messages with all of others.
+func (k *transportKeys) check(self party.ID, others []party.ID) error {
+	if len(k.secret) != TransportKeySize {
+		return errors.New("protocol: invalid transport secret key")
+	}
+	for _, id := range append([]party.ID{self}, others...) {
+		if len(k.public[id]) != TransportKeySize {
+			return fmt.Errorf("protocol: missing transport key for %s", id)
+		}
+	}
+	return nil
+}
+
+// aead returns the cipher shared by the sender and recipient of msg, and the additional data to authenticate.
+func (k *transportKeys) aead(self, peer party.ID, msg *Message) (cipher.AEAD, []byte, error) {
+	shared, err := curve25519.X25519(k.secret, k.public[peer])
+	if err != nil {
+		return nil, nil, err
+	}
+	// the key is the same in both directions, since the nonces are random
+	selfPublic, peerPublic := k.public[self], k.public[peer]
+	if self > peer {
+		selfPublic, peerPublic = peerPublic, selfPublic
+	}
+	key := make([]byte, chacha20poly1305.KeySize)
+	material := append(append(append([]byte(nil), shared...), selfPublic...), peerPublic...)
+	blake3.DeriveKey("multi-party-sig transport encryption", material, key)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roundNumber := make([]byte, 2)
+	binary.BigEndian.PutUint16(roundNumber, uint16(msg.RoundNumber))
+	h := hash.New(&hash.BytesWithDomain{TheDomain: "Transport Encryption", Bytes: msg.SSID})
+	_ = h.WriteAny(msg.From, msg.To, &hash.BytesWithDomain{
+		TheDomain: "Protocol",
+		Bytes:     append([]byte(msg.Protocol), roundNumber...),
+	})
+	return aead, h.Sum(), nil
+}
+
+// seal encrypts the content of a message we are sending to msg.To.
+func (k *transportKeys) seal(msg *Message) ([]byte, error) {
+	aead, ad, err := k.aead(msg.From, msg.To, msg)
+	if err != nil {
+		return nil, fmt.Errorf("protocol: failed to encrypt message: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("protocol: failed to encrypt message: %w", err)
+	}
+	return aead.Seal(nonce, nonce, msg.Data, ad), nil
+}
+
+// open decrypts the content of a message msg.From sent to us.
+func (k *transportKeys) open(msg *Message) ([]byte, error) {
+	aead, ad, err := k.aead(msg.To, msg.From, msg)
+	if err != nil {
+		return nil, fmt.Errorf("protocol: failed to decrypt message: %w", err)
+	}
+	if len(msg.Data) < aead.NonceSize() {
+		return nil, errors.New("protocol: encrypted message is too short")
+	}
+	nonce, ciphertext := msg.Data[:aead.NonceSize()], msg.Data[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, ad)
+	if err != nil {
+		return nil, errors.New("protocol: failed to decrypt message")
+	}
+	return data, nil
+}
+
+// encrypted returns true if msg is addressed to a single party, and so is encrypted when encryption is used.
+func encrypted(msg *Message) bool {
+	return !msg.Broadcast && msg.To != "" && msg.RoundNumber != 0
+}
@@ -0,0 +1,89 @@
+package protocol_test
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+	"github.com/koteld/multi-party-sig/protocols/frost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryption(t *testing.T) {
+	const coordinator party.ID = "coordinator"
+	group := curve.Secp256k1{}
+	ids := test.PartyIDs(3)
+
+	publicKeys := make(map[party.ID][]byte, len(ids)+1)
+	secretKeys := make(map[party.ID][]byte, len(ids)+1)
+	for _, id := range append(ids.Copy(), coordinator) {
+		public, secret, err := protocol.GenerateTransportKey(rand.Reader)
+		require.NoError(t, err)
+		publicKeys[id], secretKeys[id] = public, secret
+	}
+
+	handlers := make(map[party.ID]*protocol.MultiHandler, len(ids))
+	for _, id := range ids {
+		h, err := protocol.NewMultiHandler(frost.Keygen(group, id, ids, 1), nil, protocol.WithEncryption(secretKeys[id], publicKeys))
+		require.NoError(t, err)
+		handlers[id] = h
+	}
+
+	relay, err := protocol.NewRelay(coordinator, ids)
+	require.NoError(t, err)
+	var pending []*protocol.Message
+	drain := func(h *protocol.MultiHandler) {
+		for {
+			select {
+			case msg, ok := <-h.Listen():
+				if !ok {
+					return
+				}
+				pending = append(pending, msg)
+			default:
+				return
+			}
+		}
+	}
+	for _, id := range ids {
+		drain(handlers[id])
+	}
+	targeted := 0
+	for len(pending) > 0 {
+		msg := pending[0]
+		pending = pending[1:]
+		if !msg.Broadcast && msg.To != "" {
+			targeted++
+			_, err = protocol.OpenMessage(secretKeys[coordinator], publicKeys, msg)
+			assert.Error(t, err, "the coordinator shouldn't be able to decrypt a message to %s", msg.To)
+			_, err = protocol.OpenMessage(secretKeys[msg.To], publicKeys, msg)
+			assert.NoError(t, err, "the recipient should be able to decrypt its message")
+		}
+		recipients, err := relay.Forward(msg)
+		require.NoError(t, err)
+		for _, id := range recipients {
+			handlers[id].Accept(msg)
+			drain(handlers[id])
+		}
+	}
+	assert.NotZero(t, targeted, "keygen should send messages to a single party")
+
+	for _, id := range ids {
+		r, err := handlers[id].Result()
+		require.NoError(t, err)
+		assert.Len(t, r.(*frost.Config).VerificationShares.Points, len(ids))
+	}
+}
+
+func TestEncryptionMissingKey(t *testing.T) {
+	ids := test.PartyIDs(2)
+	public, secret, err := protocol.GenerateTransportKey(rand.Reader)
+	require.NoError(t, err)
+	publicKeys := map[party.ID][]byte{ids[0]: public}
+	_, err = protocol.NewMultiHandler(frost.Keygen(curve.Secp256k1{}, ids[0], ids, 1), nil, protocol.WithEncryption(secret, publicKeys))
+	assert.Error(t, err, "the handler should refuse to start without the keys of every party")
+}
@@ -1,16 +1,40 @@
 package protocol
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/koteld/multi-party-sig/pkg/party"
 )
 
+// The categories of an Error, which tell a caller how to react to a failed session.
+// They can be tested with errors.Is.
+var (
+	// ErrProtocolAbort is the category of sessions aborted because of the content of a message,
+	// such as a zero-knowledge proof or an OT check which failed to verify, or because another party aborted.
+	// The Culprits are the parties which sent these messages, and are not to be trusted again.
+	// A party reporting its own abort is not a culprit, since it may only be relaying what it saw.
+	ErrProtocolAbort = errors.New("protocol: aborted")
+	// ErrTransport is the category of sessions aborted because a message did not arrive intact,
+	// for instance a truncated frame, or one which failed to decrypt.
+	// The session can't continue, but it can be retried with the same parties.
+	// There are no Culprits, since the message could have been damaged by the network or a relay, rather than its sender.
+	ErrTransport = errors.New("protocol: transport error")
+	// ErrConfig is the category of sessions which could not be started or resumed with the given inputs,
+	// such as an invalid config, missing transport keys, or a replay which doesn't match the log.
+	ErrConfig = errors.New("protocol: invalid configuration")
+	// ErrLocal is the category of sessions which failed on our end, without any other party being at fault,
+	// such as a round which could not be finalized, or a session stopped by the caller.
+	ErrLocal = errors.New("protocol: local failure")
+)
+
 // Error is a custom error for protocols which contains information about the responsible round in which it occurred,
 // and the party responsible.
 type Error struct {
 	// Culprit is empty if the identity of the misbehaving party cannot be known.
 	Culprits []party.ID
+	// Category is one of ErrProtocolAbort, ErrTransport, ErrConfig or ErrLocal.
+	Category error
 	// Err is the underlying error.
 	Err error
 }
@@ -27,3 +51,49 @@ func (e Error) Error() string {
 func (e Error) Unwrap() error {
 	return e.Err
 }
+
+// Is reports whether target is the Category of e.
+func (e Error) Is(target error) bool {
+	return e.Category != nil && e.Category == target
+}
+
+// categoryError marks an error found by a handler as belonging to a category other than ErrProtocolAbort.
+type categoryError struct {
+	category error
+	err      error
+}
+
+func (e categoryError) Error() string { return e.err.Error() }
+
+func (e categoryError) Unwrap() error { return e.err }
+
+// transportError marks err as a message which did not arrive intact.
+func transportError(err error) error {
+	return categoryError{category: ErrTransport, err: err}
+}
+
+// configError marks err as a problem with the inputs of the session.
+func configError(err error) error {
+	return categoryError{category: ErrConfig, err: err}
+}
+
+// localError marks err as a failure of this party, which no other party caused.
+func localError(err error) error {
+	return categoryError{category: ErrLocal, err: err}
+}
+
+// newError returns the Error for err, blaming culprits.
+//
+// Its Category is the one err was marked with by transportError, configError or localError, and ErrProtocolAbort otherwise.
+// The culprits of a transport error are dropped, since its sender isn't necessarily the one at fault.
+func newError(err error, culprits ...party.ID) Error {
+	category := ErrProtocolAbort
+	var c categoryError
+	if errors.As(err, &c) {
+		category = c.category
+	}
+	if category == ErrTransport {
+		culprits = nil
+	}
+	return Error{Culprits: culprits, Category: category, Err: err}
+}
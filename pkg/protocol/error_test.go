@@ -0,0 +1,176 @@
+package protocol_test
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+	"github.com/koteld/multi-party-sig/protocols/doerner"
+	"github.com/koteld/multi-party-sig/protocols/frost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runTampered runs handlers until no more messages are sent,
+// passing the first message sent by from to tamper before delivering it.
+func runTampered(handlers map[party.ID]protocol.Handler, from party.ID, tamper func(*protocol.Message)) {
+	runTamperedIf(handlers, from, func(*protocol.Message) bool { return true }, tamper)
+}
+
+// runTamperedIf is like runTampered, but only tampers with the first message sent by from for which match is true.
+func runTamperedIf(handlers map[party.ID]protocol.Handler, from party.ID, match func(*protocol.Message) bool, tamper func(*protocol.Message)) {
+	tampered := false
+	for {
+		var queue []*protocol.Message
+		for _, h := range handlers {
+			for done := false; !done; {
+				select {
+				case msg, ok := <-h.Listen():
+					if !ok {
+						done = true
+						break
+					}
+					queue = append(queue, msg)
+				default:
+					done = true
+				}
+			}
+		}
+		if len(queue) == 0 {
+			return
+		}
+		for _, msg := range queue {
+			if msg.From == from && msg.RoundNumber != 0 && !tampered && match(msg) {
+				tampered = true
+				copied := *msg
+				copied.Data = append([]byte(nil), msg.Data...)
+				tamper(&copied)
+				msg = &copied
+			}
+			for id, h := range handlers {
+				if id != msg.From && msg.IsFor(id) {
+					h.Accept(msg)
+				}
+			}
+		}
+	}
+}
+
+// assertCategory checks that err is an Error of the given category, blaming culprits.
+func assertCategory(t *testing.T, err error, category error, culprits ...party.ID) {
+	t.Helper()
+	require.Error(t, err)
+	for _, other := range []error{protocol.ErrProtocolAbort, protocol.ErrTransport, protocol.ErrConfig, protocol.ErrLocal} {
+		assert.Equal(t, other == category, errors.Is(err, other), "errors.Is(%v, %v)", err, other)
+	}
+	var protocolErr protocol.Error
+	require.True(t, errors.As(err, &protocolErr))
+	assert.Equal(t, category, protocolErr.Category)
+	assert.Equal(t, culprits, protocolErr.Culprits)
+}
+
+func truncate(msg *protocol.Message) { msg.Data = msg.Data[:len(msg.Data)/2] }
+
+func corrupt(msg *protocol.Message) { msg.Data[len(msg.Data)-1] ^= 1 }
+
+func TestErrorCategoryMulti(t *testing.T) {
+	group := curve.Secp256k1{}
+	ids := test.PartyIDs(2)
+
+	run := func(tamper func(*protocol.Message)) error {
+		handlers := make(map[party.ID]protocol.Handler, len(ids))
+		for _, id := range ids {
+			h, err := protocol.NewMultiHandler(frost.Keygen(group, id, ids, 1), nil)
+			require.NoError(t, err)
+			handlers[id] = h
+		}
+		runTampered(handlers, ids[1], tamper)
+		_, err := handlers[ids[0]].Result()
+		return err
+	}
+
+	// a truncated frame is a transport error, which doesn't blame the sender, and tampered content an abort
+	assertCategory(t, run(truncate), protocol.ErrTransport)
+	assertCategory(t, run(corrupt), protocol.ErrProtocolAbort, ids[1])
+
+	// so is a ciphertext which fails to decrypt
+	publicKeys := make(map[party.ID][]byte, len(ids))
+	secretKeys := make(map[party.ID][]byte, len(ids))
+	for _, id := range ids {
+		public, secret, err := protocol.GenerateTransportKey(rand.Reader)
+		require.NoError(t, err)
+		publicKeys[id], secretKeys[id] = public, secret
+	}
+	handlers := make(map[party.ID]protocol.Handler, len(ids))
+	for _, id := range ids {
+		h, err := protocol.NewMultiHandler(frost.Keygen(group, id, ids, 1), nil, protocol.WithEncryption(secretKeys[id], publicKeys))
+		require.NoError(t, err)
+		handlers[id] = h
+	}
+	direct := func(msg *protocol.Message) bool { return !msg.Broadcast && msg.To != "" }
+	runTamperedIf(handlers, ids[1], direct, corrupt)
+	_, err := handlers[ids[0]].Result()
+	assertCategory(t, err, protocol.ErrTransport)
+
+	_, err = protocol.NewMultiHandler(frost.Keygen(group, ids[0], ids, 2), nil)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, protocol.ErrConfig))
+	assert.False(t, errors.Is(err, protocol.ErrProtocolAbort))
+
+	// stopping a session is a local failure, and the party it reaches aborts without blaming anyone
+	stopped, err := protocol.NewMultiHandler(frost.Keygen(group, ids[0], ids, 1), nil)
+	require.NoError(t, err)
+	other, err := protocol.NewMultiHandler(frost.Keygen(group, ids[1], ids, 1), nil)
+	require.NoError(t, err)
+	stopped.Stop()
+	_, err = stopped.Result()
+	assertCategory(t, err, protocol.ErrLocal)
+	for msg := range stopped.Listen() {
+		if msg.RoundNumber == 0 {
+			other.Accept(msg)
+		}
+	}
+	_, err = other.Result()
+	assertCategory(t, err, protocol.ErrProtocolAbort)
+	assert.Contains(t, err.Error(), "aborted by user")
+}
+
+func TestErrorCategoryTwoParty(t *testing.T) {
+	group := curve.Secp256k1{}
+	ids := test.PartyIDs(2)
+
+	run := func(tamper func(*protocol.Message)) error {
+		receiver, err := protocol.NewTwoPartyHandler(doerner.Keygen(group, true, ids[0], ids[1], nil), []byte("session"), true)
+		require.NoError(t, err)
+		sender, err := protocol.NewTwoPartyHandler(doerner.Keygen(group, false, ids[1], ids[0], nil), []byte("session"), false)
+		require.NoError(t, err)
+		runTampered(map[party.ID]protocol.Handler{ids[0]: receiver, ids[1]: sender}, ids[0], tamper)
+		_, err = sender.Result()
+		return err
+	}
+
+	// the first message of the receiver contains its OT setup, with a Schnorr proof
+	assertCategory(t, run(truncate), protocol.ErrTransport)
+	assertCategory(t, run(corrupt), protocol.ErrProtocolAbort, ids[0])
+
+	// stopping a session is a local failure, and the other party aborts without blaming anyone
+	receiver, err := protocol.NewTwoPartyHandler(doerner.Keygen(group, true, ids[0], ids[1], nil), []byte("session"), true)
+	require.NoError(t, err)
+	sender, err := protocol.NewTwoPartyHandler(doerner.Keygen(group, false, ids[1], ids[0], nil), []byte("session"), false)
+	require.NoError(t, err)
+	receiver.Stop()
+	_, err = receiver.Result()
+	assertCategory(t, err, protocol.ErrLocal)
+	for msg := range receiver.Listen() {
+		if msg.RoundNumber == 0 {
+			sender.Accept(msg)
+		}
+	}
+	_, err = sender.Result()
+	assertCategory(t, err, protocol.ErrProtocolAbort)
+	assert.Contains(t, err.Error(), "aborted by user")
+}
@@ -0,0 +1,9 @@
+package protocol
+
+import "github.com/koteld/multi-party-sig/pkg/party"
+
+// OpenMessage decrypts msg with secretKey, as its recipient would.
+func OpenMessage(secretKey []byte, publicKeys map[party.ID][]byte, msg *Message) ([]byte, error) {
+	k := &transportKeys{secret: secretKey, public: publicKeys}
+	return k.open(msg)
+}
@@ -2,12 +2,15 @@ package protocol
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"sync"
 
 	"github.com/fxamacker/cbor/v2"
 	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/party"
 )
@@ -35,6 +38,8 @@ type Handler interface {
 
 // MultiHandler represents an execution of a given protocol.
 // It provides a simple interface for the user to receive/deliver protocol messages.
+//
+// All methods are safe to call from multiple goroutines.
 type MultiHandler struct {
 	currentRound    round.Session
 	rounds          map[round.Number]round.Session
@@ -45,13 +50,32 @@ type MultiHandler struct {
 	broadcastHashes map[round.Number][]byte
 	out             chan *Message
 	mtx             sync.Mutex
+	// padding is the size outgoing messages are padded to, or 0 for no padding.
+	padding int
+	// transcripts holds the digest of the hash state at the start of each round.
+	transcripts map[round.Number][]byte
+	// encryption holds the keys used to encrypt messages to a single party, or nil if they are sent in the clear.
+	encryption *transportKeys
+	// seed is the snapshot the session's secret randomness is derived from, or nil to use crypto/rand.
+	seed []byte
+	// replay holds the outgoing messages while a log is being replayed, and is nil otherwise.
+	replay *replayState
+	// traceCtx is the execution tracer task of the session, and endTask ends it.
+	// Both are only used when built with the mpstrace tag.
+	traceCtx context.Context
+	endTask  func()
 }
 
+// HandlerOption configures optional behavior of a MultiHandler.
+type HandlerOption func(*MultiHandler)
+
 // NewMultiHandler expects a StartFunc for the desired protocol. It returns a handler that the user can interact with.
-func NewMultiHandler(create StartFunc, sessionID []byte) (*MultiHandler, error) {
+//
+// Additional options can be passed to modify the handler's behavior.
+func NewMultiHandler(create StartFunc, sessionID []byte, opts ...HandlerOption) (*MultiHandler, error) {
 	r, err := create(sessionID)
 	if err != nil {
-		return nil, fmt.Errorf("protocol: failed to create round: %w", err)
+		return nil, Error{Category: ErrConfig, Err: fmt.Errorf("protocol: failed to create round: %w", err)}
 	}
 	h := &MultiHandler{
 		currentRound:    r,
@@ -59,8 +83,23 @@ func NewMultiHandler(create StartFunc, sessionID []byte) (*MultiHandler, error)
 		messages:        newQueue(r.OtherPartyIDs(), r.FinalRoundNumber()),
 		broadcast:       newQueue(r.OtherPartyIDs(), r.FinalRoundNumber()),
 		broadcastHashes: map[round.Number][]byte{},
+		transcripts:     map[round.Number][]byte{r.Number(): r.Hash().Sum()},
 		out:             make(chan *Message, 2*r.N()),
 	}
+	h.traceCtx, h.endTask = trace.NewTask(r.ProtocolID())
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.encryption != nil {
+		if err = h.encryption.check(r.SelfID(), r.OtherPartyIDs()); err != nil {
+			return nil, Error{Category: ErrConfig, Err: err}
+		}
+	}
+	if h.seed != nil {
+		if err = setRandomness(h.seed, r); err != nil {
+			return nil, Error{Category: ErrConfig, Err: err}
+		}
+	}
 	h.finalize()
 	return h, nil
 }
@@ -81,14 +120,63 @@ func (h *MultiHandler) Result() (interface{}, error) {
 // Listen returns a channel with outgoing messages that must be sent to other parties.
 // The message received should be _reliably_ broadcast if msg.Broadcast is true.
 // The channel is closed when either an error occurs or the protocol detects an error.
+//
+// Listen does not block on Accept, so that outgoing messages can still be drained while Accept is waiting
+// for room in the channel.
 func (h *MultiHandler) Listen() <-chan *Message {
+	// h.out is never reassigned, so no locking is needed.
+	return h.out
+}
+
+// TranscriptDigests returns the digest of the session's Fiat-Shamir hash state at the start of each round reached so far.
+//
+// The digest for a round covers everything the previous rounds wrote to the hash state,
+// so all parties, and any conforming implementation of the protocol, should obtain the same values.
+// The output round holds the digest of the final state.
+func (h *MultiHandler) TranscriptDigests() map[round.Number][]byte {
 	h.mtx.Lock()
 	defer h.mtx.Unlock()
-	return h.out
+	digests := make(map[round.Number][]byte, len(h.transcripts))
+	for number, digest := range h.transcripts {
+		digests[number] = append([]byte(nil), digest...)
+	}
+	return digests
+}
+
+// Pending returns the parties whose messages for the current round have not been received yet.
+//
+// A session which stays with the same pending parties for too long has stalled,
+// most likely because those parties are no longer online.
+// The session can then be stopped, and restarted without them if enough parties remain.
+// Pending returns nothing once the protocol has finished.
+func (h *MultiHandler) Pending() party.IDSlice {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.result != nil || h.err != nil {
+		return nil
+	}
+	r := h.currentRound
+	number := r.Number()
+	_, isBroadcast := r.(round.BroadcastRound)
+	pending := make([]party.ID, 0, len(r.OtherPartyIDs()))
+	for _, id := range r.OtherPartyIDs() {
+		missingBroadcast := isBroadcast && h.broadcast[number] != nil && h.broadcast[number][id] == nil
+		missingMessage := expectsNormalMessage(r) && h.messages[number] != nil && h.messages[number][id] == nil
+		if missingBroadcast || missingMessage {
+			pending = append(pending, id)
+		}
+	}
+	return party.NewIDSlice(pending)
 }
 
 // CanAccept returns true if the message is designated for this protocol protocol execution.
 func (h *MultiHandler) CanAccept(msg *Message) bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.canAccept(msg)
+}
+
+func (h *MultiHandler) canAccept(msg *Message) bool {
 	r := h.currentRound
 	if msg == nil {
 		return false
@@ -131,18 +219,44 @@ func (h *MultiHandler) CanAccept(msg *Message) bool {
 // and an error is returned by Result().
 //
 // This function may be called concurrently from different threads but may block until all previous calls have finished.
+// Messages may arrive in any order: messages for a later round are stored until that round is reached,
+// and a second message from the same sender for the same round is ignored.
 func (h *MultiHandler) Accept(msg *Message) {
 	h.mtx.Lock()
 	defer h.mtx.Unlock()
+	h.accept(msg)
+}
 
+func (h *MultiHandler) accept(msg *Message) {
 	// exit early if the message is bad, or if we are already done
-	if !h.CanAccept(msg) || h.err != nil || h.result != nil || h.duplicate(msg) {
+	if !h.canAccept(msg) || h.err != nil || h.result != nil {
 		return
 	}
 
 	// a msg with roundNumber 0 is considered an abort from another party
 	if msg.RoundNumber == 0 {
-		h.abort(fmt.Errorf("aborted by other party with error: \"%s\"", msg.Data), msg.From)
+		h.abort(fmt.Errorf("aborted by %s with error: \"%s\"", msg.From, msg.Data))
+		return
+	}
+
+	if h.encryption != nil && encrypted(msg) {
+		data, err := h.encryption.open(msg)
+		if err != nil {
+			h.abort(transportError(err))
+			return
+		}
+		decrypted := *msg
+		decrypted.Data = data
+		msg = &decrypted
+	}
+
+	// a message for a round we already have one for is either sent again, or equivocation
+	duplicate, err := h.duplicate(msg)
+	if err != nil {
+		h.abort(err, msg.From)
+		return
+	}
+	if duplicate {
 		return
 	}
 
@@ -179,7 +293,10 @@ func (h *MultiHandler) verifyBroadcastMessage(msg *Message) error {
 	}
 
 	// store the broadcast message for this round
-	if err = r.(round.BroadcastRound).StoreBroadcastMessage(roundMsg); err != nil {
+	endRegion := trace.RoundRegion(h.traceCtx, r.ProtocolID(), uint16(r.Number()), "verify broadcast")
+	err = r.(round.BroadcastRound).StoreBroadcastMessage(roundMsg)
+	endRegion()
+	if err != nil {
 		return fmt.Errorf("round %d: %w", r.Number(), err)
 	}
 
@@ -219,7 +336,10 @@ func (h *MultiHandler) verifyMessage(msg *Message) error {
 	}
 
 	// verify message for round
-	if err = r.VerifyMessage(roundMsg); err != nil {
+	endRegion := trace.RoundRegion(h.traceCtx, r.ProtocolID(), uint16(r.Number()), "verify")
+	err = r.VerifyMessage(roundMsg)
+	endRegion()
+	if err != nil {
 		return fmt.Errorf("round %d: %w", r.Number(), err)
 	}
 
@@ -242,12 +362,17 @@ func (h *MultiHandler) finalize() {
 
 	out := make(chan *round.Message, h.currentRound.N()+1)
 	// since we pass a large enough channel, we should never get an error
+	endRegion := trace.RoundRegion(h.traceCtx, h.currentRound.ProtocolID(), uint16(h.currentRound.Number()), "finalize")
 	r, err := h.currentRound.Finalize(out)
+	endRegion()
 	close(out)
 	// either we got an error due to some problem on our end (sampling etc)
 	// or the new round is nil (should not happen)
 	if err != nil || r == nil {
-		h.abort(err, h.currentRound.SelfID())
+		if err == nil {
+			err = errors.New("round returned no next round")
+		}
+		h.abort(localError(fmt.Errorf("round %d: %w", h.currentRound.Number(), err)))
 		return
 	}
 
@@ -265,12 +390,19 @@ func (h *MultiHandler) finalize() {
 			RoundNumber:           roundMsg.Content.RoundNumber(),
 			Data:                  data,
 			Broadcast:             roundMsg.Broadcast,
-			BroadcastVerification: h.broadcastHashes[r.Number()-1],
+			BroadcastVerification: h.broadcastHashes[h.previousRound(r.Number())],
+			padTo:                 h.padding,
 		}
 		if msg.Broadcast {
 			h.store(msg)
 		}
-		h.out <- msg
+		if h.encryption != nil && encrypted(msg) {
+			if msg.Data, err = h.encryption.seal(msg); err != nil {
+				h.abort(localError(err))
+				return
+			}
+		}
+		h.emit(msg)
 	}
 
 	roundNumber := r.Number()
@@ -280,6 +412,7 @@ func (h *MultiHandler) finalize() {
 	}
 	h.rounds[roundNumber] = r
 	h.currentRound = r
+	h.transcripts[roundNumber] = r.Hash().Sum()
 
 	// either we get the current round, the next one, or one of the two final ones
 	switch R := r.(type) {
@@ -327,28 +460,39 @@ func (h *MultiHandler) finalize() {
 
 func (h *MultiHandler) abort(err error, culprits ...party.ID) {
 	if err != nil {
-		h.err = &Error{
-			Culprits: culprits,
-			Err:      err,
-		}
-		select {
-		case h.out <- &Message{
+		e := newError(err, culprits...)
+		h.err = &e
+		msg := &Message{
 			SSID:     h.currentRound.SSID(),
 			From:     h.currentRound.SelfID(),
 			Protocol: h.currentRound.ProtocolID(),
 			Data:     []byte(h.err.Error()),
-		}:
-		default:
+			padTo:    h.padding,
+		}
+		if h.replay != nil {
+			h.replay.produced = append(h.replay.produced, msg)
+		} else {
+			select {
+			case h.out <- msg:
+			default:
+			}
 		}
 
 	}
+	h.endTask()
+	if h.replay != nil {
+		h.replay.closed = true
+		return
+	}
 	close(h.out)
 }
 
 // Stop cancels the current execution of the protocol, and alerts the other users.
 func (h *MultiHandler) Stop() {
-	if h.err != nil || h.result != nil {
-		h.abort(errors.New("aborted by user"), h.currentRound.SelfID())
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.err == nil && h.result == nil {
+		h.abort(localError(errors.New("aborted by user")))
 	}
 }
 
@@ -399,9 +543,11 @@ func (h *MultiHandler) receivedAll() bool {
 	return true
 }
 
-func (h *MultiHandler) duplicate(msg *Message) bool {
+// duplicate returns true if a message from the same sender was already received for this round,
+// and an error if it is different from msg.
+func (h *MultiHandler) duplicate(msg *Message) (bool, error) {
 	if msg.RoundNumber == 0 {
-		return false
+		return false, nil
 	}
 	var q map[party.ID]*Message
 	if msg.Broadcast {
@@ -411,9 +557,16 @@ func (h *MultiHandler) duplicate(msg *Message) bool {
 	}
 	// technically, we already received the nil message since it is not expected :)
 	if q == nil {
-		return true
+		return true, nil
 	}
-	return q[msg.From] != nil
+	stored := q[msg.From]
+	if stored == nil {
+		return false, nil
+	}
+	if !bytes.Equal(stored.Data, msg.Data) || !bytes.Equal(stored.BroadcastVerification, msg.BroadcastVerification) {
+		return true, fmt.Errorf("received two different messages from %s for round %d", msg.From, msg.RoundNumber)
+	}
+	return true, nil
 }
 
 func (h *MultiHandler) store(msg *Message) {
@@ -447,7 +600,7 @@ func getRoundMessage(msg *Message, r round.Session) (round.Message, error) {
 
 	// unmarshal message
 	if err := cbor.Unmarshal(msg.Data, content); err != nil {
-		return round.Message{}, fmt.Errorf("failed to unmarshal: %w", err)
+		return round.Message{}, unmarshalError(err)
 	}
 	roundMsg := round.Message{
 		From:      msg.From,
@@ -462,7 +615,7 @@ func getRoundMessage(msg *Message, r round.Session) (round.Message, error) {
 func (h *MultiHandler) checkBroadcastHash() bool {
 	number := h.currentRound.Number()
 	// check BroadcastVerification
-	previousHash := h.broadcastHashes[number-1]
+	previousHash := h.broadcastHashes[h.previousRound(number)]
 	if previousHash == nil {
 		return true
 	}
@@ -480,6 +633,17 @@ func (h *MultiHandler) checkBroadcastHash() bool {
 	return true
 }
 
+// previousRound returns the number of the last round of the session before number.
+// This is number-1, unless the protocol skipped that round.
+func (h *MultiHandler) previousRound(number round.Number) round.Number {
+	for i := number; i > 1; i-- {
+		if _, ok := h.rounds[i-1]; ok {
+			return i - 1
+		}
+	}
+	return 0
+}
+
 func newQueue(senders []party.ID, rounds round.Number) map[round.Number]map[party.ID]*Message {
 	n := len(senders)
 	q := make(map[round.Number]map[party.ID]*Message, rounds)
@@ -493,5 +657,18 @@ func newQueue(senders []party.ID, rounds round.Number) map[round.Number]map[part
 }
 
 func (h *MultiHandler) String() string {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
 	return fmt.Sprintf("party: %s, protocol: %s", h.currentRound.SelfID(), h.currentRound.ProtocolID())
 }
+
+// unmarshalError wraps an error from decoding the content of a message.
+//
+// A frame which ends early was cut in transit, whereas any other decoding error comes from its sender.
+func unmarshalError(err error) error {
+	err = fmt.Errorf("failed to unmarshal: %w", err)
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return transportError(err)
+	}
+	return err
+}
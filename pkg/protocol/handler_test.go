@@ -0,0 +1,208 @@
+package protocol_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+	"github.com/koteld/multi-party-sig/protocols/cmp"
+	"github.com/koteld/multi-party-sig/protocols/frost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHandlerConcurrentAccept delivers every message from its own goroutine, in a random order,
+// with some messages sent more than once.
+// It is meant to be run with -race.
+func TestHandlerConcurrentAccept(t *testing.T) {
+	group := curve.Secp256k1{}
+	ids := test.PartyIDs(5)
+
+	handlers := make(map[party.ID]*protocol.MultiHandler, len(ids))
+	for _, id := range ids {
+		h, err := protocol.NewMultiHandler(frost.Keygen(group, id, ids, 2), nil)
+		require.NoError(t, err)
+		handlers[id] = h
+	}
+
+	type delivery struct {
+		to  party.ID
+		msg *protocol.Message
+	}
+	var mtx sync.Mutex
+	rng := rand.New(rand.NewSource(1))
+	shuffle := func(batch []delivery) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		rng.Shuffle(len(batch), func(i, j int) { batch[i], batch[j] = batch[j], batch[i] })
+	}
+
+	var deliveries sync.WaitGroup
+	var listeners sync.WaitGroup
+	for _, id := range ids {
+		listeners.Add(1)
+		go func(id party.ID) {
+			defer listeners.Done()
+			for msg := range handlers[id].Listen() {
+				// deliver each message twice, to check that duplicates are ignored
+				var batch []delivery
+				for _, to := range ids {
+					if msg.IsFor(to) && to != id {
+						batch = append(batch, delivery{to, msg}, delivery{to, msg})
+					}
+				}
+				shuffle(batch)
+				for _, d := range batch {
+					deliveries.Add(1)
+					go func(d delivery) {
+						defer deliveries.Done()
+						handlers[d.to].CanAccept(d.msg)
+						handlers[d.to].Accept(d.msg)
+					}(d)
+				}
+			}
+		}(id)
+	}
+	listeners.Wait()
+	deliveries.Wait()
+
+	var publicKey curve.Point
+	for _, id := range ids {
+		r, err := handlers[id].Result()
+		require.NoError(t, err)
+		c := r.(*frost.Config)
+		if publicKey == nil {
+			publicKey = c.PublicKey
+		}
+		assert.True(t, publicKey.Equal(c.PublicKey), "parties disagree on the public key")
+	}
+
+	// a sender equivocating with two valid messages for the same round is detected,
+	// whichever of them is delivered first
+	receiver, err := protocol.NewMultiHandler(frost.Keygen(group, ids[0], ids, 2), nil)
+	require.NoError(t, err)
+	var versions []*protocol.Message
+	for i := 0; i < 2; i++ {
+		sender, err := protocol.NewMultiHandler(frost.Keygen(group, ids[1], ids, 2), nil)
+		require.NoError(t, err)
+		versions = append(versions, <-sender.Listen())
+	}
+	require.False(t, bytes.Equal(versions[0].Data, versions[1].Data))
+	batch := make([]delivery, 0, 16)
+	for i := 0; i < cap(batch); i++ {
+		batch = append(batch, delivery{ids[0], versions[i%2]})
+	}
+	shuffle(batch)
+	for _, d := range batch {
+		deliveries.Add(1)
+		go func(d delivery) {
+			defer deliveries.Done()
+			receiver.Accept(d.msg)
+		}(d)
+	}
+	deliveries.Wait()
+	_, err = receiver.Result()
+	assertCategory(t, err, protocol.ErrProtocolAbort, ids[1])
+	assert.Contains(t, err.Error(), "two different messages")
+}
+
+func runTranscriptKeygen(t *testing.T, ids party.IDSlice, sessionID []byte) map[party.ID]map[round.Number][]byte {
+	group := curve.Secp256k1{}
+	network := test.NewNetwork(ids)
+
+	handlers := make(map[party.ID]*protocol.MultiHandler, len(ids))
+	for i, id := range ids {
+		seed := bytes.Repeat([]byte{byte(i + 1)}, protocol.MinRandomnessSeedSize)
+		h, err := protocol.NewMultiHandler(cmp.Keygen(group, id, ids, 1, nil), sessionID, protocol.WithRandomness(seed))
+		require.NoError(t, err)
+		handlers[id] = h
+	}
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id party.ID) {
+			defer wg.Done()
+			test.HandlerLoop(id, handlers[id], network)
+		}(id)
+	}
+	wg.Wait()
+
+	digests := make(map[party.ID]map[round.Number][]byte, len(ids))
+	for _, id := range ids {
+		_, err := handlers[id].Result()
+		require.NoError(t, err)
+		digests[id] = handlers[id].TranscriptDigests()
+	}
+	return digests
+}
+
+func TestTranscriptDigests(t *testing.T) {
+	ids := test.PartyIDs(2)
+	sessionID := []byte("transcript digests")
+
+	digests := runTranscriptKeygen(t, ids, sessionID)
+
+	expected := digests[ids[0]]
+	// 5 rounds of keygen, and the output round
+	require.Len(t, expected, 6)
+	for _, id := range ids {
+		assert.Equal(t, expected, digests[id], "parties disagree on the transcript")
+	}
+
+	// The parties sample their secrets from fixed seeds, so the digest of every round is fixed.
+	// Rounds 1 to 3 only depend on the session parameters, round 4 adds the RID, and round 5 the new config,
+	// which is also the final state of the output round.
+	const (
+		session = "35c507bdec281d88e7b8fccbed7d9b2a34592ec505668bcd7af1599e05e5ab09" +
+			"5c06af181227f76dcf3b1a8efef1f18672f267fd0c9a076e8c81d37a9a685394"
+		rid = "d6321acb04b3baf0f8c6509faa310f654e101aaa732dc88ee174d5a477228928" +
+			"ada0a05db69f6e5c492355510d5ce750550916f13364b0258844f81bedc32813"
+		config = "971dac5c59a7de6f36b658594a6b5dd4880079f93834a000ca999a2e57e4b269" +
+			"018b99b117500a84a93609d2c1d2d8e9580742237fe6c5ea566bf15ba30f2564"
+	)
+	pinned := map[round.Number]string{1: session, 2: session, 3: session, 4: rid, 5: config, 0: config}
+	for number, digest := range pinned {
+		assert.Equal(t, digest, hex.EncodeToString(expected[number]), "transcript digest of round %d changed", number)
+	}
+}
+
+func TestPendingShrinks(t *testing.T) {
+	group := curve.Secp256k1{}
+	ids := test.PartyIDs(4)
+	self, others := ids[0], ids[1:]
+
+	handlers := make(map[party.ID]*protocol.MultiHandler, len(ids))
+	for _, id := range ids {
+		h, err := protocol.NewMultiHandler(frost.Keygen(group, id, ids, 2), nil)
+		require.NoError(t, err)
+		handlers[id] = h
+	}
+	// the first messages of the others, addressed to us
+	var msgs []*protocol.Message
+	for _, id := range others {
+		for len(handlers[id].Listen()) > 0 {
+			if msg := <-handlers[id].Listen(); msg.IsFor(self) {
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+	require.Len(t, msgs, len(others))
+
+	h := handlers[self]
+	assert.Equal(t, others, h.Pending())
+	for i, msg := range msgs[:len(msgs)-1] {
+		h.Accept(msg)
+		assert.Equal(t, others[i+1:], h.Pending(), "after the message from %s", msg.From)
+	}
+
+	// the last message completes the round, and we wait for everyone again in the next one
+	h.Accept(msgs[len(msgs)-1])
+	assert.Equal(t, others, h.Pending())
+}
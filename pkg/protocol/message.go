@@ -27,6 +27,8 @@ type Message struct {
 	// BroadcastVerification is the hash of all messages broadcast by the parties,
 	// and is included in all messages in the round following a broadcast round.
 	BroadcastVerification []byte
+	// padTo is the size this message should be padded to when marshalled, or 0 for no padding.
+	padTo int
 }
 
 // String implements fmt.Stringer.
@@ -91,10 +93,18 @@ func (m *Message) toMarshallable() *marshallableMessage {
 }
 
 func (m *Message) MarshalBinary() ([]byte, error) {
-	return cbor.Marshal(m.toMarshallable())
+	data, err := cbor.Marshal(m.toMarshallable())
+	if err != nil || m.padTo == 0 {
+		return data, err
+	}
+	return pad(data, m.padTo)
 }
 
 func (m *Message) UnmarshalBinary(data []byte) error {
+	data, err := unpad(data)
+	if err != nil {
+		return err
+	}
 	deserialized := m.toMarshallable()
 	if err := cbor.Unmarshal(data, deserialized); err != nil {
 		return nil
@@ -0,0 +1,58 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// paddingMarker starts every padded message.
+//
+// An unpadded message is a cbor map, and so never starts with this byte.
+const paddingMarker = 0x00
+
+// paddingHeaderSize is the size of the marker, followed by the length of the original message.
+const paddingHeaderSize = 1 + 4
+
+// pad wraps data in a frame of exactly size bytes, filling the rest with random bytes.
+func pad(data []byte, size int) ([]byte, error) {
+	if len(data)+paddingHeaderSize > size {
+		return nil, fmt.Errorf("protocol: message of %d bytes doesn't fit in padding of %d bytes", len(data), size)
+	}
+	out := make([]byte, size)
+	out[0] = paddingMarker
+	binary.BigEndian.PutUint32(out[1:paddingHeaderSize], uint32(len(data)))
+	copy(out[paddingHeaderSize:], data)
+	if _, err := rand.Read(out[paddingHeaderSize+len(data):]); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// unpad strips the padding added by pad, and returns unpadded data unchanged.
+func unpad(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != paddingMarker {
+		return data, nil
+	}
+	if len(data) < paddingHeaderSize {
+		return nil, errors.New("protocol: truncated padded message")
+	}
+	length := binary.BigEndian.Uint32(data[1:paddingHeaderSize])
+	if uint64(length) > uint64(len(data)-paddingHeaderSize) {
+		return nil, errors.New("protocol: invalid length in padded message")
+	}
+	return data[paddingHeaderSize : paddingHeaderSize+int(length)], nil
+}
+
+// WithPadding pads every outgoing message to exactly size bytes when it is marshalled,
+// so that message sizes don't reveal which round or party set they belong to.
+//
+// The padding is only added by Message.MarshalBinary, and stripped by Message.UnmarshalBinary,
+// so it doesn't affect the content of messages, or how they are hashed.
+// Marshalling fails for messages which don't fit in size bytes.
+func WithPadding(size int) HandlerOption {
+	return func(h *MultiHandler) {
+		h.padding = size
+	}
+}
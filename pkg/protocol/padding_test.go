@@ -0,0 +1,98 @@
+package protocol_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+	"github.com/koteld/multi-party-sig/protocols/frost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wireLoop is like test.HandlerLoop, but sends every message through its binary encoding,
+// recording the encoded sizes.
+func wireLoop(t *testing.T, id party.ID, h protocol.Handler, network *test.Network, sizes chan<- int) {
+	for {
+		select {
+		case msg, ok := <-h.Listen():
+			if !ok {
+				<-network.Done(id)
+				return
+			}
+			data, err := msg.MarshalBinary()
+			require.NoError(t, err)
+			sizes <- len(data)
+			decoded := new(protocol.Message)
+			require.NoError(t, decoded.UnmarshalBinary(data))
+			go network.Send(decoded)
+		case msg := <-network.Next(id):
+			h.Accept(msg)
+		}
+	}
+}
+
+func runWithPadding(t *testing.T, ids party.IDSlice, start func(party.ID) protocol.StartFunc, opts ...protocol.HandlerOption) (map[party.ID]interface{}, []int) {
+	network := test.NewNetwork(ids)
+	sizes := make(chan int, 1000)
+	results := make(map[party.ID]interface{}, len(ids))
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(len(ids))
+	for _, id := range ids {
+		go func(id party.ID) {
+			defer wg.Done()
+			h, err := protocol.NewMultiHandler(start(id), nil, opts...)
+			require.NoError(t, err)
+			wireLoop(t, id, h, network, sizes)
+			r, err := h.Result()
+			require.NoError(t, err)
+			mtx.Lock()
+			results[id] = r
+			mtx.Unlock()
+		}(id)
+	}
+	wg.Wait()
+	close(sizes)
+	var out []int
+	for size := range sizes {
+		out = append(out, size)
+	}
+	return results, out
+}
+
+func TestWithPadding(t *testing.T) {
+	const paddedSize = 4096
+	ids := test.PartyIDs(3)
+	message := []byte("hello")
+
+	configs, sizes := runWithPadding(t, ids, func(id party.ID) protocol.StartFunc {
+		return frost.Keygen(curve.Secp256k1{}, id, ids, 1)
+	})
+	unpaddedSizes := make(map[int]bool)
+	for _, size := range sizes {
+		unpaddedSizes[size] = true
+	}
+	assert.Greater(t, len(unpaddedSizes), 1, "messages should have different sizes without padding")
+
+	signatures, sizes := runWithPadding(t, ids, func(id party.ID) protocol.StartFunc {
+		return frost.Sign(configs[id].(*frost.Config), ids, message)
+	}, protocol.WithPadding(paddedSize))
+	require.NotEmpty(t, sizes)
+	for _, size := range sizes {
+		assert.Equal(t, paddedSize, size)
+	}
+	for _, id := range ids {
+		signature := signatures[id].(frost.Signature)
+		assert.True(t, signature.Verify(configs[id].(*frost.Config).PublicKey, message))
+	}
+
+	// Messages which don't fit can't be marshalled
+	h, err := protocol.NewMultiHandler(frost.Sign(configs[ids[0]].(*frost.Config), ids, message), nil, protocol.WithPadding(16))
+	require.NoError(t, err)
+	_, err = (<-h.Listen()).MarshalBinary()
+	assert.Error(t, err)
+}
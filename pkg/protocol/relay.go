@@ -0,0 +1,145 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/pkg/party"
+)
+
+// Relay is a participant which forwards messages between the parties of a protocol
+// execution, without taking part in the protocol itself.
+//
+// A Relay never holds a share, and its ID must not be included in the parties running
+// the protocol, so it isn't counted towards the threshold.
+// Since every message goes through it, the Relay also takes part in the echo broadcast:
+// it checks that all the parties agree on the hash of the previous broadcast round,
+// which catches parties that were sent different broadcast messages.
+type Relay struct {
+	mtx     sync.Mutex
+	id      party.ID
+	parties party.IDSlice
+
+	ssid     []byte
+	protocol string
+	// verification holds the BroadcastVerification the first sender used for each round.
+	verification map[round.Number]relayVerification
+	err          *Error
+}
+
+// relayVerification is the BroadcastVerification of a round, and the party which first sent it.
+type relayVerification struct {
+	hash []byte
+	from party.ID
+}
+
+// NewRelay creates a Relay with the given ID, forwarding messages between parties.
+func NewRelay(id party.ID, parties []party.ID) (*Relay, error) {
+	ids := party.NewIDSlice(parties)
+	if !ids.Valid() {
+		return nil, errors.New("relay: parties contain duplicates")
+	}
+	if ids.Contains(id) {
+		return nil, fmt.Errorf("relay: %s can't be both a relay and a party", id)
+	}
+	return &Relay{
+		id:           id,
+		parties:      ids,
+		verification: map[round.Number]relayVerification{},
+	}, nil
+}
+
+// Forward checks a message sent by one of the parties, and returns the parties it should be delivered to.
+//
+// Once two parties disagree on a broadcast round, or one has aborted, every further message is rejected,
+// and the error is returned by Err.
+// A disagreement doesn't show which of the two parties lied, so the error names no culprit,
+// and lists both verification hashes instead.
+func (r *Relay) Forward(msg *Message) ([]party.ID, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.err != nil {
+		return nil, *r.err
+	}
+	if msg == nil {
+		return nil, errors.New("relay: nil message")
+	}
+	if !r.parties.Contains(msg.From) {
+		return nil, fmt.Errorf("relay: unknown sender %s", msg.From)
+	}
+	if msg.To != "" && (msg.To == msg.From || !r.parties.Contains(msg.To)) {
+		return nil, fmt.Errorf("relay: invalid recipient %s", msg.To)
+	}
+
+	// all messages must belong to the same execution
+	if r.ssid == nil {
+		r.ssid = msg.SSID
+		r.protocol = msg.Protocol
+	} else if !bytes.Equal(r.ssid, msg.SSID) || r.protocol != msg.Protocol {
+		return nil, fmt.Errorf("relay: message from %s belongs to a different session", msg.From)
+	}
+
+	// a msg with roundNumber 0 is an abort, which needs to reach everyone
+	if msg.RoundNumber == 0 {
+		r.err = &Error{
+			Category: ErrProtocolAbort,
+			Err:      fmt.Errorf("aborted by %s with error: \"%s\"", msg.From, msg.Data),
+		}
+		return r.recipients(msg), nil
+	}
+
+	if err := r.checkVerification(msg); err != nil {
+		// the two parties saw different broadcasts, but either of them could be the one lying about it
+		r.err = &Error{Category: ErrProtocolAbort, Err: err}
+		return nil, *r.err
+	}
+
+	return r.recipients(msg), nil
+}
+
+// checkVerification makes sure that msg agrees with the other messages of its round on the broadcast hash.
+func (r *Relay) checkVerification(msg *Message) error {
+	number := msg.RoundNumber
+	expected, ok := r.verification[number]
+	if !ok {
+		r.verification[number] = relayVerification{hash: msg.BroadcastVerification, from: msg.From}
+		return nil
+	}
+	if !bytes.Equal(expected.hash, msg.BroadcastVerification) {
+		return fmt.Errorf("relay: round %d: broadcast verification %x from %s doesn't match %x from %s",
+			number, msg.BroadcastVerification, msg.From, expected.hash, expected.from)
+	}
+	return nil
+}
+
+func (r *Relay) recipients(msg *Message) []party.ID {
+	if msg.To != "" {
+		return []party.ID{msg.To}
+	}
+	recipients := make([]party.ID, 0, len(r.parties)-1)
+	for _, id := range r.parties {
+		if id != msg.From {
+			recipients = append(recipients, id)
+		}
+	}
+	return recipients
+}
+
+// Err returns the error which made the Relay stop forwarding messages, or nil.
+func (r *Relay) Err() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.err == nil {
+		return nil
+	}
+	return *r.err
+}
+
+// ID returns the ID of the relay.
+func (r *Relay) ID() party.ID {
+	return r.id
+}
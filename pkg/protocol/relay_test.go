@@ -0,0 +1,125 @@
+package protocol_test
+
+import (
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+	"github.com/koteld/multi-party-sig/protocols/frost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runWithRelay runs a protocol where every message goes through relay, returning the results.
+func runWithRelay(t *testing.T, relay *protocol.Relay, ids party.IDSlice, start func(party.ID) protocol.StartFunc) map[party.ID]interface{} {
+	handlers := make(map[party.ID]*protocol.MultiHandler, len(ids))
+	for _, id := range ids {
+		h, err := protocol.NewMultiHandler(start(id), nil)
+		require.NoError(t, err)
+		handlers[id] = h
+	}
+
+	var pending []*protocol.Message
+	drain := func(h *protocol.MultiHandler) {
+		for {
+			select {
+			case msg, ok := <-h.Listen():
+				if !ok {
+					return
+				}
+				pending = append(pending, msg)
+			default:
+				return
+			}
+		}
+	}
+	for _, id := range ids {
+		drain(handlers[id])
+	}
+	for len(pending) > 0 {
+		msg := pending[0]
+		pending = pending[1:]
+		recipients, err := relay.Forward(msg)
+		require.NoError(t, err)
+		for _, id := range recipients {
+			handlers[id].Accept(msg)
+			drain(handlers[id])
+		}
+	}
+
+	results := make(map[party.ID]interface{}, len(ids))
+	for _, id := range ids {
+		r, err := handlers[id].Result()
+		require.NoError(t, err)
+		results[id] = r
+	}
+	return results
+}
+
+func TestRelay(t *testing.T) {
+	const coordinator party.ID = "coordinator"
+	group := curve.Secp256k1{}
+	ids := test.PartyIDs(3)
+	message := []byte("hello")
+
+	_, err := protocol.NewRelay(ids[0], ids)
+	assert.Error(t, err, "a party can't also be the relay")
+
+	relay, err := protocol.NewRelay(coordinator, ids)
+	require.NoError(t, err)
+	configs := runWithRelay(t, relay, ids, func(id party.ID) protocol.StartFunc {
+		return frost.Keygen(group, id, ids, 1)
+	})
+	for _, id := range ids {
+		c := configs[id].(*frost.Config)
+		assert.Len(t, c.VerificationShares.Points, len(ids), "the relay shouldn't get a share")
+		assert.Equal(t, 1, c.Threshold)
+	}
+
+	signers := ids[:2]
+	relay, err = protocol.NewRelay(coordinator, signers)
+	require.NoError(t, err)
+	signatures := runWithRelay(t, relay, signers, func(id party.ID) protocol.StartFunc {
+		return frost.Sign(configs[id].(*frost.Config), signers, message)
+	})
+	for _, id := range signers {
+		signature := signatures[id].(frost.Signature)
+		assert.True(t, signature.Verify(configs[id].(*frost.Config).PublicKey, message))
+	}
+	assert.NoError(t, relay.Err())
+}
+
+func TestRelayBroadcastMismatch(t *testing.T) {
+	ids := test.PartyIDs(3)
+	relay, err := protocol.NewRelay("coordinator", ids)
+	require.NoError(t, err)
+
+	msg := &protocol.Message{
+		SSID:                  []byte("ssid"),
+		From:                  ids[0],
+		Protocol:              "test",
+		RoundNumber:           2,
+		Data:                  []byte{},
+		BroadcastVerification: []byte("hash"),
+	}
+	recipients, err := relay.Forward(msg)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, ids[1:], recipients)
+
+	bad := *msg
+	bad.From = ids[1]
+	bad.BroadcastVerification = []byte("other hash")
+	_, err = relay.Forward(&bad)
+	require.Error(t, err)
+	var protocolErr protocol.Error
+	require.ErrorAs(t, err, &protocolErr)
+	assert.Empty(t, protocolErr.Culprits, "either of the two parties could be lying")
+	assert.Contains(t, err.Error(), string(ids[0]))
+	assert.Contains(t, err.Error(), string(ids[1]))
+
+	// the relay stops forwarding after that
+	_, err = relay.Forward(msg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/pkg/party"
+)
+
+// MinRandomnessSeedSize is the minimum size of the seed given to WithRandomness.
+const MinRandomnessSeedSize = round.MinSeedSize
+
+// WithRandomness derives all the secret randomness of the session from seed, instead of crypto/rand.
+//
+// The seed is a snapshot of the session's randomness: a handler created again with the same StartFunc,
+// session ID and seed samples the same secrets, so that Replay can rebuild its state from a message log.
+// It must be sampled from crypto/rand for each session, and kept as secret as the protocol's output,
+// since it reveals every secret sampled by this party.
+//
+// Only the protocols which sample their secrets from the session's source support this.
+// For two party protocols, use WithTwoPartyRandomness.
+func WithRandomness(seed []byte) HandlerOption {
+	return func(h *MultiHandler) {
+		h.seed = seed
+	}
+}
+
+// WithTwoPartyRandomness is like WithRandomness, for a TwoPartyHandler.
+func WithTwoPartyRandomness(seed []byte) TwoPartyOption {
+	return func(h *TwoPartyHandler) {
+		h.seed = seed
+	}
+}
+
+// setRandomness makes the rounds of the session starting with r sample their randomness from seed.
+func setRandomness(seed []byte, r round.Session) error {
+	session, ok := r.(interface{ SetSeed([]byte) error })
+	if !ok {
+		return errors.New("protocol: session doesn't support a source of randomness")
+	}
+	if err := session.SetSeed(seed); err != nil {
+		return fmt.Errorf("protocol: %w", err)
+	}
+	return nil
+}
+
+// replayState holds the messages produced by the handler while a log is being replayed.
+type replayState struct {
+	produced []*Message
+	// closed is set when the protocol ended during the replay.
+	closed bool
+}
+
+// emit sends a message produced by the session to Listen,
+// or keeps it to be matched against the log during a replay.
+func (h *MultiHandler) emit(msg *Message) {
+	if h.replay != nil {
+		h.replay.produced = append(h.replay.produced, msg)
+		return
+	}
+	h.out <- msg
+}
+
+// Replay rebuilds the state of a session from the log of the messages it sent and received before a crash.
+//
+// The handler must be freshly created with the same StartFunc, session ID and options as the one which crashed,
+// including the seed passed to WithRandomness, and Replay must be called before any other method.
+// The messages are processed in the order of the log.
+// The messages we sent are not sent again: they must be produced again by the handler,
+// otherwise the randomness doesn't match and the session can't be resumed.
+// Messages which the crashed session did not get to send are returned by Listen, and the session then
+// continues as usual.
+func (h *MultiHandler) Replay(messages []*Message) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.err != nil {
+		return *h.err
+	}
+	if h.result != nil {
+		return errors.New("protocol: replay: the session has already finished")
+	}
+
+	// the messages of the first round were already produced by NewMultiHandler
+	h.replay = &replayState{}
+	h.replay.drain(h.out)
+	err := h.replay.feed(h.currentRound.SelfID(), messages, h.accept, h.encryption != nil)
+	state := h.replay
+	h.replay = nil
+	state.flush(h.out)
+	if err != nil {
+		if h.err == nil && h.result == nil {
+			h.abort(err)
+		}
+		return newError(err)
+	}
+	if h.err != nil {
+		return *h.err
+	}
+	return nil
+}
+
+// drain moves the messages already sent to out to the produced messages.
+func (s *replayState) drain(out chan *Message) {
+	for {
+		select {
+		case msg := <-out:
+			s.produced = append(s.produced, msg)
+		default:
+			return
+		}
+	}
+}
+
+// feed processes the logged messages in order: the messages from other parties are passed to accept,
+// and ours must have been produced again.
+func (s *replayState) feed(self party.ID, messages []*Message, accept func(*Message), encryption bool) error {
+	for _, msg := range messages {
+		if msg.From != self {
+			accept(msg)
+			continue
+		}
+		if !s.consume(msg, encryption) {
+			return configError(fmt.Errorf("protocol: replay: our message for round %d was not produced again", msg.RoundNumber))
+		}
+	}
+	return nil
+}
+
+// flush sends the messages produced but not found in the log to out, and closes it if the protocol ended.
+func (s *replayState) flush(out chan *Message) {
+	for _, msg := range s.produced {
+		out <- msg
+	}
+	if s.closed {
+		close(out)
+	}
+}
+
+// consume removes the produced message matching the logged message msg,
+// and returns false if there is none.
+//
+// When encryption is used, the content of messages to a single party is not compared,
+// since it was encrypted with a random nonce.
+func (s *replayState) consume(msg *Message, encryption bool) bool {
+	for i, produced := range s.produced {
+		if !bytes.Equal(produced.SSID, msg.SSID) ||
+			produced.To != msg.To ||
+			produced.Protocol != msg.Protocol ||
+			produced.RoundNumber != msg.RoundNumber ||
+			produced.Broadcast != msg.Broadcast ||
+			!bytes.Equal(produced.BroadcastVerification, msg.BroadcastVerification) {
+			continue
+		}
+		if !(encryption && encrypted(msg)) && !bytes.Equal(produced.Data, msg.Data) {
+			continue
+		}
+		s.produced = append(s.produced[:i], s.produced[i+1:]...)
+		return true
+	}
+	return false
+}
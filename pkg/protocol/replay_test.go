@@ -0,0 +1,167 @@
+package protocol_test
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/ecdsa"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+	"github.com/koteld/multi-party-sig/protocols/cmp"
+	"github.com/koteld/multi-party-sig/protocols/doerner"
+	"github.com/koteld/multi-party-sig/protocols/frost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replayer is a handler which can rebuild its state from a message log.
+type replayer interface {
+	protocol.Handler
+	Replay(messages []*protocol.Message) error
+}
+
+// runReplay runs a session where crashing crashes once it has sent its messages for round crashRound,
+// and is then replayed from its log, and returns the results of all the parties.
+//
+// newHandler creates the handler of a party, with its randomness derived from seed.
+func runReplay(t *testing.T, ids party.IDSlice, crashing party.ID, crashRound round.Number, newHandler func(id party.ID, seed []byte) (replayer, error)) map[party.ID]interface{} {
+	seeds := make(map[party.ID][]byte, len(ids))
+	handlers := make(map[party.ID]replayer, len(ids))
+	for _, id := range ids {
+		seeds[id] = make([]byte, protocol.MinRandomnessSeedSize)
+		_, _ = rand.Read(seeds[id])
+		h, err := newHandler(id, seeds[id])
+		require.NoError(t, err)
+		handlers[id] = h
+	}
+
+	// log holds every message sent and received by the crashing party, and missed the ones it didn't receive
+	var log, missed, pending []*protocol.Message
+	crashed, replayed := false, false
+	drain := func(id party.ID) {
+		for {
+			select {
+			case msg, ok := <-handlers[id].Listen():
+				if !ok {
+					return
+				}
+				pending = append(pending, msg)
+				if id == crashing && !crashed {
+					log = append(log, msg)
+				}
+			default:
+				// crash once our messages for crashRound are sent
+				if last := len(log) - 1; id == crashing && !replayed && last >= 0 && log[last].From == crashing && log[last].RoundNumber == crashRound {
+					crashed = true
+				}
+				return
+			}
+		}
+	}
+	deliver := func() {
+		for len(pending) > 0 {
+			msg := pending[0]
+			pending = pending[1:]
+			for _, id := range ids {
+				if !msg.IsFor(id) {
+					continue
+				}
+				if id == crashing {
+					if crashed {
+						missed = append(missed, msg)
+						continue
+					}
+					log = append(log, msg)
+				}
+				handlers[id].Accept(msg)
+				drain(id)
+			}
+		}
+	}
+	for _, id := range ids {
+		drain(id)
+	}
+	deliver()
+	require.True(t, crashed)
+	_, err := handlers[crashing].Result()
+	require.Error(t, err, "the crashed party shouldn't have finished")
+
+	// replaying with other randomness doesn't give the messages we sent
+	other := make([]byte, protocol.MinRandomnessSeedSize)
+	_, _ = rand.Read(other)
+	h, err := newHandler(crashing, other)
+	require.NoError(t, err)
+	assert.Error(t, h.Replay(log))
+
+	h, err = newHandler(crashing, seeds[crashing])
+	require.NoError(t, err)
+	require.NoError(t, h.Replay(log))
+	handlers[crashing] = h
+	crashed, replayed = false, true
+	drain(crashing)
+	assert.Empty(t, pending, "the messages from before the crash shouldn't be sent again")
+	for _, msg := range missed {
+		h.Accept(msg)
+		drain(crashing)
+	}
+	deliver()
+
+	results := make(map[party.ID]interface{}, len(ids))
+	for _, id := range ids {
+		r, err := handlers[id].Result()
+		require.NoError(t, err)
+		results[id] = r
+	}
+	return results
+}
+
+func TestReplay(t *testing.T) {
+	group := curve.Secp256k1{}
+	ids := test.PartyIDs(3)
+	results := runReplay(t, ids, ids[0], 3, func(id party.ID, seed []byte) (replayer, error) {
+		return protocol.NewMultiHandler(frost.Keygen(group, id, ids, 1), []byte("replay test"), protocol.WithRandomness(seed))
+	})
+
+	publicKey := results[ids[0]].(*frost.Config).PublicKey
+	for _, id := range ids {
+		assert.True(t, publicKey.Equal(results[id].(*frost.Config).PublicKey))
+	}
+}
+
+func TestReplayCMP(t *testing.T) {
+	group := curve.Secp256k1{}
+	configs, ids := test.GenerateConfig(group, 3, 1, rand.Reader, nil)
+	message := []byte("replay test message")
+	results := runReplay(t, ids, ids[0], 3, func(id party.ID, seed []byte) (replayer, error) {
+		return protocol.NewMultiHandler(cmp.Sign(configs[id], ids, message, nil), []byte("replay test"), protocol.WithRandomness(seed))
+	})
+
+	for _, id := range ids {
+		assert.True(t, results[id].(*ecdsa.Signature).Verify(configs[id].PublicPoint(), message))
+	}
+}
+
+func TestReplayDoerner(t *testing.T) {
+	group := curve.Secp256k1{}
+	ids := test.PartyIDs(2)
+	receiver, sender := ids[0], ids[1]
+	results := runReplay(t, ids, sender, 2, func(id party.ID, seed []byte) (replayer, error) {
+		if id == receiver {
+			return protocol.NewTwoPartyHandler(doerner.Keygen(group, true, receiver, sender, nil), []byte("replay test"), true, protocol.WithTwoPartyRandomness(seed))
+		}
+		return protocol.NewTwoPartyHandler(doerner.Keygen(group, false, sender, receiver, nil), []byte("replay test"), false, protocol.WithTwoPartyRandomness(seed))
+	})
+
+	configReceiver := results[receiver].(*doerner.ConfigReceiver)
+	configSender := results[sender].(*doerner.ConfigSender)
+	assert.True(t, configReceiver.Public.Equal(configSender.Public))
+}
+
+func TestWithRandomnessShortSeed(t *testing.T) {
+	ids := test.PartyIDs(2)
+	_, err := protocol.NewMultiHandler(frost.Keygen(curve.Secp256k1{}, ids[0], ids, 1), nil, protocol.WithRandomness([]byte("short")))
+	assert.Error(t, err)
+}
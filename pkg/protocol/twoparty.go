@@ -2,12 +2,15 @@ package protocol
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"sync"
 
 	"github.com/fxamacker/cbor/v2"
 	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/internal/trace"
+	"github.com/koteld/multi-party-sig/pkg/party"
 )
 
 // TwoPartyHandler represents a restriction of the Handler for 2 party protocols.
@@ -19,12 +22,22 @@ type TwoPartyHandler struct {
 	messages map[round.Number]*Message
 	out      chan *Message
 	mtx      sync.Mutex
+	// seed is the snapshot the session's secret randomness is derived from, or nil to use crypto/rand.
+	seed []byte
+	// replay holds the outgoing messages while a log is being replayed, and is nil otherwise.
+	replay *replayState
+	// traceCtx is the execution tracer task of the session, and endTask ends it.
+	traceCtx context.Context
+	endTask  func()
 }
 
-func NewTwoPartyHandler(create StartFunc, sessionID []byte, leader bool) (*TwoPartyHandler, error) {
+// TwoPartyOption configures optional behavior of a TwoPartyHandler.
+type TwoPartyOption func(*TwoPartyHandler)
+
+func NewTwoPartyHandler(create StartFunc, sessionID []byte, leader bool, opts ...TwoPartyOption) (*TwoPartyHandler, error) {
 	r, err := create(sessionID)
 	if err != nil {
-		return nil, fmt.Errorf("protocol: failed to create round: %w", err)
+		return nil, Error{Category: ErrConfig, Err: fmt.Errorf("protocol: failed to create round: %w", err)}
 	}
 	handler := &TwoPartyHandler{
 		round:    r,
@@ -35,6 +48,15 @@ func NewTwoPartyHandler(create StartFunc, sessionID []byte, leader bool) (*TwoPa
 		out:      make(chan *Message, 2),
 		mtx:      sync.Mutex{},
 	}
+	handler.traceCtx, handler.endTask = trace.NewTask(r.ProtocolID())
+	for _, opt := range opts {
+		opt(handler)
+	}
+	if handler.seed != nil {
+		if err = setRandomness(handler.seed, r); err != nil {
+			return nil, Error{Category: ErrConfig, Err: err}
+		}
+	}
 	if leader {
 		handler.advance()
 	}
@@ -60,8 +82,10 @@ func (h *TwoPartyHandler) Listen() <-chan *Message {
 }
 
 func (h *TwoPartyHandler) Stop() {
-	if h.err != nil || h.result != nil {
-		h.abort(errors.New("aborted by user"))
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.err == nil && h.result == nil {
+		h.abort(localError(errors.New("aborted by user")))
 	}
 }
 
@@ -69,18 +93,28 @@ func (h *TwoPartyHandler) String() string {
 	return fmt.Sprintf("party: %s, protocol: %s", h.round.SelfID(), h.round.ProtocolID())
 }
 
-func (h *TwoPartyHandler) abort(err error) {
+func (h *TwoPartyHandler) abort(err error, culprits ...party.ID) {
 	if err != nil {
-		h.err = err
-		select {
-		case h.out <- &Message{
+		h.err = newError(err, culprits...)
+		msg := &Message{
 			SSID:     h.round.SSID(),
 			From:     h.round.SelfID(),
 			Protocol: h.round.ProtocolID(),
 			Data:     []byte(h.err.Error()),
-		}:
-		default:
 		}
+		if h.replay != nil {
+			h.replay.produced = append(h.replay.produced, msg)
+		} else {
+			select {
+			case h.out <- msg:
+			default:
+			}
+		}
+	}
+	h.endTask()
+	if h.replay != nil {
+		h.replay.closed = true
+		return
 	}
 	close(h.out)
 }
@@ -98,7 +132,7 @@ func (h *TwoPartyHandler) canAdvance() bool {
 func extractRoundMessage(r round.Session, msg *Message) (round.Message, error) {
 	content := r.MessageContent()
 	if err := cbor.Unmarshal(msg.Data, content); err != nil {
-		return round.Message{}, fmt.Errorf("failed to unmarshal message: %w", err)
+		return round.Message{}, unmarshalError(err)
 	}
 	roundMsg := round.Message{
 		From:      msg.From,
@@ -119,7 +153,10 @@ func (h *TwoPartyHandler) verifyMessage(msg *Message) error {
 		return err
 	}
 
-	if err = r.VerifyMessage(roundMsg); err != nil {
+	endRegion := trace.RoundRegion(h.traceCtx, r.ProtocolID(), uint16(r.Number()), "verify")
+	err = r.VerifyMessage(roundMsg)
+	endRegion()
+	if err != nil {
 		return fmt.Errorf("round %d: %w", r.Number(), err)
 	}
 
@@ -134,13 +171,18 @@ func (h *TwoPartyHandler) advance() {
 	for h.canAdvance() {
 		msg := h.messages[h.round.Number()]
 		if err := h.verifyMessage(msg); err != nil {
-			h.abort(err)
+			h.abort(err, msg.From)
 			return
 		}
 		out := make(chan *round.Message, 1)
+		endRegion := trace.RoundRegion(h.traceCtx, h.round.ProtocolID(), uint16(h.round.Number()), "finalize")
 		newRound, err := h.round.Finalize(out)
+		endRegion()
 		if err != nil || newRound == nil {
-			h.abort(err)
+			if err == nil {
+				err = errors.New("round returned no next round")
+			}
+			h.abort(localError(fmt.Errorf("round %d: %w", h.round.Number(), err)))
 			return
 		}
 		close(out)
@@ -159,13 +201,17 @@ func (h *TwoPartyHandler) advance() {
 				Broadcast:             roundMsg.Broadcast,
 				BroadcastVerification: nil,
 			}
-			h.out <- msg
+			if h.replay != nil {
+				h.replay.produced = append(h.replay.produced, msg)
+			} else {
+				h.out <- msg
+			}
 		}
 		h.round = newRound
 		switch R := newRound.(type) {
 		// An abort happened
 		case *round.Abort:
-			h.abort(R.Err)
+			h.abort(R.Err, R.Culprits...)
 			return
 		// We have the result
 		case *round.Output:
@@ -207,12 +253,16 @@ func (h *TwoPartyHandler) Accept(msg *Message) {
 	h.mtx.Lock()
 	defer h.mtx.Unlock()
 
+	h.accept(msg)
+}
+
+func (h *TwoPartyHandler) accept(msg *Message) {
 	if !h.CanAccept(msg) || h.err != nil || h.result != nil {
 		return
 	}
 
 	if msg.RoundNumber == 0 {
-		h.abort(fmt.Errorf("aborted by other party with error: \"%s\"", msg.Data))
+		h.abort(fmt.Errorf("aborted by %s with error: \"%s\"", msg.From, msg.Data))
 		return
 	}
 
@@ -220,3 +270,35 @@ func (h *TwoPartyHandler) Accept(msg *Message) {
 
 	h.advance()
 }
+
+// Replay rebuilds the state of a session from the log of the messages it sent and received before a crash,
+// like MultiHandler.Replay.
+//
+// The handler must be freshly created with the same StartFunc, session ID, role and seed
+// passed to WithTwoPartyRandomness as the one which crashed.
+func (h *TwoPartyHandler) Replay(messages []*Message) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.err != nil {
+		return h.err
+	}
+	if h.result != nil {
+		return errors.New("protocol: replay: the session has already finished")
+	}
+
+	// the leader's first messages were already produced by NewTwoPartyHandler
+	h.replay = &replayState{}
+	h.replay.drain(h.out)
+	err := h.replay.feed(h.round.SelfID(), messages, h.accept, false)
+	state := h.replay
+	h.replay = nil
+	state.flush(h.out)
+	if err != nil {
+		if h.err == nil && h.result == nil {
+			h.abort(err)
+		}
+		return newError(err)
+	}
+	return h.err
+}
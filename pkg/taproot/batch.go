@@ -0,0 +1,129 @@
+package taproot
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+)
+
+// DefaultBatchSecurity is the number of random bits in each weight of BatchVerifySchnorr.
+const DefaultBatchSecurity = 128
+
+// BatchItem is a single entry in a batch of signatures to verify.
+type BatchItem struct {
+	// Pub is the public key; only its x coordinate is used, as in BIP-340.
+	Pub curve.Point
+	// Msg is the hash of the message which was signed.
+	Msg []byte
+	// Sig is the signature, in BIP-340 format.
+	Sig [SignatureLen]byte
+}
+
+// BatchVerifySchnorr checks that every signature in a batch is valid.
+//
+// Rather than checking each signature individually, this samples random weights a_i,
+// and checks the single combined equation:
+//
+//	(Σ a_i s_i) * G = Σ a_i * R_i + Σ (a_i e_i) * P_i
+//
+// which is much cheaper than verifying each signature in turn. If this returns false,
+// FindInvalidSchnorr can be used to locate the offending signature.
+//
+// The weights have DefaultBatchSecurity random bits, see BatchVerifySchnorrWithSecurity.
+//
+// See: https://github.com/bitcoin/bips/blob/master/bip-0340.mediawiki#batch-verification
+func BatchVerifySchnorr(items []BatchItem) bool {
+	return BatchVerifySchnorrWithSecurity(items, DefaultBatchSecurity)
+}
+
+// BatchVerifySchnorrWithSecurity is like BatchVerifySchnorr, but samples weights of the given number of bits,
+// which must be between 1 and 256.
+//
+// The combined equation can hold even though some signature is invalid, but only for a single value
+// of its weight once the others are fixed. Since the weights are sampled after the batch is chosen,
+// a batch containing an invalid signature is accepted with probability at most 2^-bits.
+// The default of 128 bits matches the security of secp256k1, and more bits only make each weight
+// more expensive to apply.
+func BatchVerifySchnorrWithSecurity(items []BatchItem, bits int) bool {
+	if bits < 1 || bits > 256 {
+		panic("taproot: batch security must be between 1 and 256 bits")
+	}
+	return batchVerify(rand.Reader, items, bits)
+}
+
+func batchVerify(source io.Reader, items []BatchItem, bits int) bool {
+	group := curve.Secp256k1{}
+
+	scalars := make([]curve.Scalar, 0, 2*len(items)+1)
+	points := make([]curve.Point, 0, 2*len(items)+1)
+	// sum accumulates -Σ a_i s_i, the coefficient of G
+	sum := group.NewScalar()
+	weightBytes := make([]byte, (bits+7)/8)
+	for i := range items {
+		item := &items[i]
+		pub, ok := item.Pub.(*curve.Secp256k1Point)
+		if !ok || pub.IsIdentity() {
+			return false
+		}
+		pk := pub.XBytes()
+		P, err := group.LiftX(pk)
+		if err != nil {
+			return false
+		}
+		R, err := group.LiftX(item.Sig[:32])
+		if err != nil {
+			return false
+		}
+		s := new(curve.Secp256k1Scalar)
+		if err = s.UnmarshalBinary(item.Sig[32:]); err != nil {
+			return false
+		}
+		eHash := TaggedHash("BIP0340/challenge", item.Sig[:32], pk, item.Msg)
+		e := new(curve.Secp256k1Scalar)
+		_ = e.UnmarshalBinary(eHash)
+
+		// The first weight can be fixed to 1, saving some work.
+		a := group.NewScalar()
+		if i == 0 {
+			a.SetNat(new(safenum.Nat).SetUint64(1))
+		} else {
+			if _, err = io.ReadFull(source, weightBytes); err != nil {
+				return false
+			}
+			weightBytes[0] &= 0xff >> (8*len(weightBytes) - bits)
+			a.SetNat(new(safenum.Nat).SetBytes(weightBytes))
+		}
+
+		sum.Sub(group.NewScalar().Set(a).Mul(s))
+		scalars = append(scalars, a, group.NewScalar().Set(a).Mul(e))
+		points = append(points, R, P)
+	}
+	scalars = append(scalars, sum)
+	points = append(points, group.NewBasePoint())
+
+	check, err := group.MultiScalarMult(scalars, points)
+	if err != nil {
+		return false
+	}
+	return check.IsIdentity()
+}
+
+// FindInvalidSchnorr returns the index of the first invalid signature in a batch,
+// or -1 if every signature is valid.
+//
+// This verifies each signature individually, and is meant as a fallback after
+// BatchVerifySchnorr has rejected a batch.
+func FindInvalidSchnorr(items []BatchItem) int {
+	for i := range items {
+		pub, ok := items[i].Pub.(*curve.Secp256k1Point)
+		if !ok || pub.IsIdentity() {
+			return i
+		}
+		if !PublicKey(pub.XBytes()).Verify(items[i].Sig[:], items[i].Msg) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,92 @@
+package taproot
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/stretchr/testify/require"
+)
+
+func makeBatch(t testing.TB, n int) []BatchItem {
+	items := make([]BatchItem, n)
+	for i := range items {
+		sk, pk, err := GenKey(rand.Reader)
+		require.NoError(t, err)
+		m := sha256.Sum256([]byte{0xDE, 0xAD, 0xBE, 0xEF, byte(i)})
+		sig, err := sk.Sign(rand.Reader, m[:])
+		require.NoError(t, err)
+		P, err := curve.Secp256k1{}.LiftX(pk)
+		require.NoError(t, err)
+		items[i].Pub = P
+		items[i].Msg = m[:]
+		copy(items[i].Sig[:], sig)
+	}
+	return items
+}
+
+func TestBatchVerifySchnorr(t *testing.T) {
+	require.True(t, BatchVerifySchnorr(nil))
+
+	items := makeBatch(t, 16)
+	require.True(t, BatchVerifySchnorr(items))
+	require.Equal(t, -1, FindInvalidSchnorr(items))
+
+	for _, bad := range []int{0, 7, 15} {
+		corrupted := make([]BatchItem, len(items))
+		copy(corrupted, items)
+		corrupted[bad].Sig[40] ^= 1
+		require.False(t, BatchVerifySchnorr(corrupted))
+		require.Equal(t, bad, FindInvalidSchnorr(corrupted))
+	}
+
+	// Swapping messages keeps every component well formed, but breaks the equation.
+	swapped := make([]BatchItem, len(items))
+	copy(swapped, items)
+	swapped[2].Msg, swapped[3].Msg = swapped[3].Msg, swapped[2].Msg
+	require.False(t, BatchVerifySchnorr(swapped))
+	require.Equal(t, 2, FindInvalidSchnorr(swapped))
+}
+
+func TestBatchVerifySecurity(t *testing.T) {
+	items := makeBatch(t, 4)
+	forged := make([]BatchItem, len(items))
+	copy(forged, items)
+	forged[3].Sig[63] ^= 1
+
+	// With the default weights, a false accept has probability 2^-128.
+	for i := 0; i < 1000; i++ {
+		require.False(t, BatchVerifySchnorr(forged))
+	}
+
+	// With a single bit, the forged signature is ignored whenever its weight is 0.
+	accepted := 0
+	for i := 0; i < 200; i++ {
+		if BatchVerifySchnorrWithSecurity(forged, 1) {
+			accepted++
+		}
+	}
+	require.True(t, accepted > 40 && accepted < 160, "accepted %d of 200 forged batches with 1 bit", accepted)
+
+	require.True(t, BatchVerifySchnorrWithSecurity(items, 256))
+	require.False(t, BatchVerifySchnorrWithSecurity(forged, 256))
+	require.Panics(t, func() { BatchVerifySchnorrWithSecurity(items, 0) })
+	require.Panics(t, func() { BatchVerifySchnorrWithSecurity(items, 257) })
+}
+
+func BenchmarkBatchVerifySchnorr(b *testing.B) {
+	items := makeBatch(b, 64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BatchVerifySchnorr(items)
+	}
+}
+
+func BenchmarkSequentialVerifySchnorr(b *testing.B) {
+	items := makeBatch(b, 64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FindInvalidSchnorr(items)
+	}
+}
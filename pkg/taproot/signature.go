@@ -102,6 +102,27 @@ var signatureCounter uint64
 // Errors will be returned if the source of randomness produces an error,
 // or if the secret key is invalid.
 func (sk SecretKey) Sign(rand io.Reader, m []byte) (Signature, error) {
+	// Either read new random bytes into a, or increment a global counter.
+	var a [32]byte
+	if rand != nil {
+		if _, err := io.ReadFull(rand, a[:]); err != nil {
+			return nil, err
+		}
+	} else {
+		// Need to use atomics, because of potential multi-threading
+		ctr := atomic.AddUint64(&signatureCounter, 1)
+		binary.BigEndian.PutUint64(a[:], ctr)
+	}
+	return sk.SignWithAux(a, m)
+}
+
+// SignWithAux is like Sign, but with the auxiliary random data aux given directly,
+// as in the default signing algorithm of BIP-340.
+//
+// The nonce is derived from t = d XOR tagged_hash("BIP0340/aux", aux), so the signature is
+// a deterministic function of the key, aux, and m, and matches the reference implementation.
+// aux should be fresh randomness for each signature, but reusing it doesn't leak the key.
+func (sk SecretKey) SignWithAux(aux [32]byte, m []byte) (Signature, error) {
 	// See: https://github.com/bitcoin/bips/blob/master/bip-0340.mediawiki#default-signing
 	d := new(curve.Secp256k1Scalar)
 	if err := d.UnmarshalBinary(sk); err != nil || d.IsZero() {
@@ -115,35 +136,19 @@ func (sk SecretKey) Sign(rand io.Reader, m []byte) (Signature, error) {
 		d.Negate()
 	}
 
-	a := make([]byte, 32)
-	k := new(curve.Secp256k1Scalar)
-	for k.IsZero() {
-		// Either read new random bytes into a, or increment a global counter.
-		//
-		// Either way, the probability of us not finding a valid nonce
-		// is negligeable.
-		if rand != nil {
-			if _, err := io.ReadFull(rand, a); err != nil {
-				return nil, err
-			}
-		} else {
-			// Need to use atomics, because of potential multi-threading
-			ctr := atomic.AddUint64(&signatureCounter, 1)
-			binary.BigEndian.PutUint64(a, ctr)
-		}
-
-		t, _ := d.MarshalBinary()
-		aHash := TaggedHash("BIP0340/aux", a)
-		for i := 0; i < 32; i++ {
-			t[i] ^= aHash[i]
-		}
+	t, _ := d.MarshalBinary()
+	aHash := TaggedHash("BIP0340/aux", aux[:])
+	for i := 0; i < 32; i++ {
+		t[i] ^= aHash[i]
+	}
 
-		randHash := TaggedHash("BIP0340/nonce", t[:], PBytes, m)
+	randHash := TaggedHash("BIP0340/nonce", t[:], PBytes, m)
 
-		_ = k.UnmarshalBinary(randHash)
-		if k.IsZero() {
-			return nil, fmt.Errorf("invalid nonce")
-		}
+	// the probability of not finding a valid nonce is negligeable
+	k := new(curve.Secp256k1Scalar)
+	_ = k.UnmarshalBinary(randHash)
+	if k.IsZero() {
+		return nil, fmt.Errorf("invalid nonce")
 	}
 
 	R := k.ActOnBase().(*curve.Secp256k1Point)
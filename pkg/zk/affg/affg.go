@@ -2,8 +2,10 @@ package zkaffg
 
 import (
 	"crypto/rand"
+	"io"
 
 	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/arith"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
@@ -102,6 +104,12 @@ func (p *Proof) IsValid(public Public) bool {
 }
 
 func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	return NewProofFromSource(rand.Reader, group, hash, public, private)
+}
+
+// NewProofFromSource is like NewProof, with the randomness read from source.
+func NewProofFromSource(source io.Reader, group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	defer trace.Region("zk/affg: prove")()
 	N0 := public.Verifier.N()
 	N1 := public.Prover.N()
 	N0Modulus := public.Verifier.Modulus()
@@ -110,16 +118,16 @@ func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private
 	verifier := public.Verifier
 	prover := public.Prover
 
-	alpha := sample.IntervalLEps(rand.Reader)
-	beta := sample.IntervalLPrimeEps(rand.Reader)
+	alpha := sample.IntervalLEps(source)
+	beta := sample.IntervalLPrimeEps(source)
 
-	rho := sample.UnitModN(rand.Reader, N0)
-	rhoY := sample.UnitModN(rand.Reader, N1)
+	rho := sample.UnitModN(source, N0)
+	rhoY := sample.UnitModN(source, N1)
 
-	gamma := sample.IntervalLEpsN(rand.Reader)
-	m := sample.IntervalLN(rand.Reader)
-	delta := sample.IntervalLEpsN(rand.Reader)
-	mu := sample.IntervalLN(rand.Reader)
+	gamma := sample.IntervalLEpsN(source)
+	m := sample.IntervalLN(source)
+	delta := sample.IntervalLEpsN(source)
+	mu := sample.IntervalLN(source)
 
 	cAlpha := public.Kv.Clone().Mul(verifier, alpha)            // = Cᵃ mod N₀ = α ⊙ Kv
 	A := verifier.EncWithNonce(beta, rho).Add(verifier, cAlpha) // = Enc₀(β,ρ) ⊕ (α ⊙ Kv)
@@ -174,6 +182,7 @@ func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private
 }
 
 func (p Proof) Verify(hash *hash.Hash, public Public) bool {
+	defer trace.Region("zk/affg: verify")()
 	if !p.IsValid(public) {
 		return false
 	}
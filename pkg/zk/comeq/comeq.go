@@ -0,0 +1,190 @@
+package zkcomeq
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/koteld/multi-party-sig/internal/trace"
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
+)
+
+type Public struct {
+	// C = x⋅G+r⋅H
+	C curve.Point
+
+	// H is the blinding generator of the Pedersen commitment.
+	// Nobody should know its discrete logarithm with respect to G.
+	H curve.Point
+
+	// X = x⋅Base
+	X curve.Point
+
+	// Base is the generator for X, if nil, G is used.
+	Base curve.Point
+}
+
+type Private struct {
+	// X = x
+	X curve.Scalar
+
+	// R = r
+	R curve.Scalar
+}
+
+type Commitment struct {
+	// A = α⋅G+β⋅H
+	A curve.Point
+
+	// B = α⋅Base
+	B curve.Point
+}
+
+// Proof shows that the value committed to in C is the discrete logarithm of X.
+type Proof struct {
+	group curve.Curve
+	*Commitment
+
+	// Z1 = α+ex (mod q)
+	Z1 curve.Scalar
+
+	// Z2 = β+er (mod q)
+	Z2 curve.Scalar
+}
+
+func (p *Proof) IsValid() bool {
+	if p == nil || p.Commitment == nil {
+		return false
+	}
+	if p.A.IsIdentity() || p.B.IsIdentity() {
+		return false
+	}
+	if p.Z1.IsZero() || p.Z2.IsZero() {
+		return false
+	}
+	return true
+}
+
+// NewProof generates a proof that C opens to the discrete logarithm of X, using the Fiat-Shamir transform.
+func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	return NewProofFromSource(rand.Reader, group, hash, public, private)
+}
+
+// NewProofFromSource is like NewProof, with the randomness read from source.
+func NewProofFromSource(source io.Reader, group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	defer trace.Region("zk/comeq: prove")()
+	base := public.base(group)
+
+	alpha := sample.Scalar(source, group)
+	beta := sample.Scalar(source, group)
+
+	commitment := &Commitment{
+		A: alpha.ActOnBase().Add(beta.Act(public.H)), // A = α⋅G+β⋅H
+		B: alpha.Act(base),                           // B = α⋅Base
+	}
+	e, _ := challenge(hash, group, public, commitment)
+
+	return &Proof{
+		group:      group,
+		Commitment: commitment,
+		Z1:         group.NewScalar().Set(e).Mul(private.X).Add(alpha), // Z1 = α+ex (mod q)
+		Z2:         group.NewScalar().Set(e).Mul(private.R).Add(beta),  // Z2 = β+er (mod q)
+	}
+}
+
+func (p *Proof) Verify(hash *hash.Hash, public Public) bool {
+	defer trace.Region("zk/comeq: verify")()
+	if !p.IsValid() {
+		return false
+	}
+	if public.C == nil || public.H == nil || public.X == nil {
+		return false
+	}
+	if public.H.IsIdentity() || public.X.IsIdentity() {
+		return false
+	}
+
+	e, err := challenge(hash, p.group, public, p.Commitment)
+	if err != nil {
+		return false
+	}
+
+	{
+		lhs := p.Z1.ActOnBase().Add(p.Z2.Act(public.H)) // lhs = z₁⋅G+z₂⋅H
+		rhs := e.Act(public.C).Add(p.A)                 // rhs = A+e⋅C
+		if !lhs.Equal(rhs) {
+			return false
+		}
+	}
+
+	{
+		lhs := p.Z1.Act(public.base(p.group)) // lhs = z₁⋅Base
+		rhs := e.Act(public.X).Add(p.B)       // rhs = B+e⋅X
+		if !lhs.Equal(rhs) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func challenge(hash *hash.Hash, group curve.Curve, public Public, commitment *Commitment) (e curve.Scalar, err error) {
+	err = hash.WriteAny(public.C, public.H, public.X, public.base(group),
+		commitment.A, commitment.B)
+	e = sample.Scalar(hash.Digest(), group)
+	return
+}
+
+func (p Public) base(group curve.Curve) curve.Point {
+	if p.Base == nil {
+		return group.NewBasePoint()
+	}
+	return p.Base
+}
+
+// EmptyProof returns a proof over group, ready to be unmarshalled.
+func EmptyProof(group curve.Curve) *Proof {
+	return &Proof{
+		group: group,
+		Commitment: &Commitment{
+			A: group.NewPoint(),
+			B: group.NewPoint(),
+		},
+		Z1: group.NewScalar(),
+		Z2: group.NewScalar(),
+	}
+}
+
+type proofMarshal struct {
+	A, B   curve.Point
+	Z1, Z2 curve.Scalar
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *Proof) MarshalBinary() ([]byte, error) {
+	return cbor.Marshal(&proofMarshal{A: p.A, B: p.B, Z1: p.Z1, Z2: p.Z2})
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+//
+// The proof must have been created with EmptyProof.
+func (p *Proof) UnmarshalBinary(data []byte) error {
+	if p.group == nil {
+		return errors.New("zkcomeq: proof must be initialized using EmptyProof")
+	}
+	pm := &proofMarshal{
+		A:  p.group.NewPoint(),
+		B:  p.group.NewPoint(),
+		Z1: p.group.NewScalar(),
+		Z2: p.group.NewScalar(),
+	}
+	if err := cbor.Unmarshal(data, pm); err != nil {
+		return err
+	}
+	p.Commitment = &Commitment{A: pm.A, B: pm.B}
+	p.Z1, p.Z2 = pm.Z1, pm.Z2
+	return nil
+}
@@ -0,0 +1,78 @@
+package zkcomeq
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setup(group curve.Curve) (Public, Private) {
+	H := sample.Scalar(rand.Reader, group).ActOnBase()
+	Base := sample.Scalar(rand.Reader, group).ActOnBase()
+	x := sample.Scalar(rand.Reader, group)
+	r := sample.Scalar(rand.Reader, group)
+	public := Public{
+		C:    x.ActOnBase().Add(r.Act(H)),
+		H:    H,
+		X:    x.Act(Base),
+		Base: Base,
+	}
+	return public, Private{X: x, R: r}
+}
+
+func TestComEq(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	public, private := setup(group)
+	proof := NewProof(group, hash.New(), public, private)
+	assert.True(t, proof.Verify(hash.New(), public))
+
+	out, err := proof.MarshalBinary()
+	require.NoError(t, err, "failed to marshal proof")
+	proof2 := EmptyProof(group)
+	require.NoError(t, proof2.UnmarshalBinary(out), "failed to unmarshal proof")
+	assert.True(t, proof2.Verify(hash.New(), public))
+
+	// the default base is G
+	public.Base = nil
+	public.X = private.X.ActOnBase()
+	proof = NewProof(group, hash.New(), public, private)
+	assert.True(t, proof.Verify(hash.New(), public))
+}
+
+func TestComEqCommitmentMismatch(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	// C commits to a different value than the discrete logarithm of X
+	public, private := setup(group)
+	other := sample.Scalar(rand.Reader, group)
+	public.C = other.ActOnBase().Add(private.R.Act(public.H))
+	proof := NewProof(group, hash.New(), public, private)
+	assert.False(t, proof.Verify(hash.New(), public))
+
+	// the prover uses the wrong opening for C
+	public, private = setup(group)
+	private.R = sample.Scalar(rand.Reader, group)
+	proof = NewProof(group, hash.New(), public, private)
+	assert.False(t, proof.Verify(hash.New(), public))
+}
+
+func TestComEqDiscreteLogMismatch(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	public, private := setup(group)
+	public.X = sample.Scalar(rand.Reader, group).Act(public.Base)
+	proof := NewProof(group, hash.New(), public, private)
+	assert.False(t, proof.Verify(hash.New(), public))
+
+	// a valid proof doesn't carry over to a different base
+	public, private = setup(group)
+	proof = NewProof(group, hash.New(), public, private)
+	public.Base = nil
+	assert.False(t, proof.Verify(hash.New(), public))
+}
@@ -2,8 +2,10 @@ package zkdec
 
 import (
 	"crypto/rand"
+	"io"
 
 	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/arith"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
@@ -70,14 +72,22 @@ func (p *Proof) IsValid(public Public) bool {
 	return true
 }
 
+// NewProof generates a proof that C decrypts to y under the prover's key, and that x = y (mod q),
+// without revealing y.
 func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	return NewProofFromSource(rand.Reader, group, hash, public, private)
+}
+
+// NewProofFromSource is like NewProof, with the randomness read from source.
+func NewProofFromSource(source io.Reader, group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	defer trace.Region("zk/dec: prove")()
 	N := public.Prover.N()
 	NModulus := public.Prover.Modulus()
-	alpha := sample.IntervalLEps(rand.Reader)
+	alpha := sample.IntervalLEps(source)
 
-	mu := sample.IntervalLN(rand.Reader)
-	nu := sample.IntervalLEpsN(rand.Reader)
-	r := sample.UnitModN(rand.Reader, N)
+	mu := sample.IntervalLN(source)
+	nu := sample.IntervalLEpsN(source)
+	r := sample.UnitModN(source, N)
 
 	gamma := group.NewScalar().SetNat(alpha.Mod(group.Order()))
 
@@ -110,7 +120,9 @@ func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private
 	}
 }
 
+// Verify checks that C decrypts to some y with y = x (mod q).
 func (p *Proof) Verify(hash *hash.Hash, public Public) bool {
+	defer trace.Region("zk/dec: verify")()
 	if !p.IsValid(public) {
 		return false
 	}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"testing"
 
+	"github.com/cronokirby/safenum"
 	"github.com/fxamacker/cbor/v2"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
@@ -49,3 +50,38 @@ func TestDec(t *testing.T) {
 
 	assert.True(t, proof3.Verify(hash.New(), public))
 }
+
+func TestDecDishonest(t *testing.T) {
+	group := curve.Secp256k1{}
+
+	verifierPedersen := zk.Pedersen
+	prover := zk.ProverPaillierPublic
+
+	y := sample.IntervalL(rand.Reader)
+	x := group.NewScalar().SetNat(y.Mod(group.Order()))
+	C, rho := prover.Enc(y)
+
+	// claiming that C decrypts to another value
+	one := group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1))
+	xWrong := group.NewScalar().Set(x).Add(one)
+	public := Public{
+		C:      C,
+		X:      xWrong,
+		Prover: prover,
+		Aux:    verifierPedersen,
+	}
+	proof := NewProof(group, hash.New(), public, Private{Y: y, Rho: rho})
+	assert.False(t, proof.Verify(hash.New(), public), "proof for the wrong plaintext should fail")
+
+	// proving the decryption of a different ciphertext
+	public.X = x
+	proof = NewProof(group, hash.New(), public, Private{Y: y, Rho: rho})
+	other, _ := prover.Enc(y)
+	public.C = other
+	assert.False(t, proof.Verify(hash.New(), public), "proof for another ciphertext should fail")
+
+	// an honest proof in a different context
+	public.C = C
+	require.True(t, proof.Verify(hash.New(), public))
+	assert.False(t, proof.Verify(hash.New(&hash.BytesWithDomain{TheDomain: "Test", Bytes: []byte("other context")}), public), "proof should be bound to the hash state")
+}
@@ -21,8 +21,14 @@ func generate() {
 	pl := pool.NewPool(0)
 	defer pl.TearDown()
 
-	sk1 := paillier.NewSecretKey(pl)
-	sk2 := paillier.NewSecretKey(pl)
+	sk1, err := paillier.NewSecretKey(pl)
+	if err != nil {
+		panic(err)
+	}
+	sk2, err := paillier.NewSecretKey(pl)
+	if err != nil {
+		panic(err)
+	}
 	fmt.Printf("p1, _ := new(safenum.Nat).SetHex(\"%s\")\n", sk1.P().Hex())
 	fmt.Printf("q1, _ := new(safenum.Nat).SetHex(\"%s\")\n", sk1.Q().Hex())
 	fmt.Printf("p2, _ := new(safenum.Nat).SetHex(\"%s\")\n", sk2.P().Hex())
@@ -2,8 +2,10 @@ package zkelog
 
 import (
 	"crypto/rand"
+	"io"
 
 	"github.com/koteld/multi-party-sig/internal/elgamal"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/math/sample"
@@ -67,8 +69,14 @@ func (p *Proof) IsValid(public Public) bool {
 }
 
 func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
-	alpha := sample.Scalar(rand.Reader, group)
-	m := sample.Scalar(rand.Reader, group)
+	return NewProofFromSource(rand.Reader, group, hash, public, private)
+}
+
+// NewProofFromSource is like NewProof, with the randomness read from source.
+func NewProofFromSource(source io.Reader, group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	defer trace.Region("zk/elog: prove")()
+	alpha := sample.Scalar(source, group)
+	m := sample.Scalar(source, group)
 
 	commitment := &Commitment{
 		A: alpha.ActOnBase(),                                  // A = α⋅G
@@ -86,6 +94,7 @@ func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private
 }
 
 func (p Proof) Verify(hash *hash.Hash, public Public) bool {
+	defer trace.Region("zk/elog: verify")()
 	if !p.IsValid(public) {
 		return false
 	}
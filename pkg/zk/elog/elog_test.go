@@ -21,7 +21,7 @@ func TestElog(t *testing.T) {
 	y := sample.Scalar(rand.Reader, group)
 	Y := y.Act(H)
 
-	E, lambda := elgamal.Encrypt(X, y)
+	E, lambda := elgamal.Encrypt(rand.Reader, X, y)
 
 	public := Public{
 		E:             E,
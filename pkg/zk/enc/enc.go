@@ -2,8 +2,10 @@ package zkenc
 
 import (
 	"crypto/rand"
+	"io"
 
 	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/arith"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
@@ -62,13 +64,19 @@ func (p *Proof) IsValid(public Public) bool {
 }
 
 func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	return NewProofFromSource(rand.Reader, group, hash, public, private)
+}
+
+// NewProofFromSource is like NewProof, with the randomness read from source.
+func NewProofFromSource(source io.Reader, group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	defer trace.Region("zk/enc: prove")()
 	N := public.Prover.N()
 	NModulus := public.Prover.Modulus()
 
-	alpha := sample.IntervalLEps(rand.Reader)
-	r := sample.UnitModN(rand.Reader, N)
-	mu := sample.IntervalLN(rand.Reader)
-	gamma := sample.IntervalLEpsN(rand.Reader)
+	alpha := sample.IntervalLEps(source)
+	r := sample.UnitModN(source, N)
+	mu := sample.IntervalLN(source)
+	gamma := sample.IntervalLEpsN(source)
 
 	A := public.Prover.EncWithNonce(alpha, r)
 
@@ -99,6 +107,7 @@ func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private
 }
 
 func (p Proof) Verify(group curve.Curve, hash *hash.Hash, public Public) bool {
+	defer trace.Region("zk/enc: verify")()
 	if !p.IsValid(public) {
 		return false
 	}
@@ -2,8 +2,10 @@ package zkencelg
 
 import (
 	"crypto/rand"
+	"io"
 
 	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/arith"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
@@ -82,15 +84,21 @@ func (p *Proof) IsValid(public Public) bool {
 }
 
 func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	return NewProofFromSource(rand.Reader, group, hash, public, private)
+}
+
+// NewProofFromSource is like NewProof, with the randomness read from source.
+func NewProofFromSource(source io.Reader, group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	defer trace.Region("zk/encelg: prove")()
 	N := public.Prover.N()
 	NModulus := public.Prover.Modulus()
 
-	alpha := sample.IntervalLEps(rand.Reader)
+	alpha := sample.IntervalLEps(source)
 	alphaScalar := group.NewScalar().SetNat(alpha.Mod(group.Order()))
-	mu := sample.IntervalLN(rand.Reader)
-	r := sample.UnitModN(rand.Reader, N)
-	beta := sample.Scalar(rand.Reader, group)
-	gamma := sample.IntervalLEpsN(rand.Reader)
+	mu := sample.IntervalLN(source)
+	r := sample.UnitModN(source, N)
+	beta := sample.Scalar(source, group)
+	gamma := sample.IntervalLEpsN(source)
 
 	commitment := &Commitment{
 		S: public.Aux.Commit(private.X, mu),
@@ -125,6 +133,7 @@ func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private
 }
 
 func (p Proof) Verify(hash *hash.Hash, public Public) bool {
+	defer trace.Region("zk/encelg: verify")()
 	if !p.IsValid(public) {
 		return false
 	}
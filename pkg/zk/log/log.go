@@ -2,7 +2,9 @@ package zklog
 
 import (
 	"crypto/rand"
+	"io"
 
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/math/sample"
@@ -59,8 +61,14 @@ func (p *Proof) IsValid() bool {
 }
 
 func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
-	alpha := sample.Scalar(rand.Reader, group)
-	beta := sample.Scalar(rand.Reader, group)
+	return NewProofFromSource(rand.Reader, group, hash, public, private)
+}
+
+// NewProofFromSource is like NewProof, with the randomness read from source.
+func NewProofFromSource(source io.Reader, group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	defer trace.Region("zk/log: prove")()
+	alpha := sample.Scalar(source, group)
+	beta := sample.Scalar(source, group)
 
 	commitment := &Commitment{
 		A: alpha.ActOnBase(),   // A = α⋅G
@@ -78,6 +86,7 @@ func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private
 }
 
 func (p Proof) Verify(hash *hash.Hash, public Public) bool {
+	defer trace.Region("zk/log: verify")()
 	if !p.IsValid() {
 		return false
 	}
@@ -2,10 +2,12 @@ package zkmod
 
 import (
 	"crypto/rand"
+	"io"
 	"math/big"
 
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/params"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/arith"
 	"github.com/koteld/multi-party-sig/pkg/math/sample"
@@ -151,6 +153,12 @@ func (p *Proof) IsValid(public Public) bool {
 //  - a, b s.t. y' = (-1)ᵃ wᵇ y
 //  - R = [(xᵢ aᵢ, bᵢ), zᵢ] for i = 1, …, m
 func NewProof(hash *hash.Hash, private Private, public Public, pl *pool.Pool) *Proof {
+	return NewProofFromSource(rand.Reader, hash, private, public, pl)
+}
+
+// NewProofFromSource is like NewProof, with the randomness read from source.
+func NewProofFromSource(source io.Reader, hash *hash.Hash, private Private, public Public, pl *pool.Pool) *Proof {
+	defer trace.Region("zk/mod: prove")()
 	n, p, q, phi := public.N, private.P, private.Q, private.Phi
 	nModulus := arith.ModulusFromFactors(p, q)
 	pHalf := new(safenum.Nat).Rsh(p, 1, -1)
@@ -159,7 +167,7 @@ func NewProof(hash *hash.Hash, private Private, public Public, pl *pool.Pool) *P
 	qMod := safenum.ModulusFromNat(q)
 	phiMod := safenum.ModulusFromNat(phi)
 	// W can be leaked so no need to make this sampling return a nat.
-	w := sample.QNR(rand.Reader, n)
+	w := sample.QNR(source, n)
 
 	nInverse := new(safenum.Nat).ModInverse(n.Nat(), phiMod)
 
@@ -222,6 +230,7 @@ func (r *Response) Verify(n, w, y *big.Int) bool {
 }
 
 func (p *Proof) Verify(public Public, hash *hash.Hash, pl *pool.Pool) bool {
+	defer trace.Region("zk/mod: verify")()
 	if p == nil {
 		return false
 	}
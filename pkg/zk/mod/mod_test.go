@@ -86,7 +86,10 @@ func BenchmarkCRT(b *testing.B) {
 	pl := pool.NewPool(0)
 	defer pl.TearDown()
 
-	sk := paillier.NewSecretKey(pl)
+	sk, err := paillier.NewSecretKey(pl)
+	if err != nil {
+		b.Fatal(err)
+	}
 	ped, _ := sk.GeneratePedersen()
 
 	public := Public{
@@ -2,8 +2,10 @@ package zkmulstar
 
 import (
 	"crypto/rand"
+	"io"
 
 	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/arith"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
@@ -74,17 +76,23 @@ func (p *Proof) IsValid(public Public) bool {
 }
 
 func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	return NewProofFromSource(rand.Reader, group, hash, public, private)
+}
+
+// NewProofFromSource is like NewProof, with the randomness read from source.
+func NewProofFromSource(source io.Reader, group curve.Curve, hash *hash.Hash, public Public, private Private) *Proof {
+	defer trace.Region("zk/mulstar: prove")()
 	N0 := public.Verifier.N()
 	N0Modulus := public.Verifier.Modulus()
 
 	verifier := public.Verifier
 
-	alpha := sample.IntervalLEps(rand.Reader)
+	alpha := sample.IntervalLEps(source)
 
-	r := sample.UnitModN(rand.Reader, N0)
+	r := sample.UnitModN(source, N0)
 
-	gamma := sample.IntervalLEpsN(rand.Reader)
-	m := sample.IntervalLEpsN(rand.Reader)
+	gamma := sample.IntervalLEpsN(source)
+	m := sample.IntervalLEpsN(source)
 
 	A := public.C.Clone().Mul(verifier, alpha)
 	A.Randomize(verifier, r)
@@ -119,6 +127,7 @@ func NewProof(group curve.Curve, hash *hash.Hash, public Public, private Private
 }
 
 func (p *Proof) Verify(group curve.Curve, hash *hash.Hash, public Public) bool {
+	defer trace.Region("zk/mulstar: verify")()
 	if !p.IsValid(public) {
 		return false
 	}
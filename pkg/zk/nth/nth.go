@@ -4,8 +4,10 @@ package zknth
 
 import (
 	"crypto/rand"
+	"io"
 
 	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/arith"
 	"github.com/koteld/multi-party-sig/pkg/math/sample"
@@ -50,9 +52,15 @@ func (p *Proof) IsValid(public Public) bool {
 
 // NewProof generates a proof that r = ρᴺ (mod N²).
 func NewProof(hash *hash.Hash, public Public, private Private) *Proof {
+	return NewProofFromSource(rand.Reader, hash, public, private)
+}
+
+// NewProofFromSource is like NewProof, with the randomness read from source.
+func NewProofFromSource(source io.Reader, hash *hash.Hash, public Public, private Private) *Proof {
+	defer trace.Region("zk/nth: prove")()
 	N := public.N.N()
 	// α ← ℤₙˣ
-	alpha := sample.UnitModN(rand.Reader, N)
+	alpha := sample.UnitModN(source, N)
 	// A = αⁿ (mod n²)
 	A := public.N.ModulusSquared().Exp(alpha, N.Nat())
 	commitment := Commitment{
@@ -69,6 +77,7 @@ func NewProof(hash *hash.Hash, public Public, private Private) *Proof {
 }
 
 func (p *Proof) Verify(hash *hash.Hash, public Public) bool {
+	defer trace.Region("zk/nth: verify")()
 	if !p.IsValid(public) {
 		return false
 	}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/params"
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/arith"
 	"github.com/koteld/multi-party-sig/pkg/math/sample"
@@ -39,6 +40,12 @@ func (p *Proof) IsValid(public Public) bool {
 // NewProof generates a proof that:
 // s = t^lambda (mod N).
 func NewProof(private Private, hash *hash.Hash, public Public, pl *pool.Pool) *Proof {
+	return NewProofFromSource(rand.Reader, private, hash, public, pl)
+}
+
+// NewProofFromSource is like NewProof, with the randomness read from source.
+func NewProofFromSource(source io.Reader, private Private, hash *hash.Hash, public Public, pl *pool.Pool) *Proof {
+	defer trace.Region("zk/prm: prove")()
 	lambda := private.Lambda
 	phi := safenum.ModulusFromNat(private.Phi)
 
@@ -48,11 +55,11 @@ func NewProof(private Private, hash *hash.Hash, public Public, pl *pool.Pool) *P
 		as [params.StatParam]*safenum.Nat
 		As [params.StatParam]*big.Int
 	)
-	lockedRand := pool.NewLockedReader(rand.Reader)
+	// aᵢ ∈ mod ϕ(N), sampled in order so that the proof only depends on source
+	for i := range as {
+		as[i] = sample.ModN(source, phi)
+	}
 	pl.Parallelize(params.StatParam, func(i int) interface{} {
-		// aᵢ ∈ mod ϕ(N)
-		as[i] = sample.ModN(lockedRand, phi)
-
 		// Aᵢ = tᵃ mod N
 		As[i] = n.Exp(public.T, as[i]).Big()
 
@@ -78,6 +85,7 @@ func NewProof(private Private, hash *hash.Hash, public Public, pl *pool.Pool) *P
 }
 
 func (p *Proof) Verify(public Public, hash *hash.Hash, pl *pool.Pool) bool {
+	defer trace.Region("zk/prm: verify")()
 	if p == nil {
 		return false
 	}
@@ -15,7 +15,10 @@ func TestPrm(t *testing.T) {
 	pl := pool.NewPool(0)
 	defer pl.TearDown()
 
-	sk := paillier.NewSecretKey(pl)
+	sk, err := paillier.NewSecretKey(pl)
+	if err != nil {
+		t.Fatal(err)
+	}
 	ped, lambda := sk.GeneratePedersen()
 
 	public := Public{
@@ -51,7 +54,10 @@ func BenchmarkCRT(b *testing.B) {
 	pl := pool.NewPool(0)
 	defer pl.TearDown()
 
-	sk := paillier.NewSecretKey(pl)
+	sk, err := paillier.NewSecretKey(pl)
+	if err != nil {
+		b.Fatal(err)
+	}
 	ped, lambda := sk.GeneratePedersen()
 
 	public := Public{
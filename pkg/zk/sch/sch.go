@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"io"
 
+	"github.com/koteld/multi-party-sig/internal/trace"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/math/sample"
@@ -32,10 +33,21 @@ type Proof struct {
 }
 
 // NewProof generates a Schnorr proof of knowledge of exponent for public, using the Fiat-Shamir transform.
+//
+// gen is the point public is a multiple of, and nil stands for the base point of the group.
+// The proof only says something about whoever made it through hash: to prevent another party from
+// replaying it as their own, hash should include the prover's identity, as round.Helper.HashForID does,
+// and the verifier should use the identity of the party it expects the proof from.
 func NewProof(hash *hash.Hash, public curve.Point, private curve.Scalar, gen curve.Point) *Proof {
+	return NewProofFromSource(rand.Reader, hash, public, private, gen)
+}
+
+// NewProofFromSource is like NewProof, but samples the proof's randomness from source.
+func NewProofFromSource(source io.Reader, hash *hash.Hash, public curve.Point, private curve.Scalar, gen curve.Point) *Proof {
+	defer trace.Region("zk/sch: prove")()
 	group := private.Curve()
 
-	a := NewRandomness(rand.Reader, group, gen)
+	a := NewRandomness(source, group, gen)
 	z := a.Prove(hash, public, private, gen)
 	return &Proof{
 		C: *a.Commitment(),
@@ -45,18 +57,30 @@ func NewProof(hash *hash.Hash, public curve.Point, private curve.Scalar, gen cur
 
 // NewRandomness creates a new a ∈ ℤₚ and the corresponding commitment C = a•G.
 // This can be used to run the proof in a non-interactive way.
+//
+// If gen is nil, the base point of the group is used.
+// This gives the same proofs as passing the base point explicitly, but is faster,
+// since the curve's precomputed table for the base point can be used.
 func NewRandomness(rand io.Reader, group curve.Curve, gen curve.Point) *Randomness {
-	if gen == nil {
-		gen = group.NewBasePoint()
-	}
 	a := sample.Scalar(rand, group)
 	return &Randomness{
 		a:          a,
-		commitment: Commitment{C: a.Act(gen)},
+		commitment: Commitment{C: act(a, gen)},
+	}
+}
+
+// act returns s•gen, or s•G if gen is nil.
+func act(s curve.Scalar, gen curve.Point) curve.Point {
+	if gen == nil {
+		return s.ActOnBase()
 	}
+	return s.Act(gen)
 }
 
 func challenge(hash *hash.Hash, group curve.Curve, commitment *Commitment, public, gen curve.Point) (e curve.Scalar, err error) {
+	if gen == nil {
+		gen = group.NewBasePoint()
+	}
 	err = hash.WriteAny(commitment.C, public, gen)
 	e = sample.Scalar(hash.Digest(), group)
 	return
@@ -64,9 +88,6 @@ func challenge(hash *hash.Hash, group curve.Curve, commitment *Commitment, publi
 
 // Prove creates a Response = Randomness + H(..., Commitment, public)•secret (mod p).
 func (r *Randomness) Prove(hash *hash.Hash, public curve.Point, secret curve.Scalar, gen curve.Point) *Response {
-	if gen == nil {
-		gen = public.Curve().NewBasePoint()
-	}
 	if public.IsIdentity() || secret.IsZero() {
 		return nil
 	}
@@ -87,9 +108,6 @@ func (r *Randomness) Commitment() *Commitment {
 
 // Verify checks that Response•G = Commitment + H(..., Commitment, public)•Public.
 func (z *Response) Verify(hash *hash.Hash, public curve.Point, commitment *Commitment, gen curve.Point) bool {
-	if gen == nil {
-		gen = public.Curve().NewBasePoint()
-	}
 	if z == nil || !z.IsValid() || public.IsIdentity() {
 		return false
 	}
@@ -99,7 +117,7 @@ func (z *Response) Verify(hash *hash.Hash, public curve.Point, commitment *Commi
 		return false
 	}
 
-	lhs := z.Z.Act(gen)
+	lhs := act(z.Z, gen)
 	rhs := e.Act(public)
 	rhs = rhs.Add(commitment.C)
 
@@ -108,12 +126,28 @@ func (z *Response) Verify(hash *hash.Hash, public curve.Point, commitment *Commi
 
 // Verify checks that Proof.Response•G = Proof.Commitment + H(..., Proof.Commitment, Public)•Public.
 func (p *Proof) Verify(hash *hash.Hash, public, gen curve.Point) bool {
+	defer trace.Region("zk/sch: verify")()
 	if !p.IsValid() {
 		return false
 	}
 	return p.Z.Verify(hash, public, &p.C, gen)
 }
 
+// Challenge returns the Fiat-Shamir challenge e = H(..., Proof.Commitment, public) which Verify would use.
+//
+// Like Verify, this writes to hash, so a clone should be passed if the state is needed again.
+// It returns nil if the challenge can't be computed.
+func (p *Proof) Challenge(hash *hash.Hash, public, gen curve.Point) curve.Scalar {
+	if p == nil || p.Z.group == nil {
+		return nil
+	}
+	e, err := challenge(hash, p.Z.group, &p.C, public, gen)
+	if err != nil {
+		return nil
+	}
+	return e
+}
+
 // WriteTo implements io.WriterTo.
 func (c *Commitment) WriteTo(w io.Writer) (int64, error) {
 	data, err := c.C.MarshalBinary()
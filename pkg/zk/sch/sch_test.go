@@ -1,6 +1,7 @@
 package zksch
 
 import (
+	"bytes"
 	"crypto/rand"
 	"testing"
 
@@ -43,3 +44,66 @@ func TestSchFail(t *testing.T) {
 	proof := a.Prove(hash.New(), X, x, nil)
 	assert.False(t, proof.Verify(hash.New(), X, a.Commitment(), nil), "proof should not accept identity point")
 }
+
+func TestSchBasePointCache(t *testing.T) {
+	group := curve.Secp256k1{}
+	x, X := sample.ScalarPointPair(rand.Reader, group)
+	seed := make([]byte, 64)
+	_, _ = rand.Read(seed)
+
+	// the same randomness must give the same proof, whether or not the base point is explicit
+	cached := NewRandomness(bytes.NewReader(seed), group, nil)
+	explicit := NewRandomness(bytes.NewReader(seed), group, group.NewBasePoint())
+	require.True(t, cached.Commitment().C.Equal(explicit.Commitment().C))
+
+	zCached := cached.Prove(hash.New(), X, x, nil)
+	zExplicit := explicit.Prove(hash.New(), X, x, group.NewBasePoint())
+	require.True(t, zCached.Z.Equal(zExplicit.Z))
+
+	assert.True(t, zCached.Verify(hash.New(), X, explicit.Commitment(), group.NewBasePoint()))
+	assert.True(t, zExplicit.Verify(hash.New(), X, cached.Commitment(), nil))
+}
+
+func TestSchChallenge(t *testing.T) {
+	group := curve.Secp256k1{}
+	x, X := sample.ScalarPointPair(rand.Reader, group)
+	h := hash.New(&hash.BytesWithDomain{TheDomain: "test", Bytes: []byte("challenge")})
+
+	for _, gen := range []curve.Point{nil, sample.Scalar(rand.Reader, group).ActOnBase()} {
+		public := X
+		if gen != nil {
+			public = x.Act(gen)
+		}
+		proof := NewProof(h.Clone(), public, x, gen)
+		require.True(t, proof.Verify(h.Clone(), public, gen))
+
+		// the challenge is the one satisfying the verification equation z•gen = C + e•public
+		e := proof.Challenge(h.Clone(), public, gen)
+		require.NotNil(t, e)
+		lhs := act(proof.Z.Z, gen)
+		assert.True(t, lhs.Equal(e.Act(public).Add(proof.C.C)), "challenge doesn't match the one used by Verify")
+
+		// it is recomputed from the hash state, rather than stored
+		assert.False(t, e.Equal(proof.Challenge(hash.New(), public, gen)))
+	}
+}
+
+func benchmarkSch(b *testing.B, gen curve.Point) {
+	group := curve.Secp256k1{}
+	x, X := sample.ScalarPointPair(rand.Reader, group)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		proof := NewProof(hash.New(), X, x, gen)
+		if !proof.Verify(hash.New(), X, gen) {
+			b.Fatal("invalid proof")
+		}
+	}
+}
+
+func BenchmarkSchCachedBase(b *testing.B) {
+	benchmarkSch(b, nil)
+}
+
+func BenchmarkSchExplicitBase(b *testing.B) {
+	benchmarkSch(b, curve.Secp256k1{}.NewBasePoint())
+}
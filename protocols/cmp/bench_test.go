@@ -0,0 +1,90 @@
+package cmp
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/ecdsa"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+)
+
+// benchSizes lists the sizes used by BenchmarkCMP, as signers-of-parties,
+// where signers is Threshold + 1. For example:
+//
+//	go test ./protocols/cmp -run XXX -bench CMP -benchtime 1x -cmp.sizes 2-of-3,5-of-9
+var benchSizes = flag.String("cmp.sizes", "2-of-3", "comma separated sizes of BenchmarkCMP, as signers-of-parties")
+
+// BenchmarkCMP measures each phase of the protocol for all the parties of a session, running in the same process.
+// The allocations are those of all the parties together.
+//
+// Keygen is dominated by sampling the Paillier primes, so its time varies a lot between runs.
+func BenchmarkCMP(b *testing.B) {
+	group := curve.Secp256k1{}
+	message := []byte("hello")
+	for _, size := range strings.Split(*benchSizes, ",") {
+		var signers, parties int
+		if _, err := fmt.Sscanf(size, "%d-of-%d", &signers, &parties); err != nil || signers < 1 || signers > parties {
+			b.Fatalf("invalid size %q, expected signers-of-parties", size)
+		}
+		threshold := signers - 1
+
+		b.Run(size, func(b *testing.B) {
+			ids := test.PartyIDs(parties)
+			var configs map[party.ID]*Config
+			b.Run("keygen", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					results := test.RunProtocol(b, ids, nil, func(id party.ID) protocol.StartFunc {
+						return Keygen(group, id, ids, threshold, nil)
+					})
+					configs = make(map[party.ID]*Config, parties)
+					for id, r := range results {
+						configs[id] = r.(*Config)
+					}
+				}
+			})
+			if configs == nil {
+				// keygen was filtered out by -bench
+				configs, _ = test.GenerateConfig(group, parties, threshold, rand.Reader, nil)
+			}
+
+			online := ids[:signers]
+			presign := func(b *testing.B) map[party.ID]*ecdsa.PreSignature {
+				results := test.RunProtocol(b, online, nil, func(id party.ID) protocol.StartFunc {
+					return Presign(configs[id], online, nil)
+				})
+				preSignatures := make(map[party.ID]*ecdsa.PreSignature, signers)
+				for id, r := range results {
+					preSignatures[id] = r.(*ecdsa.PreSignature)
+				}
+				return preSignatures
+			}
+			b.Run("presign", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					presign(b)
+				}
+			})
+			b.Run("sign", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					preSignatures := presign(b)
+					b.StartTimer()
+					results := test.RunProtocol(b, online, nil, func(id party.ID) protocol.StartFunc {
+						return PresignOnline(configs[id], preSignatures[id], message, nil)
+					})
+					if !results[online[0]].(*ecdsa.Signature).Verify(configs[online[0]].PublicPoint(), message) {
+						b.Fatal("invalid signature")
+					}
+				}
+			})
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package cmp
 
 import (
+	"fmt"
+
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/pkg/ecdsa"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
@@ -46,8 +48,9 @@ func Keygen(group curve.Curve, selfID party.ID, participants []party.ID, thresho
 
 // Refresh allows the parties to refresh all existing cryptographic keys from a previously generated Config.
 // The group's ECDSA public key remains the same, but any previous shares are rendered useless.
+//
 // Returns *cmp.Config if successful.
-func Refresh(config *Config, pl *pool.Pool) protocol.StartFunc {
+func Refresh(config *Config, pl *pool.Pool, opts ...RefreshOption) protocol.StartFunc {
 	info := round.Info{
 		ProtocolID:       "cmp/refresh-threshold",
 		FinalRoundNumber: keygen.Rounds,
@@ -56,26 +59,123 @@ func Refresh(config *Config, pl *pool.Pool) protocol.StartFunc {
 		Threshold:        config.Threshold,
 		Group:            config.Group,
 	}
-	return keygen.Start(info, pl, config)
+	var options refreshOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	start := keygen.Start(info, pl, config)
+	if options.seed == nil {
+		return start
+	}
+	return func(sessionID []byte) (round.Session, error) {
+		r, err := start(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if err = r.(interface{ SetSeed([]byte) error }).SetSeed(options.seed); err != nil {
+			return nil, fmt.Errorf("cmp.Refresh: %w", err)
+		}
+		return r, nil
+	}
+}
+
+// RefreshOption modifies the behavior of Refresh.
+type RefreshOption func(*refreshOptions)
+
+type refreshOptions struct {
+	seed []byte
+}
+
+// WithSeed derives all the randomness of our part of the refresh from seed, instead of crypto/rand,
+// including the new shares and Paillier keys.
+//
+// The same seed, session ID and config always give the same refreshed config, which is meant for test vectors.
+// seed must be at least protocol.MinRandomnessSeedSize bytes, and since it reveals our new secrets,
+// it must be as secret as them, and never be reused.
+func WithSeed(seed []byte) RefreshOption {
+	return func(o *refreshOptions) {
+		o.seed = seed
+	}
 }
 
 // Sign generates an ECDSA signature for `messageHash` among the given `signers`.
+// A `messageHash` whose bytes are all zero is rejected, unless SignOptions.AllowZeroHash is set.
 // Returns *ecdsa.Signature if successful.
 func Sign(config *Config, signers []party.ID, messageHash []byte, pl *pool.Pool) protocol.StartFunc {
 	return sign.StartSign(config, signers, messageHash, pl)
 }
 
+// SignOptions modify the behavior of Sign, see SignWithOptions.
+type SignOptions = sign.Options
+
+// SignWithOptions is like Sign, with the behavior modified by options.
+//
+// With options.CommitMessage set, the signers check that they were all given the same messageHash.
+func SignWithOptions(config *Config, signers []party.ID, messageHash []byte, pl *pool.Pool, options SignOptions) protocol.StartFunc {
+	return sign.StartSignWithOptions(config, signers, messageHash, pl, options)
+}
+
+// SignOnce generates an ECDSA signature for `messageHash` among the given `signers`,
+// without PreSignatures. It is the same protocol as Sign, which already signs in a single session:
+// this is meant for callers who don't want to manage PreSignatures, and is cheaper than
+// running Presign and PresignOnline back to back.
+// Returns *ecdsa.Signature if successful.
+func SignOnce(config *Config, signers []party.ID, messageHash []byte, pl *pool.Pool) protocol.StartFunc {
+	return Sign(config, signers, messageHash, pl)
+}
+
 // Presign generates a preprocessed signature that does not depend on the message being signed.
 // When the message becomes available, the same participants can efficiently combine their shares
 // to produce a full signature with the PresignOnline protocol.
 // Note: the PreSignatures should be treated as secret key material.
+//
+// signers is the set of parties online for this presignature, which only needs to be a subset of the config
+// with more than Threshold parties, so that absent parties don't stall it.
+// The PreSignature is bound to these signers, and can only be used by them in PresignOnline.
+//
+// Presigning takes 7 rounds, the first of which only samples the secrets, so the parties send 6 flights of messages,
+// or 5 with PresignOptions.EarlyGamma. PresignOnline then needs a single flight.
 // Returns *ecdsa.PreSignature if successful.
 func Presign(config *Config, signers []party.ID, pl *pool.Pool) protocol.StartFunc {
 	return presign.StartPresign(config, signers, nil, pl)
 }
 
+// PresignOptions modify the behavior of Presign, see PresignWithOptions.
+type PresignOptions = presign.Options
+
+// PresignWithOptions is like Presign, with the behavior modified by options.
+//
+// With options.EarlyGamma set, the parties send one flight of messages less.
+func PresignWithOptions(config *Config, signers []party.ID, pl *pool.Pool, options PresignOptions) protocol.StartFunc {
+	return presign.StartPresignWithOptions(config, signers, nil, pl, options)
+}
+
+// ExcludeSigners returns the signers of a stalled Presign or Sign session, without the parties which dropped out.
+//
+// The dropped parties are usually found with protocol.MultiHandler.Pending,
+// and the session can then be started again with the remaining signers.
+// An error is returned if they are not enough to sign with config.
+func ExcludeSigners(config *Config, signers, dropped []party.ID) (party.IDSlice, error) {
+	remaining := party.NewIDSlice(signers)
+	for _, id := range dropped {
+		remaining = remaining.Remove(id)
+	}
+	if !config.QuorumSufficient(remaining) {
+		return nil, fmt.Errorf("cmp.ExcludeSigners: %d remaining signers are not enough for threshold %d", len(remaining), config.Threshold)
+	}
+	return remaining, nil
+}
+
 // PresignOnline efficiently generates an ECDSA signature for `messageHash` given a preprocessed `PreSignature`.
 // Returns *ecdsa.Signature if successful.
 func PresignOnline(config *Config, preSignature *ecdsa.PreSignature, messageHash []byte, pl *pool.Pool) protocol.StartFunc {
 	return presign.StartPresignOnline(config, preSignature, messageHash, pl)
 }
+
+// SignBatch generates an ECDSA signature for each of the `messageHashes`, pairing
+// `messageHashes[i]` with `preSignatures[i]`, in a single execution of the online phase.
+// The PreSignatures must all come from the same signers, and none may be used twice.
+// Returns []*ecdsa.Signature if successful.
+func SignBatch(config *Config, preSignatures []*ecdsa.PreSignature, messageHashes [][]byte, pl *pool.Pool) protocol.StartFunc {
+	return presign.StartPresignOnlineBatch(config, preSignatures, messageHashes, pl)
+}
@@ -2,16 +2,21 @@ package cmp
 
 import (
 	"crypto/rand"
+	"io"
 	"math"
+	mrand "math/rand"
 	"sync"
 	"testing"
 
+	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/internal/test"
 	"github.com/koteld/multi-party-sig/pkg/ecdsa"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/pool"
 	"github.com/koteld/multi-party-sig/pkg/protocol"
+	"github.com/koteld/multi-party-sig/protocols/cmp/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -86,6 +91,42 @@ func TestCMP(t *testing.T) {
 	wg.Wait()
 }
 
+func TestSignOnce(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 3
+	T := 1
+	pl := pool.NewPool(0)
+	configs, partyIDs := test.GenerateConfig(group, N, T, rand.Reader, pl)
+	pl.TearDown()
+
+	message := []byte("hello")
+	signers := partyIDs[:T+1]
+	n := test.NewNetwork(signers)
+
+	var wg sync.WaitGroup
+	wg.Add(len(signers))
+	for _, id := range signers {
+		pl := pool.NewPool(1)
+		defer pl.TearDown()
+		go func(c *Config, pl *pool.Pool) {
+			defer wg.Done()
+			h, err := protocol.NewMultiHandler(SignOnce(c, signers, message, pl), nil)
+			require.NoError(t, err)
+			test.HandlerLoop(c.ID, h, n)
+
+			signResult, err := h.Result()
+			require.NoError(t, err)
+			require.IsType(t, &ecdsa.Signature{}, signResult)
+			signature := signResult.(*ecdsa.Signature)
+			assert.True(t, signature.Verify(c.PublicPoint(), message))
+		}(configs[id], pl)
+	}
+	wg.Wait()
+
+	_, err := SignOnce(configs[signers[0]], signers, nil, nil)(nil)
+	assert.Error(t, err)
+}
+
 func TestStart(t *testing.T) {
 	group := curve.Secp256k1{}
 	N := 6
@@ -161,3 +202,356 @@ func TestStart(t *testing.T) {
 		})
 	}
 }
+
+// deliver passes messages between the handlers until none of them have anything left to send.
+// Messages for which drop returns true are lost.
+func deliver(handlers map[party.ID]*protocol.MultiHandler, drop func(*protocol.Message) bool) {
+	closed := make(map[party.ID]bool, len(handlers))
+	var queue []*protocol.Message
+	for {
+		for id, h := range handlers {
+			for !closed[id] {
+				var msg *protocol.Message
+				var ok bool
+				select {
+				case msg, ok = <-h.Listen():
+					closed[id] = !ok
+				default:
+				}
+				if msg == nil {
+					break
+				}
+				if !drop(msg) {
+					queue = append(queue, msg)
+				}
+			}
+		}
+		if len(queue) == 0 {
+			return
+		}
+		msg := queue[0]
+		queue = queue[1:]
+		for id, h := range handlers {
+			if id != msg.From && msg.IsFor(id) && h.CanAccept(msg) {
+				h.Accept(msg)
+			}
+		}
+	}
+}
+
+func TestPresignDropout(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 4
+	T := 2
+	batchSize := 3
+	configs, partyIDs := test.GenerateConfig(group, N, T, rand.Reader, nil)
+	dropped := partyIDs[N-1]
+
+	// run the batch in order, with the last party going offline partway through the second presignature
+	batches := make(map[party.ID]*PresignBatch, N)
+	for _, id := range partyIDs {
+		batches[id] = NewPresignBatch(configs[id], partyIDs, batchSize, nil)
+	}
+	for attempt := 0; !batches[partyIDs[0]].Done(); attempt++ {
+		require.Less(t, attempt, batchSize+1)
+		signers := batches[partyIDs[0]].Signers()
+		offline := len(batches[partyIDs[0]].PreSignatures()) == 1 && signers.Contains(dropped)
+		handlers := make(map[party.ID]*protocol.MultiHandler, len(signers))
+		for _, id := range signers {
+			h, err := batches[id].Start([]byte{byte(attempt)})
+			require.NoError(t, err)
+			handlers[id] = h
+		}
+		deliver(handlers, func(msg *protocol.Message) bool {
+			return offline && msg.From == dropped && msg.RoundNumber > 2
+		})
+		// the candidates are agreed on by taking all the parties pending for any signer
+		var excluded party.IDSlice
+		for _, id := range signers {
+			if id == dropped && offline {
+				continue
+			}
+			pending, err := batches[id].Finish(handlers[id])
+			require.NoError(t, err)
+			for _, j := range pending {
+				if !excluded.Contains(j) {
+					excluded = append(excluded, j)
+				}
+			}
+		}
+		if offline {
+			// the session has stalled, waiting for the party which dropped out
+			assert.Equal(t, party.IDSlice{dropped}, excluded)
+			for _, id := range signers {
+				if id != dropped {
+					require.NoError(t, batches[id].Exclude(excluded))
+					assert.Equal(t, partyIDs[:N-1], batches[id].Signers())
+				}
+			}
+		} else {
+			assert.Empty(t, excluded)
+		}
+	}
+
+	preSignatures := batches[partyIDs[0]].PreSignatures()
+	require.Len(t, preSignatures, batchSize)
+	assert.Len(t, preSignatures[0].SignerIDs(), N)
+	for _, preSignature := range preSignatures[1:] {
+		assert.NotContains(t, preSignature.SignerIDs(), dropped)
+		assert.NoError(t, preSignature.Validate())
+	}
+
+	signers := batches[partyIDs[0]].Signers()
+	_, err := ExcludeSigners(configs[partyIDs[0]], signers, signers[:1])
+	assert.Error(t, err, "T signers are not enough")
+}
+
+func TestPresignEarlyGamma(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 3
+	T := 1
+	configs, partyIDs := test.GenerateConfig(group, N, T, rand.Reader, nil)
+	message := []byte("hello")
+
+	// run returns the signature of the presignature generated with options, where each party samples its secrets
+	// from the same source in both runs.
+	// protocol.WithRandomness can't be used here, since it binds the randomness to the protocol ID.
+	run := func(options PresignOptions) *ecdsa.Signature {
+		handlers := make(map[party.ID]*protocol.MultiHandler, N)
+		for i, id := range partyIDs {
+			source := mrand.New(mrand.NewSource(int64(i)))
+			create := PresignWithOptions(configs[id], partyIDs, nil, options)
+			h, err := protocol.NewMultiHandler(func(sessionID []byte) (round.Session, error) {
+				r, err := create(sessionID)
+				if err != nil {
+					return nil, err
+				}
+				r.(interface{ SetRand(io.Reader) }).SetRand(source)
+				return r, nil
+			}, nil)
+			require.NoError(t, err)
+			handlers[id] = h
+		}
+		var flights []round.Number
+		deliver(handlers, func(msg *protocol.Message) bool {
+			if msg.From == partyIDs[0] && (len(flights) == 0 || flights[len(flights)-1] != msg.RoundNumber) {
+				flights = append(flights, msg.RoundNumber)
+			}
+			return false
+		})
+		if options.EarlyGamma {
+			assert.Equal(t, []round.Number{2, 3, 4, 6, 7}, flights)
+		} else {
+			assert.Equal(t, []round.Number{2, 3, 4, 5, 6, 7}, flights)
+		}
+
+		for _, id := range partyIDs {
+			r, err := handlers[id].Result()
+			require.NoError(t, err)
+			require.IsType(t, &ecdsa.PreSignature{}, r)
+			h, err := protocol.NewMultiHandler(PresignOnline(configs[id], r.(*ecdsa.PreSignature), message, nil), nil)
+			require.NoError(t, err)
+			handlers[id] = h
+		}
+		deliver(handlers, func(*protocol.Message) bool { return false })
+		r, err := handlers[partyIDs[0]].Result()
+		require.NoError(t, err)
+		require.IsType(t, &ecdsa.Signature{}, r)
+		signature := r.(*ecdsa.Signature)
+		assert.True(t, signature.Verify(configs[partyIDs[0]].PublicPoint(), message))
+		return signature
+	}
+
+	signature := run(PresignOptions{})
+	earlySignature := run(PresignOptions{EarlyGamma: true})
+	assert.True(t, signature.R.Equal(earlySignature.R))
+	assert.True(t, signature.S.Equal(earlySignature.S))
+}
+
+func TestRefreshWithSeed(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 2
+	T := 1
+	configs, partyIDs := test.GenerateConfig(group, N, T, rand.Reader, nil)
+
+	refresh := func(seed string) map[party.ID][]byte {
+		handlers := make(map[party.ID]*protocol.MultiHandler, N)
+		for _, id := range partyIDs {
+			partySeed := make([]byte, protocol.MinRandomnessSeedSize)
+			copy(partySeed, seed+string(id))
+			h, err := protocol.NewMultiHandler(Refresh(configs[id], nil, WithSeed(partySeed)), []byte("refresh"))
+			require.NoError(t, err)
+			handlers[id] = h
+		}
+		deliver(handlers, func(*protocol.Message) bool { return false })
+
+		refreshed := make(map[party.ID][]byte, N)
+		for _, id := range partyIDs {
+			r, err := handlers[id].Result()
+			require.NoError(t, err)
+			require.IsType(t, &Config{}, r)
+			c := r.(*Config)
+			assert.True(t, c.PublicPoint().Equal(configs[id].PublicPoint()), "the public key should stay the same")
+			assert.False(t, c.ECDSA.Equal(configs[id].ECDSA), "the share should be refreshed")
+			refreshed[id], err = c.MarshalBinary()
+			require.NoError(t, err)
+		}
+		return refreshed
+	}
+	a, b, other := refresh("seed"), refresh("seed"), refresh("other seed")
+
+	for _, id := range partyIDs {
+		assert.Equal(t, a[id], b[id], "the same seed should give the same config")
+		assert.NotEqual(t, a[id], other[id], "another seed should give another config")
+	}
+
+	_, err := protocol.NewMultiHandler(Refresh(configs[partyIDs[0]], nil, WithSeed([]byte("short"))), nil)
+	assert.Error(t, err)
+}
+
+func TestRenounce(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 3
+	T := 1
+	configs, partyIDs := test.GenerateConfig(group, N, T, rand.Reader, nil)
+	signers := partyIDs[:T+1]
+	message := []byte("hello")
+
+	// sign with the secret key x = ∑ᵢ λᵢ⋅xᵢ, before any share is renounced
+	x := group.NewScalar()
+	for _, id := range signers {
+		share, err := configs[id].AdditiveShare(signers)
+		require.NoError(t, err)
+		x.Add(share)
+	}
+	k := sample.Scalar(rand.Reader, group)
+	R := k.ActOnBase()
+	s := group.NewScalar().Set(R.XScalar()).Mul(x).Add(curve.FromHash(group, message))
+	s.Mul(group.NewScalar().Set(k).Invert())
+	signature := &ecdsa.Signature{R: R, S: s}
+
+	c := configs[signers[0]]
+	secretECDSA := c.ECDSA
+	c.Renounce()
+	assert.True(t, c.Renounced())
+	assert.True(t, secretECDSA.IsZero(), "the share should be erased in place")
+	assert.True(t, c.ElGamal.IsZero())
+	assert.True(t, c.Paillier.P().EqZero() == 1 && c.Paillier.Phi().EqZero() == 1, "the Paillier key should be erased")
+
+	assert.True(t, signature.Verify(c.PublicPoint(), message), "a renounced config can still verify")
+
+	_, err := Sign(c, signers, message, nil)(nil)
+	assert.ErrorIs(t, err, config.ErrShareRenounced)
+	_, err = SignOnce(c, signers, message, nil)(nil)
+	assert.ErrorIs(t, err, config.ErrShareRenounced)
+	_, err = Presign(c, signers, nil)(nil)
+	assert.ErrorIs(t, err, config.ErrShareRenounced)
+	assert.False(t, c.CanSign(signers))
+	_, err = c.Derive(sample.Scalar(rand.Reader, group), nil)
+	assert.ErrorIs(t, err, config.ErrShareRenounced, "a derived config would have a share again")
+	_, err = Refresh(c, nil)(nil)
+	assert.ErrorIs(t, err, config.ErrShareRenounced, "a refresh would start from the erased share")
+
+	// the config stays renounced once stored
+	data, err := c.MarshalBinary()
+	require.NoError(t, err)
+	loaded := config.EmptyConfig(group)
+	require.NoError(t, loaded.UnmarshalBinary(data))
+	assert.True(t, loaded.Renounced())
+	assert.True(t, loaded.ECDSA.IsZero())
+	assert.True(t, loaded.PublicPoint().Equal(c.PublicPoint()))
+	assert.NoError(t, loaded.Validate())
+	_, err = Presign(loaded, signers, nil)(nil)
+	assert.ErrorIs(t, err, config.ErrShareRenounced)
+
+	// the other parties are unaffected
+	assert.False(t, configs[signers[1]].Renounced())
+	assert.False(t, configs[signers[1]].ECDSA.IsZero())
+}
+
+func TestVerifyRefreshTransition(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 3
+	T := 1
+	configs, partyIDs := test.GenerateConfig(group, N, T, rand.Reader, nil)
+
+	handlers := make(map[party.ID]*protocol.MultiHandler, N)
+	for _, id := range partyIDs {
+		h, err := protocol.NewMultiHandler(Refresh(configs[id], nil), nil)
+		require.NoError(t, err)
+		handlers[id] = h
+	}
+	deliver(handlers, func(*protocol.Message) bool { return false })
+	results := make(map[party.ID]*Config, N)
+	for _, id := range partyIDs {
+		r, err := handlers[id].Result()
+		require.NoError(t, err)
+		results[id] = r.(*Config)
+	}
+	oldConfig := configs[partyIDs[0]].PublicConfig()
+	newConfig := results[partyIDs[0]].PublicConfig()
+	transcript := results[partyIDs[0]].RefreshTranscript
+	require.NotEmpty(t, transcript)
+	assert.NoError(t, config.VerifyRefreshTransition(oldConfig, newConfig, transcript))
+	for _, id := range partyIDs {
+		assert.Equal(t, transcript, results[id].RefreshTranscript, "all parties record the same transcript")
+	}
+
+	assert.Error(t, config.VerifyRefreshTransition(oldConfig, oldConfig, transcript), "nothing was refreshed")
+	assert.Error(t, config.VerifyRefreshTransition(oldConfig, newConfig, nil), "the transcript is missing")
+	assert.Error(t, config.VerifyRefreshTransition(oldConfig, newConfig, []byte("proofs")))
+	assert.Error(t, config.VerifyRefreshTransition(newConfig, newConfig, transcript), "the transcript is for another refresh")
+
+	// the transcript of another refresh of the same config doesn't match
+	for _, id := range partyIDs {
+		h, err := protocol.NewMultiHandler(Refresh(configs[id], nil), nil)
+		require.NoError(t, err)
+		handlers[id] = h
+	}
+	deliver(handlers, func(*protocol.Message) bool { return false })
+	r, err := handlers[partyIDs[0]].Result()
+	require.NoError(t, err)
+	otherTranscript := r.(*Config).RefreshTranscript
+	assert.NoError(t, config.VerifyRefreshTransition(oldConfig, r.(*Config).PublicConfig(), otherTranscript))
+	assert.Error(t, config.VerifyRefreshTransition(oldConfig, newConfig, otherTranscript))
+
+	// forge copies of the new config, with one thing changed
+	forge := func(modify func(c *config.PublicConfig)) *config.PublicConfig {
+		forged := *newConfig
+		forged.Public = make(map[party.ID]*config.Public, N)
+		for id, public := range newConfig.Public {
+			p := *public
+			forged.Public[id] = &p
+		}
+		modify(&forged)
+		return &forged
+	}
+	last := partyIDs[N-1]
+	forged := forge(func(c *config.PublicConfig) {
+		c.Public[last].ECDSA = c.Public[last].ECDSA.Add(group.NewBasePoint())
+	})
+	assert.Error(t, config.VerifyRefreshTransition(oldConfig, forged, transcript), "a share was changed")
+
+	otherConfigs, _ := test.GenerateConfig(group, N, T, rand.Reader, nil)
+	forged = forge(func(c *config.PublicConfig) {
+		for id := range c.Public {
+			c.Public[id].ECDSA = otherConfigs[id].Public[id].ECDSA
+		}
+	})
+	assert.Error(t, config.VerifyRefreshTransition(oldConfig, forged, transcript), "the shares are for another key")
+
+	forged = forge(func(c *config.PublicConfig) {
+		c.Public[last].Paillier = oldConfig.Public[last].Paillier
+		c.Public[last].Pedersen = oldConfig.Public[last].Pedersen
+	})
+	assert.Error(t, config.VerifyRefreshTransition(oldConfig, forged, transcript), "the Paillier key wasn't refreshed")
+
+	forged = forge(func(c *config.PublicConfig) {
+		c.Public[last].Paillier = otherConfigs[last].Public[last].Paillier
+		c.Public[last].Pedersen = otherConfigs[last].Public[last].Pedersen
+	})
+	assert.Error(t, config.VerifyRefreshTransition(oldConfig, forged, transcript), "the Paillier key isn't the one which was proven")
+
+	forged = forge(func(c *config.PublicConfig) { c.Threshold = 2 })
+	assert.Error(t, config.VerifyRefreshTransition(oldConfig, forged, transcript))
+}
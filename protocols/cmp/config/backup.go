@@ -0,0 +1,254 @@
+package config
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/polynomial"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+// ShareBackup is an encrypted backup of a party's share xᵢ of the ECDSA secret,
+// which can only be restored with the help of Threshold other parties.
+// Threshold is always larger than the threshold of the config, see BackupShare.
+//
+// xᵢ is split again with a polynomial fᵢ of degree Threshold - 1 such that fᵢ(0) = xᵢ,
+// and each piece fᵢ(j) is encrypted to the transport key of party j.
+// No single copy of the backup, nor fewer than Threshold of the other parties, learn anything about xᵢ.
+type ShareBackup struct {
+	// ID is the party whose share is backed up.
+	ID party.ID
+	// Threshold is the number of other parties needed to restore the share.
+	Threshold int
+	// Share is the public share Xᵢ = xᵢ⋅G, which the restored share is checked against.
+	Share curve.Point
+	// Commitments maps each party j to fᵢ(j)⋅G, used to detect a party returning a wrong piece.
+	Commitments map[party.ID]curve.Point
+	// Pieces maps each party j to fᵢ(j), encrypted to its transport key.
+	Pieces map[party.ID][]byte
+}
+
+// backupContext is authenticated along with each encrypted piece of a ShareBackup.
+type backupContext struct {
+	Purpose string
+	Owner   party.ID
+	Holder  party.ID
+	Share   curve.Point
+}
+
+// BackupShare splits our share of the ECDSA secret so that any threshold of the other parties can help restore it,
+// and encrypts each piece to the transport key of its holder.
+//
+// All the other parties must have a transport key, and threshold must be between c.Threshold + 1 and their number.
+// Any threshold of them learn xᵢ, and so together hold one more share of the key than they did before.
+// With at least c.Threshold + 1 of them, they could already sign without the backup, so it doesn't lower
+// the number of corruptions the key tolerates. A smaller threshold would, and is rejected.
+// Within that bound, the backup can be stored anywhere, see ReencryptBackupPiece and RestoreShare.
+func (c *Config) BackupShare(threshold int) (*ShareBackup, error) {
+	if c.renounced {
+		return nil, ErrShareRenounced
+	}
+	var others []party.ID
+	for _, j := range c.PartyIDs() {
+		if j == c.ID {
+			continue
+		}
+		if len(c.Public[j].TransportKey) != curve25519.PointSize {
+			return nil, fmt.Errorf("config: backup: missing transport key for %s", j)
+		}
+		others = append(others, j)
+	}
+	if threshold <= c.Threshold || threshold > len(others) {
+		return nil, fmt.Errorf("config: backup: threshold must be between %d and %d", c.Threshold+1, len(others))
+	}
+
+	f := polynomial.NewPolynomial(c.Group, threshold-1, c.ECDSA)
+	backup := &ShareBackup{
+		ID:          c.ID,
+		Threshold:   threshold,
+		Share:       c.Public[c.ID].ECDSA,
+		Commitments: make(map[party.ID]curve.Point, len(others)),
+		Pieces:      make(map[party.ID][]byte, len(others)),
+	}
+	for _, j := range others {
+		piece := f.Evaluate(j.Scalar(c.Group))
+		data, err := piece.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("config: backup: %w", err)
+		}
+		ciphertext, err := backup.seal("backup", j, c.Public[j].TransportKey, data)
+		if err != nil {
+			return nil, fmt.Errorf("config: backup: %w", err)
+		}
+		backup.Commitments[j] = piece.ActOnBase()
+		backup.Pieces[j] = ciphertext
+	}
+	return backup, nil
+}
+
+// ReencryptBackupPiece decrypts our piece of another party's backup, and encrypts it again to recipientKey,
+// the X25519 public key generated by that party to restore its share.
+//
+// The owner of the backup must be authenticated before calling this,
+// since whoever holds the secret key of recipientKey learns our piece.
+func (c *Config) ReencryptBackupPiece(backup *ShareBackup, recipientKey []byte) ([]byte, error) {
+	if backup.ID == c.ID {
+		return nil, errors.New("config: reencrypt: the backup is of our own share")
+	}
+	ciphertext, ok := backup.Pieces[c.ID]
+	if !ok {
+		return nil, fmt.Errorf("config: reencrypt: the backup has no piece for %s", c.ID)
+	}
+	piece, err := backup.open("backup", c.ID, c.TransportKey, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("config: reencrypt: %w", err)
+	}
+	data, err := piece.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("config: reencrypt: %w", err)
+	}
+	reencrypted, err := backup.seal("restore", c.ID, recipientKey, data)
+	if err != nil {
+		return nil, fmt.Errorf("config: reencrypt: %w", err)
+	}
+	return reencrypted, nil
+}
+
+// RestoreShare recovers the share xᵢ of a backup, from the pieces returned by ReencryptBackupPiece.
+//
+// pieces maps each helping party to its reencrypted piece, and secretKey is the X25519 key
+// whose public key was given to the helpers.
+// Pieces which can't be decrypted, or don't match the backup, are skipped, and their senders are
+// listed in the error if fewer than backup.Threshold pieces are valid.
+func RestoreShare(backup *ShareBackup, secretKey []byte, pieces map[party.ID][]byte) (curve.Scalar, error) {
+	if backup.Share == nil {
+		return nil, errors.New("config: restore: the backup has no public share")
+	}
+	group := backup.Share.Curve()
+
+	helpers := make([]party.ID, 0, len(pieces))
+	for j := range pieces {
+		helpers = append(helpers, j)
+	}
+	var (
+		valid    []party.ID
+		culprits []string
+	)
+	values := make(map[party.ID]curve.Scalar, backup.Threshold)
+	for _, j := range party.NewIDSlice(helpers) {
+		if len(valid) == backup.Threshold {
+			break
+		}
+		piece, err := backup.open("restore", j, secretKey, pieces[j])
+		if commitment, ok := backup.Commitments[j]; err != nil || !ok || !piece.ActOnBase().Equal(commitment) {
+			culprits = append(culprits, string(j))
+			continue
+		}
+		values[j] = piece
+		valid = append(valid, j)
+	}
+	if len(valid) < backup.Threshold {
+		return nil, fmt.Errorf("config: restore: got %d valid pieces, but %d are needed (invalid pieces from %v)",
+			len(valid), backup.Threshold, culprits)
+	}
+
+	share := group.NewScalar()
+	for j, l := range polynomial.Lagrange(group, valid) {
+		share.Add(l.Mul(values[j]))
+	}
+	if !share.ActOnBase().Equal(backup.Share) {
+		return nil, errors.New("config: restore: the restored share doesn't match the backup")
+	}
+	return share, nil
+}
+
+// seal encrypts a piece held by holder to publicKey, using an ephemeral X25519 key.
+//
+// The result is the ephemeral public key, followed by the nonce and the ciphertext.
+func (b *ShareBackup) seal(purpose string, holder party.ID, publicKey, plaintext []byte) ([]byte, error) {
+	ephemeral := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(ephemeral); err != nil {
+		return nil, err
+	}
+	ephemeralPublic, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := curve25519.X25519(ephemeral, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, ad, err := b.aead(purpose, holder, shared, ephemeralPublic, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(ephemeralPublic, nonce...)
+	return aead.Seal(out, nonce, plaintext, ad), nil
+}
+
+// open decrypts a piece encrypted by seal with the secret key matching its public key.
+func (b *ShareBackup) open(purpose string, holder party.ID, secretKey, ciphertext []byte) (curve.Scalar, error) {
+	if len(secretKey) != curve25519.ScalarSize {
+		return nil, errors.New("invalid transport secret key")
+	}
+	if len(ciphertext) < curve25519.PointSize+chacha20poly1305.NonceSizeX {
+		return nil, errors.New("encrypted piece is too short")
+	}
+	ephemeralPublic := ciphertext[:curve25519.PointSize]
+	nonce := ciphertext[curve25519.PointSize : curve25519.PointSize+chacha20poly1305.NonceSizeX]
+	ciphertext = ciphertext[curve25519.PointSize+chacha20poly1305.NonceSizeX:]
+
+	publicKey, err := curve25519.X25519(secretKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := curve25519.X25519(secretKey, ephemeralPublic)
+	if err != nil {
+		return nil, err
+	}
+	aead, ad, err := b.aead(purpose, holder, shared, ephemeralPublic, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := aead.Open(nil, nonce, ciphertext, ad)
+	if err != nil {
+		return nil, errors.New("failed to decrypt piece")
+	}
+	piece := b.Share.Curve().NewScalar()
+	if err = piece.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return piece, nil
+}
+
+// aead returns the cipher for a piece, and the additional data binding it to this backup, its holder and purpose.
+func (b *ShareBackup) aead(purpose string, holder party.ID, shared, ephemeralPublic, publicKey []byte) (cipher.AEAD, []byte, error) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	material := append(append(append([]byte(nil), shared...), ephemeralPublic...), publicKey...)
+	blake3.DeriveKey("multi-party-sig cmp share backup", material, key)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	ad, err := cbor.Marshal(&backupContext{
+		Purpose: purpose,
+		Owner:   b.ID,
+		Holder:  holder,
+		Share:   b.Share,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, ad, nil
+}
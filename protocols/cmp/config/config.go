@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"strings"
+	"sync"
 
 	"github.com/koteld/multi-party-sig/internal/bip32"
 	"github.com/koteld/multi-party-sig/internal/params"
@@ -42,6 +44,78 @@ type Config struct {
 	ChainKey types.RID
 	// Public maps party.ID to public. It contains all public information associated to a party.
 	Public map[party.ID]*Public
+	// Origin records how the secret key shared by this config was generated.
+	//
+	// A refresh keeps the Origin of the config being refreshed.
+	Origin Origin
+	// TransportKey is this party's static X25519 secret key, used to encrypt the messages addressed to it.
+	//
+	// It is optional, and is not created by keygen: the caller sets it, and the TransportKey of every Public,
+	// after generating the keys with protocol.GenerateTransportKey and exchanging the public ones.
+	// It is then kept by a refresh and by Derive, see TransportKeys.
+	TransportKey []byte
+	// VSSCommitments are the coefficients [A₀, …, Aₜ] of the polynomial F(X) = A₀ + A₁•X + … + Aₜ•Xᵗ
+	// agreed on during keygen, such that the public key is A₀ and each public share Xⱼ is F(j), see KeyProof.
+	//
+	// It is nil for configs created before it was recorded, and for those refreshed from them.
+	VSSCommitments []curve.Point
+	// RefreshTranscript contains the proofs sent by every party during the refresh which created this config,
+	// so that the refresh can be audited with VerifyRefreshTransition.
+	//
+	// It is nil for configs which weren't created by a refresh, and isn't kept by MarshalBinary.
+	RefreshTranscript []byte
+
+	// renounced is set once the secrets of this config have been erased by Renounce.
+	renounced bool
+	// lagrange caches the coefficients computed by Lagrange, see lagrangeMtx.
+	lagrange map[string]map[party.ID]curve.Scalar
+}
+
+// lagrangeMtx protects the Lagrange caches of all configs, which are filled lazily
+// by signing sessions possibly running concurrently.
+var lagrangeMtx sync.Mutex
+
+// ErrShareRenounced is returned when trying to sign with a Config whose share was erased by Renounce.
+var ErrShareRenounced = errors.New("config: share was renounced")
+
+// Origin describes how the secret key of a Config came to be.
+type Origin uint8
+
+const (
+	// OriginUnknown is used for configs which predate provenance tracking.
+	OriginUnknown Origin = iota
+	// OriginDKG means that the key was generated by the distributed keygen protocol,
+	// and that no single party ever knew the secret key.
+	OriginDKG
+	// OriginDealer means that the key was generated by a single dealer, who then
+	// distributed the shares, and so may still know the secret key.
+	OriginDealer
+)
+
+// String implements fmt.Stringer.
+func (o Origin) String() string {
+	switch o {
+	case OriginUnknown:
+		return "unknown"
+	case OriginDKG:
+		return "dkg"
+	case OriginDealer:
+		return "dealer"
+	default:
+		return fmt.Sprintf("origin(%d)", uint8(o))
+	}
+}
+
+// Valid returns true if o is one of the known origins.
+func (o Origin) Valid() bool {
+	return o <= OriginDealer
+}
+
+// Provenance returns how the secret key of this config was generated.
+//
+// Policies which do not trust dealer-generated keys should refuse to sign unless this returns OriginDKG.
+func (c *Config) Provenance() Origin {
+	return c.Origin
 }
 
 // Public holds public information for a party.
@@ -54,6 +128,8 @@ type Public struct {
 	Paillier *paillier.PublicKey
 	// Pedersen is this party's public Pedersen parameters.
 	Pedersen *pedersen.Parameters
+	// TransportKey is this party's static X25519 public key, if it has one.
+	TransportKey []byte
 }
 
 // PublicPoint returns the group's public ECC point.
@@ -176,30 +252,196 @@ func (p *Public) WriteTo(w io.Writer) (total int64, err error) {
 // a valid subset of the original parties of size > t,
 // and includes self.
 func (c *Config) CanSign(signers party.IDSlice) bool {
+	return c.CheckSigners(signers) == nil
+}
+
+// CheckSigners is like CanSign, but returns an error explaining why signers can't sign with this config.
+func (c *Config) CheckSigners(signers party.IDSlice) error {
+	if c.renounced {
+		return ErrShareRenounced
+	}
 	if !ValidThreshold(c.Threshold, len(signers)) {
-		return false
+		return fmt.Errorf("config: %d signers are not enough for threshold %d", len(signers), c.Threshold)
 	}
 
 	// check for duplicates
 	if !signers.Valid() {
-		return false
+		return errors.New("config: signers must be sorted, without duplicates")
 	}
 
 	if !signers.Contains(c.ID) {
-		return false
+		return fmt.Errorf("config: signers don't include %s", c.ID)
 	}
 
-	// check that the signers are a subset of the original parties,
-	// that it includes self, and that the size is > t.
+	// check that the signers are a subset of the original parties
 	for _, j := range signers {
 		if _, ok := c.Public[j]; !ok {
-			return false
+			return fmt.Errorf("config: signer %s is not a party of this config", j)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the Paillier and Pedersen parameters of every party have the sizes expected by the
+// zero-knowledge proofs, and should be called on a config assembled from several sources.
+//
+// Each party's Paillier modulus must have params.BitsPaillier bits, and its Pedersen parameters must use that
+// same modulus, so that all the parties agree on the sizes of the ranges being proved.
+// Our own Paillier primes must have params.BitsBlumPrime bits, and match our public key.
+func (c *Config) Validate() error {
+	if c.Paillier == nil || c.Public == nil {
+		return errors.New("config: config has nil fields")
+	}
+	if _, ok := c.Public[c.ID]; !ok {
+		return errors.New("config: no public data for this party")
+	}
+	if !c.renounced {
+		if p, q := c.Paillier.P().TrueLen(), c.Paillier.Q().TrueLen(); p != params.BitsBlumPrime || q != params.BitsBlumPrime {
+			return fmt.Errorf("config: Paillier primes have %d and %d bits, need %d", p, q, params.BitsBlumPrime)
+		}
+	}
+	if !c.Paillier.PublicKey.Equal(c.Public[c.ID].Paillier) {
+		return errors.New("config: Paillier secret key doesn't match our public key")
+	}
+
+	for _, j := range c.PartyIDs() {
+		public := c.Public[j]
+		if public.Paillier == nil || public.Pedersen == nil {
+			return fmt.Errorf("config: party %s: missing Paillier key or Pedersen parameters", j)
+		}
+		if err := paillier.ValidateN(public.Paillier.N()); err != nil {
+			return fmt.Errorf("config: party %s: Paillier modulus: %w", j, err)
+		}
+		if bits := public.Pedersen.N().BitLen(); bits != params.BitsPaillier {
+			return fmt.Errorf("config: party %s: Pedersen modulus has %d bits, need %d", j, bits, params.BitsPaillier)
+		}
+		if public.Pedersen.N().Nat().Eq(public.Paillier.N().Nat()) != 1 {
+			return fmt.Errorf("config: party %s: Pedersen parameters don't match the Paillier key", j)
+		}
+		if err := pedersen.ValidateParameters(public.Pedersen.N(), public.Pedersen.S(), public.Pedersen.T()); err != nil {
+			return fmt.Errorf("config: party %s: %w", j, err)
+		}
+	}
+	return nil
+}
+
+// Renounce erases the secrets of this party, once it has been removed from the group by a resharing,
+// and disables signing with this config.
+//
+// The ECDSA and ElGamal shares, the Paillier secret key, and the transport key are overwritten in place,
+// so other references to them are erased as well.
+// The public data is kept, so signatures under PublicPoint can still be verified,
+// but starting a signing protocol with c fails with ErrShareRenounced.
+func (c *Config) Renounce() {
+	if c.ECDSA != nil {
+		c.ECDSA.Set(c.Group.NewScalar())
+	}
+	if c.ElGamal != nil {
+		c.ElGamal.Set(c.Group.NewScalar())
+	}
+	if c.Paillier != nil {
+		c.Paillier.Zeroize()
+	}
+	for i := range c.TransportKey {
+		c.TransportKey[i] = 0
+	}
+	c.renounced = true
+}
+
+// TransportKeys returns our static X25519 secret key, and the public keys of all the parties,
+// to be passed to protocol.WithEncryption.
+func (c *Config) TransportKeys() (secretKey []byte, publicKeys map[party.ID][]byte) {
+	publicKeys = make(map[party.ID][]byte, len(c.Public))
+	for j, public := range c.Public {
+		if public.TransportKey != nil {
+			publicKeys[j] = public.TransportKey
 		}
 	}
+	return c.TransportKey, publicKeys
+}
 
+// Renounced returns true if the secrets of this config were erased by Renounce.
+func (c *Config) Renounced() bool {
+	return c.renounced
+}
+
+// CheckNotRenounced returns ErrShareRenounced if the secrets of this config were erased by Renounce,
+// and it can no longer be used to sign.
+func (c *Config) CheckNotRenounced() error {
+	if c.renounced {
+		return ErrShareRenounced
+	}
+	return nil
+}
+
+// QuorumSufficient returns true if the present parties are enough to sign with this config,
+// that is if there are more than t of them, and all of them hold a share of the key.
+//
+// Unlike CanSign, present doesn't need to be sorted or to include this party.
+// A party listed twice makes the set invalid, rather than being counted twice.
+// No secret material is used.
+func (c *Config) QuorumSufficient(present party.IDSlice) bool {
+	present = party.NewIDSlice(present)
+	if !present.Valid() || !ValidThreshold(c.Threshold, len(present)) {
+		return false
+	}
+	for _, j := range present {
+		if _, ok := c.Public[j]; !ok {
+			return false
+		}
+	}
 	return true
 }
 
+// AdditiveShare returns this party's Lagrange weighted share λᵢ⋅xᵢ for the given set of signers.
+//
+// The additive shares of all the signers sum up to the secret key.
+// An error is returned if signers isn't a valid signing subset including this party.
+func (c *Config) AdditiveShare(signers party.IDSlice) (curve.Scalar, error) {
+	signers = party.NewIDSlice(signers)
+	if !signers.Contains(c.ID) {
+		return nil, fmt.Errorf("config: %s is not one of the signers", c.ID)
+	}
+	if !c.CanSign(signers) {
+		return nil, errors.New("config: signers is not a valid signing subset")
+	}
+	lambda := c.Lagrange(signers)[c.ID]
+	return lambda.Mul(c.ECDSA), nil
+}
+
+// Lagrange returns the Lagrange coefficients at 0 for the given set of signers.
+//
+// They only depend on the group and the IDs of the signers, so they are cached for each signer set,
+// and later signatures by the same quorum don't need to compute them again.
+// The cache is also keyed by the group, and a derived or refreshed config starts with an empty one.
+// The returned Scalars are copies, which can be modified by the caller.
+func (c *Config) Lagrange(signers party.IDSlice) map[party.ID]curve.Scalar {
+	signers = party.NewIDSlice(signers)
+	var key strings.Builder
+	key.WriteString(c.Group.Name())
+	for _, j := range signers {
+		key.WriteByte(0)
+		key.WriteString(string(j))
+	}
+
+	lagrangeMtx.Lock()
+	defer lagrangeMtx.Unlock()
+	cached, ok := c.lagrange[key.String()]
+	if !ok {
+		cached = polynomial.Lagrange(c.Group, signers)
+		if c.lagrange == nil {
+			c.lagrange = make(map[string]map[party.ID]curve.Scalar)
+		}
+		c.lagrange[key.String()] = cached
+	}
+	coefficients := make(map[party.ID]curve.Scalar, len(cached))
+	for j, l := range cached {
+		coefficients[j] = c.Group.NewScalar().Set(l)
+	}
+	return coefficients
+}
+
 func ValidThreshold(t, n int) bool {
 	if t < 0 || t > math.MaxUint32 {
 		return false
@@ -217,6 +459,9 @@ func ValidThreshold(t, n int) bool {
 //
 // A new chain key can be passed, which will replace the existing one for the new keypair.
 func (c *Config) Derive(adjust curve.Scalar, newChainKey []byte) (*Config, error) {
+	if err := c.CheckNotRenounced(); err != nil {
+		return nil, err
+	}
 	if len(newChainKey) <= 0 {
 		newChainKey = c.ChainKey
 	}
@@ -235,26 +480,37 @@ func (c *Config) Derive(adjust curve.Scalar, newChainKey []byte) (*Config, error
 			ElGamal:  v.ElGamal,
 			Paillier: v.Paillier,
 			Pedersen: v.Pedersen,
+
+			TransportKey: v.TransportKey,
 		}
 	}
 
+	// F(X) + adjust•G still gives the public shares, with the new public key as constant
+	var commitments []curve.Point
+	if len(c.VSSCommitments) > 0 {
+		commitments = append([]curve.Point{c.VSSCommitments[0].Add(adjustG)}, c.VSSCommitments[1:]...)
+	}
+
 	return &Config{
-		Group:     c.Group,
-		ID:        c.ID,
-		Threshold: c.Threshold,
-		ECDSA:     c.Group.NewScalar().Set(c.ECDSA).Add(adjust),
-		ElGamal:   c.ElGamal,
-		Paillier:  c.Paillier,
-		RID:       c.RID,
-		ChainKey:  newChainKey,
-		Public:    public,
+		Group:          c.Group,
+		ID:             c.ID,
+		Threshold:      c.Threshold,
+		ECDSA:          c.Group.NewScalar().Set(c.ECDSA).Add(adjust),
+		ElGamal:        c.ElGamal,
+		Paillier:       c.Paillier,
+		RID:            c.RID,
+		ChainKey:       newChainKey,
+		Public:         public,
+		Origin:         c.Origin,
+		TransportKey:   c.TransportKey,
+		VSSCommitments: commitments,
 	}, nil
 }
 
 // DeriveBIP32 derives a sharing of the ith child of the consortium signing key.
 //
 // This function uses unhardened derivation, deriving a key without including the
-// underlying private key. This function will panic if i ⩾ 2³¹, since that indicates
+// underlying private key. An error is returned if i ⩾ 2³¹, since that indicates
 // a hardened key.
 //
 // Sometimes, an error will be returned, indicating that this index generates
@@ -0,0 +1,460 @@
+package config_test
+
+import (
+	"crypto/rand"
+	"errors"
+	mrand "math/rand"
+	"testing"
+
+	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/math/arith"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/polynomial"
+	"github.com/koteld/multi-party-sig/pkg/paillier"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/pedersen"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+	"github.com/koteld/multi-party-sig/protocols/cmp/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdditiveShare(t *testing.T) {
+	group := curve.Secp256k1{}
+	N, T := 5, 2
+	configs, partyIDs := test.GenerateConfig(group, N, T, mrand.New(mrand.NewSource(1)), nil)
+
+	// reconstruct the secret from all the shares
+	lagrange := polynomial.Lagrange(group, partyIDs)
+	secret := group.NewScalar()
+	for _, id := range partyIDs {
+		secret.Add(group.NewScalar().Set(lagrange[id]).Mul(configs[id].ECDSA))
+	}
+	require.True(t, secret.ActOnBase().Equal(configs[partyIDs[0]].PublicPoint()))
+
+	for _, signers := range []party.IDSlice{partyIDs[:T+1], partyIDs[N-T-1:], partyIDs} {
+		sum := group.NewScalar()
+		for _, id := range signers {
+			share, err := configs[id].AdditiveShare(signers)
+			require.NoError(t, err)
+			sum.Add(share)
+		}
+		assert.True(t, sum.Equal(secret), "additive shares for %v don't sum to the secret", signers)
+	}
+
+	_, err := configs[partyIDs[N-1]].AdditiveShare(partyIDs[:T+1])
+	assert.Error(t, err, "party isn't a signer")
+	_, err = configs[partyIDs[0]].AdditiveShare(partyIDs[:T])
+	assert.Error(t, err, "not enough signers")
+}
+
+func TestQuorumSufficient(t *testing.T) {
+	group := curve.Secp256k1{}
+	N, T := 5, 2
+	configs, partyIDs := test.GenerateConfig(group, N, T, mrand.New(mrand.NewSource(1)), nil)
+	c := configs[partyIDs[0]]
+
+	assert.True(t, c.QuorumSufficient(partyIDs[:T+1]), "exactly t+1 parties")
+	assert.True(t, c.QuorumSufficient(partyIDs), "all parties")
+	assert.True(t, c.QuorumSufficient(partyIDs[N-T-1:]), "quorum without this party")
+	assert.True(t, c.QuorumSufficient(party.IDSlice{partyIDs[3], partyIDs[1], partyIDs[2]}), "unsorted quorum")
+
+	assert.False(t, c.QuorumSufficient(partyIDs[:T]), "only t parties")
+	assert.False(t, c.QuorumSufficient(nil), "no parties")
+	assert.False(t, c.QuorumSufficient(party.IDSlice{partyIDs[0], partyIDs[1], partyIDs[1]}), "duplicated party")
+	assert.False(t, c.QuorumSufficient(append(partyIDs[:T].Copy(), "unknown")), "unknown party")
+	assert.False(t, c.QuorumSufficient(append(partyIDs.Copy(), "unknown")), "unknown party in a large enough set")
+}
+
+// otherCurve stands in for a different group, such as P-256.
+type otherCurve struct {
+	curve.Secp256k1
+}
+
+func (otherCurve) Name() string {
+	return "P-256"
+}
+
+func TestUnmarshalWrongCurve(t *testing.T) {
+	group := curve.Secp256k1{}
+	configs, partyIDs := test.GenerateConfig(group, 3, 1, mrand.New(mrand.NewSource(1)), nil)
+	data, err := configs[partyIDs[0]].MarshalBinary()
+	require.NoError(t, err)
+
+	err = config.EmptyConfig(otherCurve{}).UnmarshalBinary(data)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "created for curve secp256k1, but loaded as P-256")
+
+	c := config.EmptyConfig(group)
+	require.NoError(t, c.UnmarshalBinary(data))
+	assert.True(t, c.PublicPoint().Equal(configs[partyIDs[0]].PublicPoint()))
+}
+
+// xorCipher stands in for a KMS, keeping the key encrypting key to itself.
+type xorCipher struct {
+	kek []byte
+}
+
+func (c xorCipher) apply(data []byte) ([]byte, error) {
+	if len(data) != len(c.kek) {
+		return nil, errors.New("wrong length")
+	}
+	out := make([]byte, len(data))
+	for i := range data {
+		out[i] = data[i] ^ c.kek[i]
+	}
+	return out, nil
+}
+
+func TestSealOpen(t *testing.T) {
+	group := curve.Secp256k1{}
+	configs, partyIDs := test.GenerateConfig(group, 3, 1, mrand.New(mrand.NewSource(1)), nil)
+	c := configs[partyIDs[0]]
+
+	kek := make([]byte, 32)
+	_, _ = rand.Read(kek)
+	kms := xorCipher{kek}
+
+	sealed, err := c.Seal(kms.apply)
+	require.NoError(t, err)
+
+	plaintext, err := c.MarshalBinary()
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), string(plaintext), "the config should not appear in the clear")
+
+	opened, err := config.Open(sealed, kms.apply)
+	require.NoError(t, err)
+	openedData, err := opened.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, openedData)
+
+	// a different key encrypting key can't open the config
+	otherKEK := make([]byte, 32)
+	_, _ = rand.Read(otherKEK)
+	_, err = config.Open(sealed, xorCipher{otherKEK}.apply)
+	assert.Error(t, err)
+
+	// errors from the KMS are returned
+	_, err = c.Seal(func([]byte) ([]byte, error) { return nil, errors.New("unavailable") })
+	assert.Error(t, err)
+
+	// tampering with the envelope is detected
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = config.Open(tampered, kms.apply)
+	assert.Error(t, err)
+}
+
+func TestDiffConfigs(t *testing.T) {
+	group := curve.Secp256k1{}
+	configs, partyIDs := test.GenerateConfig(group, 3, 1, mrand.New(mrand.NewSource(1)), nil)
+
+	a := configs[partyIDs[0]].PublicConfig()
+	for _, id := range partyIDs[1:] {
+		assert.Empty(t, config.DiffConfigs(a, configs[id].PublicConfig()), "configs from the same keygen should agree")
+	}
+
+	b := *configs[partyIDs[1]].PublicConfig()
+	b.Threshold++
+	b.Public = make(map[party.ID]*config.Public, len(a.Public))
+	for id, public := range a.Public {
+		b.Public[id] = public
+	}
+	changed := *b.Public[partyIDs[0]]
+	changed.ECDSA = changed.ECDSA.Add(group.NewBasePoint())
+	b.Public[partyIDs[0]] = &changed
+	delete(b.Public, partyIDs[2])
+
+	diffs := config.DiffConfigs(a, &b)
+	assert.Contains(t, diffs, "threshold: 1 != 2")
+	assert.Contains(t, diffs, "party "+string(partyIDs[2])+": only in the first config")
+	assert.Contains(t, diffs, "party "+string(partyIDs[0])+": ECDSA public share differs")
+	assert.Contains(t, diffs, "public key differs")
+	assert.Len(t, diffs, 4)
+}
+
+func TestTransportKeys(t *testing.T) {
+	group := curve.Secp256k1{}
+	configs, partyIDs := test.GenerateConfig(group, 3, 1, mrand.New(mrand.NewSource(1)), nil)
+	c := configs[partyIDs[0]]
+
+	secretKey, publicKeys := c.TransportKeys()
+	assert.Nil(t, secretKey)
+	assert.Empty(t, publicKeys)
+
+	expected := make(map[party.ID][]byte, len(partyIDs))
+	for _, id := range partyIDs {
+		public, secret, err := protocol.GenerateTransportKey(rand.Reader)
+		require.NoError(t, err)
+		c.Public[id].TransportKey = public
+		expected[id] = public
+		if id == c.ID {
+			c.TransportKey = secret
+		}
+	}
+
+	data, err := c.MarshalBinary()
+	require.NoError(t, err)
+	loaded := config.EmptyConfig(group)
+	require.NoError(t, loaded.UnmarshalBinary(data))
+	secretKey, publicKeys = loaded.TransportKeys()
+	assert.Equal(t, c.TransportKey, secretKey)
+	assert.Equal(t, expected, publicKeys)
+
+	c.TransportKey = []byte("too short")
+	data, err = c.MarshalBinary()
+	require.NoError(t, err)
+	assert.Error(t, config.EmptyConfig(group).UnmarshalBinary(data))
+}
+
+func TestDeriveTransportKeys(t *testing.T) {
+	group := curve.Secp256k1{}
+	configs, partyIDs := test.GenerateConfig(group, 3, 1, mrand.New(mrand.NewSource(1)), nil)
+	for _, id := range partyIDs {
+		public, secret, err := protocol.GenerateTransportKey(rand.Reader)
+		require.NoError(t, err)
+		configs[id].TransportKey = secret
+		for _, c := range configs {
+			c.Public[id].TransportKey = public
+		}
+	}
+	c := configs[partyIDs[0]]
+	secretKey, publicKeys := c.TransportKeys()
+
+	derived, err := c.DeriveBIP32(1)
+	require.NoError(t, err)
+	data, err := derived.MarshalBinary()
+	require.NoError(t, err)
+	loaded := config.EmptyConfig(group)
+	require.NoError(t, loaded.UnmarshalBinary(data))
+
+	derivedSecret, derivedPublic := loaded.TransportKeys()
+	assert.Equal(t, secretKey, derivedSecret)
+	assert.Equal(t, publicKeys, derivedPublic)
+	_, err = loaded.BackupShare(2)
+	assert.NoError(t, err, "a derived config can still be backed up")
+}
+
+func TestDeriveBIP32Hardened(t *testing.T) {
+	group := curve.Secp256k1{}
+	configs, partyIDs := test.GenerateConfig(group, 3, 1, mrand.New(mrand.NewSource(1)), nil)
+	c := configs[partyIDs[0]]
+
+	_, err := c.DeriveBIP32(1 << 31)
+	assert.Error(t, err, "hardened derivation needs the parent secret key")
+	_, err = c.DeriveBIP32(1<<31 - 1)
+	assert.NoError(t, err)
+}
+
+func TestBackupShare(t *testing.T) {
+	group := curve.Secp256k1{}
+	configs, partyIDs := test.GenerateConfig(group, 4, 1, mrand.New(mrand.NewSource(1)), nil)
+	for _, id := range partyIDs {
+		public, secret, err := protocol.GenerateTransportKey(rand.Reader)
+		require.NoError(t, err)
+		configs[id].TransportKey = secret
+		for _, c := range configs {
+			c.Public[id].TransportKey = public
+		}
+	}
+	owner, helpers := configs[partyIDs[0]], partyIDs[1:]
+
+	_, err := owner.BackupShare(len(helpers) + 1)
+	assert.Error(t, err, "there aren't enough other parties")
+	_, err = owner.BackupShare(owner.Threshold)
+	assert.Error(t, err, "threshold parties could learn the share, and then sign without the others")
+	backup, err := owner.BackupShare(2)
+	require.NoError(t, err)
+	assert.Len(t, backup.Pieces, len(helpers))
+
+	// the owner lost its config, and generates a new key to receive the pieces
+	recipientKey, secretKey, err := protocol.GenerateTransportKey(rand.Reader)
+	require.NoError(t, err)
+	pieces := make(map[party.ID][]byte, len(helpers))
+	for _, id := range helpers {
+		pieces[id], err = configs[id].ReencryptBackupPiece(backup, recipientKey)
+		require.NoError(t, err)
+	}
+	_, err = owner.ReencryptBackupPiece(backup, recipientKey)
+	assert.Error(t, err, "the owner has no piece of its own backup")
+
+	// any two helpers are enough
+	for i := range helpers {
+		for j := i + 1; j < len(helpers); j++ {
+			subset := map[party.ID][]byte{helpers[i]: pieces[helpers[i]], helpers[j]: pieces[helpers[j]]}
+			share, err := config.RestoreShare(backup, secretKey, subset)
+			require.NoError(t, err)
+			assert.True(t, share.Equal(owner.ECDSA))
+		}
+	}
+
+	_, err = config.RestoreShare(backup, secretKey, map[party.ID][]byte{helpers[0]: pieces[helpers[0]]})
+	assert.Error(t, err, "a single helper shouldn't be enough")
+
+	// the piece of the first helper is reencrypted by the second one, so it won't match
+	forged := map[party.ID][]byte{helpers[0]: pieces[helpers[1]], helpers[1]: pieces[helpers[1]]}
+	_, err = config.RestoreShare(backup, secretKey, forged)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), string(helpers[0]))
+	forged[helpers[2]] = pieces[helpers[2]]
+	share, err := config.RestoreShare(backup, secretKey, forged)
+	require.NoError(t, err, "an invalid piece should be skipped")
+	assert.True(t, share.Equal(owner.ECDSA))
+
+	// only the holder of the recipient key can restore the share
+	_, otherKey, err := protocol.GenerateTransportKey(rand.Reader)
+	require.NoError(t, err)
+	_, err = config.RestoreShare(backup, otherKey, pieces)
+	assert.Error(t, err)
+}
+
+func TestValidateParameterSizes(t *testing.T) {
+	group := curve.Secp256k1{}
+	configs, partyIDs := test.GenerateConfig(group, 3, 1, mrand.New(mrand.NewSource(1)), nil)
+	c := configs[partyIDs[0]]
+	require.NoError(t, c.Validate())
+
+	a, b := c.Public[partyIDs[1]], c.Public[partyIDs[2]]
+	replace := func(j party.ID, public config.Public) error {
+		old := c.Public[j]
+		c.Public[j] = &public
+		defer func() { c.Public[j] = old }()
+		return c.Validate()
+	}
+
+	// Pedersen parameters over a 4096 bit modulus, with a 2048 bit Paillier key
+	n := new(safenum.Nat).Mul(a.Paillier.N().Nat(), b.Paillier.N().Nat(), -1)
+	large := pedersen.New(arith.ModulusFromN(safenum.ModulusFromNat(n)), a.Pedersen.S(), a.Pedersen.T())
+	public := *a
+	public.Pedersen = large
+	assert.Error(t, replace(partyIDs[1], public))
+
+	// a 1024 bit Paillier key
+	public = *a
+	public.Paillier = paillier.NewPublicKey(safenum.ModulusFromNat(configs[partyIDs[1]].Paillier.P()))
+	assert.Error(t, replace(partyIDs[1], public))
+
+	// parameters of the right size, but for another party's modulus
+	public = *a
+	public.Pedersen = b.Pedersen
+	assert.Error(t, replace(partyIDs[1], public))
+
+	require.NoError(t, c.Validate())
+}
+
+func TestLagrangeCache(t *testing.T) {
+	group := curve.Secp256k1{}
+	N, T := 5, 2
+	configs, partyIDs := test.GenerateConfig(group, N, T, mrand.New(mrand.NewSource(1)), nil)
+	c := configs[partyIDs[0]]
+	signers := partyIDs[:T+1]
+
+	first := c.Lagrange(signers)
+	assert.Equal(t, 1, c.LagrangeCacheSize())
+	// modifying the result doesn't modify the cache
+	first[partyIDs[0]].Add(group.NewScalar().SetNat(new(safenum.Nat).SetUint64(1)))
+	for i := 0; i < 3; i++ {
+		second := c.Lagrange(party.IDSlice{signers[2], signers[1], signers[0]})
+		assert.Equal(t, 1, c.LagrangeCacheSize(), "the same signers should use the cache")
+		for j, l := range polynomial.Lagrange(group, signers) {
+			assert.True(t, l.Equal(second[j]))
+		}
+	}
+
+	c.Lagrange(partyIDs[1 : T+2])
+	assert.Equal(t, 2, c.LagrangeCacheSize())
+
+	derived, err := c.Derive(group.NewScalar(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, derived.LagrangeCacheSize())
+}
+
+func BenchmarkLagrangeCache(b *testing.B) {
+	group := curve.Secp256k1{}
+	signers := test.PartyIDs(5)
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			polynomial.Lagrange(group, signers)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		c := &config.Config{Group: group}
+		for i := 0; i < b.N; i++ {
+			c.Lagrange(signers)
+		}
+	})
+}
+
+func TestKeyProof(t *testing.T) {
+	group := curve.Secp256k1{}
+	configs, partyIDs := test.GenerateConfig(group, 4, 2, mrand.New(mrand.NewSource(1)), nil)
+	c := configs[partyIDs[0]]
+
+	require.NoError(t, c.PublicConfig().VerifyKeyProof())
+	proof, err := c.PublicConfig().KeyProof()
+	require.NoError(t, err)
+	assert.Len(t, proof.Commitments, c.Threshold+1)
+
+	shares := make(map[party.ID]curve.Point, len(c.Public))
+	for id, public := range c.Public {
+		shares[id] = public.ECDSA
+	}
+	require.NoError(t, proof.Verify(c.PublicPoint(), shares))
+
+	// a tampered aggregate key is rejected
+	assert.Error(t, proof.Verify(c.PublicPoint().Add(group.NewBasePoint()), shares))
+
+	// so is a tampered share, even if the key is right
+	tampered := make(map[party.ID]curve.Point, len(shares))
+	for id, X := range shares {
+		tampered[id] = X
+	}
+	tampered[partyIDs[1]] = tampered[partyIDs[1]].Add(group.NewBasePoint())
+	assert.Error(t, proof.Verify(c.PublicPoint(), tampered))
+
+	// too few shares don't determine the key
+	delete(shares, partyIDs[2])
+	delete(shares, partyIDs[3])
+	assert.Error(t, proof.Verify(c.PublicPoint(), shares))
+
+	short := &config.KeyProof{Threshold: proof.Threshold, Commitments: proof.Commitments[:proof.Threshold]}
+	assert.Error(t, short.Verify(c.PublicPoint(), tampered))
+
+	// the commitments survive a round trip, and follow a derived config
+	data, err := c.MarshalBinary()
+	require.NoError(t, err)
+	decoded := config.EmptyConfig(group)
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	require.NoError(t, decoded.PublicConfig().VerifyKeyProof())
+
+	derived, err := c.Derive(group.NewScalar().SetNat(new(safenum.Nat).SetUint64(7)), nil)
+	require.NoError(t, err)
+	assert.NoError(t, derived.PublicConfig().VerifyKeyProof())
+
+	// a config without commitments has no proof
+	c.VSSCommitments = nil
+	_, err = c.PublicConfig().KeyProof()
+	assert.Error(t, err)
+}
+
+func TestMarshalDeterministic(t *testing.T) {
+	group := curve.Secp256k1{}
+	configs, partyIDs := test.GenerateConfig(group, 5, 2, mrand.New(mrand.NewSource(1)), nil)
+	c := configs[partyIDs[0]]
+
+	expected, err := c.MarshalBinary()
+	require.NoError(t, err)
+	for i := 0; i < 32; i++ {
+		data, err := c.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, expected, data, "serializing the same config should give the same bytes")
+	}
+
+	// a config decoded from those bytes gives them back
+	decoded := config.EmptyConfig(group)
+	require.NoError(t, decoded.UnmarshalBinary(expected))
+	data, err := decoded.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, expected, data)
+}
@@ -0,0 +1,8 @@
+package config
+
+// LagrangeCacheSize returns the number of signer sets whose Lagrange coefficients are cached by c.
+func (c *Config) LagrangeCacheSize() int {
+	lagrangeMtx.Lock()
+	defer lagrangeMtx.Unlock()
+	return len(c.lagrange)
+}
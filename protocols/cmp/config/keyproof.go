@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+)
+
+// KeyProof shows that a public key is shared among a set of public shares Xⱼ,
+// such that any Threshold + 1 of them determine it, and fewer don't.
+//
+// It consists of the Threshold + 1 coefficients Aₖ of a polynomial F(X) = A₀ + A₁•X + … + Aₜ•Xᵗ,
+// with the public key A₀ and Xⱼ = F(j) for each party j.
+// Since F has degree at most t, this makes the shares a t-of-n sharing of the key,
+// which a verifier can check without interpolating, and without trusting the parties.
+// Its size only depends on the threshold.
+type KeyProof struct {
+	// Threshold is the degree t of F.
+	Threshold int
+	// Commitments are the coefficients [A₀, …, Aₜ] of F.
+	Commitments []curve.Point
+}
+
+// KeyProof returns the proof that the public key is consistent with the public shares of c,
+// built from the commitments recorded by keygen.
+func (c *PublicConfig) KeyProof() (*KeyProof, error) {
+	if len(c.VSSCommitments) == 0 {
+		return nil, errors.New("config: key proof: the config has no VSS commitments")
+	}
+	return &KeyProof{
+		Threshold:   c.Threshold,
+		Commitments: append([]curve.Point(nil), c.VSSCommitments...),
+	}, nil
+}
+
+// Verify checks that publicKey is A₀, and that shares[j] = F(j) for each party j.
+//
+// There must be more than Threshold shares, otherwise they don't determine the key.
+// This costs one evaluation of F in the exponent per share.
+func (p *KeyProof) Verify(publicKey curve.Point, shares map[party.ID]curve.Point) error {
+	if publicKey == nil || publicKey.IsIdentity() {
+		return errors.New("config: key proof: invalid public key")
+	}
+	group := publicKey.Curve()
+	if p.Threshold < 0 || len(p.Commitments) != p.Threshold+1 {
+		return fmt.Errorf("config: key proof: got %d commitments for threshold %d", len(p.Commitments), p.Threshold)
+	}
+	if !ValidThreshold(p.Threshold, len(shares)) {
+		return fmt.Errorf("config: key proof: threshold %d is invalid for %d shares", p.Threshold, len(shares))
+	}
+	for k, A := range p.Commitments {
+		if A == nil || A.Curve().Name() != group.Name() {
+			return fmt.Errorf("config: key proof: invalid commitment %d", k)
+		}
+	}
+	if !p.Commitments[0].Equal(publicKey) {
+		return errors.New("config: key proof: the public key isn't the constant of the polynomial")
+	}
+	for _, j := range party.NewIDSlice(partyIDsOf(shares)) {
+		X := shares[j]
+		if X == nil || X.Curve().Name() != group.Name() {
+			return fmt.Errorf("config: key proof: invalid public share for %s", j)
+		}
+		if !p.evaluate(j.Scalar(group)).Equal(X) {
+			return fmt.Errorf("config: key proof: public share of %s isn't on the polynomial", j)
+		}
+	}
+	return nil
+}
+
+// evaluate returns F(x), using Horner's method.
+func (p *KeyProof) evaluate(x curve.Scalar) curve.Point {
+	result := x.Curve().NewPoint()
+	for k := len(p.Commitments) - 1; k >= 0; k-- {
+		result = x.Act(result).Add(p.Commitments[k])
+	}
+	return result
+}
+
+// VerifyKeyProof checks the KeyProof of c against its own public key and shares.
+func (c *PublicConfig) VerifyKeyProof() error {
+	proof, err := c.KeyProof()
+	if err != nil {
+		return err
+	}
+	shares := make(map[party.ID]curve.Point, len(c.Public))
+	for j, public := range c.Public {
+		shares[j] = public.ECDSA
+	}
+	return proof.Verify(c.publicPoint(), shares)
+}
+
+func partyIDsOf(shares map[party.ID]curve.Point) []party.ID {
+	ids := make([]party.ID, 0, len(shares))
+	for j := range shares {
+		ids = append(ids, j)
+	}
+	return ids
+}
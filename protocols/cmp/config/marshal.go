@@ -23,13 +23,23 @@ func EmptyConfig(group curve.Curve) *Config {
 	}
 }
 
+// transportKeySize is the size of an X25519 key, as in protocol.TransportKeySize.
+const transportKeySize = 32
+
 type configMarshal struct {
+	// Curve is the name of the group the config was created for.
+	Curve          string
 	ID             party.ID
 	Threshold      int
 	ECDSA, ElGamal curve.Scalar
 	P, Q           *safenum.Nat
 	RID, ChainKey  types.RID
 	Public         []cbor.RawMessage
+	Origin         Origin
+	TransportKey   []byte   `cbor:",omitempty"`
+	VSSCommitments [][]byte `cbor:",omitempty"`
+	// Renounced is set for a config whose secrets were erased by Renounce, in which case P and Q are omitted.
+	Renounced bool `cbor:",omitempty"`
 }
 
 type publicMarshal struct {
@@ -37,8 +47,10 @@ type publicMarshal struct {
 	ECDSA, ElGamal curve.Point
 	N              *safenum.Modulus
 	S, T           *safenum.Nat
+	TransportKey   []byte `cbor:",omitempty"`
 }
 
+// MarshalBinary encodes the config, always with the same bytes.
 func (c *Config) MarshalBinary() ([]byte, error) {
 	ps := make([]cbor.RawMessage, 0, len(c.Public))
 	for _, id := range c.PartyIDs() {
@@ -50,6 +62,8 @@ func (c *Config) MarshalBinary() ([]byte, error) {
 			N:       p.Pedersen.N(),
 			S:       p.Pedersen.S(),
 			T:       p.Pedersen.T(),
+
+			TransportKey: p.TransportKey,
 		}
 		data, err := cbor.Marshal(pm)
 		if err != nil {
@@ -57,23 +71,50 @@ func (c *Config) MarshalBinary() ([]byte, error) {
 		}
 		ps = append(ps, data)
 	}
-	return cbor.Marshal(&configMarshal{
+	commitments := make([][]byte, 0, len(c.VSSCommitments))
+	for _, A := range c.VSSCommitments {
+		data, err := A.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		commitments = append(commitments, data)
+	}
+	cm := &configMarshal{
+		Curve:     c.Group.Name(),
 		ID:        c.ID,
 		Threshold: c.Threshold,
 		ECDSA:     c.ECDSA,
 		ElGamal:   c.ElGamal,
-		P:         c.Paillier.P(),
-		Q:         c.Paillier.Q(),
 		RID:       c.RID,
 		ChainKey:  c.ChainKey,
 		Public:    ps,
-	})
+		Origin:    c.Origin,
+
+		TransportKey:   c.TransportKey,
+		VSSCommitments: commitments,
+		Renounced:      c.renounced,
+	}
+	if !c.renounced {
+		cm.P, cm.Q = c.Paillier.P(), c.Paillier.Q()
+	}
+	return cbor.Marshal(cm)
 }
 
 func (c *Config) UnmarshalBinary(data []byte) error {
 	if c.Group == nil {
 		return errors.New("config must be initialized using EmptyConfig")
 	}
+
+	// check the curve before decoding anything, since scalars and points
+	// from another group would fail in less obvious ways
+	var header struct{ Curve string }
+	if err := cbor.Unmarshal(data, &header); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if header.Curve != "" && header.Curve != c.Group.Name() {
+		return fmt.Errorf("config: created for curve %s, but loaded as %s", header.Curve, c.Group.Name())
+	}
+
 	cm := &configMarshal{
 		ECDSA:   c.Group.NewScalar(),
 		ElGamal: c.Group.NewScalar(),
@@ -82,19 +123,30 @@ func (c *Config) UnmarshalBinary(data []byte) error {
 		return fmt.Errorf("config: %w", err)
 	}
 
-	// check ECDSA, ElGamal
-	if cm.ECDSA.IsZero() || cm.ElGamal.IsZero() {
+	if !cm.Origin.Valid() {
+		return fmt.Errorf("config: invalid origin %s", cm.Origin)
+	}
+
+	// check ECDSA, ElGamal, unless they were erased by Renounce
+	if !cm.Renounced && (cm.ECDSA.IsZero() || cm.ElGamal.IsZero()) {
 		return errors.New("config: ECDSA or ElGamal secret key is zero")
 	}
 
-	// get Paillier secret key
-	if err := paillier.ValidatePrime(cm.P); err != nil {
-		return fmt.Errorf("config: prime P: %w", err)
+	if len(cm.TransportKey) != 0 && len(cm.TransportKey) != transportKeySize {
+		return errors.New("config: invalid transport key")
 	}
-	if err := paillier.ValidatePrime(cm.Q); err != nil {
-		return fmt.Errorf("config: prime Q: %w", err)
+
+	// get Paillier secret key, of which only the public key is left once renounced
+	var paillierSecret *paillier.SecretKey
+	if !cm.Renounced {
+		if err := paillier.ValidatePrime(cm.P); err != nil {
+			return fmt.Errorf("config: prime P: %w", err)
+		}
+		if err := paillier.ValidatePrime(cm.Q); err != nil {
+			return fmt.Errorf("config: prime Q: %w", err)
+		}
+		paillierSecret = paillier.NewSecretKeyFromPrimes(cm.P, cm.Q)
 	}
-	paillierSecret := paillier.NewSecretKeyFromPrimes(cm.P, cm.Q)
 
 	// handle public parameters
 	ps := make(map[party.ID]*Public, len(cm.Public))
@@ -109,14 +161,19 @@ func (c *Config) UnmarshalBinary(data []byte) error {
 		if _, ok := ps[p.ID]; ok {
 			return fmt.Errorf("config: party %s: duplicate entry", p.ID)
 		}
+		if len(p.TransportKey) != 0 && len(p.TransportKey) != transportKeySize {
+			return fmt.Errorf("config: party %s: invalid transport key", p.ID)
+		}
 
 		// handle our own key separately
-		if p.ID == cm.ID {
+		if p.ID == cm.ID && !cm.Renounced {
 			ps[p.ID] = &Public{
 				ECDSA:    cm.ECDSA.ActOnBase(),
 				ElGamal:  cm.ElGamal.ActOnBase(),
 				Paillier: paillierSecret.PublicKey,
 				Pedersen: pedersen.New(paillierSecret.Modulus(), p.S, p.T),
+
+				TransportKey: p.TransportKey,
 			}
 			continue
 		}
@@ -137,6 +194,8 @@ func (c *Config) UnmarshalBinary(data []byte) error {
 			ElGamal:  p.ElGamal,
 			Paillier: paillierPublic,
 			Pedersen: pedersen.New(paillierPublic.Modulus(), p.S, p.T),
+
+			TransportKey: p.TransportKey,
 		}
 	}
 
@@ -150,6 +209,24 @@ func (c *Config) UnmarshalBinary(data []byte) error {
 	if _, ok := ps[cm.ID]; !ok {
 		return errors.New("config: no public data for this party")
 	}
+	if cm.Renounced {
+		paillierSecret = &paillier.SecretKey{PublicKey: ps[cm.ID].Paillier}
+	}
+
+	var commitments []curve.Point
+	if len(cm.VSSCommitments) > 0 {
+		if len(cm.VSSCommitments) != cm.Threshold+1 {
+			return fmt.Errorf("config: got %d VSS commitments for threshold %d", len(cm.VSSCommitments), cm.Threshold)
+		}
+		commitments = make([]curve.Point, 0, len(cm.VSSCommitments))
+		for i, data := range cm.VSSCommitments {
+			A := c.Group.NewPoint()
+			if err := A.UnmarshalBinary(data); err != nil {
+				return fmt.Errorf("config: VSS commitment %d: %w", i, err)
+			}
+			commitments = append(commitments, A)
+		}
+	}
 
 	*c = Config{
 		Group:     c.Group,
@@ -161,6 +238,11 @@ func (c *Config) UnmarshalBinary(data []byte) error {
 		RID:       cm.RID,
 		ChainKey:  cm.ChainKey,
 		Public:    ps,
+		Origin:    cm.Origin,
+
+		TransportKey:   cm.TransportKey,
+		VSSCommitments: commitments,
+		renounced:      cm.Renounced,
 	}
 	return nil
 }
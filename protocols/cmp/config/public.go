@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/internal/types"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/pedersen"
+)
+
+// PublicConfig is the part of a Config which all parties should agree on.
+//
+// It contains no secrets, and so can be exchanged between parties to check for drift.
+type PublicConfig struct {
+	// Group is the Elliptic Curve Group associated with this config.
+	Group curve.Curve
+	// Threshold is the integer t, as in Config.
+	Threshold int
+	// RID is the random identifier generated for this config.
+	RID types.RID
+	// ChainKey is the chaining key value associated with this public key.
+	ChainKey types.RID
+	// Public maps party.ID to the public information of each party.
+	Public map[party.ID]*Public
+	// VSSCommitments are the coefficients of the polynomial of the public shares, as in Config.
+	VSSCommitments []curve.Point
+}
+
+// PublicConfig returns the public part of this Config.
+//
+// The PublicConfig shares the public data of c, and should not be modified.
+func (c *Config) PublicConfig() *PublicConfig {
+	return &PublicConfig{
+		Group:     c.Group,
+		Threshold: c.Threshold,
+		RID:       c.RID,
+		ChainKey:  c.ChainKey,
+		Public:    c.Public,
+
+		VSSCommitments: c.VSSCommitments,
+	}
+}
+
+// PartyIDs returns a sorted slice of party IDs.
+func (c *PublicConfig) PartyIDs() party.IDSlice {
+	ids := make([]party.ID, 0, len(c.Public))
+	for j := range c.Public {
+		ids = append(ids, j)
+	}
+	return party.NewIDSlice(ids)
+}
+
+// DiffConfigs returns a description of each difference between a and b,
+// or nothing if they are consistent with each other.
+//
+// This can be used to find a party whose config is out of date, for example after missing a refresh.
+func DiffConfigs(a, b *PublicConfig) []string {
+	var diffs []string
+	add := func(format string, args ...interface{}) {
+		diffs = append(diffs, fmt.Sprintf(format, args...))
+	}
+
+	if a.Group.Name() != b.Group.Name() {
+		add("group: %s != %s", a.Group.Name(), b.Group.Name())
+		// nothing else can be compared meaningfully
+		return diffs
+	}
+	if a.Threshold != b.Threshold {
+		add("threshold: %d != %d", a.Threshold, b.Threshold)
+	}
+	if !bytes.Equal(a.RID, b.RID) {
+		add("RID: %x != %x", []byte(a.RID), []byte(b.RID))
+	}
+	if !bytes.Equal(a.ChainKey, b.ChainKey) {
+		add("chain key: %x != %x", []byte(a.ChainKey), []byte(b.ChainKey))
+	}
+
+	for _, id := range a.PartyIDs() {
+		if _, ok := b.Public[id]; !ok {
+			add("party %s: only in the first config", id)
+		}
+	}
+	for _, id := range b.PartyIDs() {
+		if _, ok := a.Public[id]; !ok {
+			add("party %s: only in the second config", id)
+		}
+	}
+
+	for _, id := range a.PartyIDs() {
+		pa, pb := a.Public[id], b.Public[id]
+		if pb == nil {
+			continue
+		}
+		if !pa.ECDSA.Equal(pb.ECDSA) {
+			add("party %s: ECDSA public share differs", id)
+		}
+		if !pa.ElGamal.Equal(pb.ElGamal) {
+			add("party %s: ElGamal public key differs", id)
+		}
+		if !pa.Paillier.Equal(pb.Paillier) {
+			add("party %s: Paillier public key differs", id)
+		}
+		if !equalPedersen(pa.Pedersen, pb.Pedersen) {
+			add("party %s: Pedersen parameters differ", id)
+		}
+		if !bytes.Equal(pa.TransportKey, pb.TransportKey) {
+			add("party %s: transport key differs", id)
+		}
+	}
+
+	// configs which predate the commitments don't have them
+	if len(a.VSSCommitments) > 0 && len(b.VSSCommitments) > 0 && !equalPoints(a.VSSCommitments, b.VSSCommitments) {
+		add("VSS commitments differ")
+	}
+
+	// shares may differ after a refresh that only one side has seen,
+	// in which case the public key itself is still the same
+	if !a.publicPoint().Equal(b.publicPoint()) {
+		add("public key differs")
+	}
+	return diffs
+}
+
+// publicPoint returns the group's public ECC point.
+func (c *PublicConfig) publicPoint() curve.Point {
+	return c.config().PublicPoint()
+}
+
+// config returns a Config with only the public parts of c,
+// which is written to a hash.Hash in the same way as the full Config.
+func (c *PublicConfig) config() *Config {
+	return &Config{
+		Group:     c.Group,
+		Threshold: c.Threshold,
+		RID:       c.RID,
+		ChainKey:  c.ChainKey,
+		Public:    c.Public,
+
+		VSSCommitments: c.VSSCommitments,
+	}
+}
+
+func equalPedersen(a, b *pedersen.Parameters) bool {
+	var bufA, bufB bytes.Buffer
+	if _, err := a.WriteTo(&bufA); err != nil {
+		return false
+	}
+	if _, err := b.WriteTo(&bufB); err != nil {
+		return false
+	}
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
+
+func equalPoints(a, b []curve.Point) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/pkg/math/polynomial"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	zkmod "github.com/koteld/multi-party-sig/pkg/zk/mod"
+	zkprm "github.com/koteld/multi-party-sig/pkg/zk/prm"
+	zksch "github.com/koteld/multi-party-sig/pkg/zk/sch"
+)
+
+// RefreshProof contains the proofs a party sends during a refresh, about its new Paillier key, Pedersen parameters and ECDSA share.
+type RefreshProof struct {
+	// Mod proves that the new Paillier modulus N is a product of two Blum primes.
+	Mod *zkmod.Proof
+	// Prm proves that the new Pedersen parameters s, t are well formed for N.
+	Prm *zkprm.Proof
+	// SchnorrCommitment and SchnorrResponse prove knowledge of the new ECDSA share.
+	SchnorrCommitment *zksch.Commitment
+	SchnorrResponse   *zksch.Response
+}
+
+type refreshTranscript struct {
+	ProtocolID string
+	SessionID  []byte
+	// Proofs are sorted by party ID, so that all parties produce the same transcript.
+	Proofs []*refreshProofMarshal
+}
+
+type refreshProofMarshal struct {
+	ID                party.ID
+	Mod               *zkmod.Proof
+	Prm               *zkprm.Proof
+	SchnorrCommitment []byte
+	SchnorrResponse   []byte
+}
+
+// MarshalRefreshTranscript encodes the proofs sent by every party during a refresh session,
+// identified by its protocol and session IDs, so that VerifyRefreshTransition can check them later.
+func MarshalRefreshTranscript(protocolID string, sessionID []byte, proofs map[party.ID]*RefreshProof) ([]byte, error) {
+	t := refreshTranscript{
+		ProtocolID: protocolID,
+		SessionID:  sessionID,
+		Proofs:     make([]*refreshProofMarshal, 0, len(proofs)),
+	}
+	ids := make([]party.ID, 0, len(proofs))
+	for id := range proofs {
+		ids = append(ids, id)
+	}
+	for _, id := range party.NewIDSlice(ids) {
+		proof := proofs[id]
+		commitment, err := proof.SchnorrCommitment.C.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		response, err := proof.SchnorrResponse.Z.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		t.Proofs = append(t.Proofs, &refreshProofMarshal{
+			ID:                id,
+			Mod:               proof.Mod,
+			Prm:               proof.Prm,
+			SchnorrCommitment: commitment,
+			SchnorrResponse:   response,
+		})
+	}
+	return cbor.Marshal(&t)
+}
+
+// VerifyRefreshTransition checks that newConfig is the result of refreshing oldConfig,
+// using only their public parts, and the transcript of the refresh produced by MarshalRefreshTranscript.
+//
+// The checks are that:
+//   - the group, threshold, parties, chain key and transport keys are the same,
+//   - the public key is the same,
+//   - the new ECDSA public shares are the evaluations of a polynomial of degree t,
+//   - every party has a new RID, ECDSA share, ElGamal key, Paillier key, and Pedersen parameters,
+//   - every party proved that its Paillier key and Pedersen parameters are valid,
+//     and that it knows the secret of its new ECDSA share, in the session of the transcript.
+func VerifyRefreshTransition(oldConfig, newConfig *PublicConfig, transcript []byte) error {
+	if oldConfig.Group.Name() != newConfig.Group.Name() {
+		return fmt.Errorf("config: refresh changed the group from %s to %s", oldConfig.Group.Name(), newConfig.Group.Name())
+	}
+	if oldConfig.Threshold != newConfig.Threshold {
+		return fmt.Errorf("config: refresh changed the threshold from %d to %d", oldConfig.Threshold, newConfig.Threshold)
+	}
+	partyIDs := oldConfig.PartyIDs()
+	if len(partyIDs) != len(newConfig.Public) || !newConfig.PartyIDs().Contains(partyIDs...) {
+		return errors.New("config: refresh changed the parties")
+	}
+	if !bytes.Equal(oldConfig.ChainKey, newConfig.ChainKey) {
+		return errors.New("config: refresh changed the chain key")
+	}
+	if bytes.Equal(oldConfig.RID, newConfig.RID) {
+		return errors.New("config: refresh kept the same RID")
+	}
+	if !oldConfig.publicPoint().Equal(newConfig.publicPoint()) {
+		return errors.New("config: refresh changed the public key")
+	}
+	if err := newConfig.checkShares(); err != nil {
+		return err
+	}
+
+	for _, id := range partyIDs {
+		old, updated := oldConfig.Public[id], newConfig.Public[id]
+		if updated.ECDSA.Equal(old.ECDSA) {
+			return fmt.Errorf("config: party %s: refresh kept the same ECDSA share", id)
+		}
+		if updated.ElGamal.Equal(old.ElGamal) {
+			return fmt.Errorf("config: party %s: refresh kept the same ElGamal key", id)
+		}
+		if updated.Paillier.Equal(old.Paillier) {
+			return fmt.Errorf("config: party %s: refresh kept the same Paillier key", id)
+		}
+		if updated.Pedersen.N().Nat().Eq(updated.Paillier.N().Nat()) != 1 {
+			return fmt.Errorf("config: party %s: Pedersen parameters don't match the Paillier key", id)
+		}
+		if equalPedersen(updated.Pedersen, old.Pedersen) {
+			return fmt.Errorf("config: party %s: refresh kept the same Pedersen parameters", id)
+		}
+		if !bytes.Equal(updated.TransportKey, old.TransportKey) {
+			return fmt.Errorf("config: party %s: refresh changed the transport key", id)
+		}
+	}
+	return verifyRefreshProofs(oldConfig, newConfig, transcript)
+}
+
+// verifyRefreshProofs checks the proofs of transcript against the hash state of the refresh session,
+// which is recomputed in the same way as the rounds of the refresh:
+// the session is started from oldConfig, then the new RID is added before the Paillier and Pedersen proofs,
+// and the new config before the Schnorr proofs.
+func verifyRefreshProofs(oldConfig, newConfig *PublicConfig, transcript []byte) error {
+	if len(transcript) == 0 {
+		return errors.New("config: missing refresh transcript")
+	}
+	var t refreshTranscript
+	if err := cbor.Unmarshal(transcript, &t); err != nil {
+		return fmt.Errorf("config: refresh transcript: %w", err)
+	}
+	partyIDs := newConfig.PartyIDs()
+	if len(t.Proofs) != len(partyIDs) {
+		return errors.New("config: refresh transcript doesn't have the proofs of all parties")
+	}
+
+	helper, err := round.NewSession(round.Info{
+		ProtocolID: t.ProtocolID,
+		SelfID:     partyIDs[0],
+		PartyIDs:   partyIDs,
+		Threshold:  oldConfig.Threshold,
+		Group:      oldConfig.Group,
+	}, t.SessionID, nil, oldConfig.config())
+	if err != nil {
+		return fmt.Errorf("config: refresh transcript: %w", err)
+	}
+
+	group := newConfig.Group
+	commitments := make(map[party.ID]*zksch.Commitment, len(partyIDs))
+	responses := make(map[party.ID]*zksch.Response, len(partyIDs))
+	helper.UpdateHashState(newConfig.RID)
+	for i, id := range partyIDs {
+		proof := t.Proofs[i]
+		if proof == nil || proof.ID != id {
+			return fmt.Errorf("config: party %s: missing refresh proofs", id)
+		}
+		public := newConfig.Public[id]
+		if !proof.Mod.Verify(zkmod.Public{N: public.Paillier.N()}, helper.HashForID(id), nil) {
+			return fmt.Errorf("config: party %s: failed to validate mod proof", id)
+		}
+		if !proof.Prm.Verify(zkprm.Public{N: public.Pedersen.N(), S: public.Pedersen.S(), T: public.Pedersen.T()}, helper.HashForID(id), nil) {
+			return fmt.Errorf("config: party %s: failed to validate prm proof", id)
+		}
+
+		commitments[id] = zksch.EmptyCommitment(group)
+		if err = commitments[id].C.UnmarshalBinary(proof.SchnorrCommitment); err != nil {
+			return fmt.Errorf("config: party %s: Schnorr commitment: %w", id, err)
+		}
+		responses[id] = zksch.EmptyResponse(group)
+		if err = responses[id].Z.UnmarshalBinary(proof.SchnorrResponse); err != nil {
+			return fmt.Errorf("config: party %s: Schnorr response: %w", id, err)
+		}
+		if !commitments[id].IsValid() || !responses[id].IsValid() {
+			return fmt.Errorf("config: party %s: invalid Schnorr proof", id)
+		}
+	}
+
+	helper.UpdateHashState(newConfig.config())
+	for _, id := range partyIDs {
+		if !responses[id].Verify(helper.HashForID(id), newConfig.Public[id].ECDSA, commitments[id], nil) {
+			return fmt.Errorf("config: party %s: failed to validate Schnorr proof for the new share", id)
+		}
+	}
+	return nil
+}
+
+// checkShares verifies that the ECDSA public shares Xⱼ are the evaluations of a single polynomial of degree t,
+// whose constant is the public key.
+//
+// The first t+1 shares define this polynomial.
+// Replacing one of them by another share Xⱼ must then interpolate to the same public key,
+// since both polynomials would agree on t points and on 0.
+func (c *PublicConfig) checkShares() error {
+	partyIDs := c.PartyIDs()
+	if c.Threshold+1 > len(partyIDs) {
+		return errors.New("config: not enough parties for the threshold")
+	}
+	base := partyIDs[:c.Threshold+1]
+	publicKey := c.publicPoint()
+	for _, j := range partyIDs[c.Threshold+1:] {
+		// replace the last party of base by j
+		domain := append(base[:c.Threshold:c.Threshold], j)
+		lagrange := polynomial.Lagrange(c.Group, domain)
+		sum := c.Group.NewPoint()
+		for _, l := range domain {
+			sum = sum.Add(lagrange[l].Act(c.Public[l].ECDSA))
+		}
+		if !sum.Equal(publicKey) {
+			return fmt.Errorf("config: party %s: ECDSA share isn't consistent with the threshold", j)
+		}
+	}
+	return nil
+}
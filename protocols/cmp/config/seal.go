@@ -0,0 +1,150 @@
+package config
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// sealVersion is the version of the envelope produced by Seal.
+const sealVersion = 1
+
+// sealedConfig is the envelope produced by Seal.
+//
+// The config is encrypted under a fresh data key with XChaCha20-Poly1305,
+// and only the data key is passed to the caller's encrypt function.
+type sealedConfig struct {
+	Version uint8
+	// KeyID identifies the config, and is authenticated as associated data.
+	KeyID      []byte
+	WrappedKey []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// keyIDMarshal is the content of a KeyID.
+type keyIDMarshal struct {
+	Curve     string
+	ID        party.ID
+	PublicKey curve.Point
+}
+
+// keyID returns an identifier for this share of the key, binding the curve, our ID and the public key.
+func (c *Config) keyID() ([]byte, error) {
+	return cbor.Marshal(&keyIDMarshal{
+		Curve:     c.Group.Name(),
+		ID:        c.ID,
+		PublicKey: c.PublicPoint(),
+	})
+}
+
+// Seal serializes the config and encrypts it, so that it can be stored at rest.
+//
+// The config is encrypted with a freshly generated data key, which is in turn encrypted
+// with encrypt. This lets the caller use a KMS, or any other key management scheme,
+// to hold the key encrypting key, without sending it the config itself.
+// The curve, ID and public key are authenticated along with the config.
+func (c *Config) Seal(encrypt func(plaintext []byte) ([]byte, error)) ([]byte, error) {
+	plaintext, err := c.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("config: seal: %w", err)
+	}
+	keyID, err := c.keyID()
+	if err != nil {
+		return nil, fmt.Errorf("config: seal: %w", err)
+	}
+
+	dataKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err = rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("config: seal: %w", err)
+	}
+	aead, err := chacha20poly1305.NewX(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("config: seal: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("config: seal: %w", err)
+	}
+
+	wrappedKey, err := encrypt(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("config: seal: failed to encrypt data key: %w", err)
+	}
+
+	return cbor.Marshal(&sealedConfig{
+		Version:    sealVersion,
+		KeyID:      keyID,
+		WrappedKey: wrappedKey,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, keyID),
+	})
+}
+
+// Open decrypts a config produced by Seal, where decrypt reverses the encrypt function given to Seal.
+func Open(ciphertext []byte, decrypt func([]byte) ([]byte, error)) (*Config, error) {
+	var sealed sealedConfig
+	if err := cbor.Unmarshal(ciphertext, &sealed); err != nil {
+		return nil, fmt.Errorf("config: open: %w", err)
+	}
+	if sealed.Version != sealVersion {
+		return nil, fmt.Errorf("config: open: unsupported version %d", sealed.Version)
+	}
+
+	// the curve is needed to decode the rest of the KeyID
+	var header struct{ Curve string }
+	if err := cbor.Unmarshal(sealed.KeyID, &header); err != nil {
+		return nil, fmt.Errorf("config: open: key ID: %w", err)
+	}
+	group, err := groupByName(header.Curve)
+	if err != nil {
+		return nil, fmt.Errorf("config: open: %w", err)
+	}
+
+	dataKey, err := decrypt(sealed.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("config: open: failed to decrypt data key: %w", err)
+	}
+	aead, err := chacha20poly1305.NewX(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("config: open: %w", err)
+	}
+	if len(sealed.Nonce) != aead.NonceSize() {
+		return nil, errors.New("config: open: invalid nonce")
+	}
+	plaintext, err := aead.Open(nil, sealed.Nonce, sealed.Ciphertext, sealed.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("config: open: %w", err)
+	}
+
+	c := EmptyConfig(group)
+	if err = c.UnmarshalBinary(plaintext); err != nil {
+		return nil, fmt.Errorf("config: open: %w", err)
+	}
+
+	// the KeyID was authenticated, but must also match the config it was sealed with
+	keyID, err := c.keyID()
+	if err != nil {
+		return nil, fmt.Errorf("config: open: %w", err)
+	}
+	if !bytes.Equal(keyID, sealed.KeyID) {
+		return nil, errors.New("config: open: key ID doesn't match the config")
+	}
+	return c, nil
+}
+
+// groupByName returns the curve with the given name, as returned by curve.Curve.Name.
+func groupByName(name string) (curve.Curve, error) {
+	switch name {
+	case curve.Secp256k1{}.Name():
+		return curve.Secp256k1{}, nil
+	default:
+		return nil, fmt.Errorf("unknown curve %q", name)
+	}
+}
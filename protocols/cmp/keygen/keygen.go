@@ -1,13 +1,10 @@
 package keygen
 
 import (
-	"crypto/rand"
 	"fmt"
 
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
-	"github.com/koteld/multi-party-sig/pkg/math/polynomial"
-	"github.com/koteld/multi-party-sig/pkg/math/sample"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/pool"
 	"github.com/koteld/multi-party-sig/pkg/protocol"
@@ -18,6 +15,12 @@ const Rounds round.Number = 5
 
 func Start(info round.Info, pl *pool.Pool, c *config.Config) protocol.StartFunc {
 	return func(sessionID []byte) (_ round.Session, err error) {
+		// a refresh would start from the erased share
+		if c != nil {
+			if err = c.CheckNotRenounced(); err != nil {
+				return nil, fmt.Errorf("keygen: %w", err)
+			}
+		}
 		var helper *round.Helper
 		if c == nil {
 			helper, err = round.NewSession(info, sessionID, pl)
@@ -28,28 +31,27 @@ func Start(info round.Info, pl *pool.Pool, c *config.Config) protocol.StartFunc
 			return nil, fmt.Errorf("keygen: %w", err)
 		}
 
-		group := helper.Group()
-
 		if c != nil {
 			PublicSharesECDSA := make(map[party.ID]curve.Point, len(c.Public))
 			for id, public := range c.Public {
 				PublicSharesECDSA[id] = public.ECDSA
 			}
+			transportKey, publicTransportKeys := c.TransportKeys()
 			return &round1{
-				Helper:                    helper,
-				PreviousSecretECDSA:       c.ECDSA,
-				PreviousPublicSharesECDSA: PublicSharesECDSA,
-				PreviousChainKey:          c.ChainKey,
-				VSSSecret:                 polynomial.NewPolynomial(group, helper.Threshold(), group.NewScalar()), // fᵢ(X) deg(fᵢ) = t, fᵢ(0) = 0
+				Helper:                      helper,
+				PreviousSecretECDSA:         c.ECDSA,
+				PreviousPublicSharesECDSA:   PublicSharesECDSA,
+				PreviousChainKey:            c.ChainKey,
+				PreviousOrigin:              c.Origin,
+				PreviousTransportKey:        transportKey,
+				PreviousPublicTransportKeys: publicTransportKeys,
+				PreviousVSSCommitments:      c.VSSCommitments,
+				SessionID:                   sessionID,
 			}, nil
 		}
 
-		// sample fᵢ(X) deg(fᵢ) = t, fᵢ(0) = secretᵢ
-		VSSConstant := sample.Scalar(rand.Reader, group)
-		VSSSecret := polynomial.NewPolynomial(group, helper.Threshold(), VSSConstant)
 		return &round1{
-			Helper:    helper,
-			VSSSecret: VSSSecret,
+			Helper: helper,
 		}, nil
 
 	}
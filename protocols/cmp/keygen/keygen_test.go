@@ -8,6 +8,7 @@ import (
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/internal/test"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/pool"
 	"github.com/koteld/multi-party-sig/protocols/cmp/config"
 	"github.com/stretchr/testify/assert"
@@ -16,7 +17,7 @@ import (
 
 var group = curve.Secp256k1{}
 
-func checkOutput(t *testing.T, rounds []round.Session) {
+func checkOutput(t *testing.T, rounds []round.Session, origin config.Origin) {
 	N := len(rounds)
 	newConfigs := make([]*config.Config, 0, N)
 	for _, r := range rounds {
@@ -38,6 +39,8 @@ func checkOutput(t *testing.T, rounds []round.Session) {
 		assert.True(t, pk.Equal(c.PublicPoint()), "RID is different")
 		assert.Equal(t, firstConfig.RID, c.RID, "RID is different")
 		assert.EqualValues(t, firstConfig.ChainKey, c.ChainKey, "ChainKey is different")
+		assert.Equal(t, origin, c.Provenance(), "wrong provenance")
+		assert.NoError(t, c.PublicConfig().VerifyKeyProof(), "invalid key proof")
 		for id, p := range firstConfig.Public {
 			assert.True(t, p.ECDSA.Equal(c.Public[id].ECDSA), "ecdsa not the same", id)
 			assert.True(t, p.ElGamal.Equal(c.Public[id].ElGamal), "elgamal not the same", id)
@@ -83,7 +86,7 @@ func TestKeygen(t *testing.T) {
 			break
 		}
 	}
-	checkOutput(t, rounds)
+	checkOutput(t, rounds, config.OriginDKG)
 }
 
 func TestRefresh(t *testing.T) {
@@ -96,6 +99,7 @@ func TestRefresh(t *testing.T) {
 
 	rounds := make([]round.Session, 0, N)
 	for _, c := range configs {
+		require.Equal(t, config.OriginDealer, c.Provenance())
 		info := round.Info{
 			ProtocolID:       "cmp/refresh-test",
 			FinalRoundNumber: Rounds,
@@ -117,5 +121,51 @@ func TestRefresh(t *testing.T) {
 			break
 		}
 	}
-	checkOutput(t, rounds)
+	// refreshing a dealer generated key doesn't hide where it came from
+	checkOutput(t, rounds, config.OriginDealer)
+}
+
+func TestRefreshTransportKeys(t *testing.T) {
+	N := 2
+	configs, _ := test.GenerateConfig(group, N, N-1, mrand.New(mrand.NewSource(1)), nil)
+	// the transport keys don't change with a refresh
+	transportKeys := make(map[party.ID][]byte, N)
+	for id := range configs {
+		transportKeys[id] = make([]byte, 32)
+		transportKeys[id][0] = byte(len(transportKeys))
+	}
+	for id, c := range configs {
+		c.TransportKey = transportKeys[id]
+		for j := range c.Public {
+			c.Public[j].TransportKey = transportKeys[j]
+		}
+	}
+
+	rounds := make([]round.Session, 0, N)
+	for _, c := range configs {
+		info := round.Info{
+			ProtocolID:       "cmp/refresh-test",
+			FinalRoundNumber: Rounds,
+			SelfID:           c.ID,
+			PartyIDs:         c.PartyIDs(),
+			Threshold:        N - 1,
+			Group:            group,
+		}
+		r, err := Start(info, nil, c)(nil)
+		require.NoError(t, err, "round creation should not result in an error")
+		rounds = append(rounds, r)
+	}
+	for {
+		err, done := test.Rounds(rounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if done {
+			break
+		}
+	}
+	for _, r := range rounds {
+		c := r.(*round.Output).Result.(*config.Config)
+		secretKey, publicKeys := c.TransportKeys()
+		assert.Equal(t, transportKeys[c.ID], secretKey)
+		assert.Equal(t, transportKeys, publicKeys)
+	}
 }
@@ -1,7 +1,6 @@
 package keygen
 
 import (
-	"crypto/rand"
 	"errors"
 
 	"github.com/cronokirby/safenum"
@@ -14,6 +13,7 @@ import (
 	"github.com/koteld/multi-party-sig/pkg/paillier"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	zksch "github.com/koteld/multi-party-sig/pkg/zk/sch"
+	"github.com/koteld/multi-party-sig/protocols/cmp/config"
 )
 
 var _ round.Round = (*round1)(nil)
@@ -37,7 +37,27 @@ type round1 struct {
 	// In that case, we will simply use the previous chain key at the very end.
 	PreviousChainKey types.RID
 
-	// VSSSecret = fᵢ(X)
+	// PreviousOrigin is the Origin of the config being refreshed.
+	// Keygen:  unused, the new config comes from a DKG
+	// Refresh: the origin is preserved
+	PreviousOrigin config.Origin
+
+	// PreviousTransportKey and PreviousPublicTransportKeys are the static X25519 keys of the config being refreshed,
+	// which are carried over to the new config.
+	PreviousTransportKey        []byte
+	PreviousPublicTransportKeys map[party.ID][]byte
+
+	// PreviousVSSCommitments are the VSS commitments of the config being refreshed, which the new ones are added to.
+	// Keygen:  nil
+	// Refresh: the coefficients of F'(X), or nil if the config doesn't have them
+	PreviousVSSCommitments []curve.Point
+
+	// SessionID is the ID this session was started with.
+	// Keygen:  unused
+	// Refresh: recorded in the transcript of the new config, see config.VerifyRefreshTransition
+	SessionID []byte
+
+	// VSSSecret = fᵢ(X), sampled in Finalize
 	// Polynomial from which the new secret shares are computed.
 	// Keygen:  fᵢ(0) = xⁱ
 	// Refresh: fᵢ(0) = 0
@@ -52,6 +72,7 @@ func (r *round1) StoreMessage(round.Message) error { return nil }
 
 // Finalize implements round.Round
 //
+// - sample fᵢ(X)
 // - sample Paillier (pᵢ, qᵢ)
 // - sample Pedersen Nᵢ, sᵢ, tᵢ
 // - sample aᵢ  <- 𝔽
@@ -61,12 +82,22 @@ func (r *round1) StoreMessage(round.Message) error { return nil }
 // - sample cᵢ <- {0,1}ᵏ
 // - commit to message.
 func (r *round1) Finalize(out chan<- *round.Message) (round.Session, error) {
+	// sample fᵢ(X) deg(fᵢ) = t, with fᵢ(0) = secretᵢ for keygen, and fᵢ(0) = 0 for refresh
+	VSSConstant := r.Group().NewScalar()
+	if r.PreviousSecretECDSA == nil {
+		VSSConstant = sample.Scalar(r.Rand(), r.Group())
+	}
+	r.VSSSecret = polynomial.NewPolynomialFromSource(r.Rand(), r.Group(), r.Threshold(), VSSConstant)
+
 	// generate Paillier and Pedersen
-	PaillierSecret := paillier.NewSecretKey(nil)
+	PaillierSecret, err := paillier.NewSecretKeyFromSource(r.Rand(), nil)
+	if err != nil {
+		return r, err
+	}
 	SelfPaillierPublic := PaillierSecret.PublicKey
-	SelfPedersenPublic, PedersenSecret := PaillierSecret.GeneratePedersen()
+	SelfPedersenPublic, PedersenSecret := PaillierSecret.GeneratePedersenFromSource(r.Rand())
 
-	ElGamalSecret, ElGamalPublic := sample.ScalarPointPair(rand.Reader, r.Group())
+	ElGamalSecret, ElGamalPublic := sample.ScalarPointPair(r.Rand(), r.Group())
 
 	// save our own share already so we are consistent with what we receive from others
 	SelfShare := r.VSSSecret.Evaluate(r.SelfID().Scalar(r.Group()))
@@ -75,20 +106,20 @@ func (r *round1) Finalize(out chan<- *round.Message) (round.Session, error) {
 	SelfVSSPolynomial := polynomial.NewPolynomialExponent(r.VSSSecret)
 
 	// generate Schnorr randomness
-	SchnorrRand := zksch.NewRandomness(rand.Reader, r.Group(), nil)
+	SchnorrRand := zksch.NewRandomness(r.Rand(), r.Group(), nil)
 
 	// Sample RIDᵢ
-	SelfRID, err := types.NewRID(rand.Reader)
+	SelfRID, err := types.NewRID(r.Rand())
 	if err != nil {
 		return r, errors.New("failed to sample Rho")
 	}
-	chainKey, err := types.NewRID(rand.Reader)
+	chainKey, err := types.NewRID(r.Rand())
 	if err != nil {
 		return r, errors.New("failed to sample c")
 	}
 
 	// commit to data in message 2
-	SelfCommitment, Decommitment, err := r.HashForID(r.SelfID()).Commit(
+	SelfCommitment, Decommitment, err := r.HashForID(r.SelfID()).CommitFromSource(r.Rand(),
 		SelfRID, chainKey, SelfVSSPolynomial, SchnorrRand.Commitment(), ElGamalPublic,
 		SelfPedersenPublic.N(), SelfPedersenPublic.S(), SelfPedersenPublic.T())
 	if err != nil {
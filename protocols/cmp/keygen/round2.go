@@ -105,7 +105,7 @@ func (r *round2) Finalize(out chan<- *round.Message) (round.Session, error) {
 	}
 	return &round3{
 		round2:             r,
-		SchnorrCommitments: map[party.ID]*zksch.Commitment{},
+		SchnorrCommitments: map[party.ID]*zksch.Commitment{r.SelfID(): r.SchnorrRand.Commitment()},
 	}, nil
 }
 
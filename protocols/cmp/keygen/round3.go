@@ -154,14 +154,14 @@ func (r *round3) Finalize(out chan<- *round.Message) (round.Session, error) {
 	_ = h.WriteAny(rid, r.SelfID())
 
 	// Prove N is a blum prime with zkmod
-	mod := zkmod.NewProof(h.Clone(), zkmod.Private{
+	mod := zkmod.NewProofFromSource(r.Rand(), h.Clone(), zkmod.Private{
 		P:   r.PaillierSecret.P(),
 		Q:   r.PaillierSecret.Q(),
 		Phi: r.PaillierSecret.Phi(),
 	}, zkmod.Public{N: r.NModulus[r.SelfID()]}, r.Pool)
 
 	// prove s, t are correct as aux parameters with zkprm
-	prm := zkprm.NewProof(zkprm.Private{
+	prm := zkprm.NewProofFromSource(r.Rand(), zkprm.Private{
 		Lambda: r.PedersenSecret,
 		Phi:    r.PaillierSecret.Phi(),
 		P:      r.PaillierSecret.P(),
@@ -180,7 +180,7 @@ func (r *round3) Finalize(out chan<- *round.Message) (round.Session, error) {
 		// compute fᵢ(j)
 		share := r.VSSSecret.Evaluate(j.Scalar(r.Group()))
 		// Encrypt share
-		C, _ := r.PaillierPublic[j].Enc(curve.MakeInt(share))
+		C, _ := r.PaillierPublic[j].EncFromSource(r.Rand(), curve.MakeInt(share))
 
 		err := r.SendMessage(out, &message4{
 			Share: C,
@@ -193,9 +193,11 @@ func (r *round3) Finalize(out chan<- *round.Message) (round.Session, error) {
 	// Write rid to the hash state
 	r.UpdateHashState(rid)
 	return &round4{
-		round3:   r,
-		RID:      rid,
-		ChainKey: chainKey,
+		round3:    r,
+		RID:       rid,
+		ChainKey:  chainKey,
+		ModProofs: map[party.ID]*zkmod.Proof{r.SelfID(): mod},
+		PrmProofs: map[party.ID]*zkprm.Proof{r.SelfID(): prm},
 	}, nil
 }
 
@@ -12,6 +12,7 @@ import (
 	"github.com/koteld/multi-party-sig/pkg/pedersen"
 	zkmod "github.com/koteld/multi-party-sig/pkg/zk/mod"
 	zkprm "github.com/koteld/multi-party-sig/pkg/zk/prm"
+	zksch "github.com/koteld/multi-party-sig/pkg/zk/sch"
 	"github.com/koteld/multi-party-sig/protocols/cmp/config"
 )
 
@@ -25,6 +26,11 @@ type round4 struct {
 	RID types.RID
 	// ChainKey is a sequence of random bytes agreed upon together
 	ChainKey types.RID
+
+	// ModProofs[j] and PrmProofs[j] are the proofs for Nⱼ, Sⱼ, Tⱼ,
+	// which are kept for the transcript of a refresh.
+	ModProofs map[party.ID]*zkmod.Proof
+	PrmProofs map[party.ID]*zkprm.Proof
 }
 
 type message4 struct {
@@ -57,6 +63,9 @@ func (r *round4) StoreBroadcastMessage(msg round.Message) error {
 	if !body.Prm.Verify(zkprm.Public{N: r.NModulus[from], S: r.S[from], T: r.T[from]}, r.HashForID(from), r.Pool) {
 		return errors.New("failed to validate prm proof")
 	}
+
+	r.ModProofs[from] = body.Mod
+	r.PrmProofs[from] = body.Prm
 	return nil
 }
 
@@ -149,9 +158,24 @@ func (r *round4) Finalize(out chan<- *round.Message) (round.Session, error) {
 			ElGamal:  r.ElGamalPublic[j],
 			Paillier: r.PaillierPublic[j],
 			Pedersen: pedersen.New(r.PaillierPublic[j].Modulus(), r.S[j], r.T[j]),
+
+			TransportKey: r.PreviousPublicTransportKeys[j],
 		}
 	}
 
+	// the coefficients of F(X) (+F'(X) if doing a refresh), which the public shares are evaluations of
+	var VSSCommitments []curve.Point
+	if r.PreviousSecretECDSA == nil || r.PreviousVSSCommitments != nil {
+		VSSCommitments = ShamirPublicPolynomial.Coefficients()
+		for k := range r.PreviousVSSCommitments {
+			VSSCommitments[k] = VSSCommitments[k].Add(r.PreviousVSSCommitments[k])
+		}
+	}
+
+	origin := config.OriginDKG
+	if r.PreviousSecretECDSA != nil {
+		origin = r.PreviousOrigin
+	}
 	UpdatedConfig := &config.Config{
 		Group:     r.Group(),
 		ID:        r.SelfID(),
@@ -162,6 +186,10 @@ func (r *round4) Finalize(out chan<- *round.Message) (round.Session, error) {
 		RID:       r.RID.Copy(),
 		ChainKey:  r.ChainKey.Copy(),
 		Public:    PublicData,
+		Origin:    origin,
+
+		TransportKey:   r.PreviousTransportKey,
+		VSSCommitments: VSSCommitments,
 	}
 
 	// write new ssid to hash, to bind the Schnorr proof to this new config
@@ -179,8 +207,9 @@ func (r *round4) Finalize(out chan<- *round.Message) (round.Session, error) {
 
 	r.UpdateHashState(UpdatedConfig)
 	return &round5{
-		round4:        r,
-		UpdatedConfig: UpdatedConfig,
+		round4:           r,
+		UpdatedConfig:    UpdatedConfig,
+		SchnorrResponses: map[party.ID]*zksch.Response{r.SelfID(): proof},
 	}, nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/pkg/party"
 	sch "github.com/koteld/multi-party-sig/pkg/zk/sch"
 	"github.com/koteld/multi-party-sig/protocols/cmp/config"
 )
@@ -13,6 +14,10 @@ var _ round.Round = (*round5)(nil)
 type round5 struct {
 	*round4
 	UpdatedConfig *config.Config
+
+	// SchnorrResponses[j] is the proof of knowledge of the new share of j,
+	// which is kept for the transcript of a refresh.
+	SchnorrResponses map[party.ID]*sch.Response
 }
 
 type broadcast5 struct {
@@ -40,6 +45,8 @@ func (r *round5) StoreBroadcastMessage(msg round.Message) error {
 		r.SchnorrCommitments[from], nil) {
 		return errors.New("failed to validate schnorr proof for received share")
 	}
+
+	r.SchnorrResponses[from] = body.SchnorrResponse
 	return nil
 }
 
@@ -51,6 +58,22 @@ func (r *round5) StoreMessage(round.Message) error { return nil }
 
 // Finalize implements round.Round.
 func (r *round5) Finalize(chan<- *round.Message) (round.Session, error) {
+	if r.PreviousSecretECDSA != nil {
+		proofs := make(map[party.ID]*config.RefreshProof, r.N())
+		for _, j := range r.PartyIDs() {
+			proofs[j] = &config.RefreshProof{
+				Mod:               r.ModProofs[j],
+				Prm:               r.PrmProofs[j],
+				SchnorrCommitment: r.SchnorrCommitments[j],
+				SchnorrResponse:   r.SchnorrResponses[j],
+			}
+		}
+		transcript, err := config.MarshalRefreshTranscript(r.ProtocolID(), r.SessionID, proofs)
+		if err != nil {
+			return r, err
+		}
+		r.UpdatedConfig.RefreshTranscript = transcript
+	}
 	return r.ResultRound(r.UpdatedConfig), nil
 }
 
@@ -2,6 +2,7 @@ package presign
 
 import (
 	"errors"
+	"io"
 
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/round"
@@ -114,12 +115,12 @@ type abortNth struct {
 	Proof     *zknth.Proof
 }
 
-func proveNth(hash *hash.Hash, paillierSecret *paillier.SecretKey, c *paillier.Ciphertext) *abortNth {
+func proveNth(source io.Reader, hash *hash.Hash, paillierSecret *paillier.SecretKey, c *paillier.Ciphertext) *abortNth {
 	NSquared := paillierSecret.ModulusSquared()
 	N := paillierSecret.Modulus()
 	deltaShareAlpha, deltaNonce, _ := paillierSecret.DecWithRandomness(c)
 	deltaNonceHidden := NSquared.Exp(deltaNonce, N.Nat())
-	proof := zknth.NewProof(hash, zknth.Public{
+	proof := zknth.NewProofFromSource(source, hash, zknth.Public{
 		N: paillierSecret.PublicKey,
 		R: deltaNonceHidden,
 	}, zknth.Private{Rho: deltaNonce})
@@ -0,0 +1,67 @@
+package presign
+
+import (
+	"errors"
+
+	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+)
+
+// mtaShares are the secrets of one party in the MtA instances of presign, reduced modulo the order.
+//
+// For every pair of parties i, j, the shares satisfy
+//
+//	αⱼᵢ + βᵢⱼ = γᵢ⋅kⱼ, and α̂ⱼᵢ + β̂ᵢⱼ = xᵢ⋅kⱼ,
+//
+// where α is held by j, β by i, and xᵢ is the share of i scaled by its Lagrange coefficient.
+type mtaShares struct {
+	// KShare = kᵢ
+	KShare curve.Scalar
+	// GammaShare = γᵢ
+	GammaShare curve.Scalar
+	// SecretECDSA = xᵢ
+	SecretECDSA curve.Scalar
+	// DeltaAlpha[j] = αᵢⱼ, DeltaBeta[j] = βᵢⱼ
+	DeltaAlpha, DeltaBeta map[party.ID]curve.Scalar
+	// ChiAlpha[j] = α̂ᵢⱼ, ChiBeta[j] = β̂ᵢⱼ
+	ChiAlpha, ChiBeta map[party.ID]curve.Scalar
+}
+
+// mtaSharesOf returns the MtA shares of a presign session, once it has reached its fourth round.
+//
+// This reveals the secrets of the session, and so only exists in tests.
+func mtaSharesOf(s round.Session) (*mtaShares, error) {
+	var r *presign4
+	switch s := s.(type) {
+	case *presign4:
+		r = s
+	case *presign5:
+		r = s.presign4
+	case *presign6:
+		r = s.presign4
+	case *presign7:
+		r = s.presign4
+	default:
+		return nil, errors.New("presign: the MtA shares are only known from the fourth round")
+	}
+
+	group := r.Group()
+	reduce := func(shares map[party.ID]*safenum.Int) map[party.ID]curve.Scalar {
+		out := make(map[party.ID]curve.Scalar, len(shares))
+		for j, share := range shares {
+			out[j] = group.NewScalar().SetNat(share.Mod(group.Order()))
+		}
+		return out
+	}
+	return &mtaShares{
+		KShare:      group.NewScalar().Set(r.KShare),
+		GammaShare:  group.NewScalar().SetNat(r.GammaShare.Mod(group.Order())),
+		SecretECDSA: group.NewScalar().Set(r.SecretECDSA),
+		DeltaAlpha:  reduce(r.DeltaShareAlpha),
+		DeltaBeta:   reduce(r.DeltaShareBeta),
+		ChiAlpha:    reduce(r.ChiShareAlpha),
+		ChiBeta:     reduce(r.ChiShareBeta),
+	}, nil
+}
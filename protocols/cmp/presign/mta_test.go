@@ -0,0 +1,53 @@
+package presign
+
+import (
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMtAShares(t *testing.T) {
+	rounds := make([]round.Session, 0, N)
+	for _, id := range partyIDs {
+		r, err := StartPresign(configs[id], partyIDs, nil, nil)(nil)
+		require.NoError(t, err, "round creation should not result in an error")
+		rounds = append(rounds, r)
+	}
+	_, err := mtaSharesOf(rounds[0])
+	assert.Error(t, err, "the shares aren't known in the first round")
+
+	for {
+		err, done := test.Rounds(rounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if _, ok := rounds[0].(*presign4); ok || done {
+			break
+		}
+	}
+
+	shares := make(map[party.ID]*mtaShares, N)
+	for _, r := range rounds {
+		shares[r.SelfID()], err = mtaSharesOf(r)
+		require.NoError(t, err)
+	}
+
+	for _, i := range partyIDs {
+		for _, j := range partyIDs {
+			if i == j {
+				continue
+			}
+			// αⱼᵢ + βᵢⱼ = γᵢ⋅kⱼ
+			delta := group.NewScalar().Set(shares[j].DeltaAlpha[i]).Add(shares[i].DeltaBeta[j])
+			expected := group.NewScalar().Set(shares[i].GammaShare).Mul(shares[j].KShare)
+			assert.True(t, delta.Equal(expected), "δ shares of %s and %s don't add up", i, j)
+
+			// α̂ⱼᵢ + β̂ᵢⱼ = xᵢ⋅kⱼ
+			chi := group.NewScalar().Set(shares[j].ChiAlpha[i]).Add(shares[i].ChiBeta[j])
+			expected = group.NewScalar().Set(shares[i].SecretECDSA).Mul(shares[j].KShare)
+			assert.True(t, chi.Equal(expected), "χ shares of %s and %s don't add up", i, j)
+		}
+	}
+}
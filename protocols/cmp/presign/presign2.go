@@ -10,6 +10,7 @@ import (
 	"github.com/koteld/multi-party-sig/internal/types"
 	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
 	"github.com/koteld/multi-party-sig/pkg/paillier"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	zkaffg "github.com/koteld/multi-party-sig/pkg/zk/affg"
@@ -137,14 +138,15 @@ func (r *presign2) Finalize(out chan<- *round.Message) (round.Session, error) {
 		ChiF       *paillier.Ciphertext
 		ChiProof   *zkaffg.Proof
 	}
+	sources := sample.Split(r.Rand(), len(otherIDs))
 	mtaOuts := r.Pool.Parallelize(len(otherIDs), func(i int) interface{} {
 		j := otherIDs[i]
 
-		DeltaBeta, DeltaD, DeltaF, DeltaProof := mta.ProveAffP(r.Group(), r.HashForID(r.SelfID()),
+		DeltaBeta, DeltaD, DeltaF, DeltaProof := mta.ProveAffP(sources[i], r.Group(), r.HashForID(r.SelfID()),
 			r.GammaShare, r.G[r.SelfID()], r.GNonce, r.K[j],
 			r.SecretPaillier, r.Paillier[j], r.Pedersen[j])
 
-		ChiBeta, ChiD, ChiF, ChiProof := mta.ProveAffG(r.Group(), r.HashForID(r.SelfID()),
+		ChiBeta, ChiD, ChiF, ChiProof := mta.ProveAffG(sources[i], r.Group(), r.HashForID(r.SelfID()),
 			curve.MakeInt(r.SecretECDSA), r.ECDSA[r.SelfID()], r.K[j],
 			r.SecretPaillier, r.Paillier[j], r.Pedersen[j])
 
@@ -13,6 +13,7 @@ import (
 	"github.com/koteld/multi-party-sig/pkg/party"
 	zkaffg "github.com/koteld/multi-party-sig/pkg/zk/affg"
 	zkaffp "github.com/koteld/multi-party-sig/pkg/zk/affp"
+	zklogstar "github.com/koteld/multi-party-sig/pkg/zk/logstar"
 )
 
 var _ round.Round = (*presign3)(nil)
@@ -163,7 +164,7 @@ func (r *presign3) Finalize(out chan<- *round.Message) (round.Session, error) {
 
 	// ElGamalChi = Ẑⱼ = (b̂ⱼ⋅G, χᵢ+b̂ⱼ⋅Yᵢ)
 	// ElGamalChiNonce = b̂ⱼ
-	ElGamalChi, ElGamalChiNonce := elgamal.Encrypt(r.ElGamal[r.SelfID()], r.Group().NewScalar().SetNat(ChiShare.Mod(r.Group().Order())))
+	ElGamalChi, ElGamalChiNonce := elgamal.Encrypt(r.Rand(), r.ElGamal[r.SelfID()], r.Group().NewScalar().SetNat(ChiShare.Mod(r.Group().Order())))
 
 	DeltaShareScalar := r.Group().NewScalar().SetNat(DeltaShare.Mod(r.Group().Order()))
 
@@ -171,9 +172,22 @@ func (r *presign3) Finalize(out chan<- *round.Message) (round.Session, error) {
 		DeltaShare: DeltaShareScalar,
 		ElGamalChi: ElGamalChi,
 	}
+	var EarlyGammaShare map[party.ID]curve.Point
+	if r.EarlyGamma {
+		msg.BigGammaShare = r.bigGammaShare()
+		EarlyGammaShare = map[party.ID]curve.Point{r.SelfID(): msg.BigGammaShare}
+	}
 	if err = r.BroadcastMessage(out, msg); err != nil {
 		return r, err
 	}
+	if r.EarlyGamma {
+		err = r.proveBigGammaShare(out, msg.BigGammaShare, func(proof *zklogstar.Proof) round.Content {
+			return &message4{ProofLog: proof}
+		})
+		if err != nil {
+			return r, err
+		}
+	}
 
 	return &presign4{
 		presign3:        r,
@@ -183,6 +197,7 @@ func (r *presign3) Finalize(out chan<- *round.Message) (round.Session, error) {
 		ElGamalChi:      map[party.ID]*elgamal.Ciphertext{r.SelfID(): ElGamalChi},
 		DeltaShares:     map[party.ID]curve.Scalar{r.SelfID(): DeltaShareScalar},
 		ChiShare:        r.Group().NewScalar().SetNat(ChiShare.Mod(r.Group().Order())),
+		EarlyGammaShare: EarlyGammaShare,
 	}, nil
 }
 
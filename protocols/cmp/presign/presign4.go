@@ -1,10 +1,13 @@
 package presign
 
 import (
+	"errors"
+
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/elgamal"
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	zklogstar "github.com/koteld/multi-party-sig/pkg/zk/logstar"
 )
@@ -29,6 +32,13 @@ type presign4 struct {
 
 	// ChiShare = χᵢ
 	ChiShare curve.Scalar
+
+	// EarlyGammaShare[j] = Γⱼ = [γⱼ]•G, received in this round with EarlyGamma, and nil otherwise.
+	EarlyGammaShare map[party.ID]curve.Point
+}
+
+type message4 struct {
+	ProofLog *zklogstar.Proof
 }
 
 type broadcast4 struct {
@@ -37,11 +47,14 @@ type broadcast4 struct {
 	DeltaShare curve.Scalar
 	// ElGamalChi = Ẑᵢ = (b̂ᵢ, χᵢ⋅G+b̂ᵢ⋅Yᵢ)
 	ElGamalChi *elgamal.Ciphertext
+	// BigGammaShare = Γᵢ, only sent with EarlyGamma.
+	BigGammaShare curve.Point `cbor:",omitempty"`
 }
 
 // StoreBroadcastMessage implements round.BroadcastRound.
 //
 // - store Ẑⱼ, δⱼ.
+// - with EarlyGamma, store Γⱼ.
 func (r *presign4) StoreBroadcastMessage(msg round.Message) error {
 	body, ok := msg.Content.(*broadcast4)
 	if !ok || body == nil {
@@ -51,13 +64,30 @@ func (r *presign4) StoreBroadcastMessage(msg round.Message) error {
 	if body.DeltaShare.IsZero() || !body.ElGamalChi.Valid() {
 		return round.ErrNilFields
 	}
+	if r.EarlyGamma {
+		if body.BigGammaShare == nil || body.BigGammaShare.IsIdentity() {
+			return round.ErrNilFields
+		}
+		r.EarlyGammaShare[msg.From] = body.BigGammaShare
+	}
 	r.ElGamalChi[msg.From] = body.ElGamalChi
 	r.DeltaShares[msg.From] = body.DeltaShare
 	return nil
 }
 
 // VerifyMessage implements round.Round.
-func (presign4) VerifyMessage(round.Message) error { return nil }
+//
+// - with EarlyGamma, verify zklogstar.
+func (r *presign4) VerifyMessage(msg round.Message) error {
+	if !r.EarlyGamma {
+		return nil
+	}
+	body, ok := msg.Content.(*message4)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	return r.verifyBigGammaShare(msg.From, msg.To, r.EarlyGammaShare[msg.From], body.ProofLog)
+}
 
 // StoreMessage implements round.Round.
 func (presign4) StoreMessage(round.Message) error { return nil }
@@ -66,63 +96,111 @@ func (presign4) StoreMessage(round.Message) error { return nil }
 //
 // - set Γᵢ = γᵢ⋅G.
 // - prove zklogstar.
+//
+// With EarlyGamma, this was already done in round 3, so we continue with round 5 directly.
 func (r *presign4) Finalize(out chan<- *round.Message) (round.Session, error) {
+	if r.EarlyGamma {
+		next := &presign5{
+			presign4:      r,
+			BigGammaShare: r.EarlyGammaShare,
+		}
+		return next.Finalize(out)
+	}
+
 	// Γᵢ = γᵢ⋅G
-	BigGammaShare := r.Group().NewScalar().SetNat(r.GammaShare.Mod(r.Group().Order())).ActOnBase()
+	BigGammaShare := r.bigGammaShare()
 
-	zkPrivate := zklogstar.Private{
-		X:   r.GammaShare,
-		Rho: r.GNonce,
+	if err := r.BroadcastMessage(out, &broadcast5{BigGammaShare: BigGammaShare}); err != nil {
+		return r, err
 	}
 
-	if err := r.BroadcastMessage(out, &broadcast5{BigGammaShare: BigGammaShare}); err != nil {
+	err := r.proveBigGammaShare(out, BigGammaShare, func(proof *zklogstar.Proof) round.Content {
+		return &message5{ProofLog: proof}
+	})
+	if err != nil {
 		return r, err
 	}
 
+	return &presign5{
+		presign4:      r,
+		BigGammaShare: map[party.ID]curve.Point{r.SelfID(): BigGammaShare},
+	}, nil
+}
+
+// bigGammaShare returns Γᵢ = γᵢ⋅G.
+func (r *presign3) bigGammaShare() curve.Point {
+	return r.Group().NewScalar().SetNat(r.GammaShare.Mod(r.Group().Order())).ActOnBase()
+}
+
+// proveBigGammaShare sends every other party a zklogstar proof that Γᵢ and Gᵢ hide the same γᵢ,
+// in the message returned by newMessage.
+func (r *presign3) proveBigGammaShare(out chan<- *round.Message, BigGammaShare curve.Point, newMessage func(*zklogstar.Proof) round.Content) error {
+	zkPrivate := zklogstar.Private{
+		X:   r.GammaShare,
+		Rho: r.GNonce,
+	}
+
 	otherIDs := r.OtherPartyIDs()
+	sources := sample.Split(r.Rand(), len(otherIDs))
 	errors := r.Pool.Parallelize(len(otherIDs), func(i int) interface{} {
 		j := otherIDs[i]
 
-		proofLog := zklogstar.NewProof(r.Group(), r.HashForID(r.SelfID()), zklogstar.Public{
+		proofLog := zklogstar.NewProofFromSource(sources[i], r.Group(), r.HashForID(r.SelfID()), zklogstar.Public{
 			C:      r.G[r.SelfID()],
 			X:      BigGammaShare,
 			Prover: r.Paillier[r.SelfID()],
 			Aux:    r.Pedersen[j],
 		}, zkPrivate)
 
-		err := r.SendMessage(out, &message5{
-			ProofLog: proofLog,
-		}, j)
-		if err != nil {
-			return err
-		}
-
-		return nil
+		return r.SendMessage(out, newMessage(proofLog), j)
 	})
 	for _, err := range errors {
 		if err != nil {
-			return r, err.(error)
+			return err.(error)
 		}
 	}
+	return nil
+}
 
-	return &presign5{
-		presign4:      r,
-		BigGammaShare: map[party.ID]curve.Point{r.SelfID(): BigGammaShare},
-	}, nil
+// verifyBigGammaShare verifies the zklogstar proof sent by from, that Γⱼ and Gⱼ hide the same γⱼ.
+func (r *presign3) verifyBigGammaShare(from, to party.ID, BigGammaShare curve.Point, proof *zklogstar.Proof) error {
+	if !proof.Verify(r.HashForID(from), zklogstar.Public{
+		C:      r.G[from],
+		X:      BigGammaShare,
+		Prover: r.Paillier[from],
+		Aux:    r.Pedersen[to],
+	}) {
+		return errors.New("failed to validate log* proof for BigGammaShare")
+	}
+	return nil
 }
 
+// RoundNumber implements round.Content.
+func (message4) RoundNumber() round.Number { return 4 }
+
 // MessageContent implements round.Round.
-func (r *presign4) MessageContent() round.Content { return nil }
+func (r *presign4) MessageContent() round.Content {
+	if !r.EarlyGamma {
+		return nil
+	}
+	return &message4{
+		ProofLog: zklogstar.Empty(r.Group()),
+	}
+}
 
 // RoundNumber implements round.Content.
 func (broadcast4) RoundNumber() round.Number { return 4 }
 
 // BroadcastContent implements round.BroadcastRound.
 func (r *presign4) BroadcastContent() round.BroadcastContent {
-	return &broadcast4{
+	content := &broadcast4{
 		DeltaShare: r.Group().NewScalar(),
 		ElGamalChi: elgamal.Empty(r.Group()),
 	}
+	if r.EarlyGamma {
+		content.BigGammaShare = r.Group().NewPoint()
+	}
+	return content
 }
 
 // Number implements round.Round.
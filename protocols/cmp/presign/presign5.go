@@ -1,8 +1,6 @@
 package presign
 
 import (
-	"errors"
-
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/party"
@@ -52,16 +50,7 @@ func (r *presign5) VerifyMessage(msg round.Message) error {
 	if !ok || body == nil {
 		return round.ErrInvalidContent
 	}
-	if !body.ProofLog.Verify(r.HashForID(msg.From), zklogstar.Public{
-		C:      r.G[from],
-		X:      r.BigGammaShare[from],
-		Prover: r.Paillier[from],
-		Aux:    r.Pedersen[to],
-	}) {
-		return errors.New("failed to validate log* proof for BigGammaShare")
-	}
-
-	return nil
+	return r.verifyBigGammaShare(from, to, r.BigGammaShare[from], body.ProofLog)
 }
 
 // StoreMessage implements round.Round.
@@ -81,7 +70,7 @@ func (r *presign5) Finalize(out chan<- *round.Message) (round.Session, error) {
 	// Δᵢ = kᵢ⋅Γ
 	BigDeltaShare := r.KShare.Act(Gamma)
 
-	proofLog := zkelog.NewProof(r.Group(), r.HashForID(r.SelfID()),
+	proofLog := zkelog.NewProofFromSource(r.Rand(), r.Group(), r.HashForID(r.SelfID()),
 		zkelog.Public{
 			E:             r.ElGamalK[r.SelfID()],
 			ElGamalPublic: r.ElGamal[r.SelfID()],
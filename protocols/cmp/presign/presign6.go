@@ -95,11 +95,11 @@ func (r *presign6) Finalize(out chan<- *round.Message) (round.Session, error) {
 		DeltaProofs := make(map[party.ID]*abortNth, r.N()-1)
 		for _, j := range r.OtherPartyIDs() {
 			deltaCiphertext := r.DeltaCiphertext[j][r.SelfID()] // Dᵢⱼ
-			DeltaProofs[j] = proveNth(r.HashForID(r.SelfID()), r.SecretPaillier, deltaCiphertext)
+			DeltaProofs[j] = proveNth(r.Rand(), r.HashForID(r.SelfID()), r.SecretPaillier, deltaCiphertext)
 		}
 		msg := &broadcastAbort1{
 			GammaShare:  r.GammaShare,
-			KProof:      proveNth(r.HashForID(r.SelfID()), r.SecretPaillier, r.K[r.SelfID()]),
+			KProof:      proveNth(r.Rand(), r.HashForID(r.SelfID()), r.SecretPaillier, r.K[r.SelfID()]),
 			DeltaProofs: DeltaProofs,
 		}
 		if err := r.BroadcastMessage(out, msg); err != nil {
@@ -122,7 +122,7 @@ func (r *presign6) Finalize(out chan<- *round.Message) (round.Session, error) {
 		RBar[j] = DeltaInv.Act(BigDeltaJ)
 	}
 
-	proof := zkelog.NewProof(r.Group(), r.HashForID(r.SelfID()), zkelog.Public{
+	proof := zkelog.NewProofFromSource(r.Rand(), r.Group(), r.HashForID(r.SelfID()), zkelog.Public{
 		E:             r.ElGamalChi[r.SelfID()],
 		ElGamalPublic: r.ElGamal[r.SelfID()],
 		Base:          R,
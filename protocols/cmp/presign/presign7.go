@@ -85,7 +85,8 @@ func (presign7) StoreMessage(round.Message) error { return nil }
 
 // Finalize implements round.Round
 //
-// - verify ∑ⱼ Sⱼ = X.
+// - verify ∑ⱼ Sⱼ = X
+// - prove that R̄ᵢ = kᵢ⋅R, so that the presignature can be audited later.
 func (r *presign7) Finalize(out chan<- *round.Message) (round.Session, error) {
 	// compute ∑ⱼ Sⱼ
 	PublicKeyComputed := r.Group().NewPoint()
@@ -101,7 +102,7 @@ func (r *presign7) Finalize(out chan<- *round.Message) (round.Session, error) {
 	// ∑ⱼ Sⱼ ?= X
 	if !r.PublicKey.Equal(PublicKeyComputed) {
 		YHat := r.ElGamalKNonce.Act(r.ElGamal[r.SelfID()])
-		YHatProof := zklog.NewProof(r.Group(), r.HashForID(r.SelfID()), zklog.Public{
+		YHatProof := zklog.NewProofFromSource(r.Rand(), r.Group(), r.HashForID(r.SelfID()), zklog.Public{
 			H: r.ElGamalKNonce.ActOnBase(),
 			X: r.ElGamal[r.SelfID()],
 			Y: YHat,
@@ -113,12 +114,12 @@ func (r *presign7) Finalize(out chan<- *round.Message) (round.Session, error) {
 		ChiProofs := make(map[party.ID]*abortNth, r.N()-1)
 		for _, j := range r.OtherPartyIDs() {
 			chiCiphertext := r.ChiCiphertext[j][r.SelfID()] // D̂ᵢⱼ
-			ChiProofs[j] = proveNth(r.HashForID(r.SelfID()), r.SecretPaillier, chiCiphertext)
+			ChiProofs[j] = proveNth(r.Rand(), r.HashForID(r.SelfID()), r.SecretPaillier, chiCiphertext)
 		}
 		msg := &broadcastAbort2{
 			YHat:      YHat,
 			YHatProof: YHatProof,
-			KProof:    proveNth(r.HashForID(r.SelfID()), r.SecretPaillier, r.K[r.SelfID()]),
+			KProof:    proveNth(r.Rand(), r.HashForID(r.SelfID()), r.SecretPaillier, r.K[r.SelfID()]),
 			ChiProofs: ChiProofs,
 		}
 		if err := r.BroadcastMessage(out, msg); err != nil {
@@ -144,6 +145,9 @@ func (r *presign7) Finalize(out chan<- *round.Message) (round.Session, error) {
 		KShare:   r.KShare,
 		ChiShare: r.ChiShare,
 	}
+	if err := preSignature.Prove(r.SelfID()); err != nil {
+		return r.AbortRound(err, r.SelfID()), nil
+	}
 	if r.Message == nil {
 		return r.ResultRound(preSignature), nil
 	}
@@ -47,17 +47,25 @@ func (sign2) StoreMessage(round.Message) error { return nil }
 
 // Finalize implements round.Round
 //
-// - verify (r,s)
-// - if not, find culprit.
+// - verify each σⱼ, naming the parties whose shares are invalid
+// - check that r ≠ 0 and s ≠ 0
+// - verify (r,s).
 func (r *sign2) Finalize(chan<- *round.Message) (round.Session, error) {
+	if culprits := r.PreSignature.VerifySignatureShares(r.SigmaShares, r.Message); len(culprits) > 0 {
+		return r.AbortRound(errors.New("invalid signature shares"), culprits...), nil
+	}
+
 	s := r.PreSignature.Signature(r.SigmaShares)
 
-	if s.Verify(r.PublicKey, r.Message) {
-		return r.ResultRound(s), nil
+	// r = 0 or s = 0 can only be fixed by using another presignature
+	if err := s.CheckNonZero(); err != nil {
+		return r.AbortRound(err), nil
 	}
 
-	culprits := r.PreSignature.VerifySignatureShares(r.SigmaShares, r.Message)
-	return r.AbortRound(errors.New("signature failed to verify"), culprits...), nil
+	if !s.Verify(r.PublicKey, r.Message) {
+		return r.AbortRound(errors.New("signature failed to verify")), nil
+	}
+	return r.ResultRound(s), nil
 }
 
 // MessageContent implements round.Round.
@@ -0,0 +1,247 @@
+package presign
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/internal/types"
+	"github.com/koteld/multi-party-sig/pkg/ecdsa"
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/pool"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+	"github.com/koteld/multi-party-sig/protocols/cmp/config"
+)
+
+const protocolOnlineBatchID = "cmp/presign-online-batch"
+
+// StartPresignOnlineBatch signs several messages at once, pairing preSignatures[i] with messages[i].
+//
+// All the preSignatures must have been generated by the same set of signers,
+// and no preSignature may appear twice in the batch.
+// Returns []*ecdsa.Signature if successful.
+func StartPresignOnlineBatch(c *config.Config, preSignatures []*ecdsa.PreSignature, messages [][]byte, pl *pool.Pool) protocol.StartFunc {
+	return func(sessionID []byte) (round.Session, error) {
+		if c == nil {
+			return nil, errors.New("presign: config is nil")
+		}
+		if err := c.CheckNotRenounced(); err != nil {
+			return nil, fmt.Errorf("sign.Create: %w", err)
+		}
+		if len(preSignatures) == 0 {
+			return nil, errors.New("sign.Create: no preSignatures")
+		}
+		if len(preSignatures) != len(messages) {
+			return nil, fmt.Errorf("sign.Create: got %d preSignatures for %d messages", len(preSignatures), len(messages))
+		}
+
+		var signers party.IDSlice
+		sessionData := make([]hash.WriterToWithDomain, 0, 2*len(messages))
+		for i, preSignature := range preSignatures {
+			if preSignature == nil {
+				return nil, fmt.Errorf("sign.Create: preSignature %d is nil", i)
+			}
+			// this could be used to indicate a pre-signature later on
+			if len(messages[i]) == 0 {
+				return nil, fmt.Errorf("sign.Create: message %d is nil", i)
+			}
+			if err := preSignature.Validate(); err != nil {
+				return nil, fmt.Errorf("sign.Create: preSignature %d: %w", i, err)
+			}
+			for j := 0; j < i; j++ {
+				if bytes.Equal(preSignatures[j].ID, preSignature.ID) {
+					return nil, fmt.Errorf("sign.Create: preSignature %d is reused at %d", j, i)
+				}
+			}
+			ids := preSignature.SignerIDs()
+			if signers == nil {
+				signers = ids
+			} else if len(signers) != len(ids) || !signers.Contains(ids...) {
+				return nil, fmt.Errorf("sign.Create: preSignature %d has different signers", i)
+			}
+			sessionData = append(sessionData,
+				hash.BytesWithDomain{
+					TheDomain: "PreSignatureID",
+					Bytes:     preSignature.ID,
+				},
+				types.SigningMessage(messages[i]),
+			)
+		}
+
+		if err := c.CheckSigners(signers); err != nil {
+			return nil, fmt.Errorf("sign.Create: %w", err)
+		}
+
+		info := round.Info{
+			ProtocolID:       protocolOnlineBatchID,
+			FinalRoundNumber: protocolFullRounds,
+			SelfID:           c.ID,
+			PartyIDs:         signers,
+			Threshold:        c.Threshold,
+			Group:            c.Group,
+		}
+
+		auxInfo := make([]hash.WriterToWithDomain, 0, len(sessionData)+1)
+		auxInfo = append(auxInfo, c)
+		auxInfo = append(auxInfo, sessionData...)
+		helper, err := round.NewSession(info, sessionID, pl, auxInfo...)
+		if err != nil {
+			return nil, fmt.Errorf("sign.Create: %w", err)
+		}
+
+		return &signBatch1{
+			Helper:        helper,
+			Pool:          pl,
+			PublicKey:     c.PublicPoint(),
+			Messages:      messages,
+			PreSignatures: preSignatures,
+		}, nil
+	}
+}
+
+var _ round.Round = (*signBatch1)(nil)
+
+type signBatch1 struct {
+	*round.Helper
+	Pool *pool.Pool
+	// PublicKey = X
+	PublicKey curve.Point
+	// Messages[l] = mₗ
+	Messages [][]byte
+	// PreSignatures[l] = (Rₗ, {R̄ₗⱼ,Sₗⱼ}ⱼ, kₗᵢ, χₗᵢ)
+	PreSignatures []*ecdsa.PreSignature
+}
+
+// VerifyMessage implements round.Round.
+func (r *signBatch1) VerifyMessage(round.Message) error { return nil }
+
+// StoreMessage implements round.Round.
+func (r *signBatch1) StoreMessage(round.Message) error { return nil }
+
+// Finalize implements round.Round
+//
+// - compute σₗᵢ = kₗᵢmₗ+rₗχₗᵢ (mod q) for each message.
+func (r *signBatch1) Finalize(out chan<- *round.Message) (round.Session, error) {
+	sigmas := make([]curve.Scalar, len(r.Messages))
+	sigmaShares := make([]map[party.ID]curve.Scalar, len(r.Messages))
+	for l := range r.Messages {
+		sigmas[l] = r.PreSignatures[l].SignatureShare(r.Messages[l])
+		sigmaShares[l] = map[party.ID]curve.Scalar{r.SelfID(): sigmas[l]}
+	}
+
+	err := r.BroadcastMessage(out, &broadcastSignBatch2{
+		Sigmas: sigmas,
+	})
+	if err != nil {
+		return r, err.(error)
+	}
+
+	return &signBatch2{
+		signBatch1:  r,
+		SigmaShares: sigmaShares,
+	}, nil
+}
+
+// MessageContent implements round.Round.
+func (signBatch1) MessageContent() round.Content { return nil }
+
+// Number implements round.Round.
+func (signBatch1) Number() round.Number { return 1 }
+
+var _ round.Round = (*signBatch2)(nil)
+
+type signBatch2 struct {
+	*signBatch1
+	// SigmaShares[l][j] = σₗⱼ
+	SigmaShares []map[party.ID]curve.Scalar
+}
+
+type broadcastSignBatch2 struct {
+	round.NormalBroadcastContent
+	// Sigmas[l] = σₗᵢ
+	Sigmas []curve.Scalar
+}
+
+// StoreBroadcastMessage implements round.BroadcastRound.
+//
+// - save σₗⱼ for each message.
+func (r *signBatch2) StoreBroadcastMessage(msg round.Message) error {
+	body, ok := msg.Content.(*broadcastSignBatch2)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+
+	if len(body.Sigmas) != len(r.Messages) {
+		return round.ErrInvalidContent
+	}
+	for _, sigma := range body.Sigmas {
+		if sigma == nil || sigma.IsZero() {
+			return round.ErrNilFields
+		}
+	}
+
+	for l, sigma := range body.Sigmas {
+		r.SigmaShares[l][msg.From] = sigma
+	}
+	return nil
+}
+
+// VerifyMessage implements round.Round.
+func (signBatch2) VerifyMessage(round.Message) error { return nil }
+
+// StoreMessage implements round.Round.
+func (signBatch2) StoreMessage(round.Message) error { return nil }
+
+// Finalize implements round.Round
+//
+// - check that rₗ ≠ 0 and sₗ ≠ 0, and verify each (rₗ,sₗ) in parallel
+// - if one fails, find the culprits.
+func (r *signBatch2) Finalize(chan<- *round.Message) (round.Session, error) {
+	results := r.Pool.Parallelize(len(r.Messages), func(l int) interface{} {
+		s := r.PreSignatures[l].Signature(r.SigmaShares[l])
+		if err := s.CheckNonZero(); err != nil {
+			return err
+		}
+		if !s.Verify(r.PublicKey, r.Messages[l]) {
+			return nil
+		}
+		return s
+	})
+
+	signatures := make([]*ecdsa.Signature, len(results))
+	for l, result := range results {
+		if err, ok := result.(error); ok {
+			return r.AbortRound(fmt.Errorf("signature %d: %w", l, err)), nil
+		}
+		s, ok := result.(*ecdsa.Signature)
+		if !ok {
+			culprits := r.PreSignatures[l].VerifySignatureShares(r.SigmaShares[l], r.Messages[l])
+			return r.AbortRound(fmt.Errorf("signature %d failed to verify", l), culprits...), nil
+		}
+		signatures[l] = s
+	}
+	return r.ResultRound(signatures), nil
+}
+
+// MessageContent implements round.Round.
+func (signBatch2) MessageContent() round.Content { return nil }
+
+// RoundNumber implements round.Content.
+func (broadcastSignBatch2) RoundNumber() round.Number { return 8 }
+
+// BroadcastContent implements round.BroadcastRound.
+func (r *signBatch2) BroadcastContent() round.BroadcastContent {
+	sigmas := make([]curve.Scalar, len(r.Messages))
+	for l := range sigmas {
+		sigmas[l] = r.Group().NewScalar()
+	}
+	return &broadcastSignBatch2{
+		Sigmas: sigmas,
+	}
+}
+
+// Number implements round.Round.
+func (signBatch2) Number() round.Number { return 8 }
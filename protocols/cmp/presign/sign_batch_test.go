@@ -0,0 +1,84 @@
+package presign
+
+import (
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/ecdsa"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/pool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/sha3"
+)
+
+func TestSignBatch(t *testing.T) {
+	const count = 16
+	// Each party gets its own pool, since a pool can't be shared between concurrent sessions.
+	pools := make(map[party.ID]*pool.Pool, N)
+	for _, id := range partyIDs {
+		pools[id] = pool.NewPool(1)
+		defer pools[id].TearDown()
+	}
+
+	// preSignatures[id][l] is party id's share of the l-th PreSignature
+	preSignatures := make(map[party.ID][]*ecdsa.PreSignature, N)
+	for l := 0; l < count; l++ {
+		rounds := make([]round.Session, 0, N)
+		for _, id := range partyIDs {
+			r, err := StartPresign(configs[id], partyIDs, nil, pools[id])(nil)
+			require.NoError(t, err, "round creation should not result in an error")
+			rounds = append(rounds, r)
+		}
+		for {
+			err, done := test.Rounds(rounds, nil)
+			require.NoError(t, err, "failed to process round")
+			if done {
+				break
+			}
+		}
+		for _, r := range rounds {
+			require.IsType(t, &round.Output{}, r)
+			preSignature, ok := r.(*round.Output).Result.(*ecdsa.PreSignature)
+			require.True(t, ok, "result should be *ecdsa.PreSignature")
+			preSignatures[r.SelfID()] = append(preSignatures[r.SelfID()], preSignature)
+		}
+	}
+
+	messages := make([][]byte, count)
+	for l := range messages {
+		messages[l] = make([]byte, 64)
+		sha3.ShakeSum128(messages[l], []byte{byte(l)})
+	}
+
+	_, err := StartPresignOnlineBatch(configs[partyIDs[0]], preSignatures[partyIDs[0]], messages[1:], nil)(nil)
+	assert.Error(t, err, "should reject mismatched counts")
+	reused := append([]*ecdsa.PreSignature{}, preSignatures[partyIDs[0]]...)
+	reused[1] = reused[0]
+	_, err = StartPresignOnlineBatch(configs[partyIDs[0]], reused, messages, nil)(nil)
+	assert.Error(t, err, "should reject reused preSignatures")
+
+	rounds := make([]round.Session, 0, N)
+	for _, id := range partyIDs {
+		r, err := StartPresignOnlineBatch(configs[id], preSignatures[id], messages, pools[id])(nil)
+		require.NoError(t, err, "round creation should not result in an error")
+		rounds = append(rounds, r)
+	}
+	for {
+		err, done := test.Rounds(rounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if done {
+			break
+		}
+	}
+	for _, r := range rounds {
+		require.IsType(t, &round.Output{}, r)
+		signatures, ok := r.(*round.Output).Result.([]*ecdsa.Signature)
+		require.True(t, ok, "result should be []*ecdsa.Signature")
+		require.Len(t, signatures, count)
+		for l, signature := range signatures {
+			assert.True(t, signature.Verify(configs[r.SelfID()].PublicPoint(), messages[l]))
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package presign
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	mrand "math/rand"
 	"testing"
 
@@ -9,6 +12,7 @@ import (
 	"github.com/koteld/multi-party-sig/internal/test"
 	"github.com/koteld/multi-party-sig/pkg/ecdsa"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/pool"
 	"github.com/koteld/multi-party-sig/protocols/cmp/config"
@@ -68,3 +72,145 @@ func TestRound(t *testing.T) {
 		assert.True(t, signature.Verify(configs[r.SelfID()].PublicPoint(), messageHash))
 	}
 }
+
+func TestAggregateNonce(t *testing.T) {
+	rounds := make([]round.Session, 0, N)
+	for _, c := range configs {
+		pl := pool.NewPool(1)
+		defer pl.TearDown()
+		r, err := StartPresign(c, partyIDs, nil, pl)(nil)
+		require.NoError(t, err, "round creation should not result in an error")
+		rounds = append(rounds, r)
+	}
+
+	// the shares Γⱼ and δ are public once the last round is reached
+	var Gamma map[party.ID]curve.Point
+	var Delta curve.Scalar
+	for {
+		err, done := test.Rounds(rounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if r, ok := rounds[0].(*presign7); ok {
+			Gamma, Delta = r.BigGammaShare, r.Delta
+		}
+		if done {
+			break
+		}
+	}
+	require.NotNil(t, Gamma, "presign7 was never reached")
+
+	DeltaInv := group.NewScalar().Set(Delta).Invert()
+	coeffs := make(map[party.ID]curve.Scalar, len(Gamma))
+	for j := range Gamma {
+		coeffs[j] = DeltaInv
+	}
+	R := ecdsa.AggregateNonce(Gamma, coeffs)
+
+	for _, r := range rounds {
+		require.IsType(t, &round.Output{}, r)
+		preSignature := r.(*round.Output).Result.(*ecdsa.PreSignature)
+		assert.True(t, R.Equal(preSignature.R), "R = δ⁻¹⋅∑ⱼΓⱼ should be the R of the presignature")
+		assert.True(t, ecdsa.AggregateNonce(preSignature.RBar.Points, nil).Equal(group.NewBasePoint()), "∑ⱼR̄ⱼ should be G")
+	}
+}
+
+func TestSignZeroR(t *testing.T) {
+	// R = (n, y) has an x coordinate of 0 mod n
+	data, _ := hex.DecodeString("02fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+	R := group.NewPoint()
+	require.NoError(t, R.UnmarshalBinary(data))
+
+	c := configs[partyIDs[0]]
+	helper, err := round.NewSession(round.Info{
+		ProtocolID:       protocolOnlineID,
+		FinalRoundNumber: protocolFullRounds,
+		SelfID:           c.ID,
+		PartyIDs:         partyIDs,
+		Threshold:        T,
+		Group:            group,
+	}, nil, nil)
+	require.NoError(t, err)
+
+	// with r = 0, a share σⱼ is valid when σⱼ⋅R = m⋅R̄ⱼ
+	mInv := curve.FromHash(group, messageHash).Invert()
+	sigmaShares := make(map[party.ID]curve.Scalar, len(partyIDs))
+	RBar := make(map[party.ID]curve.Point, len(partyIDs))
+	S := make(map[party.ID]curve.Point, len(partyIDs))
+	for _, id := range partyIDs {
+		sigmaShares[id] = sample.Scalar(rand.Reader, group)
+		RBar[id] = group.NewScalar().Set(mInv).Mul(sigmaShares[id]).Act(R)
+		S[id] = R
+	}
+	r := &sign2{
+		sign1: &sign1{
+			Helper:    helper,
+			PublicKey: c.PublicPoint(),
+			Message:   messageHash,
+			PreSignature: &ecdsa.PreSignature{
+				R:    R,
+				RBar: party.NewPointMap(RBar),
+				S:    party.NewPointMap(S),
+			},
+		},
+		SigmaShares: sigmaShares,
+	}
+	next, err := r.Finalize(nil)
+	require.NoError(t, err)
+	require.IsType(t, &round.Abort{}, next)
+	assert.True(t, errors.Is(next.(*round.Abort).Err, ecdsa.ErrZeroSignatureValue), "the error should be retryable")
+}
+
+func TestPresignOnlineSubset(t *testing.T) {
+	configs, partyIDs := test.GenerateConfig(group, 5, 2, mrand.New(mrand.NewSource(2)), nil)
+	online := party.NewIDSlice([]party.ID{partyIDs[0], partyIDs[2], partyIDs[4]})
+	absent := partyIDs[1]
+
+	_, err := StartPresign(configs[online[0]], online[:2], nil, nil)(nil)
+	assert.Error(t, err, "two signers aren't enough for a threshold of 2")
+	_, err = StartPresign(configs[absent], online, nil, nil)(nil)
+	assert.Error(t, err, "a party outside the online set can't presign with it")
+	_, err = StartPresign(configs[online[0]], append(online[:2:2], "outsider"), nil, nil)(nil)
+	assert.Error(t, err, "signers must be parties of the config")
+
+	rounds := make([]round.Session, 0, len(online))
+	for _, id := range online {
+		r, err := StartPresign(configs[id], online, nil, nil)(nil)
+		require.NoError(t, err, "round creation should not result in an error")
+		rounds = append(rounds, r)
+	}
+	for {
+		err, done := test.Rounds(rounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if done {
+			break
+		}
+	}
+	preSignatures := make(map[party.ID]*ecdsa.PreSignature, len(online))
+	for _, r := range rounds {
+		require.IsType(t, &round.Output{}, r)
+		preSignatures[r.SelfID()] = r.(*round.Output).Result.(*ecdsa.PreSignature)
+		assert.Equal(t, online, preSignatures[r.SelfID()].SignerIDs())
+	}
+
+	// the presignature is bound to the online set
+	_, err = StartPresignOnline(configs[absent], preSignatures[online[0]], messageHash, nil)(nil)
+	assert.Error(t, err)
+
+	rounds = rounds[:0]
+	for _, id := range online {
+		r, err := StartPresignOnline(configs[id], preSignatures[id], messageHash, nil)(nil)
+		require.NoError(t, err, "round creation should not result in an error")
+		rounds = append(rounds, r)
+	}
+	for {
+		err, done := test.Rounds(rounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if done {
+			break
+		}
+	}
+	for _, r := range rounds {
+		require.IsType(t, &round.Output{}, r)
+		signature := r.(*round.Output).Result.(*ecdsa.Signature)
+		assert.True(t, signature.Verify(configs[r.SelfID()].PublicPoint(), messageHash))
+	}
+}
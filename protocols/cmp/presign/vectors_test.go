@@ -0,0 +1,100 @@
+package presign
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	mrand "math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/ecdsa"
+	"github.com/koteld/multi-party-sig/pkg/pool"
+	"github.com/stretchr/testify/require"
+)
+
+var updateVectors = flag.Bool("update", false, "regenerate the golden test vectors in testdata")
+
+var vectorsPath = filepath.Join("testdata", "vectors.json")
+
+// TestVectors checks that configs generated from a fixed seed don't change across refactors,
+// and neither does the signature produced with them by the full presign and sign flow,
+// when the randomness of each party is seeded too.
+//
+// Run with -update to regenerate the vectors.
+func TestVectors(t *testing.T) {
+	cases := []struct {
+		N, T int
+		seed int64
+	}{
+		{3, 1, 2},
+		{5, 2, 3},
+	}
+
+	pl := pool.NewPool(0)
+	defer pl.TearDown()
+
+	got := make([]*test.Vector, 0, len(cases))
+	for _, c := range cases {
+		name := fmt.Sprintf("%d-of-%d", c.T+1, c.N)
+		configs, partyIDs := test.GenerateConfig(group, c.N, c.T, mrand.New(mrand.NewSource(c.seed)), pl)
+		v, err := test.NewVector(name, c.seed, c.T, configs)
+		require.NoError(t, err)
+		got = append(got, v)
+
+		signers := partyIDs[:c.T+1]
+		rounds := make([]round.Session, 0, len(signers))
+		for _, id := range signers {
+			r, err := StartPresign(configs[id], signers, messageHash, pl)(nil)
+			require.NoError(t, err)
+			r.(interface{ SetRand(io.Reader) }).SetRand(mrand.New(mrand.NewSource(c.seed + int64(len(rounds)) + 1)))
+			rounds = append(rounds, r)
+		}
+		for {
+			err, done := test.Rounds(rounds, nil)
+			require.NoError(t, err, "failed to process round")
+			if done {
+				break
+			}
+		}
+		public := configs[signers[0]].PublicPoint()
+		for _, r := range rounds {
+			require.IsType(t, &round.Output{}, r)
+			signature, ok := r.(*round.Output).Result.(*ecdsa.Signature)
+			require.True(t, ok, "result should *ecdsa.Signature")
+			require.True(t, signature.Verify(public, messageHash), "%s: invalid signature from %s", name, r.SelfID())
+		}
+		signature := rounds[0].(*round.Output).Result.(*ecdsa.Signature)
+		R, err := signature.R.MarshalBinary()
+		require.NoError(t, err)
+		S, err := signature.S.MarshalBinary()
+		require.NoError(t, err)
+		v.Signature = hex.EncodeToString(append(R, S...))
+	}
+
+	if *updateVectors {
+		data, err := json.MarshalIndent(got, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(vectorsPath, append(data, '\n'), 0644))
+		return
+	}
+
+	data, err := ioutil.ReadFile(vectorsPath)
+	require.NoError(t, err, "run with -update to generate the vectors")
+	var expected []*test.Vector
+	require.NoError(t, json.Unmarshal(data, &expected))
+	require.Len(t, expected, len(got))
+	var diff []string
+	for i := range got {
+		diff = append(diff, got[i].Diff(expected[i])...)
+	}
+	if len(diff) > 0 {
+		t.Fatalf("test vectors changed:\n%s", strings.Join(diff, "\n"))
+	}
+}
@@ -0,0 +1,103 @@
+package cmp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/pkg/ecdsa"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/pool"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+)
+
+// PresignBatch generates a batch of presignatures for one party, one Presign session at a time,
+// and keeps going without the parties which drop out partway through.
+//
+// Each session is started with Start, and its messages are exchanged by the caller as usual.
+// Once the session has finished, or has stalled because the parties returned by
+// protocol.MultiHandler.Pending have stopped responding for too long, it is passed to Finish.
+// A stalled session is stopped, and Finish returns the parties it was waiting for.
+//
+// These are only the parties this signer is waiting for: a party which reached some signers but not others
+// is pending for some of them only. So the signers must agree on the parties to exclude, by some means outside of
+// the protocol, and each pass the same set to Exclude, before the next session is started with the remaining signers.
+type PresignBatch struct {
+	config        *Config
+	signers       party.IDSlice
+	count         int
+	pl            *pool.Pool
+	preSignatures []*ecdsa.PreSignature
+}
+
+// NewPresignBatch returns a PresignBatch generating count presignatures with signers.
+func NewPresignBatch(config *Config, signers []party.ID, count int, pl *pool.Pool) *PresignBatch {
+	return &PresignBatch{
+		config:  config,
+		signers: party.NewIDSlice(signers),
+		count:   count,
+		pl:      pl,
+	}
+}
+
+// Start returns the handler of the next Presign session, which must be passed to Finish once it is over.
+//
+// sessionID must be different for every session, and agreed on by the remaining signers.
+func (b *PresignBatch) Start(sessionID []byte) (*protocol.MultiHandler, error) {
+	if b.Done() {
+		return nil, errors.New("cmp.PresignBatch: batch is complete")
+	}
+	return protocol.NewMultiHandler(Presign(b.config, b.signers, b.pl), sessionID)
+}
+
+// Finish records the outcome of the session of h.
+//
+// If the session finished, its presignature is added to the batch, and no parties are returned.
+// If it stalled, it is stopped, and the parties it was waiting for are returned,
+// as candidates for Exclude.
+// An error is returned if the session failed for another reason.
+func (b *PresignBatch) Finish(h *protocol.MultiHandler) ([]party.ID, error) {
+	result, err := h.Result()
+	if err == nil {
+		preSignature, ok := result.(*ecdsa.PreSignature)
+		if !ok {
+			return nil, fmt.Errorf("cmp.PresignBatch: unexpected result %T", result)
+		}
+		b.preSignatures = append(b.preSignatures, preSignature)
+		return nil, nil
+	}
+
+	pending := h.Pending()
+	if len(pending) == 0 {
+		return nil, err
+	}
+	h.Stop()
+	return pending, nil
+}
+
+// Exclude removes parties from the signers of the next sessions, with ExcludeSigners.
+//
+// excluded must be the same for all the remaining signers, which agree on it after a session stalled.
+// An error is returned if too few signers would remain.
+func (b *PresignBatch) Exclude(excluded []party.ID) error {
+	signers, err := ExcludeSigners(b.config, b.signers, excluded)
+	if err != nil {
+		return err
+	}
+	b.signers = signers
+	return nil
+}
+
+// Signers returns the signers of the next session.
+func (b *PresignBatch) Signers() party.IDSlice {
+	return b.signers
+}
+
+// Done returns true once the batch contains all the presignatures.
+func (b *PresignBatch) Done() bool {
+	return len(b.preSignatures) >= b.count
+}
+
+// PreSignatures returns the presignatures generated so far.
+func (b *PresignBatch) PreSignatures() []*ecdsa.PreSignature {
+	return b.preSignatures
+}
@@ -0,0 +1,121 @@
+package cmp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/koteld/multi-party-sig/pkg/ecdsa"
+)
+
+// PresignPool keeps track of the presignatures available to a party, along with the
+// presignatures which are still being generated.
+//
+// A presignature can only be used once, so Get removes it from the pool for good,
+// and it can never be added back.
+//
+// A PresignPool is safe for concurrent use.
+type PresignPool struct {
+	mtx       sync.Mutex
+	available []*ecdsa.PreSignature
+	// ids contains the ID of every presignature which was ever added, including consumed ones.
+	ids map[string]bool
+	// inFlight is the number of presignatures being generated.
+	inFlight int
+}
+
+// NewPresignPool returns an empty PresignPool.
+func NewPresignPool() *PresignPool {
+	return &PresignPool{ids: map[string]bool{}}
+}
+
+// Put adds presignatures to the pool.
+//
+// A presignature whose ID was already added is rejected, even if it has since been used,
+// and none of the presignatures are added in that case.
+func (p *PresignPool) Put(preSignatures ...*ecdsa.PreSignature) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.put(preSignatures)
+}
+
+func (p *PresignPool) put(preSignatures []*ecdsa.PreSignature) error {
+	seen := make(map[string]bool, len(preSignatures))
+	for i, preSignature := range preSignatures {
+		if preSignature == nil {
+			return fmt.Errorf("presign pool: preSignature %d is nil", i)
+		}
+		id := string(preSignature.ID)
+		if p.ids[id] || seen[id] {
+			return fmt.Errorf("presign pool: preSignature %d was already added", i)
+		}
+		seen[id] = true
+	}
+	for id := range seen {
+		p.ids[id] = true
+	}
+	p.available = append(p.available, preSignatures...)
+	return nil
+}
+
+// Get removes a presignature from the pool, returning nil if none are available.
+func (p *PresignPool) Get() *ecdsa.PreSignature {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if len(p.available) == 0 {
+		return nil
+	}
+	preSignature := p.available[0]
+	p.available[0] = nil
+	p.available = p.available[1:]
+	return preSignature
+}
+
+// Len returns the number of presignatures ready to be used.
+func (p *PresignPool) Len() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return len(p.available)
+}
+
+// BeginFill records that count presignatures are being generated.
+//
+// Every call must be matched by a call to EndFill with the same count, once the presign protocols have finished.
+func (p *PresignPool) BeginFill(count int) {
+	if count <= 0 {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.inFlight += count
+}
+
+// EndFill ends a fill started with BeginFill(count), adding the presignatures which were generated.
+//
+// Fewer than count presignatures may be passed, if some of the protocol executions failed.
+func (p *PresignPool) EndFill(count int, preSignatures ...*ecdsa.PreSignature) error {
+	if len(preSignatures) > count {
+		return errors.New("presign pool: more presignatures than were being filled")
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if count > p.inFlight {
+		return errors.New("presign pool: EndFill without a matching BeginFill")
+	}
+	p.inFlight -= count
+	return p.put(preSignatures)
+}
+
+// Shortfall returns how many more presignatures need to be generated for expectedDemand signatures.
+//
+// Both the presignatures in the pool and the ones being filled are counted,
+// but the ones which have been used are not.
+func (p *PresignPool) Shortfall(expectedDemand int) int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	shortfall := expectedDemand - len(p.available) - p.inFlight
+	if shortfall < 0 {
+		return 0
+	}
+	return shortfall
+}
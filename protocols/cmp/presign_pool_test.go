@@ -0,0 +1,73 @@
+package cmp
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/pkg/ecdsa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakePreSignatures(prefix string, n int) []*ecdsa.PreSignature {
+	preSignatures := make([]*ecdsa.PreSignature, n)
+	for i := range preSignatures {
+		preSignatures[i] = &ecdsa.PreSignature{ID: []byte(fmt.Sprintf("%s-%d", prefix, i))}
+	}
+	return preSignatures
+}
+
+func TestPresignPoolShortfall(t *testing.T) {
+	const (
+		initial = 10
+		getters = 6
+		fillers = 3
+		perFill = 4
+		demand  = 40
+	)
+	p := NewPresignPool()
+	require.NoError(t, p.Put(fakePreSignatures("initial", initial)...))
+	assert.Equal(t, demand-initial, p.Shortfall(demand))
+
+	// in flight presignatures count towards the demand
+	p.BeginFill(5)
+	assert.Equal(t, demand-initial-5, p.Shortfall(demand))
+	require.NoError(t, p.EndFill(5))
+	assert.Equal(t, demand-initial, p.Shortfall(demand))
+
+	var used int64
+	var wg sync.WaitGroup
+	wg.Add(getters + fillers)
+	for i := 0; i < getters; i++ {
+		go func() {
+			defer wg.Done()
+			if p.Get() != nil {
+				atomic.AddInt64(&used, 1)
+			}
+		}()
+	}
+	for i := 0; i < fillers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			p.BeginFill(perFill)
+			assert.NoError(t, p.EndFill(perFill, fakePreSignatures(fmt.Sprint("fill", i), perFill)...))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, getters, used)
+	available := initial + fillers*perFill - getters
+	assert.Equal(t, available, p.Len())
+	assert.Equal(t, demand-available, p.Shortfall(demand))
+	assert.Equal(t, 0, p.Shortfall(available-1))
+
+	// used presignatures can't be added back
+	preSignature := p.Get()
+	require.NotNil(t, preSignature)
+	assert.Error(t, p.Put(preSignature))
+	assert.Equal(t, demand-available+1, p.Shortfall(demand))
+
+	assert.Error(t, p.EndFill(1), "EndFill without BeginFill")
+}
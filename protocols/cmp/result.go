@@ -0,0 +1,52 @@
+package cmp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/pkg/ecdsa"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+)
+
+// ErrUnexpectedResult is returned when a handler's result isn't of the type which was asked for,
+// usually because it ran a different protocol.
+var ErrUnexpectedResult = errors.New("cmp: unexpected result type")
+
+// ConfigResult returns the result of a handler running Keygen or Refresh.
+func ConfigResult(h protocol.Handler) (*Config, error) {
+	r, err := h.Result()
+	if err != nil {
+		return nil, err
+	}
+	c, ok := r.(*Config)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected *cmp.Config, got %T", ErrUnexpectedResult, r)
+	}
+	return c, nil
+}
+
+// SignatureResult returns the result of a handler running Sign, SignOnce, or PresignOnline.
+func SignatureResult(h protocol.Handler) (*ecdsa.Signature, error) {
+	r, err := h.Result()
+	if err != nil {
+		return nil, err
+	}
+	sig, ok := r.(*ecdsa.Signature)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected *ecdsa.Signature, got %T", ErrUnexpectedResult, r)
+	}
+	return sig, nil
+}
+
+// PreSignatureResult returns the result of a handler running Presign.
+func PreSignatureResult(h protocol.Handler) (*ecdsa.PreSignature, error) {
+	r, err := h.Result()
+	if err != nil {
+		return nil, err
+	}
+	preSignature, ok := r.(*ecdsa.PreSignature)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected *ecdsa.PreSignature, got %T", ErrUnexpectedResult, r)
+	}
+	return preSignature, nil
+}
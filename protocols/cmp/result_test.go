@@ -0,0 +1,55 @@
+package cmp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/pkg/ecdsa"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resultHandler is a protocol.Handler which has already finished.
+type resultHandler struct {
+	result interface{}
+	err    error
+}
+
+func (h resultHandler) Result() (interface{}, error)   { return h.result, h.err }
+func (resultHandler) Listen() <-chan *protocol.Message { return nil }
+func (resultHandler) Stop()                            {}
+func (resultHandler) CanAccept(*protocol.Message) bool { return false }
+func (resultHandler) Accept(*protocol.Message)         {}
+
+func TestTypedResults(t *testing.T) {
+	config := &Config{}
+	signature := &ecdsa.Signature{}
+	preSignature := &ecdsa.PreSignature{}
+
+	c, err := ConfigResult(resultHandler{result: config})
+	require.NoError(t, err)
+	assert.Same(t, config, c)
+	sig, err := SignatureResult(resultHandler{result: signature})
+	require.NoError(t, err)
+	assert.Same(t, signature, sig)
+	presig, err := PreSignatureResult(resultHandler{result: preSignature})
+	require.NoError(t, err)
+	assert.Same(t, preSignature, presig)
+
+	_, err = ConfigResult(resultHandler{result: signature})
+	assert.ErrorIs(t, err, ErrUnexpectedResult)
+	_, err = SignatureResult(resultHandler{result: config})
+	assert.ErrorIs(t, err, ErrUnexpectedResult)
+	_, err = SignatureResult(resultHandler{result: []*ecdsa.Signature{signature}})
+	assert.ErrorIs(t, err, ErrUnexpectedResult, "SignBatch results are not a single signature")
+	_, err = PreSignatureResult(resultHandler{result: signature})
+	assert.ErrorIs(t, err, ErrUnexpectedResult)
+
+	// errors from the protocol are returned as is
+	protocolErr := errors.New("aborted")
+	_, err = ConfigResult(resultHandler{err: protocolErr})
+	assert.Equal(t, protocolErr, err)
+	_, err = SignatureResult(resultHandler{err: protocolErr})
+	assert.Equal(t, protocolErr, err)
+}
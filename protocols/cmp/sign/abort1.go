@@ -0,0 +1,220 @@
+package sign
+
+import (
+	"errors"
+
+	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
+	"github.com/koteld/multi-party-sig/pkg/paillier"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	zkdec "github.com/koteld/multi-party-sig/pkg/zk/dec"
+	zkmul "github.com/koteld/multi-party-sig/pkg/zk/mul"
+)
+
+var _ round.Round = (*abort1)(nil)
+
+// abort1 replaces round5 when Δ ≠ [δ]G, and finds the parties which broadcast a wrong δⱼ.
+//
+// Each party proves that δᵢ is the decryption of
+//
+//	Uᵢ = Hᵢ ⊕ ∑ⱼ (Dᵢⱼ ⊖ Fᵢⱼ) = encᵢ(γᵢ⋅kᵢ + ∑ⱼ (αᵢⱼ + βᵢⱼ)),
+//
+// where Hᵢ = γᵢ ⊙ Kᵢ is checked against Gᵢ with zkmul, and the decryption with zkdec.
+// The Dᵢⱼ and Fᵢⱼ were only exchanged between i and j, so only j can check that i reveals the right ones.
+type abort1 struct {
+	*round4
+
+	// U[j] = Uⱼ
+	U map[party.ID]*paillier.Ciphertext
+
+	// Culprits[j] is set once the proofs of j fail to verify.
+	Culprits map[party.ID]bool
+}
+
+type broadcastAbort1 struct {
+	round.NormalBroadcastContent
+	// H = Hᵢ = γᵢ ⊙ Kᵢ
+	H *paillier.Ciphertext
+	// MulProof proves that H = γᵢ ⊙ Kᵢ, for the γᵢ encrypted in Gᵢ
+	MulProof *zkmul.Proof
+	// DeltaD[j] = Dᵢⱼ
+	DeltaD map[party.ID]*paillier.Ciphertext
+	// DeltaF[j] = Fᵢⱼ
+	DeltaF map[party.ID]*paillier.Ciphertext
+}
+
+type messageAbort1 struct {
+	// DecProof proves that Uᵢ decrypts to δᵢ
+	DecProof *zkdec.Proof
+}
+
+// StoreBroadcastMessage implements round.BroadcastRound.
+//
+// - verify Hⱼ = γⱼ ⊙ Kⱼ
+// - check the ciphertexts exchanged with j
+// - compute Uⱼ = Hⱼ ⊕ ∑ₗ (Dⱼₗ ⊖ Fⱼₗ).
+func (r *abort1) StoreBroadcastMessage(msg round.Message) error {
+	from := msg.From
+	body, ok := msg.Content.(*broadcastAbort1)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.H == nil || body.MulProof == nil || body.DeltaD == nil || body.DeltaF == nil {
+		return round.ErrNilFields
+	}
+
+	public := r.Paillier[from]
+	if !public.ValidateCiphertexts(body.H) {
+		return errors.New("invalid H")
+	}
+	U := body.H.Clone()
+	minusOne := new(safenum.Int).SetNat(new(safenum.Nat).SetUint64(1)).Neg(1)
+	for _, l := range r.PartyIDs() {
+		if l == from {
+			continue
+		}
+		D, F := body.DeltaD[l], body.DeltaF[l]
+		if !public.ValidateCiphertexts(D, F) {
+			return errors.New("invalid D, F")
+		}
+		U.Add(public, D)
+		U.Add(public, F.Clone().Mul(public, minusOne))
+	}
+	r.U[from] = U
+
+	if !body.MulProof.Verify(r.Group(), r.HashForID(from), zkmul.Public{
+		X:      r.G[from],
+		Y:      r.K[from],
+		C:      body.H,
+		Prover: public,
+	}) {
+		r.Culprits[from] = true
+	}
+
+	// the ciphertexts we exchanged with from must be the ones we sent and received
+	self := r.SelfID()
+	if !body.DeltaD[self].Equal(r.DeltaDSent[from]) || !body.DeltaF[self].Equal(r.DeltaFReceived[from]) {
+		r.Culprits[from] = true
+	}
+	return nil
+}
+
+// VerifyMessage implements round.Round.
+//
+// - verify that Uⱼ decrypts to δⱼ.
+func (r *abort1) VerifyMessage(msg round.Message) error {
+	from, to := msg.From, msg.To
+	body, ok := msg.Content.(*messageAbort1)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.DecProof == nil {
+		return round.ErrNilFields
+	}
+
+	if !body.DecProof.Verify(r.HashForID(from), zkdec.Public{
+		C:      r.U[from],
+		X:      r.DeltaShares[from],
+		Prover: r.Paillier[from],
+		Aux:    r.Pedersen[to],
+	}) {
+		r.Culprits[from] = true
+	}
+	return nil
+}
+
+// StoreMessage implements round.Round.
+func (abort1) StoreMessage(round.Message) error { return nil }
+
+// Finalize implements round.Round
+//
+// - abort, blaming the parties whose proofs failed.
+func (r *abort1) Finalize(chan<- *round.Message) (round.Session, error) {
+	culprits := make([]party.ID, 0, len(r.Culprits))
+	for _, j := range r.OtherPartyIDs() {
+		if r.Culprits[j] {
+			culprits = append(culprits, j)
+		}
+	}
+	if len(culprits) == 0 {
+		return r.AbortRound(errors.New("abort1: computed Δ is inconsistent with [δ]G, but every δⱼ was proven")), nil
+	}
+	return r.AbortRound(errors.New("abort1: detected culprit"), culprits...), nil
+}
+
+// RoundNumber implements round.Content.
+func (messageAbort1) RoundNumber() round.Number { return 5 }
+
+// MessageContent implements round.Round.
+func (r *abort1) MessageContent() round.Content {
+	return &messageAbort1{DecProof: zkdec.Empty(r.Group())}
+}
+
+// RoundNumber implements round.Content.
+func (broadcastAbort1) RoundNumber() round.Number { return 5 }
+
+// BroadcastContent implements round.BroadcastRound.
+func (r *abort1) BroadcastContent() round.BroadcastContent { return &broadcastAbort1{} }
+
+// Number implements round.Round.
+func (abort1) Number() round.Number { return 5 }
+
+// startAbort1 proves that δᵢ was computed correctly, and moves to abort1.
+func (r *round4) startAbort1(out chan<- *round.Message) (round.Session, error) {
+	public := r.Paillier[r.SelfID()]
+
+	// Hᵢ = γᵢ ⊙ Kᵢ
+	H := r.K[r.SelfID()].Clone().Mul(public, r.GammaShare)
+	nonce := H.Randomize(public, sample.UnitModN(r.Rand(), public.N()))
+	mulProof := zkmul.NewProofFromSource(r.Rand(), r.Group(), r.HashForID(r.SelfID()), zkmul.Public{
+		X:      r.G[r.SelfID()],
+		Y:      r.K[r.SelfID()],
+		C:      H,
+		Prover: public,
+	}, zkmul.Private{
+		X:    r.GammaShare,
+		Rho:  nonce,
+		RhoX: r.GNonce,
+	})
+	if err := r.BroadcastMessage(out, &broadcastAbort1{
+		H:        H,
+		MulProof: mulProof,
+		DeltaD:   r.DeltaD,
+		DeltaF:   r.DeltaF,
+	}); err != nil {
+		return r, err
+	}
+
+	// Uᵢ = Hᵢ ⊕ ∑ⱼ (Dᵢⱼ ⊖ Fᵢⱼ)
+	U := H.Clone()
+	minusOne := new(safenum.Int).SetNat(new(safenum.Nat).SetUint64(1)).Neg(1)
+	for _, j := range r.OtherPartyIDs() {
+		U.Add(public, r.DeltaD[j])
+		U.Add(public, r.DeltaF[j].Clone().Mul(public, minusOne))
+	}
+	y, rho, err := r.SecretPaillier.DecWithRandomness(U)
+	if err != nil {
+		return r, err
+	}
+	for _, j := range r.OtherPartyIDs() {
+		proof := zkdec.NewProofFromSource(r.Rand(), r.Group(), r.HashForID(r.SelfID()), zkdec.Public{
+			C:      U,
+			X:      r.Group().NewScalar().SetNat(y.Mod(r.Group().Order())),
+			Prover: public,
+			Aux:    r.Pedersen[j],
+		}, zkdec.Private{
+			Y:   y,
+			Rho: rho,
+		})
+		if err := r.SendMessage(out, &messageAbort1{DecProof: proof}, j); err != nil {
+			return r, err
+		}
+	}
+
+	return &abort1{
+		round4:   r,
+		U:        map[party.ID]*paillier.Ciphertext{r.SelfID(): U},
+		Culprits: map[party.ID]bool{},
+	}, nil
+}
@@ -1,7 +1,8 @@
 package sign
 
 import (
-	"crypto/rand"
+	"fmt"
+	"io"
 
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
@@ -10,6 +11,7 @@ import (
 	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/pedersen"
 	zkenc "github.com/koteld/multi-party-sig/pkg/zk/enc"
+	"github.com/zeebo/blake3"
 )
 
 var _ round.Round = (*round1)(nil)
@@ -26,6 +28,10 @@ type round1 struct {
 	ECDSA          map[party.ID]curve.Point
 
 	Message []byte
+	// MessageCommitment = H(Message) is broadcast in round 1, if the signers should check they agree on the message.
+	MessageCommitment []byte
+	// ExtraEntropy is mixed into the randomness of our nonce shares, if set.
+	ExtraEntropy []byte
 }
 
 // VerifyMessage implements round.Round.
@@ -49,25 +55,31 @@ func (round1) StoreMessage(round.Message) error { return nil }
 // In the next round, we send a hash of all the {Kⱼ,Gⱼ}ⱼ.
 // In two rounds, we compare the hashes received and if they are different then we abort.
 func (r *round1) Finalize(out chan<- *round.Message) (round.Session, error) {
+	source, err := nonceSource(r.Rand(), r.ExtraEntropy)
+	if err != nil {
+		return r, err
+	}
+
 	// γᵢ <- 𝔽,
 	// Γᵢ = [γᵢ]⋅G
-	GammaShare, BigGammaShare := sample.ScalarPointPair(rand.Reader, r.Group())
+	GammaShare, BigGammaShare := sample.ScalarPointPair(source, r.Group())
 	// Gᵢ = Encᵢ(γᵢ;νᵢ)
-	G, GNonce := r.Paillier[r.SelfID()].Enc(curve.MakeInt(GammaShare))
+	G, GNonce := r.Paillier[r.SelfID()].EncFromSource(r.Rand(), curve.MakeInt(GammaShare))
 
 	// kᵢ <- 𝔽,
-	KShare := sample.Scalar(rand.Reader, r.Group())
+	KShare := sample.Scalar(source, r.Group())
 	// Kᵢ = Encᵢ(kᵢ;ρᵢ)
-	K, KNonce := r.Paillier[r.SelfID()].Enc(curve.MakeInt(KShare))
+	K, KNonce := r.Paillier[r.SelfID()].EncFromSource(r.Rand(), curve.MakeInt(KShare))
 
 	otherIDs := r.OtherPartyIDs()
-	broadcastMsg := broadcast2{K: K, G: G}
+	broadcastMsg := broadcast2{K: K, G: G, MessageCommitment: r.MessageCommitment}
 	if err := r.BroadcastMessage(out, &broadcastMsg); err != nil {
 		return r, err
 	}
+	sources := sample.Split(r.Rand(), len(otherIDs))
 	errors := r.Pool.Parallelize(len(otherIDs), func(i int) interface{} {
 		j := otherIDs[i]
-		proof := zkenc.NewProof(r.Group(), r.HashForID(r.SelfID()), zkenc.Public{
+		proof := zkenc.NewProofFromSource(sources[i], r.Group(), r.HashForID(r.SelfID()), zkenc.Public{
 			K:      K,
 			Prover: r.Paillier[r.SelfID()],
 			Aux:    r.Pedersen[j],
@@ -91,14 +103,15 @@ func (r *round1) Finalize(out chan<- *round.Message) (round.Session, error) {
 	}
 
 	return &round2{
-		round1:        r,
-		K:             map[party.ID]*paillier.Ciphertext{r.SelfID(): K},
-		G:             map[party.ID]*paillier.Ciphertext{r.SelfID(): G},
-		BigGammaShare: map[party.ID]curve.Point{r.SelfID(): BigGammaShare},
-		GammaShare:    curve.MakeInt(GammaShare),
-		KShare:        KShare,
-		KNonce:        KNonce,
-		GNonce:        GNonce,
+		round1:             r,
+		K:                  map[party.ID]*paillier.Ciphertext{r.SelfID(): K},
+		G:                  map[party.ID]*paillier.Ciphertext{r.SelfID(): G},
+		BigGammaShare:      map[party.ID]curve.Point{r.SelfID(): BigGammaShare},
+		MessageCommitments: map[party.ID][]byte{},
+		GammaShare:         curve.MakeInt(GammaShare),
+		KShare:             KShare,
+		KNonce:             KNonce,
+		GNonce:             GNonce,
 	}, nil
 }
 
@@ -107,3 +120,25 @@ func (round1) MessageContent() round.Content { return nil }
 
 // Number implements round.Round.
 func (round1) Number() round.Number { return 1 }
+
+// nonceSource returns the source of randomness for our shares of the nonce.
+//
+// With extra entropy, 32 bytes of the session's randomness are hashed together with it,
+// and the result is expanded with blake3.
+func nonceSource(system io.Reader, extra []byte) (io.Reader, error) {
+	if extra == nil {
+		return system, nil
+	}
+	material := make([]byte, 32, 32+len(extra))
+	if _, err := io.ReadFull(system, material); err != nil {
+		return nil, fmt.Errorf("failed to sample nonce: %w", err)
+	}
+	material = append(material, extra...)
+	key := make([]byte, 32)
+	blake3.DeriveKey("multi-party-sig cmp sign nonce entropy", material, key)
+	h, err := blake3.NewKeyed(key)
+	if err != nil {
+		return nil, err
+	}
+	return h.Digest(), nil
+}
@@ -1,12 +1,15 @@
 package sign
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/mta"
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
 	"github.com/koteld/multi-party-sig/pkg/paillier"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	zkenc "github.com/koteld/multi-party-sig/pkg/zk/enc"
@@ -26,6 +29,9 @@ type round2 struct {
 	// BigGammaShare[j] = Γⱼ = [γⱼ]•G
 	BigGammaShare map[party.ID]curve.Point
 
+	// MessageCommitments[j] = H(m) as committed to by party j, when the signers check that they agree on the message.
+	MessageCommitments map[party.ID][]byte
+
 	// GammaShare = γᵢ <- 𝔽
 	GammaShare *safenum.Int
 	// KShare = kᵢ  <- 𝔽
@@ -45,6 +51,8 @@ type broadcast2 struct {
 	K *paillier.Ciphertext
 	// G = Gᵢ
 	G *paillier.Ciphertext
+	// MessageCommitment = H(m), when the signers check that they agree on the message.
+	MessageCommitment []byte `cbor:",omitempty"`
 }
 
 type message2 struct {
@@ -67,6 +75,7 @@ func (r *round2) StoreBroadcastMessage(msg round.Message) error {
 
 	r.K[from] = body.K
 	r.G[from] = body.G
+	r.MessageCommitments[from] = body.MessageCommitment
 
 	return nil
 }
@@ -103,7 +112,16 @@ func (round2) StoreMessage(round.Message) error { return nil }
 // Finalize implements round.Round
 //
 // - compute Hash(ssid, K₁, G₁, …, Kₙ, Gₙ).
+// - check that all signers committed to the same message.
 func (r *round2) Finalize(out chan<- *round.Message) (round.Session, error) {
+	// a different commitment doesn't show which of the two signers was given the wrong message,
+	// so we abort without a culprit.
+	for _, j := range r.OtherPartyIDs() {
+		if !bytes.Equal(r.MessageCommitments[j], r.MessageCommitment) {
+			return r.AbortRound(fmt.Errorf("%s committed to a different message", j)), nil
+		}
+	}
+
 	if err := r.BroadcastMessage(out, &broadcast3{
 		BigGammaShare: r.BigGammaShare[r.SelfID()],
 	}); err != nil {
@@ -114,19 +132,22 @@ func (r *round2) Finalize(out chan<- *round.Message) (round.Session, error) {
 	type mtaOut struct {
 		err       error
 		DeltaBeta *safenum.Int
+		DeltaD    *paillier.Ciphertext
+		DeltaF    *paillier.Ciphertext
 		ChiBeta   *safenum.Int
 	}
+	sources := sample.Split(r.Rand(), len(otherIDs))
 	mtaOuts := r.Pool.Parallelize(len(otherIDs), func(i int) interface{} {
 		j := otherIDs[i]
 
-		DeltaBeta, DeltaD, DeltaF, DeltaProof := mta.ProveAffG(r.Group(), r.HashForID(r.SelfID()),
+		DeltaBeta, DeltaD, DeltaF, DeltaProof := mta.ProveAffG(sources[i], r.Group(), r.HashForID(r.SelfID()),
 			r.GammaShare, r.BigGammaShare[r.SelfID()], r.K[j],
 			r.SecretPaillier, r.Paillier[j], r.Pedersen[j])
-		ChiBeta, ChiD, ChiF, ChiProof := mta.ProveAffG(r.Group(),
+		ChiBeta, ChiD, ChiF, ChiProof := mta.ProveAffG(sources[i], r.Group(),
 			r.HashForID(r.SelfID()), curve.MakeInt(r.SecretECDSA), r.ECDSA[r.SelfID()], r.K[j],
 			r.SecretPaillier, r.Paillier[j], r.Pedersen[j])
 
-		proof := zklogstar.NewProof(r.Group(), r.HashForID(r.SelfID()),
+		proof := zklogstar.NewProofFromSource(sources[i], r.Group(), r.HashForID(r.SelfID()),
 			zklogstar.Public{
 				C:      r.G[r.SelfID()],
 				X:      r.BigGammaShare[r.SelfID()],
@@ -149,11 +170,15 @@ func (r *round2) Finalize(out chan<- *round.Message) (round.Session, error) {
 		return mtaOut{
 			err:       err,
 			DeltaBeta: DeltaBeta,
+			DeltaD:    DeltaD,
+			DeltaF:    DeltaF,
 			ChiBeta:   ChiBeta,
 		}
 	})
 	DeltaShareBetas := make(map[party.ID]*safenum.Int, len(otherIDs)-1)
 	ChiShareBetas := make(map[party.ID]*safenum.Int, len(otherIDs)-1)
+	DeltaDSent := make(map[party.ID]*paillier.Ciphertext, len(otherIDs))
+	DeltaF := make(map[party.ID]*paillier.Ciphertext, len(otherIDs))
 	for idx, mtaOutRaw := range mtaOuts {
 		j := otherIDs[idx]
 		m := mtaOutRaw.(mtaOut)
@@ -162,6 +187,8 @@ func (r *round2) Finalize(out chan<- *round.Message) (round.Session, error) {
 		}
 		DeltaShareBetas[j] = m.DeltaBeta
 		ChiShareBetas[j] = m.ChiBeta
+		DeltaDSent[j] = m.DeltaD
+		DeltaF[j] = m.DeltaF
 	}
 
 	return &round3{
@@ -170,6 +197,10 @@ func (r *round2) Finalize(out chan<- *round.Message) (round.Session, error) {
 		ChiShareBeta:    ChiShareBetas,
 		DeltaShareAlpha: map[party.ID]*safenum.Int{},
 		ChiShareAlpha:   map[party.ID]*safenum.Int{},
+		DeltaD:          map[party.ID]*paillier.Ciphertext{},
+		DeltaF:          DeltaF,
+		DeltaDSent:      DeltaDSent,
+		DeltaFReceived:  map[party.ID]*paillier.Ciphertext{},
 	}, nil
 }
 
@@ -7,6 +7,7 @@ import (
 	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
 	"github.com/koteld/multi-party-sig/pkg/paillier"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	zkaffg "github.com/koteld/multi-party-sig/pkg/zk/affg"
@@ -26,6 +27,17 @@ type round3 struct {
 	ChiShareAlpha map[party.ID]*safenum.Int
 	// ChiShareBeta[j] = β̂ᵢⱼ
 	ChiShareBeta map[party.ID]*safenum.Int
+
+	// The ciphertexts of the δ MtA are kept to prove δᵢ in abort1.
+	//
+	// DeltaD[j] = Dᵢⱼ = encᵢ(αᵢⱼ), received from j
+	DeltaD map[party.ID]*paillier.Ciphertext
+	// DeltaF[j] = Fᵢⱼ = encᵢ(-βᵢⱼ), sent to j
+	DeltaF map[party.ID]*paillier.Ciphertext
+	// DeltaDSent[j] = Dⱼᵢ = encⱼ(αⱼᵢ), sent to j
+	DeltaDSent map[party.ID]*paillier.Ciphertext
+	// DeltaFReceived[j] = Fⱼᵢ = encⱼ(-βⱼᵢ), received from j
+	DeltaFReceived map[party.ID]*paillier.Ciphertext
 }
 
 type message3 struct {
@@ -124,6 +136,8 @@ func (r *round3) StoreMessage(msg round.Message) error {
 
 	r.DeltaShareAlpha[from] = DeltaShareAlpha
 	r.ChiShareAlpha[from] = ChiShareAlpha
+	r.DeltaD[from] = body.DeltaD
+	r.DeltaFReceived[from] = body.DeltaF
 
 	return nil
 }
@@ -175,10 +189,11 @@ func (r *round3) Finalize(out chan<- *round.Message) (round.Session, error) {
 	}
 
 	otherIDs := r.OtherPartyIDs()
+	sources := sample.Split(r.Rand(), len(otherIDs))
 	errs := r.Pool.Parallelize(len(otherIDs), func(i int) interface{} {
 		j := otherIDs[i]
 
-		proofLog := zklogstar.NewProof(r.Group(), r.HashForID(r.SelfID()), zklogstar.Public{
+		proofLog := zklogstar.NewProofFromSource(sources[i], r.Group(), r.HashForID(r.SelfID()), zklogstar.Public{
 			C:      r.K[r.SelfID()],
 			X:      BigDeltaShare,
 			G:      Gamma,
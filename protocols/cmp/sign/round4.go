@@ -87,7 +87,7 @@ func (round4) StoreMessage(round.Message) error {
 //
 // - set δ = ∑ⱼ δⱼ
 // - set Δ = ∑ⱼ Δⱼ
-// - verify Δ = [δ]G
+// - verify Δ = [δ]G, or move to abort1 to find who sent a wrong δⱼ
 // - compute σᵢ = rχᵢ + kᵢm.
 func (r *round4) Finalize(out chan<- *round.Message) (round.Session, error) {
 	// δ = ∑ⱼ δⱼ
@@ -102,9 +102,12 @@ func (r *round4) Finalize(out chan<- *round.Message) (round.Session, error) {
 	// Δ == [δ]G
 	deltaComputed := Delta.ActOnBase()
 	if !deltaComputed.Equal(BigDelta) {
-		return r.AbortRound(errors.New("computed Δ is inconsistent with [δ]G")), nil
+		return r.startAbort1(out)
 	}
 
+	// R is never sent by any party: it is recomputed here from Γ = ∑ⱼ Γⱼ,
+	// where each Γⱼ was checked against the encryption Gⱼ by the proofs of round 3,
+	// so a party broadcasting another Γⱼ is identified before r is used in σᵢ.
 	deltaInv := r.Group().NewScalar().Set(Delta).Invert() // δ⁻¹
 	BigR := deltaInv.Act(r.Gamma)                         // R = [δ⁻¹] Γ
 	R := BigR.XScalar()                                   // r = R|ₓ
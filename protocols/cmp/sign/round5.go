@@ -62,6 +62,7 @@ func (round5) StoreMessage(round.Message) error { return nil }
 // Finalize implements round.Round
 //
 // - compute σ = ∑ⱼ σⱼ
+// - check that r ≠ 0 and σ ≠ 0
 // - verify signature.
 func (r *round5) Finalize(chan<- *round.Message) (round.Session, error) {
 	// compute σ = ∑ⱼ σⱼ
@@ -75,6 +76,11 @@ func (r *round5) Finalize(chan<- *round.Message) (round.Session, error) {
 		S: Sigma,
 	}
 
+	// r = 0 or s = 0 can only be fixed by signing again with a new nonce
+	if err := signature.CheckNonZero(); err != nil {
+		return r.AbortRound(err), nil
+	}
+
 	if !signature.Verify(r.PublicKey, r.Message) {
 		return r.AbortRound(errors.New("failed to validate signature")), nil
 	}
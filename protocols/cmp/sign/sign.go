@@ -3,11 +3,13 @@ package sign
 import (
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/internal/types"
+	"github.com/koteld/multi-party-sig/pkg/ecdsa"
+	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
-	"github.com/koteld/multi-party-sig/pkg/math/polynomial"
 	"github.com/koteld/multi-party-sig/pkg/paillier"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/pedersen"
@@ -18,18 +20,58 @@ import (
 
 // protocolSignID for the "3 round" variant using echo broadcast.
 const (
-	protocolSignID                  = "cmp/sign"
-	protocolSignRounds round.Number = 5
+	protocolSignID                       = "cmp/sign"
+	protocolSignCommittedID              = "cmp/sign-committed-message"
+	protocolSignRounds      round.Number = 5
 )
 
+// extraEntropySize is the number of bytes read from Options.ExtraEntropy.
+const extraEntropySize = 32
+
+// Options modify the behavior of a signing session.
+type Options struct {
+	// CommitMessage makes every signer include a commitment to the message in its first broadcast.
+	// If a signer was given a different message, the others abort as soon as they see its commitment.
+	// The abort has no culprit: a mismatch only shows that two signers were given different messages,
+	// not which of them was given the wrong one.
+	//
+	// By default, the message is part of the session's hash state instead,
+	// so that signers with different messages can't communicate, and the session doesn't complete.
+	CommitMessage bool
+	// ExtraEntropy is an additional source of randomness, such as an HSM, which is mixed with the session's
+	// randomness to sample our shares kᵢ, γᵢ of the nonce.
+	// The nonce then stays unpredictable as long as one of the two sources is.
+	//
+	// By default, only the session's randomness is used, which is crypto/rand unless set with protocol.WithRandomness.
+	ExtraEntropy io.Reader
+	// AllowZeroHash allows signing a message hash whose bytes are all zero.
+	//
+	// By default, such a hash is rejected, since it usually comes from a message that was never hashed.
+	AllowZeroHash bool
+}
+
 func StartSign(config *config.Config, signers []party.ID, message []byte, pl *pool.Pool) protocol.StartFunc {
+	return StartSignWithOptions(config, signers, message, pl, Options{})
+}
+
+// StartSignWithOptions is like StartSign, with the behavior modified by options.
+func StartSignWithOptions(config *config.Config, signers []party.ID, message []byte, pl *pool.Pool, options Options) protocol.StartFunc {
 	return func(sessionID []byte) (round.Session, error) {
+		if err := config.CheckNotRenounced(); err != nil {
+			return nil, fmt.Errorf("sign.Create: %w", err)
+		}
+		if err := ecdsa.CheckCurve(config.Group); err != nil {
+			return nil, fmt.Errorf("sign.Create: %w", err)
+		}
 		group := config.Group
 
 		// this could be used to indicate a pre-signature later on
 		if len(message) == 0 {
 			return nil, errors.New("sign.Create: message is nil")
 		}
+		if !options.AllowZeroHash && isZeroHash(message) {
+			return nil, errors.New("sign.Create: message hash is all zero, set Options.AllowZeroHash to sign it anyway")
+		}
 
 		info := round.Info{
 			ProtocolID:       protocolSignID,
@@ -40,7 +82,24 @@ func StartSign(config *config.Config, signers []party.ID, message []byte, pl *po
 			Group:            config.Group,
 		}
 
-		helper, err := round.NewSession(info, sessionID, pl, config, types.SigningMessage(message))
+		// the message is either part of the session, or committed to in round 1
+		var sessionMessage hash.WriterToWithDomain = types.SigningMessage(message)
+		var messageCommitment []byte
+		if options.CommitMessage {
+			info.ProtocolID = protocolSignCommittedID
+			sessionMessage = nil
+			messageCommitment = hash.New(types.SigningMessage(message)).Sum()
+		}
+
+		var extraEntropy []byte
+		if options.ExtraEntropy != nil {
+			extraEntropy = make([]byte, extraEntropySize)
+			if _, err := io.ReadFull(options.ExtraEntropy, extraEntropy); err != nil {
+				return nil, fmt.Errorf("sign.Create: failed to read extra entropy: %w", err)
+			}
+		}
+
+		helper, err := round.NewSession(info, sessionID, pl, config, sessionMessage)
 		if err != nil {
 			return nil, fmt.Errorf("sign.Create: %w", err)
 		}
@@ -55,7 +114,7 @@ func StartSign(config *config.Config, signers []party.ID, message []byte, pl *po
 		Paillier := make(map[party.ID]*paillier.PublicKey, T)
 		Pedersen := make(map[party.ID]*pedersen.Parameters, T)
 		PublicKey := group.NewPoint()
-		lagrange := polynomial.Lagrange(group, signers)
+		lagrange := config.Lagrange(signers)
 		// Scale own secret
 		SecretECDSA := group.NewScalar().Set(lagrange[config.ID]).Mul(config.ECDSA)
 		SecretPaillier := config.Paillier
@@ -69,14 +128,26 @@ func StartSign(config *config.Config, signers []party.ID, message []byte, pl *po
 		}
 
 		return &round1{
-			Helper:         helper,
-			PublicKey:      PublicKey,
-			SecretECDSA:    SecretECDSA,
-			SecretPaillier: SecretPaillier,
-			Paillier:       Paillier,
-			Pedersen:       Pedersen,
-			ECDSA:          ECDSA,
-			Message:        message,
+			Helper:            helper,
+			PublicKey:         PublicKey,
+			SecretECDSA:       SecretECDSA,
+			SecretPaillier:    SecretPaillier,
+			Paillier:          Paillier,
+			Pedersen:          Pedersen,
+			ECDSA:             ECDSA,
+			Message:           message,
+			MessageCommitment: messageCommitment,
+			ExtraEntropy:      extraEntropy,
 		}, nil
 	}
 }
+
+// isZeroHash returns true if every byte of messageHash is zero.
+func isZeroHash(messageHash []byte) bool {
+	for _, b := range messageHash {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -1,14 +1,21 @@
 package sign
 
 import (
+	"bytes"
+	"errors"
 	mrand "math/rand"
 	"testing"
 
+	"github.com/cronokirby/safenum"
+	"github.com/fxamacker/cbor/v2"
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/internal/test"
 	"github.com/koteld/multi-party-sig/pkg/ecdsa"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
+	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/pool"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/sha3"
@@ -57,3 +64,326 @@ func TestRound(t *testing.T) {
 		assert.True(t, signature.Verify(publicPoint, messageHash), "expected valid signature")
 	}
 }
+
+func TestSignCommitMessage(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 3
+	T := N - 1
+	configs, partyIDs := test.GenerateConfig(group, N, T, mrand.New(mrand.NewSource(1)), nil)
+
+	messageHash := make([]byte, 64)
+	sha3.ShakeSum128(messageHash, []byte("hello"))
+	otherHash := make([]byte, 64)
+	sha3.ShakeSum128(otherHash, []byte("goodbye"))
+
+	// with the same message, the signers complete as usual
+	rounds := make([]round.Session, 0, N)
+	for _, id := range partyIDs {
+		r, err := StartSignWithOptions(configs[id], partyIDs, messageHash, nil, Options{CommitMessage: true})(nil)
+		require.NoError(t, err)
+		rounds = append(rounds, r)
+	}
+	for {
+		err, done := test.Rounds(rounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if done {
+			break
+		}
+	}
+	for _, r := range rounds {
+		require.IsType(t, &round.Output{}, r, "expected result round")
+		signature := r.(*round.Output).Result.(*ecdsa.Signature)
+		assert.True(t, signature.Verify(configs[r.SelfID()].PublicPoint(), messageHash), "expected valid signature")
+	}
+
+	// the last signer was given a different message, which every signer notices,
+	// but without knowing which of the two messages is the right one
+	liar := partyIDs[N-1]
+	rounds = rounds[:0]
+	for _, id := range partyIDs {
+		m := messageHash
+		if id == liar {
+			m = otherHash
+		}
+		r, err := StartSignWithOptions(configs[id], partyIDs, m, nil, Options{CommitMessage: true})(nil)
+		require.NoError(t, err)
+		rounds = append(rounds, r)
+	}
+	for {
+		err, done := test.Rounds(rounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if done {
+			break
+		}
+	}
+	for _, r := range rounds {
+		require.IsType(t, &round.Abort{}, r, "expected abort round")
+		abort := r.(*round.Abort)
+		assert.Empty(t, abort.Culprits)
+		assert.Contains(t, abort.Err.Error(), "committed to a different message")
+	}
+
+	// without CommitMessage, the broadcast is encoded as before
+	data, err := cbor.Marshal(&broadcast2{})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "MessageCommitment")
+}
+
+func TestNonceSource(t *testing.T) {
+	group := curve.Secp256k1{}
+	system := func() *mrand.Rand { return mrand.New(mrand.NewSource(1)) }
+	nonce := func(extra []byte) curve.Scalar {
+		source, err := nonceSource(system(), extra)
+		require.NoError(t, err)
+		return sample.Scalar(source, group)
+	}
+
+	assert.True(t, nonce(nil).Equal(nonce(nil)), "without extra entropy, the system source should be used")
+	a, b := nonce([]byte("extra entropy a")), nonce([]byte("extra entropy b"))
+	assert.False(t, a.Equal(b), "different extra entropy should give a different nonce")
+	assert.False(t, a.Equal(nonce(nil)))
+	assert.True(t, a.Equal(nonce([]byte("extra entropy a"))))
+}
+
+func TestSignExtraEntropy(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 2
+	T := N - 1
+	configs, partyIDs := test.GenerateConfig(group, N, T, mrand.New(mrand.NewSource(1)), nil)
+
+	messageHash := make([]byte, 64)
+	sha3.ShakeSum128(messageHash, []byte("hello"))
+
+	sign := func(entropy byte) *ecdsa.Signature {
+		rounds := make([]round.Session, 0, N)
+		for _, id := range partyIDs {
+			extra := bytes.Repeat([]byte{entropy}, extraEntropySize)
+			r, err := StartSignWithOptions(configs[id], partyIDs, messageHash, nil, Options{ExtraEntropy: bytes.NewReader(extra)})(nil)
+			require.NoError(t, err)
+			rounds = append(rounds, r)
+		}
+		for {
+			err, done := test.Rounds(rounds, nil)
+			require.NoError(t, err, "failed to process round")
+			if done {
+				break
+			}
+		}
+		signature := rounds[0].(*round.Output).Result.(*ecdsa.Signature)
+		assert.True(t, signature.Verify(configs[partyIDs[0]].PublicPoint(), messageHash), "expected valid signature")
+		return signature
+	}
+
+	a, b := sign(1), sign(2)
+	assert.False(t, a.R.Equal(b.R), "different extra entropy should give different nonces")
+
+	// the extra entropy must have enough bytes
+	_, err := StartSignWithOptions(configs[partyIDs[0]], partyIDs, messageHash, nil, Options{ExtraEntropy: bytes.NewReader([]byte("short"))})(nil)
+	assert.Error(t, err)
+}
+
+func TestSignZeroHash(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 2
+	T := N - 1
+	configs, partyIDs := test.GenerateConfig(group, N, T, mrand.New(mrand.NewSource(1)), nil)
+
+	zeroHash := make([]byte, 32)
+	_, err := StartSign(configs[partyIDs[0]], partyIDs, zeroHash, nil)(nil)
+	assert.Error(t, err, "a zero hash should be rejected by default")
+
+	rounds := make([]round.Session, 0, N)
+	for _, id := range partyIDs {
+		r, err := StartSignWithOptions(configs[id], partyIDs, zeroHash, nil, Options{AllowZeroHash: true})(nil)
+		require.NoError(t, err)
+		rounds = append(rounds, r)
+	}
+	for {
+		err, done := test.Rounds(rounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if done {
+			break
+		}
+	}
+	signature := rounds[0].(*round.Output).Result.(*ecdsa.Signature)
+	assert.True(t, signature.Verify(configs[partyIDs[0]].PublicPoint(), zeroHash), "expected valid signature")
+}
+
+// p256 stands in for a curve without signing support.
+type p256 struct {
+	curve.Secp256k1
+}
+
+func (p256) Name() string { return "P-256" }
+
+func TestSignUnsupportedCurve(t *testing.T) {
+	group := curve.Secp256k1{}
+	configs, partyIDs := test.GenerateConfig(group, 2, 1, mrand.New(mrand.NewSource(1)), nil)
+	c := *configs[partyIDs[0]]
+	c.Group = p256{}
+	_, err := StartSign(&c, partyIDs, make([]byte, 32), nil)(nil)
+	assert.True(t, errors.Is(err, ecdsa.ErrUnsupportedCurve), "expected ErrUnsupportedCurve, got %v", err)
+}
+
+func TestSignForgedGammaShare(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 3
+	T := N - 1
+	configs, partyIDs := test.GenerateConfig(group, N, T, mrand.New(mrand.NewSource(1)), nil)
+	messageHash := make([]byte, 64)
+	sha3.ShakeSum128(messageHash, []byte("hello"))
+
+	handlers := make(map[party.ID]*protocol.MultiHandler, N)
+	for _, id := range partyIDs {
+		h, err := protocol.NewMultiHandler(StartSign(configs[id], partyIDs, messageHash, nil), nil)
+		require.NoError(t, err)
+		handlers[id] = h
+	}
+
+	// the malicious party broadcasts Γⱼ + G instead of Γⱼ, which would shift R = δ⁻¹⋅∑ⱼΓⱼ
+	malicious := partyIDs[1]
+	forge := func(msg *protocol.Message) {
+		content := &broadcast3{BigGammaShare: group.NewPoint()}
+		require.NoError(t, cbor.Unmarshal(msg.Data, content))
+		content.BigGammaShare = content.BigGammaShare.Add(group.NewBasePoint())
+		data, err := cbor.Marshal(content)
+		require.NoError(t, err)
+		msg.Data = data
+	}
+
+	var pending []*protocol.Message
+	drain := func(id party.ID) {
+		for {
+			select {
+			case msg, ok := <-handlers[id].Listen():
+				if !ok {
+					return
+				}
+				if msg.From == malicious && msg.Broadcast && msg.RoundNumber == 3 {
+					forge(msg)
+				}
+				pending = append(pending, msg)
+			default:
+				return
+			}
+		}
+	}
+	for _, id := range partyIDs {
+		drain(id)
+	}
+	for len(pending) > 0 {
+		msg := pending[0]
+		pending = pending[1:]
+		for _, id := range partyIDs {
+			if msg.IsFor(id) {
+				handlers[id].Accept(msg)
+				drain(id)
+			}
+		}
+	}
+
+	for _, id := range partyIDs {
+		if id == malicious {
+			continue
+		}
+		_, err := handlers[id].Result()
+		var protocolErr protocol.Error
+		require.True(t, errors.As(err, &protocolErr), "expected a protocol.Error, got %v", err)
+		assert.Equal(t, []party.ID{malicious}, protocolErr.Culprits)
+	}
+}
+
+// deltaRule makes malicious broadcast δᵢ + 1 in round 3, and use it as its own share in round 4.
+type deltaRule struct {
+	malicious party.ID
+}
+
+func (deltaRule) ModifyBefore(round.Session) {}
+
+func (rule deltaRule) ModifyAfter(rNext round.Session) {
+	r, ok := rNext.(*round4)
+	if !ok || r.SelfID() != rule.malicious {
+		return
+	}
+	one := r.Group().NewScalar().SetNat(new(safenum.Nat).SetUint64(1))
+	r.DeltaShares[r.SelfID()] = r.Group().NewScalar().Set(r.DeltaShares[r.SelfID()]).Add(one)
+}
+
+func (rule deltaRule) ModifyContent(rNext round.Session, _ party.ID, content round.Content) {
+	c, ok := content.(*broadcast4)
+	if !ok || rNext.SelfID() != rule.malicious {
+		return
+	}
+	one := rNext.Group().NewScalar().SetNat(new(safenum.Nat).SetUint64(1))
+	c.DeltaShare = rNext.Group().NewScalar().Set(c.DeltaShare).Add(one)
+}
+
+func TestSignWrongDeltaShare(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 3
+	T := N - 1
+	configs, partyIDs := test.GenerateConfig(group, N, T, mrand.New(mrand.NewSource(1)), nil)
+	messageHash := make([]byte, 64)
+	sha3.ShakeSum128(messageHash, []byte("hello"))
+
+	malicious := partyIDs[1]
+	rounds := make([]round.Session, 0, N)
+	for _, id := range partyIDs {
+		r, err := StartSign(configs[id], partyIDs, messageHash, nil)(nil)
+		require.NoError(t, err)
+		rounds = append(rounds, r)
+	}
+	for {
+		err, done := test.Rounds(rounds, deltaRule{malicious: malicious})
+		require.NoError(t, err, "failed to process round")
+		if done {
+			break
+		}
+	}
+
+	// Δ ≠ [δ]G, and the decryption proof of the malicious party doesn't match the δᵢ it broadcast
+	for _, r := range rounds {
+		require.IsType(t, &round.Abort{}, r, "expected abort round")
+		if r.SelfID() == malicious {
+			continue
+		}
+		assert.Equal(t, []party.ID{malicious}, r.(*round.Abort).Culprits)
+	}
+}
+
+func TestSignGenerateTestConfigs(t *testing.T) {
+	group := curve.Secp256k1{}
+	N, T := 3, 1
+	messageHash := make([]byte, 64)
+	sha3.ShakeSum128(messageHash, []byte("hello"))
+
+	_, err := test.GenerateTestConfigs(group, N, N, mrand.New(mrand.NewSource(1)))
+	assert.Error(t, err, "the threshold must be less than the number of parties")
+
+	// the second call reuses the Paillier keys of the first, but has its own shares
+	for seed := int64(1); seed <= 2; seed++ {
+		configs, err := test.GenerateTestConfigs(group, T, N, mrand.New(mrand.NewSource(seed)))
+		require.NoError(t, err)
+		partyIDs := test.PartyIDs(N)
+		signers := partyIDs[1:]
+		require.NoError(t, configs[partyIDs[0]].Validate())
+
+		rounds := make([]round.Session, 0, len(signers))
+		for _, id := range signers {
+			r, err := StartSign(configs[id], signers, messageHash, nil)(nil)
+			require.NoError(t, err)
+			rounds = append(rounds, r)
+		}
+		for {
+			err, done := test.Rounds(rounds, nil)
+			require.NoError(t, err, "failed to process round")
+			if done {
+				break
+			}
+		}
+		for _, r := range rounds {
+			signature := r.(*round.Output).Result.(*ecdsa.Signature)
+			assert.True(t, signature.Verify(configs[partyIDs[0]].PublicPoint(), messageHash), "expected valid signature")
+		}
+	}
+}
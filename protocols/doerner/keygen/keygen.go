@@ -1,7 +1,6 @@
 package keygen
 
 import (
-	"crypto/rand"
 	"errors"
 	"fmt"
 
@@ -10,7 +9,6 @@ import (
 	"github.com/koteld/multi-party-sig/internal/params"
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
-	"github.com/koteld/multi-party-sig/pkg/math/sample"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/pool"
 	"github.com/koteld/multi-party-sig/pkg/protocol"
@@ -116,13 +114,14 @@ func StartKeygen(group curve.Curve, receiver bool, selfID, otherID party.ID, sec
 			return nil, fmt.Errorf("keygen.StartKeygen: %w", err)
 		}
 
-		refresh := true
-		if secretShare == nil && public == nil {
-			secretShare = sample.Scalar(rand.Reader, group)
-			refresh = false
+		// Without a share to refresh, ours is sampled by the first round, from the session's source of randomness
+		refresh := secretShare != nil || public != nil
+		var publicShare curve.Point
+		if refresh {
+			publicShare = secretShare.ActOnBase()
 		}
-		publicShare := secretShare.ActOnBase()
 
+		// The Receiver sends the setup message for the base Random OTs, so both sides bind its proof to the Receiver.
 		if receiver {
 			return &round1R{
 				Helper:      helper,
@@ -130,7 +129,7 @@ func StartKeygen(group curve.Curve, receiver bool, selfID, otherID party.ID, sec
 				secretShare: secretShare,
 				publicShare: publicShare,
 				public:      public,
-				receiver:    ot.NewCorreOTSetupReceiver(pl, helper.Hash(), helper.Group()),
+				receiver:    ot.NewCorreOTSetupReceiver(pl, helper.HashForID(selfID), helper.Group()),
 			}, nil
 		}
 		return &round1S{
@@ -139,7 +138,7 @@ func StartKeygen(group curve.Curve, receiver bool, selfID, otherID party.ID, sec
 			secretShare: secretShare,
 			publicShare: publicShare,
 			public:      public,
-			sender:      ot.NewCorreOTSetupSender(pl, helper.Hash()),
+			sender:      ot.NewCorreOTSetupSender(pl, helper.HashForID(otherID), helper.Group()),
 		}, nil
 	}
 }
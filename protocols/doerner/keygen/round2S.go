@@ -38,6 +38,9 @@ func (r *round2S) VerifyMessage(msg round.Message) error {
 	if err := body.Decommit.Validate(); err != nil {
 		return err
 	}
+	if err := body.OtMsg.Validate(); err != nil {
+		return err
+	}
 	if !r.Hash().Decommit(r.receiverCommit, body.Decommit, body.PublicShare) {
 		return errors.New("invalid commitment")
 	}
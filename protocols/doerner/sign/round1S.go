@@ -1,7 +1,6 @@
 package sign
 
 import (
-	"crypto/rand"
 	"errors"
 
 	"github.com/koteld/multi-party-sig/internal/ot"
@@ -65,7 +64,7 @@ func (r *round1S) StoreMessage(msg round.Message) error {
 func (r *round1S) Finalize(out chan<- *round.Message) (round.Session, error) {
 	group := r.Group()
 
-	kAPrime := sample.Scalar(rand.Reader, group)
+	kAPrime := sample.Scalar(r.Rand(), group)
 	RPrime := kAPrime.Act(r.D)
 
 	H := r.Hash()
@@ -73,9 +72,9 @@ func (r *round1S) Finalize(out chan<- *round.Message) (round.Session, error) {
 	kA := sample.Scalar(H.Digest(), group).Add(kAPrime)
 
 	R := kA.Act(r.D)
-	RProof := zksch.NewProof(r.Hash(), R, kA, r.D)
+	RProof := zksch.NewProofFromSource(r.Rand(), r.Hash(), R, kA, r.D)
 
-	phi := sample.Scalar(rand.Reader, group)
+	phi := sample.Scalar(r.Rand(), group)
 	kAInv := group.NewScalar().Set(kA).Invert()
 	alpha1 := group.NewScalar().Set(r.config.SecretShare).Mul(kAInv)
 	alpha2 := group.NewScalar().Set(kAInv)
@@ -83,11 +82,11 @@ func (r *round1S) Finalize(out chan<- *round.Message) (round.Session, error) {
 	alpha0.Add(phi)
 
 	tag0 := &hash.BytesWithDomain{TheDomain: "Multiply0", Bytes: nil}
-	multiply0 := ot.NewMultiplySender(r.Hash().Fork(tag0), r.config.Setup, alpha0)
+	multiply0 := ot.NewMultiplySender(r.Rand(), r.Hash().Fork(tag0), r.config.Setup, alpha0)
 	tag1 := &hash.BytesWithDomain{TheDomain: "Multiply1", Bytes: nil}
-	multiply1 := ot.NewMultiplySender(r.Hash().Fork(tag1), r.config.Setup, alpha1)
+	multiply1 := ot.NewMultiplySender(r.Rand(), r.Hash().Fork(tag1), r.config.Setup, alpha1)
 	tag2 := &hash.BytesWithDomain{TheDomain: "Multiply1", Bytes: nil}
-	multiply2 := ot.NewMultiplySender(r.Hash().Fork(tag2), r.config.Setup, alpha2)
+	multiply2 := ot.NewMultiplySender(r.Rand(), r.Hash().Fork(tag2), r.config.Setup, alpha2)
 
 	msg0, tA1, err := multiply0.Round1(r.mulMsg0)
 	if err != nil {
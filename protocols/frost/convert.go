@@ -0,0 +1,107 @@
+package frost
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/taproot"
+	"github.com/koteld/multi-party-sig/protocols/cmp"
+)
+
+// CMPToFROST converts a config produced by CMP into a FROST config for the same key.
+//
+// Both protocols share the secret with a polynomial of degree Threshold over the same party IDs,
+// so the share xᵢ, the public shares Xⱼ and the chain key are reused as they are.
+// The Paillier, Pedersen and ElGamal keys only matter for CMP, and are dropped.
+//
+// The configs then hold the same secret, but are independent afterwards:
+// refreshing one of them leaves the other unchanged, and a share leaked from the old one
+// still counts towards the threshold of the other.
+func CMPToFROST(c *cmp.Config) (*Config, error) {
+	_, privateShare, shares, err := cmpShares(c)
+	if err != nil {
+		return nil, err
+	}
+	converted := &Config{
+		ID:                 c.ID,
+		Threshold:          c.Threshold,
+		PrivateShare:       privateShare,
+		PublicKey:          c.PublicPoint(),
+		ChainKey:           append([]byte(nil), c.ChainKey...),
+		VerificationShares: party.NewPointMap(shares),
+	}
+	// the public shares must interpolate to the public key, and our share must match ours
+	if err = converted.Validate(); err != nil {
+		return nil, fmt.Errorf("frost: convert: %w", err)
+	}
+	return converted, nil
+}
+
+// CMPToFROSTTaproot is like CMPToFROST, but returns a config for BIP-340 signatures,
+// and requires the config to be on secp256k1.
+//
+// The taproot public key is the x coordinate of the CMP public key.
+// If the CMP public key has an odd y coordinate, every share is negated,
+// so that the secret matches the point with an even y coordinate, as KeygenTaproot does.
+func CMPToFROSTTaproot(c *cmp.Config) (*TaprootConfig, error) {
+	group, privateShare, shares, err := cmpShares(c)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := group.(curve.Secp256k1); !ok {
+		return nil, fmt.Errorf("frost: convert: taproot requires secp256k1, not %s", group.Name())
+	}
+	publicKey := c.PublicPoint().(*curve.Secp256k1Point)
+	negate := !publicKey.HasEvenY()
+	if negate {
+		privateShare.Negate()
+	}
+	verificationShares := make(map[party.ID]*curve.Secp256k1Point, len(shares))
+	for j, X := range shares {
+		if negate {
+			X = X.Negate()
+		}
+		verificationShares[j] = X.(*curve.Secp256k1Point)
+	}
+	converted := &TaprootConfig{
+		ID:                 c.ID,
+		Threshold:          c.Threshold,
+		PrivateShare:       privateShare.(*curve.Secp256k1Scalar),
+		PublicKey:          taproot.PublicKey(publicKey.XBytes()),
+		ChainKey:           append([]byte(nil), c.ChainKey...),
+		VerificationShares: verificationShares,
+	}
+	if err = converted.Validate(); err != nil {
+		return nil, fmt.Errorf("frost: convert: %w", err)
+	}
+	return converted, nil
+}
+
+// cmpShares returns the group of c, a copy of our share, and the public shares of all parties,
+// after checking that they all belong to the same group.
+func cmpShares(c *cmp.Config) (curve.Curve, curve.Scalar, map[party.ID]curve.Point, error) {
+	if c == nil || c.Group == nil || c.ECDSA == nil || c.Public == nil {
+		return nil, nil, nil, errors.New("frost: convert: config has nil fields")
+	}
+	if err := c.CheckNotRenounced(); err != nil {
+		return nil, nil, nil, fmt.Errorf("frost: convert: %w", err)
+	}
+	group := c.Group
+	if c.ECDSA.Curve().Name() != group.Name() {
+		return nil, nil, nil, fmt.Errorf("frost: convert: share is on %s, not %s", c.ECDSA.Curve().Name(), group.Name())
+	}
+	shares := make(map[party.ID]curve.Point, len(c.Public))
+	for j, public := range c.Public {
+		if public == nil || public.ECDSA == nil {
+			return nil, nil, nil, fmt.Errorf("frost: convert: missing public share for %s", j)
+		}
+		if public.ECDSA.Curve().Name() != group.Name() {
+			return nil, nil, nil, fmt.Errorf("frost: convert: public share of %s is on %s, not %s",
+				j, public.ECDSA.Curve().Name(), group.Name())
+		}
+		shares[j] = public.ECDSA
+	}
+	return group, group.NewScalar().Set(c.ECDSA), shares, nil
+}
@@ -0,0 +1,95 @@
+package frost
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/ecdsa"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/protocol"
+	"github.com/koteld/multi-party-sig/pkg/taproot"
+	"github.com/koteld/multi-party-sig/protocols/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signAll runs a signing session for each of signers, and returns the result of the first one.
+func signAll(t *testing.T, signers []party.ID, start func(id party.ID) protocol.StartFunc) interface{} {
+	return test.RunProtocol(t, signers, nil, start)[signers[0]]
+}
+
+func TestCMPToFROST(t *testing.T) {
+	group := curve.Secp256k1{}
+	message := []byte("hello")
+	configs, err := test.GenerateTestConfigs(group, 1, 3, rand.Reader)
+	require.NoError(t, err)
+	ids := test.PartyIDs(3)
+	signers := ids[:2]
+
+	for _, odd := range []bool{false, true} {
+		publicKey := configs[ids[0]].PublicPoint().(*curve.Secp256k1Point)
+		if publicKey.HasEvenY() == odd {
+			// negate the secret, so that both parities of the public key are tested
+			for _, c := range configs {
+				c.ECDSA.Negate()
+			}
+			for _, public := range configs[ids[0]].Public {
+				public.ECDSA = public.ECDSA.Negate()
+			}
+			publicKey = configs[ids[0]].PublicPoint().(*curve.Secp256k1Point)
+		}
+		require.Equal(t, odd, !publicKey.HasEvenY())
+
+		frostConfigs := make(map[party.ID]*Config, len(configs))
+		taprootConfigs := make(map[party.ID]*TaprootConfig, len(configs))
+		for id, c := range configs {
+			frostConfigs[id], err = CMPToFROST(c)
+			require.NoError(t, err)
+			require.True(t, frostConfigs[id].PublicKey.Equal(publicKey))
+			taprootConfigs[id], err = CMPToFROSTTaproot(c)
+			require.NoError(t, err)
+			require.Equal(t, taproot.PublicKey(publicKey.XBytes()), taprootConfigs[id].PublicKey)
+		}
+
+		signature := signAll(t, signers, func(id party.ID) protocol.StartFunc {
+			return Sign(frostConfigs[id], signers, message)
+		}).(Signature)
+		assert.True(t, signature.Verify(publicKey, message))
+
+		taprootSignature := signAll(t, signers, func(id party.ID) protocol.StartFunc {
+			return SignTaproot(taprootConfigs[id], signers, message)
+		}).(taproot.Signature)
+		assert.True(t, taproot.PublicKey(publicKey.XBytes()).Verify(taprootSignature, message))
+	}
+
+	// the CMP configs are left unchanged, and still sign ECDSA
+	ecdsaSignature := signAll(t, signers, func(id party.ID) protocol.StartFunc {
+		return cmp.Sign(configs[id], signers, message, nil)
+	}).(*ecdsa.Signature)
+	assert.True(t, ecdsaSignature.Verify(configs[ids[0]].PublicPoint(), message))
+}
+
+func TestCMPToFROSTInvalid(t *testing.T) {
+	configs, err := test.GenerateTestConfigs(curve.Secp256k1{}, 1, 3, rand.Reader)
+	require.NoError(t, err)
+	id := test.PartyIDs(3)[0]
+
+	// a public share which doesn't match the others is caught
+	c := configs[id]
+	other := test.PartyIDs(3)[1]
+	original := c.Public[other].ECDSA
+	c.Public[other].ECDSA = original.Add(curve.Secp256k1{}.NewBasePoint())
+	_, err = CMPToFROST(c)
+	assert.Error(t, err)
+	_, err = CMPToFROSTTaproot(c)
+	assert.Error(t, err)
+	c.Public[other].ECDSA = original
+
+	c.Renounce()
+	_, err = CMPToFROST(c)
+	assert.Error(t, err)
+	_, err = CMPToFROST(nil)
+	assert.Error(t, err)
+}
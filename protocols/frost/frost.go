@@ -13,6 +13,8 @@ type (
 	Config        = keygen.Config
 	TaprootConfig = keygen.TaprootConfig
 	Signature     = sign.Signature
+	SignOptions   = sign.Options
+	NonceSource   = sign.NonceSource
 )
 
 // EmptyConfig creates an empty Config with a specific group.
@@ -57,7 +59,11 @@ func KeygenTaproot(selfID party.ID, participants []party.ID, threshold int) prot
 	return keygen.StartKeygenCommon(true, curve.Secp256k1{}, participants, threshold, selfID, nil, nil, nil)
 }
 
-// Refresh
+// Refresh generates new shares of the secret key of config, with the same public key.
+//
+// All the randomness of each party is sampled from the session's source, so with protocol.WithRandomness,
+// the same seeds, session ID and old configs give the same refreshed configs.
+// This is meant for crash recovery with MultiHandler.Replay, and for test vectors.
 func Refresh(config *Config, participants []party.ID) protocol.StartFunc {
 	return keygen.StartKeygenCommon(false, config.Curve(), participants, config.Threshold, config.ID, config.PrivateShare, config.PublicKey, config.VerificationShares.Points)
 }
@@ -105,6 +111,13 @@ func Sign(config *Config, signers []party.ID, messageHash []byte) protocol.Start
 	return sign.StartSignCommon(false, config, signers, messageHash)
 }
 
+// SignWithOptions is like Sign, with the behavior modified by options.
+//
+// With options.Audit set, the result is a *sign.AuditedSignature instead of a Signature.
+func SignWithOptions(config *Config, signers []party.ID, messageHash []byte, options SignOptions) protocol.StartFunc {
+	return sign.StartSignWithOptions(false, options, config, signers, messageHash)
+}
+
 // SignTaproot is like Sign, but will generate a Taproot / BIP-340 compatible signature.
 //
 // This needs to result of a Taproot compatible key generation phase, naturally.
@@ -94,3 +94,42 @@ func TestFrost(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestRefreshDeterministic(t *testing.T) {
+	group := curve.Secp256k1{}
+	ids := test.PartyIDs(3)
+	threshold := 1
+
+	run := func(start func(id party.ID) protocol.StartFunc, sessionID []byte, seed string) map[party.ID]*Config {
+		var opts []func(id party.ID) protocol.HandlerOption
+		if seed != "" {
+			opts = append(opts, func(id party.ID) protocol.HandlerOption {
+				partySeed := make([]byte, protocol.MinRandomnessSeedSize)
+				copy(partySeed, seed+string(id))
+				return protocol.WithRandomness(partySeed)
+			})
+		}
+		configs := make(map[party.ID]*Config, len(ids))
+		for id, r := range test.RunProtocol(t, ids, sessionID, start, opts...) {
+			configs[id] = r.(*Config)
+		}
+		return configs
+	}
+
+	old := run(func(id party.ID) protocol.StartFunc { return Keygen(group, id, ids, threshold) }, nil, "")
+	refresh := func(seed string) map[party.ID]*Config {
+		return run(func(id party.ID) protocol.StartFunc { return Refresh(old[id], ids) }, []byte("refresh"), seed)
+	}
+	a, b, other := refresh("seed"), refresh("seed"), refresh("other seed")
+
+	for _, id := range ids {
+		assert.True(t, a[id].PublicKey.Equal(old[id].PublicKey), "the public key should stay the same")
+		assert.False(t, a[id].PrivateShare.Equal(old[id].PrivateShare), "the share should be refreshed")
+		assert.True(t, a[id].PrivateShare.Equal(b[id].PrivateShare), "the same seed should give the same share")
+		assert.Equal(t, a[id].ChainKey, b[id].ChainKey)
+		for _, j := range ids {
+			assert.True(t, a[id].VerificationShares.Points[j].Equal(b[id].VerificationShares.Points[j]))
+		}
+		assert.False(t, a[id].PrivateShare.Equal(other[id].PrivateShare), "another seed should give another share")
+	}
+}
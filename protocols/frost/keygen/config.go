@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/koteld/multi-party-sig/internal/bip32"
 	"github.com/koteld/multi-party-sig/internal/params"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/polynomial"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/taproot"
 )
@@ -41,6 +43,7 @@ type Config struct {
 //
 // This needs to be called before unmarshalling, instead of just using new(Result).
 // This is to allow points and scalars to be correctly unmarshalled.
+// The loaded config should then be checked with Validate.
 func EmptyConfig(group curve.Curve) *Config {
 	return &Config{
 		PrivateShare:       group.NewScalar(),
@@ -54,6 +57,18 @@ func (r *Config) Curve() curve.Curve {
 	return r.PublicKey.Curve()
 }
 
+// Validate checks that the config is consistent, and should be called after loading it.
+//
+// The verification shares must be the evaluations of a single polynomial of degree Threshold,
+// whose constant is the public key, so that a swapped or altered share is caught before signing.
+// Our private share must also match our verification share.
+func (r *Config) Validate() error {
+	if r.PublicKey == nil || r.PrivateShare == nil || r.VerificationShares == nil {
+		return errors.New("keygen: config has nil fields")
+	}
+	return validateShares(r.ID, r.Threshold, r.PrivateShare, r.PublicKey, r.VerificationShares.Points)
+}
+
 // Derive performs an arbitrary derivation of a related key, by adding a scalar.
 //
 // This can support methods like BIP32, but is more general.
@@ -145,6 +160,58 @@ func (r *TaprootConfig) Clone() *TaprootConfig {
 	}
 }
 
+// taprootConfigMarshal is encoded like TaprootConfig, with the verification shares sorted by ID.
+type taprootConfigMarshal struct {
+	ID                 party.ID
+	Threshold          int
+	PrivateShare       *curve.Secp256k1Scalar
+	PublicKey          taproot.PublicKey
+	ChainKey           []byte
+	VerificationShares cbor.RawMessage
+}
+
+// MarshalCBOR implements cbor.Marshaler, so that the same config always gives the same bytes.
+func (r *TaprootConfig) MarshalCBOR() ([]byte, error) {
+	var shares cbor.RawMessage
+	if r.VerificationShares != nil {
+		points := make(map[party.ID]curve.Point, len(r.VerificationShares))
+		for j, Y := range r.VerificationShares {
+			points[j] = Y
+		}
+		var err error
+		if shares, err = party.NewPointMap(points).MarshalBinary(); err != nil {
+			return nil, err
+		}
+	}
+	return cbor.Marshal(&taprootConfigMarshal{
+		ID:                 r.ID,
+		Threshold:          r.Threshold,
+		PrivateShare:       r.PrivateShare,
+		PublicKey:          r.PublicKey,
+		ChainKey:           r.ChainKey,
+		VerificationShares: shares,
+	})
+}
+
+// Validate is like Config.Validate, where the public key is the point with an even y coordinate.
+func (r *TaprootConfig) Validate() error {
+	if r.PrivateShare == nil {
+		return errors.New("keygen: config has nil fields")
+	}
+	publicKey, err := curve.Secp256k1{}.LiftX(r.PublicKey)
+	if err != nil {
+		return fmt.Errorf("keygen: public key: %w", err)
+	}
+	shares := make(map[party.ID]curve.Point, len(r.VerificationShares))
+	for j, Y := range r.VerificationShares {
+		if Y == nil {
+			return fmt.Errorf("keygen: missing verification share for %s", j)
+		}
+		shares[j] = Y
+	}
+	return validateShares(r.ID, r.Threshold, r.PrivateShare, publicKey, shares)
+}
+
 // Derive performs an arbitrary derivation of a related key, by adding a scalar.
 //
 // This can support methods like BIP32, but is more general.
@@ -210,3 +277,53 @@ func (r *TaprootConfig) DeriveChild(i uint32) (*TaprootConfig, error) {
 	}
 	return r.Derive(scalar, newChainKey)
 }
+
+// validateShares checks the verification shares of a config against its public key and private share.
+//
+// The first Threshold+1 shares define a polynomial, which must interpolate to the public key.
+// Replacing the last of them by any other share must give the same public key,
+// since both polynomials then agree on Threshold points and on 0.
+func validateShares(id party.ID, threshold int, privateShare curve.Scalar, publicKey curve.Point, shares map[party.ID]curve.Point) error {
+	group := publicKey.Curve()
+	if err := group.ValidatePoint(publicKey); err != nil {
+		return fmt.Errorf("keygen: public key: %w", err)
+	}
+	partyIDs := make([]party.ID, 0, len(shares))
+	for j, Y := range shares {
+		if err := group.ValidatePoint(Y); err != nil {
+			return fmt.Errorf("keygen: verification share of %s: %w", j, err)
+		}
+		partyIDs = append(partyIDs, j)
+	}
+	ids := party.NewIDSlice(partyIDs)
+	if threshold < 0 || threshold+1 > len(ids) {
+		return fmt.Errorf("keygen: threshold %d is invalid for %d parties", threshold, len(ids))
+	}
+	own, ok := shares[id]
+	if !ok {
+		return fmt.Errorf("keygen: missing verification share for %s", id)
+	}
+	if !privateShare.ActOnBase().Equal(own) {
+		return errors.New("keygen: private share doesn't match our verification share")
+	}
+
+	base := ids[:threshold+1]
+	interpolate := func(domain []party.ID) curve.Point {
+		lagrange := polynomial.Lagrange(group, domain)
+		sum := group.NewPoint()
+		for _, l := range domain {
+			sum = sum.Add(lagrange[l].Act(shares[l]))
+		}
+		return sum
+	}
+	if !interpolate(base).Equal(publicKey) {
+		return errors.New("keygen: verification shares don't interpolate to the public key")
+	}
+	for _, j := range ids[threshold+1:] {
+		// replace the last party of base by j
+		if !interpolate(append(base[:threshold:threshold], j)).Equal(publicKey) {
+			return fmt.Errorf("keygen: verification share of %s isn't consistent with the threshold", j)
+		}
+	}
+	return nil
+}
@@ -92,6 +92,51 @@ func TestKeygen(t *testing.T) {
 	checkOutput(t, rounds, partyIDs)
 }
 
+func TestRefreshKeepsConfig(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 3
+	partyIDs := test.PartyIDs(N)
+
+	rounds := make([]round.Session, 0, N)
+	for _, partyID := range partyIDs {
+		r, err := StartKeygenCommon(false, group, partyIDs, N-1, partyID, nil, nil, nil)(nil)
+		require.NoError(t, err)
+		rounds = append(rounds, r)
+	}
+	for {
+		err, done := test.Rounds(rounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if done {
+			break
+		}
+	}
+
+	configs := make([]*Config, 0, N)
+	oldShares := make([]curve.Scalar, 0, N)
+	refreshRounds := make([]round.Session, 0, N)
+	for _, r := range rounds {
+		c := r.(*round.Output).Result.(*Config)
+		configs = append(configs, c)
+		oldShares = append(oldShares, group.NewScalar().Set(c.PrivateShare))
+		refresh, err := StartKeygenCommon(false, group, partyIDs, N-1, c.ID, c.PrivateShare, c.PublicKey, c.VerificationShares.Points)(nil)
+		require.NoError(t, err)
+		refreshRounds = append(refreshRounds, refresh)
+	}
+	for {
+		err, done := test.Rounds(refreshRounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if done {
+			break
+		}
+	}
+
+	checkOutput(t, refreshRounds, partyIDs)
+	for i, c := range configs {
+		assert.True(t, c.PrivateShare.Equal(oldShares[i]), "refreshing should not modify the old config")
+		assert.NoError(t, c.Validate())
+	}
+}
+
 func checkOutputTaproot(t *testing.T, rounds []round.Session, parties party.IDSlice) {
 	group := curve.Secp256k1{}
 
@@ -164,3 +209,106 @@ func TestKeygenTaproot(t *testing.T) {
 
 	checkOutputTaproot(t, rounds, partyIDs)
 }
+
+func TestConfigValidate(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 5
+	partyIDs := test.PartyIDs(N)
+
+	for _, taproot := range []bool{false, true} {
+		rounds := make([]round.Session, 0, N)
+		for _, partyID := range partyIDs {
+			r, err := StartKeygenCommon(taproot, group, partyIDs, 2, partyID, nil, nil, nil)(nil)
+			require.NoError(t, err, "round creation should not result in an error")
+			rounds = append(rounds, r)
+		}
+		for {
+			err, done := test.Rounds(rounds, nil)
+			require.NoError(t, err, "failed to process round")
+			if done {
+				break
+			}
+		}
+
+		for _, r := range rounds {
+			result := r.(*round.Output).Result
+			if taproot {
+				c := result.(*TaprootConfig)
+				require.NoError(t, c.Validate())
+
+				a, b := partyIDs[0], partyIDs[N-1]
+				swapped := c.Clone()
+				swapped.VerificationShares = map[party.ID]*curve.Secp256k1Point{}
+				for j, Y := range c.VerificationShares {
+					swapped.VerificationShares[j] = Y
+				}
+				swapped.VerificationShares[a], swapped.VerificationShares[b] = c.VerificationShares[b], c.VerificationShares[a]
+				assert.Error(t, swapped.Validate(), "swapped verification shares should be detected")
+				continue
+			}
+
+			c := result.(*Config)
+			require.NoError(t, c.Validate())
+
+			for _, j := range partyIDs {
+				if j == c.ID {
+					continue
+				}
+				points := make(map[party.ID]curve.Point, N)
+				for l, Y := range c.VerificationShares.Points {
+					points[l] = Y
+				}
+				points[j] = points[j].Add(group.NewBasePoint())
+				mutated := *c
+				mutated.VerificationShares = party.NewPointMap(points)
+				assert.Error(t, mutated.Validate(), "a mutated verification share of %s should be detected", j)
+			}
+
+			wrongShare := *c
+			wrongShare.PrivateShare = c.PrivateShare.Neg()
+			assert.Error(t, wrongShare.Validate())
+		}
+	}
+}
+
+func TestConfigMarshalDeterministic(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 5
+	partyIDs := test.PartyIDs(N)
+
+	for _, taproot := range []bool{false, true} {
+		rounds := make([]round.Session, 0, N)
+		for _, partyID := range partyIDs {
+			r, err := StartKeygenCommon(taproot, group, partyIDs, 2, partyID, nil, nil, nil)(nil)
+			require.NoError(t, err, "round creation should not result in an error")
+			rounds = append(rounds, r)
+		}
+		for {
+			err, done := test.Rounds(rounds, nil)
+			require.NoError(t, err, "failed to process round")
+			if done {
+				break
+			}
+		}
+
+		result := rounds[0].(*round.Output).Result
+		expected, err := cbor.Marshal(result)
+		require.NoError(t, err)
+		for i := 0; i < 32; i++ {
+			data, err := cbor.Marshal(result)
+			require.NoError(t, err)
+			require.Equal(t, expected, data, "serializing the same config should give the same bytes")
+		}
+
+		if taproot {
+			decoded := &TaprootConfig{}
+			require.NoError(t, cbor.Unmarshal(expected, decoded))
+			require.NoError(t, decoded.Validate())
+			assert.Equal(t, result.(*TaprootConfig).PublicKey, decoded.PublicKey)
+			continue
+		}
+		decoded := EmptyConfig(group)
+		require.NoError(t, cbor.Unmarshal(expected, decoded))
+		assert.NoError(t, decoded.Validate())
+	}
+}
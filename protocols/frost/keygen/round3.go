@@ -107,8 +107,10 @@ func (r *round3) Finalize(chan<- *round.Message) (round.Session, error) {
 	// 3. "Each P_i calculates their long-lived private signing share by computing
 	// sᵢ = ∑ₗ₌₁ⁿ fₗ(i), stores s_i securely, and deletes each fₗ(i)"
 
+	// when refreshing, r.privateShare is the share of the old config, which must not be modified
+	privateShare := r.Group().NewScalar().Set(r.privateShare)
 	for l, f_li := range r.shareFrom {
-		r.privateShare.Add(f_li)
+		privateShare.Add(f_li)
 		// TODO: Maybe actually clear this in a better way
 		delete(r.shareFrom, l)
 	}
@@ -148,7 +150,7 @@ func (r *round3) Finalize(chan<- *round.Message) (round.Session, error) {
 		// shares.
 		YSecp := r.publicKey.(*curve.Secp256k1Point)
 		if !YSecp.HasEvenY() {
-			r.privateShare.Negate()
+			privateShare.Negate()
 			for i, y_i := range r.verificationShares {
 				r.verificationShares[i] = y_i.Negate()
 			}
@@ -160,7 +162,7 @@ func (r *round3) Finalize(chan<- *round.Message) (round.Session, error) {
 		return r.ResultRound(&TaprootConfig{
 			ID:                 r.SelfID(),
 			Threshold:          r.threshold,
-			PrivateShare:       r.privateShare.(*curve.Secp256k1Scalar),
+			PrivateShare:       privateShare.(*curve.Secp256k1Scalar),
 			PublicKey:          YSecp.XBytes()[:],
 			VerificationShares: secpVerificationShares,
 		}), nil
@@ -169,7 +171,7 @@ func (r *round3) Finalize(chan<- *round.Message) (round.Session, error) {
 	return r.ResultRound(&Config{
 		ID:                 r.SelfID(),
 		Threshold:          r.threshold,
-		PrivateShare:       r.privateShare,
+		PrivateShare:       privateShare,
 		PublicKey:          r.publicKey,
 		VerificationShares: party.NewPointMap(r.verificationShares),
 	}), nil
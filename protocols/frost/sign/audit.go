@@ -0,0 +1,177 @@
+package sign
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cronokirby/safenum"
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/polynomial"
+	"github.com/koteld/multi-party-sig/pkg/math/sample"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/taproot"
+	zksch "github.com/koteld/multi-party-sig/pkg/zk/sch"
+)
+
+// AuditRecord holds the public transcript of a signing session.
+//
+// It lets an auditor check, after the fact, that every signer committed to its nonces
+// with (Dₗ, Eₗ) before the group commitment R was fixed, and that each response zₗ
+// was computed from those commitments.
+type AuditRecord struct {
+	// Taproot indicates whether a Taproot / BIP-340 signature was produced.
+	Taproot bool
+	// Participants are the signers whose shares were combined into the signature.
+	Participants party.IDSlice
+	// M is the hash of the message that was signed.
+	M []byte
+	// Y is the public key.
+	Y curve.Point
+	// YShares[l] = Yₗ is the verification share of each signer.
+	YShares map[party.ID]curve.Point
+	// D[l] = Dₗ is the first nonce commitment of each signer.
+	D map[party.ID]curve.Point
+	// E[l] = Eₗ is the second nonce commitment of each signer.
+	E map[party.ID]curve.Point
+	// R is the group commitment.
+	R curve.Point
+	// Z[l] = zₗ is the response of each signer.
+	Z map[party.ID]curve.Scalar
+	// Times[l] is when signer l computed its response, according to its own clock.
+	Times map[party.ID]time.Time
+	// TimeProofs[l] is a Schnorr signature by signer l with its share sₗ, on Y, m, R and Times[l].
+	TimeProofs map[party.ID]*zksch.Proof
+}
+
+// AuditedSignature is the result of a signing session started with StartSignAudited.
+type AuditedSignature struct {
+	// Signature is the result the session would have had without auditing,
+	// either a Signature or a taproot.Signature.
+	Signature interface{}
+	// Record is the transcript of the session.
+	Record *AuditRecord
+}
+
+// VerifyNonceCommitments checks that the group commitment and every response in record
+// match the nonce commitments each signer broadcast.
+//
+// For each signer l, this recomputes Rₗ = Dₗ + ρₗ⋅Eₗ, and checks that zₗ⋅G = Rₗ + c⋅λₗ⋅Yₗ,
+// as well as R = ∑ₗ Rₗ.
+func VerifyNonceCommitments(record *AuditRecord) error {
+	if record == nil || record.Y == nil || record.R == nil {
+		return errors.New("audit: record is incomplete")
+	}
+	group := record.Y.Curve()
+
+	signers := make([]party.ID, 0, len(record.Z))
+	for l := range record.Z {
+		signers = append(signers, l)
+	}
+	partyIDs := party.NewIDSlice(signers)
+	for _, l := range partyIDs {
+		if record.D[l] == nil || record.E[l] == nil || record.YShares[l] == nil || record.Z[l] == nil {
+			return fmt.Errorf("audit: missing data for %s", l)
+		}
+		if record.D[l].IsIdentity() || record.E[l].IsIdentity() {
+			return fmt.Errorf("audit: nonce commitment of %s is the identity point", l)
+		}
+	}
+	if len(record.D) != len(partyIDs) || len(record.E) != len(partyIDs) {
+		return errors.New("audit: nonce commitments don't match the signers")
+	}
+	if len(record.Participants) != len(partyIDs) || !partyIDs.Contains(record.Participants...) {
+		return errors.New("audit: participants don't match the signers")
+	}
+
+	R, RShares, _ := groupCommitment(group, record.M, partyIDs, record.D, record.E)
+	if !R.Equal(record.R) {
+		return errors.New("audit: group commitment doesn't match the nonce commitments")
+	}
+	if record.Taproot && !R.(*curve.Secp256k1Point).HasEvenY() {
+		for _, l := range partyIDs {
+			RShares[l] = RShares[l].Negate()
+		}
+	}
+	c := challenge(group, record.Taproot, R, record.Y, record.M)
+
+	lambda := polynomial.Lagrange(group, partyIDs)
+	for _, l := range partyIDs {
+		expected := c.Act(lambda[l].Act(record.YShares[l])).Add(RShares[l])
+		if !record.Z[l].ActOnBase().Equal(expected) {
+			return fmt.Errorf("audit: response of %s doesn't match its nonce commitments", l)
+		}
+	}
+	return nil
+}
+
+// VerifySignerTimes checks that the time of every signer in record was signed with its share,
+// for this message and group commitment.
+func VerifySignerTimes(record *AuditRecord) error {
+	if record == nil || record.Y == nil || record.R == nil {
+		return errors.New("audit: record is incomplete")
+	}
+	for _, l := range record.Participants {
+		t, ok := record.Times[l]
+		proof := record.TimeProofs[l]
+		if !ok || proof == nil || record.YShares[l] == nil {
+			return fmt.Errorf("audit: missing time of %s", l)
+		}
+		if !proof.Verify(timeHash(record.Y, record.R, record.M, l, t.UnixNano()), record.YShares[l], nil) {
+			return fmt.Errorf("audit: time of %s isn't signed with its share", l)
+		}
+	}
+	return nil
+}
+
+// timeHash returns the hash state on which signer l proves knowledge of its share sₗ,
+// to attest that it computed its response at time t, in nanoseconds since the Unix epoch.
+func timeHash(Y, R curve.Point, m messageHash, l party.ID, t int64) *hash.Hash {
+	tBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(tBytes, uint64(t))
+	h := hash.New()
+	_ = h.WriteAny(&hash.BytesWithDomain{
+		TheDomain: "FROST Signer Time",
+		Bytes:     tBytes,
+	}, Y, R, m, l)
+	return h
+}
+
+// groupCommitment computes the binding values ρₗ = H(m, B, l), each signer's share of
+// the group commitment Rₗ = Dₗ + ρₗ⋅Eₗ, and R = ∑ₗ Rₗ.
+func groupCommitment(group curve.Curve, m messageHash, partyIDs party.IDSlice, D, E map[party.ID]curve.Point) (R curve.Point, RShares map[party.ID]curve.Point, rho map[party.ID]curve.Scalar) {
+	// It's easier to calculate H(m, B, l), that way we can simply clone the hash
+	// state after H(m, B), instead of rehashing them each time.
+	rhoPreHash := hash.New()
+	_ = rhoPreHash.WriteAny(m)
+	for _, l := range partyIDs {
+		_ = rhoPreHash.WriteAny(D[l], E[l])
+	}
+
+	R = group.NewPoint()
+	RShares = make(map[party.ID]curve.Point, len(partyIDs))
+	rho = make(map[party.ID]curve.Scalar, len(partyIDs))
+	for _, l := range partyIDs {
+		rhoHash := rhoPreHash.Clone()
+		_ = rhoHash.WriteAny(l)
+		rho[l] = sample.Scalar(rhoHash.Digest(), group)
+		RShares[l] = rho[l].Act(E[l]).Add(D[l])
+		R = R.Add(RShares[l])
+	}
+	return R, RShares, rho
+}
+
+// challenge computes c = H(R, Y, m), using the BIP-340 challenge hash when useTaproot is set.
+func challenge(group curve.Curve, useTaproot bool, R, Y curve.Point, m messageHash) curve.Scalar {
+	if useTaproot {
+		RBytes := R.(*curve.Secp256k1Point).XBytes()
+		PBytes := Y.(*curve.Secp256k1Point).XBytes()
+		cHash := taproot.TaggedHash("BIP0340/challenge", RBytes, PBytes, m)
+		return group.NewScalar().SetNat(new(safenum.Nat).SetBytes(cHash))
+	}
+	cHash := hash.New()
+	_ = cHash.WriteAny(R, Y, m)
+	return sample.Scalar(cHash.Digest(), group)
+}
@@ -0,0 +1,300 @@
+package sign
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/polynomial"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	"github.com/koteld/multi-party-sig/pkg/taproot"
+	"github.com/koteld/multi-party-sig/protocols/frost/keygen"
+)
+
+// This file implements signing with a signing authority, as in Figure 3 of the Frost paper:
+//
+//	https://eprint.iacr.org/2020/852.pdf
+//
+// Instead of broadcasting their nonce commitments and responses to each other,
+// the signers send them to a Coordinator, which assembles the list of commitments B,
+// sends it back to every signer, and then combines their responses into a signature.
+//
+// The Coordinator is trusted for liveness, but not for unforgeability:
+// a coordinator which modifies B is detected, either by the signer whose commitment was changed,
+// or when the responses computed from inconsistent lists are verified.
+
+// SigningPackage is the message (m, B) sent by the Coordinator to every signer.
+type SigningPackage struct {
+	// M is the hash of the message to sign.
+	M []byte
+	// D[l] = Dₗ is the first nonce commitment of each signer.
+	D map[party.ID]curve.Point
+	// E[l] = Eₗ is the second nonce commitment of each signer.
+	E map[party.ID]curve.Point
+}
+
+// Signer is a party taking part in a signing session run by a Coordinator.
+//
+// A Signer can only be used for a single signature.
+type Signer struct {
+	taproot bool
+	config  *keygen.Config
+	signers party.IDSlice
+	m       []byte
+	// d, e are the nonces, which are deleted once a response has been computed.
+	d, e curve.Scalar
+	D, E curve.Point
+}
+
+// NewSigner prepares config's owner to sign messageHash with signers, and generates its nonces.
+func NewSigner(taproot bool, config *keygen.Config, signers []party.ID, messageHash []byte) (*Signer, error) {
+	partyIDs := party.NewIDSlice(signers)
+	if err := checkSigners(partyIDs, config.Threshold); err != nil {
+		return nil, fmt.Errorf("sign.NewSigner: %w", err)
+	}
+	if !partyIDs.Contains(config.ID) {
+		return nil, errors.New("sign.NewSigner: we are not a signer")
+	}
+
+	ctx := hash.New()
+	_ = ctx.WriteAny(&hash.BytesWithDomain{
+		TheDomain: "Coordinated Signing",
+		Bytes:     []byte(protocolIDFor(taproot)),
+	}, config.PublicKey, partyIDs)
+	d, e, err := deriveNonces(rand.Reader, config.PrivateShare, ctx.Sum(), messageHash)
+	if err != nil {
+		return nil, fmt.Errorf("sign.NewSigner: %w", err)
+	}
+	return &Signer{
+		taproot: taproot,
+		config:  config,
+		signers: partyIDs,
+		m:       messageHash,
+		d:       d,
+		e:       e,
+		D:       d.ActOnBase(),
+		E:       e.ActOnBase(),
+	}, nil
+}
+
+// Commitments returns the nonce commitments (Dᵢ, Eᵢ) to send to the Coordinator.
+func (s *Signer) Commitments() (D, E curve.Point) {
+	return s.D, s.E
+}
+
+// Sign checks the SigningPackage received from the Coordinator, and computes our response zᵢ.
+func (s *Signer) Sign(pkg *SigningPackage) (curve.Scalar, error) {
+	if s.d == nil || s.e == nil {
+		return nil, errors.New("sign.Signer: nonces were already used")
+	}
+	group := s.config.PublicKey.Curve()
+
+	// 3. "After receiving (m, B), each Pᵢ first validates the message m,
+	// and then checks Dₗ, Eₗ in Gˣ for each commitment in B, aborting if
+	// either check fails."
+	//
+	// We also make sure that B contains exactly the signers we agreed to sign with,
+	// and that our own commitments weren't modified.
+	if err := checkSigningPackage(pkg, s.m, s.signers); err != nil {
+		return nil, fmt.Errorf("sign.Signer: %w", err)
+	}
+	if !pkg.D[s.config.ID].Equal(s.D) || !pkg.E[s.config.ID].Equal(s.E) {
+		return nil, errors.New("sign.Signer: our nonce commitments were modified")
+	}
+
+	// 4. "Each Pᵢ then computes the set of binding values ρₗ = H₁(l, m, B).
+	// Each Pᵢ then derives the group commitment R = ∑ₗ Dₗ + ρₗ * Eₗ and
+	// the challenge c = H₂(R, Y, m)."
+	R, _, rho := groupCommitment(group, s.m, s.signers, pkg.D, pkg.E)
+	if s.taproot && !R.(*curve.Secp256k1Point).HasEvenY() {
+		// BIP-340 adjustment, as in round2
+		s.d.Negate()
+		s.e.Negate()
+	}
+	c := challenge(group, s.taproot, R, s.config.PublicKey, s.m)
+
+	// 5. "Each Pᵢ computes their response using their long-lived secret share sᵢ
+	// by computing zᵢ = dᵢ + (eᵢ ρᵢ) + λᵢ sᵢ c"
+	lambda := polynomial.Lagrange(group, s.signers)
+	z := group.NewScalar().Set(lambda[s.config.ID]).Mul(s.config.PrivateShare).Mul(c)
+	z.Add(s.d)
+	z.Add(group.NewScalar().Set(rho[s.config.ID]).Mul(s.e))
+
+	// 6. "Each Pᵢ securely deletes ((dᵢ, Dᵢ), (eᵢ, Eᵢ)) from their local storage"
+	s.d, s.e = nil, nil
+	return z, nil
+}
+
+// Coordinator is the signing authority of a session, which collects the signers' nonce commitments,
+// and combines their responses into a signature.
+type Coordinator struct {
+	taproot bool
+	// y = Y is the public key, and yShares[l] = Yₗ the verification share of each signer.
+	y       curve.Point
+	yShares map[party.ID]curve.Point
+	signers party.IDSlice
+	m       []byte
+	// commitmentsD[l] = Dₗ, commitmentsE[l] = Eₗ, as received from each signer.
+	commitmentsD, commitmentsE map[party.ID]curve.Point
+}
+
+// NewCoordinator creates a Coordinator for signing messageHash with signers,
+// using the public key and verification shares from the key generation.
+func NewCoordinator(taproot bool, publicKey curve.Point, verificationShares *party.PointMap, threshold int, signers []party.ID, messageHash []byte) (*Coordinator, error) {
+	partyIDs := party.NewIDSlice(signers)
+	if err := checkSigners(partyIDs, threshold); err != nil {
+		return nil, fmt.Errorf("sign.NewCoordinator: %w", err)
+	}
+	for _, l := range partyIDs {
+		if _, ok := verificationShares.Points[l]; !ok {
+			return nil, fmt.Errorf("sign.NewCoordinator: missing verification share for %s", l)
+		}
+	}
+	return &Coordinator{
+		taproot:      taproot,
+		y:            publicKey,
+		yShares:      verificationShares.Points,
+		signers:      partyIDs,
+		m:            messageHash,
+		commitmentsD: make(map[party.ID]curve.Point, len(partyIDs)),
+		commitmentsE: make(map[party.ID]curve.Point, len(partyIDs)),
+	}, nil
+}
+
+// AddCommitments stores the nonce commitments (Dₗ, Eₗ) received from signer l.
+func (c *Coordinator) AddCommitments(l party.ID, D, E curve.Point) error {
+	if !c.signers.Contains(l) {
+		return fmt.Errorf("sign.Coordinator: %s is not a signer", l)
+	}
+	if _, ok := c.commitmentsD[l]; ok {
+		return fmt.Errorf("sign.Coordinator: duplicate commitments from %s", l)
+	}
+	if D == nil || E == nil || D.IsIdentity() || E.IsIdentity() {
+		return fmt.Errorf("sign.Coordinator: invalid commitments from %s", l)
+	}
+	c.commitmentsD[l] = D
+	c.commitmentsE[l] = E
+	return nil
+}
+
+// SigningPackage returns the message (m, B) to send to every signer,
+// once the commitments of all signers have been added.
+func (c *Coordinator) SigningPackage() (*SigningPackage, error) {
+	pkg := &SigningPackage{
+		M: c.m,
+		D: make(map[party.ID]curve.Point, len(c.signers)),
+		E: make(map[party.ID]curve.Point, len(c.signers)),
+	}
+	for _, l := range c.signers {
+		if _, ok := c.commitmentsD[l]; !ok {
+			return nil, fmt.Errorf("sign.Coordinator: missing commitments from %s", l)
+		}
+		pkg.D[l] = c.commitmentsD[l]
+		pkg.E[l] = c.commitmentsE[l]
+	}
+	return pkg, nil
+}
+
+// Aggregate verifies the response zₗ of every signer, and combines them into a signature.
+//
+// The result is a Signature, or a taproot.Signature when taproot is used.
+// If a response is invalid, the error names the signer who sent it.
+func (c *Coordinator) Aggregate(responses map[party.ID]curve.Scalar) (interface{}, error) {
+	group := c.y.Curve()
+	if len(c.commitmentsD) != len(c.signers) {
+		return nil, errors.New("sign.Coordinator: commitments are incomplete")
+	}
+
+	// 7.a "SA first derives ρₗ = H₁(l, m, B), Rₗ = Dₗ + (Eₗ ρₗ) for l ∈ S,
+	// and R = ∑ₗ Rₗ, and c = H₂(R, Y, m)."
+	R, RShares, _ := groupCommitment(group, c.m, c.signers, c.commitmentsD, c.commitmentsE)
+	if c.taproot && !R.(*curve.Secp256k1Point).HasEvenY() {
+		for _, l := range c.signers {
+			RShares[l] = RShares[l].Negate()
+		}
+	}
+	ch := challenge(group, c.taproot, R, c.y, c.m)
+
+	// 7.b "Verify the validity of each response by checking
+	//
+	//    zᵢ • G = Rᵢ + c * λᵢ * Yᵢ
+	//
+	// for each share zᵢ, i in S."
+	lambda := polynomial.Lagrange(group, c.signers)
+	z := group.NewScalar()
+	for _, l := range c.signers {
+		z_l, ok := responses[l]
+		if !ok || z_l == nil {
+			return nil, fmt.Errorf("sign.Coordinator: missing response from %s", l)
+		}
+		expected := ch.Act(lambda[l].Act(c.yShares[l])).Add(RShares[l])
+		if !z_l.ActOnBase().Equal(expected) {
+			return nil, fmt.Errorf("sign.Coordinator: failed to verify response from %s", l)
+		}
+		// 7.c "Compute the group's response z = ∑ᵢ zᵢ"
+		z.Add(z_l)
+	}
+
+	if c.taproot {
+		zBytes, err := z.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		sig := taproot.Signature(make([]byte, 0, taproot.SignatureLen))
+		sig = append(sig, R.(*curve.Secp256k1Point).XBytes()...)
+		sig = append(sig, zBytes...)
+		if !taproot.PublicKey(c.y.(*curve.Secp256k1Point).XBytes()).Verify(sig, c.m) {
+			return nil, errors.New("sign.Coordinator: generated signature failed to verify")
+		}
+		return sig, nil
+	}
+	sig := Signature{R: R, z: z}
+	if !sig.Verify(c.y, c.m) {
+		return nil, errors.New("sign.Coordinator: generated signature failed to verify")
+	}
+	return sig, nil
+}
+
+// checkSigners verifies that signers is a valid set of more than threshold parties.
+func checkSigners(signers party.IDSlice, threshold int) error {
+	if !signers.Valid() {
+		return errors.New("invalid signers")
+	}
+	if len(signers) <= threshold {
+		return fmt.Errorf("%d signers are not enough for threshold %d", len(signers), threshold)
+	}
+	return nil
+}
+
+// checkSigningPackage verifies that pkg is for the message m, and contains valid commitments from exactly the signers.
+func checkSigningPackage(pkg *SigningPackage, m []byte, signers party.IDSlice) error {
+	if pkg == nil {
+		return errors.New("missing signing package")
+	}
+	if string(pkg.M) != string(m) {
+		return errors.New("signing package is for a different message")
+	}
+	if len(pkg.D) != len(signers) || len(pkg.E) != len(signers) {
+		return errors.New("signing package doesn't match the signers")
+	}
+	for _, l := range signers {
+		D, E := pkg.D[l], pkg.E[l]
+		if D == nil || E == nil {
+			return fmt.Errorf("signing package is missing commitments from %s", l)
+		}
+		if D.IsIdentity() || E.IsIdentity() {
+			return fmt.Errorf("nonce commitment of %s is the identity point", l)
+		}
+	}
+	return nil
+}
+
+// protocolIDFor returns the protocol ID of a signing session.
+func protocolIDFor(taproot bool) string {
+	if taproot {
+		return protocolIDTaproot
+	}
+	return protocolID
+}
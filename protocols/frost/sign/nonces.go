@@ -0,0 +1,32 @@
+package sign
+
+import (
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	zksch "github.com/koteld/multi-party-sig/pkg/zk/sch"
+)
+
+// NonceSource produces the nonces (dᵢ, eᵢ) of a signer outside of the protocol,
+// for instance in a secure element, so that they are never known to the process running it.
+//
+// Each session calls Commit once, and then Respond once, unless it is aborted in between.
+// The source must never use the same nonces twice, and should erase them after Respond.
+type NonceSource interface {
+	// Commit generates new nonces (dᵢ, eᵢ), and returns Dᵢ = dᵢ•G and Eᵢ = eᵢ•G,
+	// with a Schnorr proof of knowledge of each of them.
+	//
+	// Both proofs are made with hash, or clones of it, as zksch.NewProof(hash.Clone(), D, d, nil).
+	// hash is bound to the session and our identity, so that the proofs can't be replayed in another one.
+	Commit(hash *hash.Hash) (D, E curve.Point, proofD, proofE *zksch.Proof, err error)
+	// Respond returns the nonce part dᵢ + ρᵢ•eᵢ of our response, where ρᵢ is our binding factor.
+	//
+	// If negate is set, the nonces must be negated first, giving -(dᵢ + ρᵢ•eᵢ).
+	// This is required by BIP-340 when the group commitment has an odd y coordinate.
+	Respond(rho curve.Scalar, negate bool) (curve.Scalar, error)
+}
+
+// verifyNonceProofs checks the proofs of knowledge of the nonces committed to by D and E,
+// made with hash by the party sending them.
+func verifyNonceProofs(hash *hash.Hash, D, E curve.Point, proofD, proofE *zksch.Proof) bool {
+	return proofD.Verify(hash.Clone(), D, nil) && proofE.Verify(hash, E, nil)
+}
@@ -0,0 +1,210 @@
+package sign
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/koteld/multi-party-sig/pkg/hash"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/polynomial"
+	"github.com/koteld/multi-party-sig/pkg/party"
+	zksch "github.com/koteld/multi-party-sig/pkg/zk/sch"
+)
+
+// QuorumCertificate attests that a quorum of signers took part in signing a message.
+//
+// A certificate carries the public transcript of the session in an AuditRecord.
+// Each response zₗ satisfies zₗ⋅G = Rₗ + c⋅λₗ⋅Yₗ, where the challenge c covers the message and R,
+// and R is derived from every signer's nonce commitments and ID.
+// These responses act as signatures by each participant on the message and participant set,
+// so a coordinator can't forge a certificate without the signers' shares.
+//
+// The time of each signer is covered by its own signature, made with its share,
+// so it is only as trustworthy as that signer's clock.
+type QuorumCertificate struct {
+	// KeyID identifies the public key the signature is valid for.
+	KeyID []byte
+	// Record is the public transcript of the session.
+	Record *AuditRecord
+}
+
+// quorumCertificateMarshal is encoded as an array, to keep certificates compact.
+type quorumCertificateMarshal struct {
+	_       struct{} `cbor:",toarray"`
+	Taproot bool
+	KeyID   []byte
+	M       []byte
+	Y       []byte
+	R       []byte
+	Signers []quorumSignerMarshal
+}
+
+type quorumSignerMarshal struct {
+	_         struct{} `cbor:",toarray"`
+	ID        party.ID
+	D, E, Z   []byte
+	Time      int64
+	TimeProof []byte
+}
+
+// KeyID returns an identifier for the public key Y, which can be stored in place of the full key.
+func KeyID(Y curve.Point) []byte {
+	h := hash.New()
+	_ = h.WriteAny(&hash.BytesWithDomain{
+		TheDomain: "FROST Key ID",
+		Bytes:     []byte(Y.Curve().Name()),
+	}, Y)
+	return h.Sum()[:32]
+}
+
+// BuildQuorumCertificate serializes the public parts of record into a certificate,
+// after checking that the record is consistent.
+//
+// The certificate contains no secrets: only the public key, nonce commitments, signature shares,
+// and the signed time of each signer.
+// The verification shares are left out, since the verifier must already know them.
+func BuildQuorumCertificate(record *AuditRecord) ([]byte, error) {
+	if err := VerifyNonceCommitments(record); err != nil {
+		return nil, fmt.Errorf("quorum certificate: %w", err)
+	}
+	if err := verifyVerificationShares(record); err != nil {
+		return nil, fmt.Errorf("quorum certificate: %w", err)
+	}
+	if err := VerifySignerTimes(record); err != nil {
+		return nil, fmt.Errorf("quorum certificate: %w", err)
+	}
+
+	var err error
+	cert := quorumCertificateMarshal{
+		Taproot: record.Taproot,
+		KeyID:   KeyID(record.Y),
+		M:       record.M,
+		Signers: make([]quorumSignerMarshal, 0, len(record.Participants)),
+	}
+	if cert.Y, err = record.Y.MarshalBinary(); err != nil {
+		return nil, err
+	}
+	if cert.R, err = record.R.MarshalBinary(); err != nil {
+		return nil, err
+	}
+	for _, l := range party.NewIDSlice(record.Participants) {
+		signer := quorumSignerMarshal{ID: l, Time: record.Times[l].UnixNano()}
+		if signer.D, err = record.D[l].MarshalBinary(); err != nil {
+			return nil, err
+		}
+		if signer.E, err = record.E[l].MarshalBinary(); err != nil {
+			return nil, err
+		}
+		if signer.Z, err = record.Z[l].MarshalBinary(); err != nil {
+			return nil, err
+		}
+		if signer.TimeProof, err = cbor.Marshal(record.TimeProofs[l]); err != nil {
+			return nil, err
+		}
+		cert.Signers = append(cert.Signers, signer)
+	}
+	return cbor.Marshal(cert)
+}
+
+// VerifyQuorumCertificate decodes a certificate produced by BuildQuorumCertificate,
+// and checks that it was produced by a quorum of signers for the public key Y,
+// whose verification shares are verificationShares, as in the signers' Config.
+func VerifyQuorumCertificate(data []byte, Y curve.Point, verificationShares map[party.ID]curve.Point) (*QuorumCertificate, error) {
+	var cert quorumCertificateMarshal
+	if err := cbor.Unmarshal(data, &cert); err != nil {
+		return nil, fmt.Errorf("quorum certificate: %w", err)
+	}
+	if !bytes.Equal(cert.KeyID, KeyID(Y)) {
+		return nil, errors.New("quorum certificate: issued for a different key")
+	}
+
+	group := Y.Curve()
+	record := &AuditRecord{
+		Taproot:    cert.Taproot,
+		M:          cert.M,
+		Y:          group.NewPoint(),
+		R:          group.NewPoint(),
+		YShares:    make(map[party.ID]curve.Point, len(cert.Signers)),
+		D:          make(map[party.ID]curve.Point, len(cert.Signers)),
+		E:          make(map[party.ID]curve.Point, len(cert.Signers)),
+		Z:          make(map[party.ID]curve.Scalar, len(cert.Signers)),
+		Times:      make(map[party.ID]time.Time, len(cert.Signers)),
+		TimeProofs: make(map[party.ID]*zksch.Proof, len(cert.Signers)),
+	}
+	if err := record.Y.UnmarshalBinary(cert.Y); err != nil {
+		return nil, fmt.Errorf("quorum certificate: %w", err)
+	}
+	if !record.Y.Equal(Y) {
+		return nil, errors.New("quorum certificate: issued for a different key")
+	}
+	if err := record.R.UnmarshalBinary(cert.R); err != nil {
+		return nil, fmt.Errorf("quorum certificate: %w", err)
+	}
+	participants := make([]party.ID, 0, len(cert.Signers))
+	for _, signer := range cert.Signers {
+		l := signer.ID
+		if _, ok := record.Z[l]; ok {
+			return nil, fmt.Errorf("quorum certificate: duplicate signer %s", l)
+		}
+		YShare, ok := verificationShares[l]
+		if !ok {
+			return nil, fmt.Errorf("quorum certificate: unknown signer %s", l)
+		}
+		participants = append(participants, l)
+		record.YShares[l] = YShare
+		record.Times[l] = time.Unix(0, signer.Time).UTC()
+		record.TimeProofs[l] = zksch.EmptyProof(group)
+		record.D[l] = group.NewPoint()
+		record.E[l] = group.NewPoint()
+		record.Z[l] = group.NewScalar()
+		for _, decode := range []struct {
+			v    interface{ UnmarshalBinary([]byte) error }
+			data []byte
+		}{
+			{record.D[l], signer.D},
+			{record.E[l], signer.E},
+			{record.Z[l], signer.Z},
+		} {
+			if err := decode.v.UnmarshalBinary(decode.data); err != nil {
+				return nil, fmt.Errorf("quorum certificate: signer %s: %w", l, err)
+			}
+		}
+		if err := cbor.Unmarshal(signer.TimeProof, record.TimeProofs[l]); err != nil {
+			return nil, fmt.Errorf("quorum certificate: signer %s: %w", l, err)
+		}
+	}
+	record.Participants = party.NewIDSlice(participants)
+
+	if err := verifyVerificationShares(record); err != nil {
+		return nil, fmt.Errorf("quorum certificate: %w", err)
+	}
+	if err := VerifyNonceCommitments(record); err != nil {
+		return nil, fmt.Errorf("quorum certificate: %w", err)
+	}
+	if err := VerifySignerTimes(record); err != nil {
+		return nil, fmt.Errorf("quorum certificate: %w", err)
+	}
+	return &QuorumCertificate{KeyID: cert.KeyID, Record: record}, nil
+}
+
+// verifyVerificationShares checks that the participants' verification shares interpolate to Y,
+// so that their responses can only have been computed with shares of the private key.
+func verifyVerificationShares(record *AuditRecord) error {
+	group := record.Y.Curve()
+	lambda := polynomial.Lagrange(group, record.Participants)
+	Y := group.NewPoint()
+	for _, l := range record.Participants {
+		YShare, ok := record.YShares[l]
+		if !ok {
+			return fmt.Errorf("missing verification share for %s", l)
+		}
+		Y = Y.Add(lambda[l].Act(YShare))
+	}
+	if !Y.Equal(record.Y) {
+		return errors.New("verification shares don't match the public key")
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package sign
 
 import (
-	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
 
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
@@ -27,6 +29,12 @@ type round1 struct {
 	// and we need to make sure to generate our challenge in the correct way. Naturally,
 	// we also return a taproot.Signature instead a generic signature.
 	taproot bool
+	// audit indicates whether an AuditRecord should be returned along with the signature.
+	audit bool
+	// paranoid enables the additional checks described in Options.
+	paranoid bool
+	// nonces produces our nonces outside of the protocol, or is nil to derive them here.
+	nonces NonceSource
 	// M is the hash of the message we're signing.
 	//
 	// This plays the same role as m in the Frost paper. One slight difference
@@ -56,32 +64,15 @@ func (r *round1) Finalize(out chan<- *round.Message) (round.Session, error) {
 	// to generate two nonces (dᵢ, eᵢ) in Z/(q)ˣ, then two commitments
 	// Dᵢ = dᵢ * G, Eᵢ = eᵢ * G, and then broadcast them.
 
-	// We use a hedged deterministic process, instead of simply sampling (d_i, e_i):
-	//
-	//   a = random()
-	//   hk = KDF(s_i)
-	//   (d_i, e_i) = H_hk(ctx, m, a)
-	//
-	// This protects against bad randomness, since a constant value for a is still unpredictable,
-	// and fault attacks against the hash function, because of the randomness.
-	s_iBytes, err := r.s_i.MarshalBinary()
+	if r.nonces != nil {
+		return r.finalizeExternal(out)
+	}
+
+	d_i, e_i, err := deriveNonces(r.Rand(), r.s_i, r.Hash().Sum(), r.M)
 	if err != nil {
 		return r, err
 	}
 
-	hashKey := make([]byte, 32)
-	blake3.DeriveKey(deriveHashKeyContext, s_iBytes[:], hashKey)
-	nonceHasher, _ := blake3.NewKeyed(hashKey)
-	_, _ = nonceHasher.Write(r.Hash().Sum())
-	_, _ = nonceHasher.Write(r.M)
-	a := make([]byte, 32)
-	_, _ = rand.Read(a)
-	_, _ = nonceHasher.Write(a)
-	nonceDigest := nonceHasher.Digest()
-
-	d_i := sample.ScalarUnit(nonceDigest, r.Group())
-	e_i := sample.ScalarUnit(nonceDigest, r.Group())
-
 	D_i := d_i.ActOnBase()
 	E_i := e_i.ActOnBase()
 
@@ -99,8 +90,67 @@ func (r *round1) Finalize(out chan<- *round.Message) (round.Session, error) {
 	}, nil
 }
 
+// finalizeExternal is like Finalize, but gets the commitments from r.nonces,
+// and broadcasts them along with their proofs of knowledge.
+func (r *round1) finalizeExternal(out chan<- *round.Message) (round.Session, error) {
+	D_i, E_i, proofD, proofE, err := r.nonces.Commit(r.HashForID(r.SelfID()))
+	if err != nil {
+		return r, fmt.Errorf("nonce source: %w", err)
+	}
+	if D_i == nil || E_i == nil || proofD == nil || proofE == nil {
+		return r, errors.New("nonce source: nil commitment or proof")
+	}
+	if D_i.IsIdentity() || E_i.IsIdentity() {
+		return r, errors.New("nonce source: nonce commitment is the identity point")
+	}
+	if !verifyNonceProofs(r.HashForID(r.SelfID()), D_i, E_i, proofD, proofE) {
+		return r, errors.New("nonce source: invalid proof of knowledge")
+	}
+
+	err = r.BroadcastMessage(out, &broadcast2{D_i: D_i, E_i: E_i, ProofD: proofD, ProofE: proofE})
+	if err != nil {
+		return r, err
+	}
+	return &round2{
+		round1: r,
+		D:      map[party.ID]curve.Point{r.SelfID(): D_i},
+		E:      map[party.ID]curve.Point{r.SelfID(): E_i},
+	}, nil
+}
+
 // MessageContent implements round.Round.
 func (round1) MessageContent() round.Content { return nil }
 
 // Number implements round.Round.
 func (round1) Number() round.Number { return 1 }
+
+// deriveNonces generates the nonces (dᵢ, eᵢ) of the party with secret share s_i.
+//
+// We use a hedged deterministic process, instead of simply sampling (d_i, e_i):
+//
+//	a = random()
+//	hk = KDF(s_i)
+//	(d_i, e_i) = H_hk(ctx, m, a)
+//
+// This protects against bad randomness, since a constant value for a is still unpredictable,
+// and fault attacks against the hash function, because of the randomness.
+func deriveNonces(source io.Reader, s_i curve.Scalar, ctx, m []byte) (d_i, e_i curve.Scalar, err error) {
+	s_iBytes, err := s_i.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashKey := make([]byte, 32)
+	blake3.DeriveKey(deriveHashKeyContext, s_iBytes[:], hashKey)
+	nonceHasher, _ := blake3.NewKeyed(hashKey)
+	_, _ = nonceHasher.Write(ctx)
+	_, _ = nonceHasher.Write(m)
+	a := make([]byte, 32)
+	_, _ = io.ReadFull(source, a)
+	_, _ = nonceHasher.Write(a)
+	nonceDigest := nonceHasher.Digest()
+
+	d_i = sample.ScalarUnit(nonceDigest, s_i.Curve())
+	e_i = sample.ScalarUnit(nonceDigest, s_i.Curve())
+	return d_i, e_i, nil
+}
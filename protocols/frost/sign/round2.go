@@ -1,16 +1,15 @@
 package sign
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
-	"github.com/cronokirby/safenum"
 	"github.com/koteld/multi-party-sig/internal/round"
-	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/math/polynomial"
-	"github.com/koteld/multi-party-sig/pkg/math/sample"
 	"github.com/koteld/multi-party-sig/pkg/party"
-	"github.com/koteld/multi-party-sig/pkg/taproot"
+	zksch "github.com/koteld/multi-party-sig/pkg/zk/sch"
 )
 
 // This round roughly corresponds with steps 3-6 of Figure 3 in the Frost paper:
@@ -25,9 +24,9 @@ import (
 // to everyone instead.
 type round2 struct {
 	*round1
-	// d_i = dᵢ is the first nonce we've created.
+	// d_i = dᵢ is the first nonce we've created, or nil if our nonces are produced by r.nonces.
 	d_i curve.Scalar
-	// e_i = eᵢ is the second nonce we've created.
+	// e_i = eᵢ is the second nonce we've created, or nil if our nonces are produced by r.nonces.
 	e_i curve.Scalar
 	// D[i] = Dᵢ will contain all of the commitments created by each party, ourself included.
 	D map[party.ID]curve.Point
@@ -41,6 +40,10 @@ type broadcast2 struct {
 	D_i curve.Point
 	// E_i is the second commitment produced by the sender of this message.
 	E_i curve.Point
+	// ProofD and ProofE prove the knowledge of the nonces of D_i and E_i.
+	//
+	// They are only sent by a party whose nonces come from a NonceSource, and are nil otherwise.
+	ProofD, ProofE *zksch.Proof
 }
 
 // StoreBroadcastMessage implements round.BroadcastRound.
@@ -66,6 +69,21 @@ func (r *round2) StoreBroadcastMessage(msg round.Message) error {
 	if body.D_i.IsIdentity() || body.E_i.IsIdentity() {
 		return fmt.Errorf("nonce commitment is the identity point")
 	}
+	if r.paranoid {
+		if err := r.Group().ValidatePoint(body.D_i); err != nil {
+			return fmt.Errorf("nonce commitment D: %w", err)
+		}
+		if err := r.Group().ValidatePoint(body.E_i); err != nil {
+			return fmt.Errorf("nonce commitment E: %w", err)
+		}
+	}
+
+	if (body.ProofD == nil) != (body.ProofE == nil) {
+		return errors.New("missing proof of knowledge for one of the nonces")
+	}
+	if body.ProofD != nil && !verifyNonceProofs(r.HashForID(msg.From), body.D_i, body.E_i, body.ProofD, body.ProofE) {
+		return errors.New("invalid proof of knowledge of the nonces")
+	}
 
 	r.D[msg.From] = body.D_i
 	r.E[msg.From] = body.E_i
@@ -86,58 +104,27 @@ func (r *round2) Finalize(out chan<- *round.Message) (round.Session, error) {
 	// Each Pᵢ then derives the group commitment R = ∑ₗ Dₗ + ρₗ * Eₗ and
 	// the challenge c = H₂(R, Y, m)."
 	//
-	// It's easier to calculate H(m, B, l), that way we can simply clone the hash
-	// state after H(m, B), instead of rehashing them each time.
-	//
-	// We also use a hash of the message, instead of the message directly.
-
-	rho := make(map[party.ID]curve.Scalar)
-	// This calculates H(m, B), allowing us to avoid re-hashing this data for
-	// each extra party l.
-	rhoPreHash := hash.New()
-	_ = rhoPreHash.WriteAny(r.M)
-	for _, l := range r.PartyIDs() {
-		_ = rhoPreHash.WriteAny(r.D[l], r.E[l])
-	}
-	for _, l := range r.PartyIDs() {
-		rhoHash := rhoPreHash.Clone()
-		_ = rhoHash.WriteAny(l)
-		rho[l] = sample.Scalar(rhoHash.Digest(), r.Group())
-	}
-
-	R := r.Group().NewPoint()
-	RShares := make(map[party.ID]curve.Point)
-	for _, l := range r.PartyIDs() {
-		RShares[l] = rho[l].Act(r.E[l])
-		RShares[l] = RShares[l].Add(r.D[l])
-		R = R.Add(RShares[l])
-	}
-	var c curve.Scalar
+	// We use a hash of the message, instead of the message directly.
+	R, RShares, rho := groupCommitment(r.Group(), r.M, r.PartyIDs(), r.D, r.E)
+	negate := false
 	if r.taproot {
 		// BIP-340 adjustment: We need R to have an even y coordinate. This means
 		// conditionally negating k = ∑ᵢ (dᵢ + (eᵢ ρᵢ)), which we can accomplish
 		// by negating our dᵢ, eᵢ, if necessary. This entails negating the RShares
 		// as well.
-		RSecp := R.(*curve.Secp256k1Point)
-		if !RSecp.HasEvenY() {
-			r.d_i.Negate()
-			r.e_i.Negate()
+		if negate = !R.(*curve.Secp256k1Point).HasEvenY(); negate {
+			if r.nonces == nil {
+				r.d_i.Negate()
+				r.e_i.Negate()
+			}
 			for _, l := range r.PartyIDs() {
 				RShares[l] = RShares[l].Negate()
 			}
 		}
-
-		// BIP-340 adjustment: we need to calculate our hash as specified in:
-		// https://github.com/bitcoin/bips/blob/master/bip-0340.mediawiki#default-signing
-		RBytes := RSecp.XBytes()
-		PBytes := r.Y.(*curve.Secp256k1Point).XBytes()
-		cHash := taproot.TaggedHash("BIP0340/challenge", RBytes, PBytes, r.M)
-		c = r.Group().NewScalar().SetNat(new(safenum.Nat).SetBytes(cHash))
-	} else {
-		cHash := hash.New()
-		_ = cHash.WriteAny(R, r.Y, r.M)
-		c = sample.Scalar(cHash.Digest(), r.Group())
 	}
+	// BIP-340 adjustment: when using taproot, we need to calculate our hash as specified in:
+	// https://github.com/bitcoin/bips/blob/master/bip-0340.mediawiki#default-signing
+	c := challenge(r.Group(), r.taproot, R, r.Y, r.M)
 
 	// Lambdas[i] = λᵢ
 	Lambdas := polynomial.Lagrange(r.Group(), r.PartyIDs())
@@ -145,9 +132,30 @@ func (r *round2) Finalize(out chan<- *round.Message) (round.Session, error) {
 	// by computing zᵢ = dᵢ + (eᵢ ρᵢ) + λᵢ sᵢ c, using S to determine
 	// the ith lagrange coefficient λᵢ"
 	z_i := r.Group().NewScalar().Set(Lambdas[r.SelfID()]).Mul(r.s_i).Mul(c)
-	z_i.Add(r.d_i)
-	ed := r.Group().NewScalar().Set(rho[r.SelfID()]).Mul(r.e_i)
-	z_i.Add(ed)
+	if r.nonces != nil {
+		// The nonce source computes dᵢ + (eᵢ ρᵢ) itself, which must match our share Rᵢ of R.
+		k_i, err := r.nonces.Respond(r.Group().NewScalar().Set(rho[r.SelfID()]), negate)
+		if err != nil {
+			return r, fmt.Errorf("nonce source: %w", err)
+		}
+		if k_i == nil || !k_i.ActOnBase().Equal(RShares[r.SelfID()]) {
+			return r, errors.New("nonce source: response doesn't match the nonce commitments")
+		}
+		z_i.Add(k_i)
+	} else {
+		z_i.Add(r.d_i)
+		ed := r.Group().NewScalar().Set(rho[r.SelfID()]).Mul(r.e_i)
+		z_i.Add(ed)
+	}
+
+	// Make sure our response will pass the check done by the others in round 3:
+	// zᵢ • G = Rᵢ + c * λᵢ * Yᵢ
+	if r.paranoid {
+		expected := c.Act(Lambdas[r.SelfID()].Act(r.YShares[r.SelfID()])).Add(RShares[r.SelfID()])
+		if !z_i.ActOnBase().Equal(expected) {
+			return r.AbortRound(errors.New("our own response failed to verify"), r.SelfID()), nil
+		}
+	}
 
 	// 6. "Each Pᵢ securely deletes ((dᵢ, Dᵢ), (eᵢ, Eᵢ)) from their local storage,
 	// and returns zᵢ to SA."
@@ -157,19 +165,28 @@ func (r *round2) Finalize(out chan<- *round.Message) (round.Session, error) {
 	// TODO: Securely delete the nonces.
 
 	// Broadcast our response
-	err := r.BroadcastMessage(out, &broadcast3{Z_i: z_i})
-	if err != nil {
-		return r, err
-	}
-
-	return &round3{
+	msg := &broadcast3{Z_i: z_i}
+	next := &round3{
 		round2:  r,
 		R:       R,
 		RShares: RShares,
 		c:       c,
 		z:       map[party.ID]curve.Scalar{r.SelfID(): z_i},
 		Lambda:  Lambdas,
-	}, nil
+	}
+	if r.audit {
+		// Sign the time of our response with our share, so that it can't be changed in a QuorumCertificate
+		msg.Time = time.Now().UnixNano()
+		msg.TimeProof = zksch.NewProofFromSource(r.Rand(), timeHash(r.Y, R, r.M, r.SelfID(), msg.Time), r.YShares[r.SelfID()], r.s_i, nil)
+		next.times = map[party.ID]time.Time{r.SelfID(): time.Unix(0, msg.Time).UTC()}
+		next.timeProofs = map[party.ID]*zksch.Proof{r.SelfID(): msg.TimeProof}
+	}
+	err := r.BroadcastMessage(out, msg)
+	if err != nil {
+		return r, err
+	}
+
+	return next, nil
 }
 
 // MessageContent implements round.Round.
@@ -181,8 +198,10 @@ func (broadcast2) RoundNumber() round.Number { return 2 }
 // BroadcastContent implements round.BroadcastRound.
 func (r *round2) BroadcastContent() round.BroadcastContent {
 	return &broadcast2{
-		D_i: r.Group().NewPoint(),
-		E_i: r.Group().NewPoint(),
+		D_i:    r.Group().NewPoint(),
+		E_i:    r.Group().NewPoint(),
+		ProofD: zksch.EmptyProof(r.Group()),
+		ProofE: zksch.EmptyProof(r.Group()),
 	}
 }
 
@@ -2,15 +2,18 @@ package sign
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/taproot"
+	zksch "github.com/koteld/multi-party-sig/pkg/zk/sch"
 )
 
 // This corresponds with step 7 of Figure 3 in the Frost paper:
-//   https://eprint.iacr.org/2020/852.pdf
+//
+//	https://eprint.iacr.org/2020/852.pdf
 //
 // The big difference, once again, stems from their being no signing authority.
 // Instead, each participant calculates the signature on their own.
@@ -32,12 +35,21 @@ type round3 struct {
 	// Lambda contains all Lagrange coefficients of the parties participating in this session.
 	// Lambda[l] = λₗ
 	Lambda map[party.ID]curve.Scalar
+
+	// times[l] is when l computed its response, and timeProofs[l] its signature of that time.
+	// They are only set in audited sessions.
+	times      map[party.ID]time.Time
+	timeProofs map[party.ID]*zksch.Proof
 }
 
 type broadcast3 struct {
 	round.NormalBroadcastContent
 	// Z_i is the response scalar computed by the sender of this message.
 	Z_i curve.Scalar
+	// Time is when the sender computed Z_i, in nanoseconds since the Unix epoch,
+	// and TimeProof its signature of it. They are only sent in audited sessions.
+	Time      int64        `cbor:",omitempty"`
+	TimeProof *zksch.Proof `cbor:",omitempty"`
 }
 
 // StoreBroadcastMessage implements round.BroadcastRound.
@@ -73,6 +85,14 @@ func (r *round3) StoreBroadcastMessage(msg round.Message) error {
 		return fmt.Errorf("failed to verify response from %v", from)
 	}
 
+	if r.audit {
+		if body.TimeProof == nil || !body.TimeProof.Verify(timeHash(r.Y, r.R, r.M, from, body.Time), r.YShares[from], nil) {
+			return fmt.Errorf("failed to verify the time of the response from %v", from)
+		}
+		r.times[from] = time.Unix(0, body.Time).UTC()
+		r.timeProofs[from] = body.TimeProof
+	}
+
 	r.z[from] = body.Z_i
 
 	return nil
@@ -110,7 +130,7 @@ func (r *round3) Finalize(chan<- *round.Message) (round.Session, error) {
 			return r.AbortRound(fmt.Errorf("generated signature failed to verify")), nil
 		}
 
-		return r.ResultRound(sig), nil
+		return r.ResultRound(r.result(sig)), nil
 	} else {
 		sig := Signature{
 			R: r.R,
@@ -121,7 +141,34 @@ func (r *round3) Finalize(chan<- *round.Message) (round.Session, error) {
 			return r.AbortRound(fmt.Errorf("generated signature failed to verify")), nil
 		}
 
-		return r.ResultRound(sig), nil
+		return r.ResultRound(r.result(sig)), nil
+	}
+}
+
+// result wraps the signature in an AuditedSignature, if an audit record was requested.
+func (r *round3) result(sig interface{}) interface{} {
+	if !r.audit {
+		return sig
+	}
+	YShares := make(map[party.ID]curve.Point, len(r.PartyIDs()))
+	for _, l := range r.PartyIDs() {
+		YShares[l] = r.YShares[l]
+	}
+	return &AuditedSignature{
+		Signature: sig,
+		Record: &AuditRecord{
+			Taproot:      r.taproot,
+			Participants: r.PartyIDs().Copy(),
+			M:            r.M,
+			Y:            r.Y,
+			YShares:      YShares,
+			D:            r.D,
+			E:            r.E,
+			R:            r.R,
+			Z:            r.z,
+			Times:        r.times,
+			TimeProofs:   r.timeProofs,
+		},
 	}
 }
 
@@ -133,9 +180,13 @@ func (broadcast3) RoundNumber() round.Number { return 3 }
 
 // BroadcastContent implements round.BroadcastRound.
 func (r *round3) BroadcastContent() round.BroadcastContent {
-	return &broadcast3{
+	content := &broadcast3{
 		Z_i: r.Group().NewScalar(),
 	}
+	if r.audit {
+		content.TimeProof = zksch.EmptyProof(r.Group())
+	}
+	return content
 }
 
 // Number implements round.Round.
@@ -1,9 +1,12 @@
 package sign
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/koteld/multi-party-sig/internal/round"
+	"github.com/koteld/multi-party-sig/pkg/math/curve"
+	"github.com/koteld/multi-party-sig/pkg/math/polynomial"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/protocol"
 	"github.com/koteld/multi-party-sig/protocols/frost/keygen"
@@ -17,7 +20,37 @@ const (
 	protocolRounds round.Number = 3
 )
 
+// Options modify the behavior of a signing session.
+type Options struct {
+	// Audit makes the result an *AuditedSignature, see StartSignAudited.
+	Audit bool
+	// Paranoid enables checks which are redundant when every party, including us, behaves correctly:
+	//
+	//   - our own config is consistent with the public key, before anything is sent,
+	//   - every point received is validated again,
+	//   - our own response is verified before it is sent,
+	//   - the precomputed multiples of the base point are checked, see curve.VerifyBaseTable.
+	//
+	// The final signature is verified against the public key in every mode.
+	Paranoid bool
+	// Nonces, if set, produces our nonces instead of the protocol sampling them, see NonceSource.
+	//
+	// Its proofs of knowledge are verified, and broadcast so that the other signers verify them too.
+	Nonces NonceSource
+}
+
 func StartSignCommon(taproot bool, result *keygen.Config, signers []party.ID, messageHash []byte) protocol.StartFunc {
+	return StartSignWithOptions(taproot, Options{}, result, signers, messageHash)
+}
+
+// StartSignAudited is like StartSignCommon, but the result is an *AuditedSignature,
+// containing the transcript needed to check the signers' nonce commitments afterwards.
+func StartSignAudited(taproot bool, result *keygen.Config, signers []party.ID, messageHash []byte) protocol.StartFunc {
+	return StartSignWithOptions(taproot, Options{Audit: true}, result, signers, messageHash)
+}
+
+// StartSignWithOptions is like StartSignCommon, with the behavior modified by options.
+func StartSignWithOptions(taproot bool, options Options, result *keygen.Config, signers []party.ID, messageHash []byte) protocol.StartFunc {
 	return func(sessionID []byte) (round.Session, error) {
 		info := round.Info{
 			FinalRoundNumber: protocolRounds,
@@ -25,24 +58,59 @@ func StartSignCommon(taproot bool, result *keygen.Config, signers []party.ID, me
 			PartyIDs:         signers,
 			Threshold:        result.Threshold,
 			Group:            result.PublicKey.Curve(),
-		}
-		if taproot {
-			info.ProtocolID = protocolIDTaproot
-		} else {
-			info.ProtocolID = protocolID
+			ProtocolID:       protocolIDFor(taproot),
 		}
 
 		helper, err := round.NewSession(info, sessionID, nil)
 		if err != nil {
 			return nil, fmt.Errorf("sign.StartSign: %w", err)
 		}
+		if options.Paranoid {
+			if err = curve.VerifyBaseTable(info.Group); err != nil {
+				return nil, fmt.Errorf("sign.StartSign: %w", err)
+			}
+			if err = checkConfig(result, helper.PartyIDs()); err != nil {
+				return nil, fmt.Errorf("sign.StartSign: %w", err)
+			}
+		}
 		return &round1{
-			Helper:  helper,
-			taproot: taproot,
-			M:       messageHash,
-			Y:       result.PublicKey,
-			YShares: result.VerificationShares.Points,
-			s_i:     result.PrivateShare,
+			Helper:   helper,
+			taproot:  taproot,
+			audit:    options.Audit,
+			paranoid: options.Paranoid,
+			nonces:   options.Nonces,
+			M:        messageHash,
+			Y:        result.PublicKey,
+			YShares:  result.VerificationShares.Points,
+			s_i:      result.PrivateShare,
 		}, nil
 	}
 }
+
+// checkConfig verifies that our secret share matches our verification share, and that the
+// verification shares of the signers interpolate to the public key.
+func checkConfig(result *keygen.Config, signers party.IDSlice) error {
+	group := result.PublicKey.Curve()
+	if err := group.ValidatePoint(result.PublicKey); err != nil {
+		return fmt.Errorf("public key: %w", err)
+	}
+	if !result.PrivateShare.ActOnBase().Equal(result.VerificationShares.Points[result.ID]) {
+		return errors.New("private share doesn't match our verification share")
+	}
+	lambda := polynomial.Lagrange(group, signers)
+	Y := group.NewPoint()
+	for _, l := range signers {
+		Y_l, ok := result.VerificationShares.Points[l]
+		if !ok {
+			return fmt.Errorf("missing verification share for %s", l)
+		}
+		if err := group.ValidatePoint(Y_l); err != nil {
+			return fmt.Errorf("verification share of %s: %w", l, err)
+		}
+		Y = Y.Add(lambda[l].Act(Y_l))
+	}
+	if !Y.Equal(result.PublicKey) {
+		return errors.New("verification shares of the signers don't match the public key")
+	}
+	return nil
+}
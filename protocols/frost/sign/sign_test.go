@@ -4,15 +4,18 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"testing"
+	"time"
 
 	"github.com/koteld/multi-party-sig/internal/params"
 	"github.com/koteld/multi-party-sig/internal/round"
 	"github.com/koteld/multi-party-sig/internal/test"
+	"github.com/koteld/multi-party-sig/pkg/hash"
 	"github.com/koteld/multi-party-sig/pkg/math/curve"
 	"github.com/koteld/multi-party-sig/pkg/math/polynomial"
 	"github.com/koteld/multi-party-sig/pkg/math/sample"
 	"github.com/koteld/multi-party-sig/pkg/party"
 	"github.com/koteld/multi-party-sig/pkg/taproot"
+	zksch "github.com/koteld/multi-party-sig/pkg/zk/sch"
 	"github.com/koteld/multi-party-sig/protocols/frost/keygen"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -166,3 +169,435 @@ func TestSignTaproot(t *testing.T) {
 
 	checkOutputTaproot(t, rounds, newPublicKey, steak)
 }
+
+// signAudited runs an audited signing session on a fresh key, returning the key, the signers, and their results.
+func signAudited(t *testing.T, m []byte) (curve.Point, party.IDSlice, []*AuditedSignature) {
+	group := curve.Secp256k1{}
+	N := 5
+	threshold := 2
+
+	partyIDs := test.PartyIDs(N)
+	signers := partyIDs[1 : threshold+2]
+
+	secret := sample.Scalar(rand.Reader, group)
+	f := polynomial.NewPolynomial(group, threshold, secret)
+	publicKey := secret.ActOnBase()
+
+	verificationShares := make(map[party.ID]curve.Point, N)
+	privateShares := make(map[party.ID]curve.Scalar, N)
+	for _, id := range partyIDs {
+		privateShares[id] = f.Evaluate(id.Scalar(group))
+		verificationShares[id] = privateShares[id].ActOnBase()
+	}
+
+	rounds := make([]round.Session, 0, len(signers))
+	for _, id := range signers {
+		result := &keygen.Config{
+			ID:                 id,
+			Threshold:          threshold,
+			PublicKey:          publicKey,
+			PrivateShare:       privateShares[id],
+			VerificationShares: party.NewPointMap(verificationShares),
+		}
+		r, err := StartSignAudited(false, result, signers, m)(nil)
+		require.NoError(t, err, "round creation should not result in an error")
+		rounds = append(rounds, r)
+	}
+	for {
+		err, done := test.Rounds(rounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if done {
+			break
+		}
+	}
+
+	results := make([]*AuditedSignature, 0, len(rounds))
+	for _, r := range rounds {
+		require.IsType(t, &round.Output{}, r, "expected result round")
+		audited, ok := r.(*round.Output).Result.(*AuditedSignature)
+		require.True(t, ok, "expected an audited signature")
+		results = append(results, audited)
+	}
+	return publicKey, signers, results
+}
+
+func TestSignAudited(t *testing.T) {
+	group := curve.Secp256k1{}
+	partyIDs := test.PartyIDs(5)
+	steak := []byte{0xDE, 0xAD, 0xBE, 0xEF}
+	publicKey, signers, results := signAudited(t, steak)
+	threshold := len(signers) - 1
+
+	for _, audited := range results {
+		assert.True(t, audited.Signature.(Signature).Verify(publicKey, steak), "expected valid signature")
+		assert.NoError(t, VerifyNonceCommitments(audited.Record))
+		assert.Equal(t, signers, audited.Record.Participants, "participants should be the signers")
+	}
+
+	// claiming a different set of participants is caught
+	record := *results[0].Record
+	record.Participants = partyIDs[:threshold+1]
+	assert.Error(t, VerifyNonceCommitments(&record))
+	record.Participants = signers
+
+	// swapping a nonce commitment after the fact changes R
+	record.D = make(map[party.ID]curve.Point, len(signers))
+	for id, D := range results[0].Record.D {
+		record.D[id] = D
+	}
+	record.D[signers[1]] = sample.Scalar(rand.Reader, group).ActOnBase()
+	assert.Error(t, VerifyNonceCommitments(&record))
+
+	// a forged R, consistent with the forged commitment, exposes the response instead
+	record.R, _, _ = groupCommitment(group, record.M, signers, record.D, record.E)
+	assert.Error(t, VerifyNonceCommitments(&record))
+}
+
+func TestQuorumCertificate(t *testing.T) {
+	group := curve.Secp256k1{}
+	m := []byte("quorum")
+	publicKey, signers, results := signAudited(t, m)
+	record := results[0].Record
+	verificationShares := record.YShares
+
+	assert.NoError(t, VerifySignerTimes(record))
+	for _, audited := range results[1:] {
+		for _, l := range signers {
+			assert.True(t, record.Times[l].Equal(audited.Record.Times[l]), "every signer should record the same times")
+		}
+	}
+
+	data, err := BuildQuorumCertificate(record)
+	require.NoError(t, err)
+	cert, err := VerifyQuorumCertificate(data, publicKey, verificationShares)
+	require.NoError(t, err)
+	assert.Equal(t, KeyID(publicKey), cert.KeyID)
+	assert.Equal(t, m, cert.Record.M)
+	assert.Equal(t, signers, cert.Record.Participants)
+	for _, l := range signers {
+		assert.True(t, record.Times[l].Equal(cert.Record.Times[l]))
+	}
+
+	_, err = VerifyQuorumCertificate(data, sample.Scalar(rand.Reader, group).ActOnBase(), verificationShares)
+	assert.Error(t, err, "certificate accepted for another key")
+
+	// the verification shares are the ones the verifier expects, not whatever the certificate claims
+	otherShares := make(map[party.ID]curve.Point, len(verificationShares))
+	for l, YShare := range verificationShares {
+		otherShares[l] = YShare
+	}
+	otherShares[signers[0]] = sample.Scalar(rand.Reader, group).ActOnBase()
+	_, err = VerifyQuorumCertificate(data, publicKey, otherShares)
+	assert.Error(t, err, "certificate accepted for other verification shares")
+	delete(otherShares, signers[0])
+	_, err = VerifyQuorumCertificate(data, publicKey, otherShares)
+	assert.Error(t, err, "certificate accepted with an unknown signer")
+
+	// flipping any single byte, including the times, must not produce a valid certificate
+	for i := range data {
+		tampered := append([]byte(nil), data...)
+		tampered[i] ^= 0x01
+		_, err := VerifyQuorumCertificate(tampered, publicKey, verificationShares)
+		assert.Error(t, err, "tampering with byte %d wasn't detected", i)
+	}
+
+	// a time changed after the fact isn't covered by the signer's proof
+	forged := *record
+	forged.Times = make(map[party.ID]time.Time, len(signers))
+	for l, at := range record.Times {
+		forged.Times[l] = at
+	}
+	forged.Times[signers[1]] = record.Times[signers[1]].Add(-time.Hour)
+	_, err = BuildQuorumCertificate(&forged)
+	assert.Error(t, err)
+
+	// dropping a signer breaks both the group commitment and the interpolation of Y
+	forged = *record
+	forged.Participants = signers[1:]
+	_, err = BuildQuorumCertificate(&forged)
+	assert.Error(t, err)
+}
+
+func TestSignWithCoordinator(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 5
+	threshold := 2
+
+	partyIDs := test.PartyIDs(N)
+	signers := partyIDs[:threshold+1]
+
+	secret := sample.Scalar(rand.Reader, group)
+	publicKey := secret.ActOnBase()
+	// taproot needs a public key with an even y coordinate
+	if !publicKey.(*curve.Secp256k1Point).HasEvenY() {
+		secret.Negate()
+		publicKey = secret.ActOnBase()
+	}
+	f := polynomial.NewPolynomial(group, threshold, secret)
+	verificationShares := make(map[party.ID]curve.Point, N)
+	configs := make(map[party.ID]*keygen.Config, N)
+	for _, id := range partyIDs {
+		privateShare := f.Evaluate(id.Scalar(group))
+		verificationShares[id] = privateShare.ActOnBase()
+		configs[id] = &keygen.Config{
+			ID:           id,
+			Threshold:    threshold,
+			PublicKey:    publicKey,
+			PrivateShare: privateShare,
+		}
+	}
+	for _, id := range partyIDs {
+		configs[id].VerificationShares = party.NewPointMap(verificationShares)
+	}
+	m := []byte("coordinated")
+
+	// run starts a session, letting tamper modify the package sent to each signer.
+	run := func(useTaproot bool, tamper func(to party.ID, pkg *SigningPackage) *SigningPackage) (interface{}, error) {
+		coordinator, err := NewCoordinator(useTaproot, publicKey, configs[signers[0]].VerificationShares, threshold, signers, m)
+		require.NoError(t, err)
+		parties := make(map[party.ID]*Signer, len(signers))
+		for _, id := range signers {
+			parties[id], err = NewSigner(useTaproot, configs[id], signers, m)
+			require.NoError(t, err)
+			D, E := parties[id].Commitments()
+			require.NoError(t, coordinator.AddCommitments(id, D, E))
+		}
+		pkg, err := coordinator.SigningPackage()
+		require.NoError(t, err)
+
+		responses := make(map[party.ID]curve.Scalar, len(signers))
+		for _, id := range signers {
+			z, err := parties[id].Sign(tamper(id, pkg))
+			if err != nil {
+				return nil, err
+			}
+			responses[id] = z
+		}
+		return coordinator.Aggregate(responses)
+	}
+	honest := func(_ party.ID, pkg *SigningPackage) *SigningPackage { return pkg }
+
+	result, err := run(false, honest)
+	require.NoError(t, err)
+	require.IsType(t, Signature{}, result)
+	assert.True(t, result.(Signature).Verify(publicKey, m))
+
+	result, err = run(true, honest)
+	require.NoError(t, err)
+	require.IsType(t, taproot.Signature{}, result)
+	assert.True(t, taproot.PublicKey(publicKey.(*curve.Secp256k1Point).XBytes()).Verify(result.(taproot.Signature), m))
+
+	// replacing a signer's own commitment is caught by that signer
+	_, err = run(false, func(to party.ID, pkg *SigningPackage) *SigningPackage {
+		if to != signers[1] {
+			return pkg
+		}
+		tampered := copySigningPackage(pkg)
+		tampered.D[signers[1]] = sample.Scalar(rand.Reader, group).ActOnBase()
+		return tampered
+	})
+	assert.EqualError(t, err, "sign.Signer: our nonce commitments were modified")
+
+	// replacing someone else's commitment makes the response inconsistent with B
+	_, err = run(false, func(to party.ID, pkg *SigningPackage) *SigningPackage {
+		if to != signers[0] {
+			return pkg
+		}
+		tampered := copySigningPackage(pkg)
+		tampered.E[signers[2]] = sample.Scalar(rand.Reader, group).ActOnBase()
+		return tampered
+	})
+	assert.EqualError(t, err, "sign.Coordinator: failed to verify response from "+string(signers[0]))
+
+	// dropping a signer from B is caught by everyone
+	_, err = run(false, func(_ party.ID, pkg *SigningPackage) *SigningPackage {
+		tampered := copySigningPackage(pkg)
+		delete(tampered.D, signers[2])
+		delete(tampered.E, signers[2])
+		return tampered
+	})
+	assert.Error(t, err)
+}
+
+func copySigningPackage(pkg *SigningPackage) *SigningPackage {
+	c := &SigningPackage{
+		M: pkg.M,
+		D: make(map[party.ID]curve.Point, len(pkg.D)),
+		E: make(map[party.ID]curve.Point, len(pkg.E)),
+	}
+	for l := range pkg.D {
+		c.D[l] = pkg.D[l]
+		c.E[l] = pkg.E[l]
+	}
+	return c
+}
+
+func TestSignParanoid(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 5
+	threshold := 2
+
+	partyIDs := test.PartyIDs(N)
+	signers := partyIDs[:threshold+1]
+
+	secret := sample.Scalar(rand.Reader, group)
+	f := polynomial.NewPolynomial(group, threshold, secret)
+	publicKey := secret.ActOnBase()
+	steak := []byte{0xDE, 0xAD, 0xBE, 0xEF}
+
+	verificationShares := make(map[party.ID]curve.Point, N)
+	privateShares := make(map[party.ID]curve.Scalar, N)
+	for _, id := range partyIDs {
+		privateShares[id] = f.Evaluate(id.Scalar(group))
+		verificationShares[id] = privateShares[id].ActOnBase()
+	}
+	newConfig := func(id party.ID) *keygen.Config {
+		return &keygen.Config{
+			ID:                 id,
+			Threshold:          threshold,
+			PublicKey:          publicKey,
+			PrivateShare:       privateShares[id],
+			VerificationShares: party.NewPointMap(verificationShares),
+		}
+	}
+	paranoid := Options{Paranoid: true}
+
+	rounds := make([]round.Session, 0, len(signers))
+	for _, id := range signers {
+		r, err := StartSignWithOptions(false, paranoid, newConfig(id), signers, steak)(nil)
+		require.NoError(t, err, "round creation should not result in an error")
+		rounds = append(rounds, r)
+	}
+	for {
+		err, done := test.Rounds(rounds, nil)
+		require.NoError(t, err, "failed to process round")
+		if done {
+			break
+		}
+	}
+	for _, r := range rounds {
+		require.IsType(t, &round.Output{}, r, "expected result round")
+		assert.True(t, r.(*round.Output).Result.(Signature).Verify(publicKey, steak), "expected valid signature")
+	}
+
+	// a corrupted verification share of another signer is only caught up front in paranoid mode
+	corrupted := newConfig(signers[0])
+	corrupted.VerificationShares.Points[signers[1]] = sample.Scalar(rand.Reader, group).ActOnBase()
+	_, err := StartSignWithOptions(false, Options{}, corrupted, signers, steak)(nil)
+	assert.NoError(t, err)
+	_, err = StartSignWithOptions(false, paranoid, corrupted, signers, steak)(nil)
+	assert.Error(t, err)
+
+	// so is a corrupted private share
+	corrupted = newConfig(signers[0])
+	corrupted.PrivateShare = sample.Scalar(rand.Reader, group)
+	_, err = StartSignWithOptions(false, Options{}, corrupted, signers, steak)(nil)
+	assert.NoError(t, err)
+	_, err = StartSignWithOptions(false, paranoid, corrupted, signers, steak)(nil)
+	assert.Error(t, err)
+}
+
+// softwareNonces stands in for a secure element producing the nonces of a signer.
+type softwareNonces struct {
+	d, e curve.Scalar
+	// badProof makes Commit prove the knowledge of another nonce than eᵢ.
+	badProof bool
+	// badResponse makes Respond ignore negate.
+	badResponse bool
+}
+
+func (s *softwareNonces) Commit(h *hash.Hash) (D, E curve.Point, proofD, proofE *zksch.Proof, err error) {
+	group := curve.Secp256k1{}
+	s.d, s.e = sample.ScalarUnit(rand.Reader, group), sample.ScalarUnit(rand.Reader, group)
+	D, E = s.d.ActOnBase(), s.e.ActOnBase()
+	proofD = zksch.NewProof(h.Clone(), D, s.d, nil)
+	if s.badProof {
+		proofE = zksch.NewProof(h, E, sample.Scalar(rand.Reader, group), nil)
+	} else {
+		proofE = zksch.NewProof(h, E, s.e, nil)
+	}
+	return D, E, proofD, proofE, nil
+}
+
+func (s *softwareNonces) Respond(rho curve.Scalar, negate bool) (curve.Scalar, error) {
+	k := rho.Mul(s.e).Add(s.d)
+	if negate && !s.badResponse {
+		k.Negate()
+	}
+	s.d, s.e = nil, nil
+	return k, nil
+}
+
+func TestSignNonceSource(t *testing.T) {
+	group := curve.Secp256k1{}
+	N := 4
+	threshold := 2
+
+	partyIDs := test.PartyIDs(N)
+	signers := partyIDs[:threshold+1]
+
+	// an even public key, so that the same shares can be used for taproot
+	secret := sample.Scalar(rand.Reader, group)
+	if !secret.ActOnBase().(*curve.Secp256k1Point).HasEvenY() {
+		secret.Negate()
+	}
+	publicKey := secret.ActOnBase()
+	f := polynomial.NewPolynomial(group, threshold, secret)
+	verificationShares := make(map[party.ID]curve.Point, N)
+	privateShares := make(map[party.ID]curve.Scalar, N)
+	for _, id := range partyIDs {
+		privateShares[id] = f.Evaluate(id.Scalar(group))
+		verificationShares[id] = privateShares[id].ActOnBase()
+	}
+	steak := sha256.Sum256([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+
+	run := func(taproot bool, source *softwareNonces) ([]round.Session, error) {
+		rounds := make([]round.Session, 0, len(signers))
+		for _, id := range signers {
+			var options Options
+			if id == signers[0] {
+				options.Nonces = source
+			}
+			config := &keygen.Config{
+				ID:                 id,
+				Threshold:          threshold,
+				PublicKey:          publicKey,
+				PrivateShare:       privateShares[id],
+				VerificationShares: party.NewPointMap(verificationShares),
+			}
+			r, err := StartSignWithOptions(taproot, options, config, signers, steak[:])(nil)
+			require.NoError(t, err, "round creation should not result in an error")
+			rounds = append(rounds, r)
+		}
+		for {
+			err, done := test.Rounds(rounds, nil)
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				return rounds, nil
+			}
+		}
+	}
+
+	rounds, err := run(false, &softwareNonces{})
+	require.NoError(t, err)
+	checkOutput(t, rounds, publicKey, steak[:])
+
+	// the group commitment has an odd y coordinate half of the time, which needs the nonces to be negated
+	for i := 0; i < 8; i++ {
+		rounds, err = run(true, &softwareNonces{})
+		require.NoError(t, err)
+		checkOutputTaproot(t, rounds, publicKey.(*curve.Secp256k1Point).XBytes(), steak[:])
+	}
+
+	_, err = run(false, &softwareNonces{badProof: true})
+	assert.Error(t, err, "a proof for another nonce should be rejected")
+
+	failed := false
+	for i := 0; i < 16 && !failed; i++ {
+		_, err = run(true, &softwareNonces{badResponse: true})
+		failed = err != nil
+	}
+	assert.True(t, failed, "a response ignoring the negation should be rejected")
+}